@@ -0,0 +1,132 @@
+package pipelinetests
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/Tests/testutils"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/bxcodec/faker/v3"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+For individual tests - in separate window run: go run server.go
+go test -p 1 -v -count=1 -run TestPipelineConcurrency github.com/dataplane-app/dataplane/app/mainapp/Tests/pipelines
+* Login
+* Create pipeline
+* Update pipeline concurrency
+* Update pipeline concurrency with an invalid policy
+*/
+func TestPipelineConcurrency(t *testing.T) {
+
+	database.DBConnect()
+
+	graphQLUrl := testutils.GraphQLUrlPublic
+	graphQLUrlPrivate := testutils.GraphQLUrlPrivate
+
+	testUser := testutils.AdminUser
+	testPassword := testutils.AdminPassword
+
+	//--------- Login ------------
+	log.Println("📢 - Login")
+	loginUser := `{
+		loginUser(
+		  username: "` + testUser + `",
+		  password: "` + testPassword + `",
+		) {
+		  access_token
+		  refresh_token
+		}
+	  }`
+
+	loginUserResponse, httpLoginResponse := testutils.GraphQLRequestPublic(loginUser, "{}", graphQLUrl, t)
+	accessToken := jsoniter.Get(loginUserResponse, "data", "loginUser", "access_token").ToString()
+
+	log.Println(string(loginUserResponse))
+
+	if strings.Contains(string(loginUserResponse), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpLoginResponse.StatusCode, "Login user 200 status code")
+
+	devEnv := models.Environment{}
+	database.DBConn.Where("name = ?", "Development").First(&devEnv)
+	envID := devEnv.ID
+
+	pipelineName := "test_" + testutils.TextEscape(faker.UUIDHyphenated())
+
+	// -------- Create pipeline -------------
+	log.Println("📢 - Create pipeline")
+	mutation := `mutation {
+		addPipeline(
+			name: "` + pipelineName + `",
+			environmentID: "` + envID + `",
+			description: "Test",
+			workerGroup: "python_1"
+			)
+		}`
+
+	response, httpResponse := testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Create pipeline 200 status code")
+
+	pipelineID := jsoniter.Get(response, "data", "addPipeline").ToString()
+
+	// -------- Update pipeline concurrency -------------
+	log.Println("📢 - Update pipeline concurrency")
+	mutation = `mutation {
+		updatePipelineConcurrency(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `",
+			maxConcurrentRuns: 2,
+			concurrencyPolicy: "skip"
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Update pipeline concurrency 200 status code")
+
+	// -------- Update pipeline concurrency with an invalid policy -------------
+	log.Println("📢 - Update pipeline concurrency with an invalid policy")
+	mutation = `mutation {
+		updatePipelineConcurrency(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `",
+			maxConcurrentRuns: 2,
+			concurrencyPolicy: "drop"
+			)
+		}`
+
+	response, _ = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if !strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Invalid concurrency policy accepted")
+	}
+
+	p := models.Pipelines{}
+	database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, envID).First(&p)
+	assert.Equalf(t, 2, p.MaxConcurrentRuns, "Pipeline max concurrent runs saved")
+	assert.Equalf(t, "skip", p.ConcurrencyPolicy, "Pipeline concurrency policy saved")
+}
@@ -0,0 +1,231 @@
+package pipelinetests
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/Tests/testutils"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/bxcodec/faker/v3"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+For individual tests - in separate window run: go run server.go
+go test -p 1 -v -count=1 -run TestPipelineNotifications github.com/dataplane-app/dataplane/app/mainapp/Tests/pipelines
+* Login
+* Create pipeline
+* Add notification channel
+* Add notification rule
+* Add notification rule with an invalid event
+* Delete notification channel used by a rule
+* Delete notification rule
+* Delete notification channel
+*/
+func TestPipelineNotifications(t *testing.T) {
+
+	database.DBConnect()
+
+	graphQLUrl := testutils.GraphQLUrlPublic
+	graphQLUrlPrivate := testutils.GraphQLUrlPrivate
+
+	testUser := testutils.AdminUser
+	testPassword := testutils.AdminPassword
+
+	//--------- Login ------------
+	log.Println("📢 - Login")
+	loginUser := `{
+		loginUser(
+		  username: "` + testUser + `",
+		  password: "` + testPassword + `",
+		) {
+		  access_token
+		  refresh_token
+		}
+	  }`
+
+	loginUserResponse, httpLoginResponse := testutils.GraphQLRequestPublic(loginUser, "{}", graphQLUrl, t)
+	accessToken := jsoniter.Get(loginUserResponse, "data", "loginUser", "access_token").ToString()
+
+	log.Println(string(loginUserResponse))
+
+	if strings.Contains(string(loginUserResponse), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpLoginResponse.StatusCode, "Login user 200 status code")
+
+	devEnv := models.Environment{}
+	database.DBConn.Where("name = ?", "Development").First(&devEnv)
+	envID := devEnv.ID
+
+	pipelineName := "test_" + testutils.TextEscape(faker.UUIDHyphenated())
+
+	// -------- Create pipeline -------------
+	log.Println("📢 - Create pipeline")
+	mutation := `mutation {
+		addPipeline(
+			name: "` + pipelineName + `",
+			environmentID: "` + envID + `",
+			description: "Test",
+			workerGroup: "python_1"
+			)
+		}`
+
+	response, httpResponse := testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Create pipeline 200 status code")
+
+	pipelineID := jsoniter.Get(response, "data", "addPipeline").ToString()
+
+	// -------- Add notification channel -------------
+	log.Println("📢 - Add notification channel")
+	mutation = `mutation {
+		addUpdateNotificationChannel(
+			input: {
+				channelID: "",
+				environmentID: "` + envID + `",
+				name: "` + pipelineName + `",
+				channelType: "webhook",
+				recipients: "",
+				url: "https://example.com/hooks/dataplane",
+				active: true
+			}
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Add notification channel 200 status code")
+
+	channelID := jsoniter.Get(response, "data", "addUpdateNotificationChannel").ToString()
+
+	// -------- Add notification rule -------------
+	log.Println("📢 - Add notification rule")
+	mutation = `mutation {
+		addUpdateNotificationRule(
+			input: {
+				ruleID: "",
+				environmentID: "` + envID + `",
+				pipelineID: "` + pipelineID + `",
+				runType: "pipeline",
+				event: "fail",
+				slaSeconds: 0,
+				channelID: "` + channelID + `",
+				template: "",
+				active: true
+			}
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Add notification rule 200 status code")
+
+	ruleID := jsoniter.Get(response, "data", "addUpdateNotificationRule").ToString()
+
+	// -------- Add notification rule with an invalid event -------------
+	log.Println("📢 - Add notification rule with an invalid event")
+	mutation = `mutation {
+		addUpdateNotificationRule(
+			input: {
+				ruleID: "",
+				environmentID: "` + envID + `",
+				pipelineID: "` + pipelineID + `",
+				runType: "pipeline",
+				event: "started",
+				slaSeconds: 0,
+				channelID: "` + channelID + `",
+				template: "",
+				active: true
+			}
+			)
+		}`
+
+	response, _ = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if !strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Invalid notification event accepted")
+	}
+
+	// -------- Delete notification channel used by a rule -------------
+	log.Println("📢 - Delete notification channel used by a rule")
+	mutation = `mutation {
+		deleteNotificationChannel(
+			channelID: "` + channelID + `",
+			environmentID: "` + envID + `"
+			)
+		}`
+
+	response, _ = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if !strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Notification channel used by a rule deleted")
+	}
+
+	// -------- Delete notification rule -------------
+	log.Println("📢 - Delete notification rule")
+	mutation = `mutation {
+		deleteNotificationRule(
+			ruleID: "` + ruleID + `",
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `"
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Delete notification rule 200 status code")
+
+	// -------- Delete notification channel -------------
+	log.Println("📢 - Delete notification channel")
+	mutation = `mutation {
+		deleteNotificationChannel(
+			channelID: "` + channelID + `",
+			environmentID: "` + envID + `"
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Delete notification channel 200 status code")
+}
@@ -0,0 +1,160 @@
+package pipelinetests
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/Tests/testutils"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/bxcodec/faker/v3"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+For individual tests - in separate window run: go run server.go
+go test -p 1 -v -count=1 -run TestPipelineParameters github.com/dataplane-app/dataplane/app/mainapp/Tests/pipelines
+* Login
+* Create pipeline
+* Update pipeline parameters
+* Get pipeline parameters
+* Update pipeline parameters with an invalid default
+*/
+func TestPipelineParameters(t *testing.T) {
+
+	database.DBConnect()
+
+	graphQLUrl := testutils.GraphQLUrlPublic
+	graphQLUrlPrivate := testutils.GraphQLUrlPrivate
+
+	testUser := testutils.AdminUser
+	testPassword := testutils.AdminPassword
+
+	//--------- Login ------------
+	log.Println("📢 - Login")
+	loginUser := `{
+		loginUser(
+		  username: "` + testUser + `",
+		  password: "` + testPassword + `",
+		) {
+		  access_token
+		  refresh_token
+		}
+	  }`
+
+	loginUserResponse, httpLoginResponse := testutils.GraphQLRequestPublic(loginUser, "{}", graphQLUrl, t)
+	accessToken := jsoniter.Get(loginUserResponse, "data", "loginUser", "access_token").ToString()
+
+	log.Println(string(loginUserResponse))
+
+	if strings.Contains(string(loginUserResponse), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpLoginResponse.StatusCode, "Login user 200 status code")
+
+	devEnv := models.Environment{}
+	database.DBConn.Where("name = ?", "Development").First(&devEnv)
+	envID := devEnv.ID
+
+	pipelineName := "test_" + testutils.TextEscape(faker.UUIDHyphenated())
+
+	// -------- Create pipeline -------------
+	log.Println("📢 - Create pipeline")
+	mutation := `mutation {
+		addPipeline(
+			name: "` + pipelineName + `",
+			environmentID: "` + envID + `",
+			description: "Test",
+			workerGroup: "python_1"
+			)
+		}`
+
+	response, httpResponse := testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Create pipeline 200 status code")
+
+	pipelineID := jsoniter.Get(response, "data", "addPipeline").ToString()
+
+	// -------- Update pipeline parameters -------------
+	log.Println("📢 - Update pipeline parameters")
+	mutation = `mutation {
+		updatePipelineParameters(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `",
+			parameters: [
+				{name: "region", type: "string", default: "eu", description: "Region to load"},
+				{name: "limit", type: "integer", default: 100},
+				{name: "date", type: "string", required: true}
+			]
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Update pipeline parameters 200 status code")
+
+	// -------- Get pipeline parameters -------------
+	log.Println("📢 - Get pipeline parameters")
+	query := `query {
+		getPipelineParameters(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `",
+			){
+				name
+				type
+				default
+				required
+				description
+			}
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(query, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Get pipeline parameters 200 status code")
+	assert.Equalf(t, 3, jsoniter.Get(response, "data", "getPipelineParameters").Size(), "Get pipeline parameters count")
+	assert.Equalf(t, "eu", jsoniter.Get(response, "data", "getPipelineParameters", 0, "default").ToString(), "Get pipeline parameters default")
+	assert.Equalf(t, true, jsoniter.Get(response, "data", "getPipelineParameters", 2, "required").ToBool(), "Get pipeline parameters required")
+
+	// -------- Update pipeline parameters with an invalid default -------------
+	log.Println("📢 - Update pipeline parameters with an invalid default")
+	mutation = `mutation {
+		updatePipelineParameters(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `",
+			parameters: [{name: "limit", type: "integer", default: "ten"}]
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if !strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Invalid parameter default accepted")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Update pipeline parameters invalid 200 status code")
+}
@@ -0,0 +1,110 @@
+package pipelinetests
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/Tests/testutils"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/bxcodec/faker/v3"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+For individual tests - in separate window run: go run server.go
+go test -p 1 -v -count=1 -run TestPipelinePauseRuns github.com/dataplane-app/dataplane/app/mainapp/Tests/pipelines
+* Login
+* Create pipeline
+* Pause pipeline runs
+*/
+func TestPipelinePauseRuns(t *testing.T) {
+
+	database.DBConnect()
+
+	graphQLUrl := testutils.GraphQLUrlPublic
+	graphQLUrlPrivate := testutils.GraphQLUrlPrivate
+
+	testUser := testutils.AdminUser
+	testPassword := testutils.AdminPassword
+
+	//--------- Login ------------
+	log.Println("📢 - Login")
+	loginUser := `{
+		loginUser(
+		  username: "` + testUser + `",
+		  password: "` + testPassword + `",
+		) {
+		  access_token
+		  refresh_token
+		}
+	  }`
+
+	loginUserResponse, httpLoginResponse := testutils.GraphQLRequestPublic(loginUser, "{}", graphQLUrl, t)
+	accessToken := jsoniter.Get(loginUserResponse, "data", "loginUser", "access_token").ToString()
+
+	log.Println(string(loginUserResponse))
+
+	if strings.Contains(string(loginUserResponse), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpLoginResponse.StatusCode, "Login user 200 status code")
+
+	devEnv := models.Environment{}
+	database.DBConn.Where("name = ?", "Development").First(&devEnv)
+	envID := devEnv.ID
+
+	pipelineName := "test_" + testutils.TextEscape(faker.UUIDHyphenated())
+
+	// -------- Create pipeline -------------
+	log.Println("📢 - Create pipeline")
+	mutation := `mutation {
+		addPipeline(
+			name: "` + pipelineName + `",
+			environmentID: "` + envID + `",
+			description: "Test",
+			workerGroup: "python_1"
+			)
+		}`
+
+	response, httpResponse := testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Create pipeline 200 status code")
+
+	pipelineID := jsoniter.Get(response, "data", "addPipeline").ToString()
+
+	// -------- Pause pipeline runs -------------
+	log.Println("📢 - Pause pipeline runs")
+	mutation = `mutation {
+		pausePipelineRuns(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `",
+			paused: true
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Pause pipeline runs 200 status code")
+
+	p := models.Pipelines{}
+	database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, envID).First(&p)
+	assert.Equalf(t, true, p.PauseRuns, "Pipeline runs paused")
+}
@@ -0,0 +1,140 @@
+package pipelinetests
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/Tests/testutils"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/bxcodec/faker/v3"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+For individual tests - in separate window run: go run server.go
+go test -p 1 -v -count=1 -run TestPipelineYAML github.com/dataplane-app/dataplane/app/mainapp/Tests/pipelines
+* Login
+* Create pipeline
+* Export pipeline YAML
+* Import pipeline YAML dry run
+*/
+func TestPipelineYAML(t *testing.T) {
+
+	database.DBConnect()
+
+	graphQLUrl := testutils.GraphQLUrlPublic
+	graphQLUrlPrivate := testutils.GraphQLUrlPrivate
+
+	testUser := testutils.AdminUser
+	testPassword := testutils.AdminPassword
+
+	//--------- Login ------------
+	log.Println("📢 - Login")
+	loginUser := `{
+		loginUser(
+		  username: "` + testUser + `",
+		  password: "` + testPassword + `",
+		) {
+		  access_token
+		  refresh_token
+		}
+	  }`
+
+	loginUserResponse, httpLoginResponse := testutils.GraphQLRequestPublic(loginUser, "{}", graphQLUrl, t)
+	accessToken := jsoniter.Get(loginUserResponse, "data", "loginUser", "access_token").ToString()
+
+	log.Println(string(loginUserResponse))
+
+	if strings.Contains(string(loginUserResponse), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpLoginResponse.StatusCode, "Login user 200 status code")
+
+	devEnv := models.Environment{}
+	database.DBConn.Where("name = ?", "Development").First(&devEnv)
+	envID := devEnv.ID
+
+	pipelineName := "test_" + testutils.TextEscape(faker.UUIDHyphenated())
+
+	// -------- Create pipeline -------------
+	log.Println("📢 - Create pipeline")
+	mutation := `mutation {
+		addPipeline(
+			name: "` + pipelineName + `",
+			environmentID: "` + envID + `",
+			description: "Test",
+			workerGroup: "python_1"
+			)
+		}`
+
+	response, httpResponse := testutils.GraphQLRequestPrivate(mutation, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Create pipeline 200 status code")
+
+	pipelineID := jsoniter.Get(response, "data", "addPipeline").ToString()
+
+	// -------- Export pipeline YAML -------------
+	log.Println("📢 - Export pipeline YAML")
+	query := `query {
+		exportPipelineYAML(
+			pipelineID: "` + pipelineID + `",
+			environmentID: "` + envID + `"
+			)
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(query, accessToken, "{}", graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Export pipeline YAML 200 status code")
+
+	exported := jsoniter.Get(response, "data", "exportPipelineYAML").ToString()
+	assert.Containsf(t, exported, pipelineName, "Export pipeline YAML name")
+
+	// -------- Import pipeline YAML dry run -------------
+	log.Println("📢 - Import pipeline YAML dry run")
+	variables, _ := jsoniter.Marshal(map[string]string{"yaml": exported})
+	mutation = `mutation ImportPipeline($yaml: String!) {
+		importPipelineYAML(
+			environmentID: "` + envID + `",
+			yaml: $yaml,
+			pipelineID: "` + pipelineID + `",
+			dryRun: true
+			){
+				pipelineID
+				dryRun
+				errors {
+					path
+					message
+				}
+			}
+		}`
+
+	response, httpResponse = testutils.GraphQLRequestPrivate(mutation, accessToken, string(variables), graphQLUrlPrivate, t)
+
+	log.Println(string(response))
+
+	if strings.Contains(string(response), `"errors":[{`) {
+		t.Errorf("Error in graphql response")
+	}
+
+	assert.Equalf(t, http.StatusOK, httpResponse.StatusCode, "Import pipeline YAML 200 status code")
+	assert.Equalf(t, true, jsoniter.Get(response, "data", "importPipelineYAML", "dryRun").ToBool(), "Import pipeline YAML dry run")
+	assert.Equalf(t, 0, jsoniter.Get(response, "data", "importPipelineYAML", "errors").Size(), "Import pipeline YAML no errors")
+}
@@ -0,0 +1,355 @@
+package configascode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"unicode/utf8"
+
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
+	"gopkg.in/yaml.v3"
+)
+
+type exportFileRow struct {
+	FileID    string
+	FolderID  string
+	NodeID    string
+	FileName  string
+	FileStore []byte
+	External  bool
+	ObjectKey string
+}
+
+type nodeMeta struct {
+	Position *PipelineYAMLPosition `json:"position"`
+	Data     struct {
+		Language    string                 `json:"language"`
+		Genericdata map[string]interface{} `json:"genericdata"`
+	} `json:"data"`
+}
+
+// Schedule settings in the node meta that have their own place in the YAML
+var scheduleSettings = []string{"schedule", "scheduleType", "timezone", "catchUp", "parameters"}
+
+/*
+PipelineYAMLExport returns the YAML of a pipeline as it is saved, see PipelineYAMLBuild.
+*/
+func PipelineYAMLExport(pipelineID string, environmentID string) ([]byte, error) {
+
+	out, err := PipelineYAMLBuild(pipelineID, environmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	err = encoder.Encode(out)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Pipeline YAML encode error.")
+	}
+	encoder.Close()
+
+	return buf.Bytes(), nil
+}
+
+/*
+PipelineYAMLBuild builds a pipeline as it is saved: settings, graph, triggers, the secrets of its worker groups and the code of its nodes.
+Nodes and edges are in a fixed order so that exports of the same pipeline diff cleanly.
+*/
+func PipelineYAMLBuild(pipelineID string, environmentID string) (PipelineYAML, error) {
+
+	pipeline := models.Pipelines{}
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).First(&pipeline).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PipelineYAML{}, errors.New("Pipeline not found.")
+	}
+
+	nodes := []models.PipelineNodes{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&nodes).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PipelineYAML{}, errors.New("Retrieve pipeline nodes database error.")
+	}
+
+	edges := []models.PipelineEdges{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&edges).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PipelineYAML{}, errors.New("Retrieve pipeline edges database error.")
+	}
+
+	apiTrigger := models.PipelineApiTriggers{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Limit(1).Find(&apiTrigger).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PipelineYAML{}, errors.New("Retrieve pipeline API trigger database error.")
+	}
+
+	folders := []models.CodeFolders{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&folders).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PipelineYAML{}, errors.New("Retrieve pipeline folders database error.")
+	}
+
+	files := []exportFileRow{}
+	err = database.DBConn.Raw(`
+	select
+	f.file_id,
+	f.folder_id,
+	f.node_id,
+	f.file_name,
+	s.file_store,
+	coalesce(s.external, false) as external,
+	coalesce(s.object_key, '') as object_key
+	from code_files f
+	left join code_files_store s on s.file_id = f.file_id and s.environment_id = f.environment_id
+	where f.pipeline_id = ? and f.environment_id = ?
+	`, pipelineID, environmentID).Scan(&files).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PipelineYAML{}, errors.New("Retrieve pipeline files database error.")
+	}
+
+	out := PipelineYAML{
+		Kind: PipelineYAMLKind,
+		Metadata: PipelineYAMLMetadata{
+			Name:        pipeline.Name,
+			Description: pipeline.Description,
+		},
+		Spec: PipelineYAMLSpec{
+			WorkerGroup:       pipeline.WorkerGroup,
+			TimeoutSeconds:    pipeline.TimeoutSeconds,
+			MaxConcurrentRuns: pipeline.MaxConcurrentRuns,
+			ConcurrencyPolicy: pipeline.ConcurrencyPolicy,
+		},
+	}
+
+	// ----- Parameters
+	for _, p := range pipeline.Parameters {
+
+		param := PipelineYAMLParameter{
+			Name:        p.Name,
+			Type:        p.Type,
+			Required:    p.Required,
+			Description: p.Description,
+		}
+
+		if len(p.Default) > 0 {
+			json.Unmarshal(p.Default, &param.Default)
+		}
+
+		out.Spec.Parameters = append(out.Spec.Parameters, param)
+	}
+
+	// ----- Code by node, paths relative to the node folder
+	folderByID := map[string]models.CodeFolders{}
+	for _, f := range folders {
+		folderByID[f.FolderID] = f
+	}
+
+	code := map[string][]PipelineYAMLFile{}
+	for _, f := range files {
+
+		path := f.FileName
+		folderID := f.FolderID
+
+		// The depth limit guards against a broken parent chain
+		for i := 0; i < 100; i++ {
+			folder, ok := folderByID[folderID]
+			if !ok || folder.Level == "node" || folder.Level == "pipeline" {
+				break
+			}
+			path = folder.FolderName + "/" + path
+			folderID = folder.ParentID
+		}
+
+		content, err := objectstorage.Content(f.FileStore, f.External, f.ObjectKey)
+		if err != nil {
+			return PipelineYAML{}, err
+		}
+
+		file := PipelineYAMLFile{Path: path}
+		if utf8.Valid(content) {
+			file.Content = string(content)
+		} else {
+			file.ContentBase64 = base64.StdEncoding.EncodeToString(content)
+		}
+
+		code[f.NodeID] = append(code[f.NodeID], file)
+	}
+
+	// ----- Nodes, trigger first
+	sort.Slice(nodes, func(i, j int) bool {
+		if (nodes[i].NodeType == "trigger") != (nodes[j].NodeType == "trigger") {
+			return nodes[i].NodeType == "trigger"
+		}
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+
+	workerGroups := map[string]bool{pipeline.WorkerGroup: true}
+
+	for _, n := range nodes {
+
+		node := PipelineYAMLNode{
+			ID:             n.NodeID,
+			Name:           n.Name,
+			Type:           n.NodeType,
+			TypeDesc:       n.NodeTypeDesc,
+			Description:    n.Description,
+			TimeoutSeconds: n.TimeoutSeconds,
+		}
+
+		if n.WorkerGroup != "" && n.WorkerGroup != pipeline.WorkerGroup {
+			node.WorkerGroup = n.WorkerGroup
+			workerGroups[n.WorkerGroup] = true
+		}
+
+		if n.NodeType == "trigger" && n.NodeTypeDesc != "play" {
+			node.Online = n.TriggerOnline
+		}
+
+		commands := []pipelines.Command{}
+		json.Unmarshal(n.Commands, &commands)
+		for _, c := range commands {
+			node.Commands = append(node.Commands, c.Command)
+		}
+
+		if n.RetryPolicy.MaxAttempts > 1 {
+			node.Retry = &PipelineYAMLRetry{
+				MaxAttempts:  n.RetryPolicy.MaxAttempts,
+				Backoff:      n.RetryPolicy.Backoff,
+				DelaySeconds: n.RetryPolicy.DelaySeconds,
+				ExitCodes:    n.RetryPolicy.ExitCodes,
+			}
+			if node.Retry.Backoff == "" {
+				node.Retry.Backoff = "fixed"
+			}
+		}
+
+		meta := nodeMeta{}
+		json.Unmarshal(n.Meta, &meta)
+		node.Position = meta.Position
+		node.Language = meta.Data.Language
+		settings := meta.Data.Genericdata
+
+		switch n.NodeTypeDesc {
+		case "schedule":
+			node.Schedule = &PipelineYAMLSchedule{
+				Schedule:     settingString(settings, "schedule"),
+				ScheduleType: settingString(settings, "scheduleType"),
+				Timezone:     settingString(settings, "timezone"),
+			}
+			if catchUp, ok := settings["catchUp"].(bool); ok {
+				node.Schedule.CatchUp = catchUp
+			}
+			if parameters, ok := settings["parameters"].(map[string]interface{}); ok {
+				node.Schedule.Parameters = parameters
+			}
+			for _, key := range scheduleSettings {
+				delete(settings, key)
+			}
+		case "api":
+			if apiTrigger.TriggerID != "" {
+				node.API = &PipelineYAMLAPI{
+					PublicLive:   apiTrigger.PublicLive,
+					PrivateLive:  apiTrigger.PrivateLive,
+					APIKeyActive: apiTrigger.APIKeyActive,
+				}
+			}
+		}
+
+		if len(settings) > 0 {
+			node.Settings = settings
+		}
+
+		node.Code = code[n.NodeID]
+		sort.Slice(node.Code, func(i, j int) bool {
+			return node.Code[i].Path < node.Code[j].Path
+		})
+
+		out.Spec.Nodes = append(out.Spec.Nodes, node)
+	}
+
+	// ----- Edges
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+
+	for _, e := range edges {
+
+		edge := PipelineYAMLEdge{
+			From:       e.From,
+			To:         e.To,
+			Expression: e.Expression,
+		}
+
+		if e.Condition != "success" {
+			edge.Condition = e.Condition
+		}
+
+		handles := map[string]interface{}{}
+		json.Unmarshal(e.Meta, &handles)
+		edge.SourceHandle = settingString(handles, "sourceHandle")
+		edge.TargetHandle = settingString(handles, "targetHandle")
+
+		out.Spec.Edges = append(out.Spec.Edges, edge)
+	}
+
+	// ----- Secrets of the worker groups, names only
+	groups := []string{}
+	for g := range workerGroups {
+		if g != "" {
+			groups = append(groups, g)
+		}
+	}
+
+	if len(groups) > 0 {
+
+		secrets := []models.WorkerSecrets{}
+		err = database.DBConn.Where("environment_id = ? and worker_group_id in ?", environmentID, groups).Order("worker_group_id, secret_id").Find(&secrets).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return PipelineYAML{}, errors.New("Retrieve worker secrets database error.")
+		}
+
+		for _, s := range secrets {
+			out.Spec.Secrets = append(out.Spec.Secrets, PipelineYAMLSecret{WorkerGroup: s.WorkerGroupID, Secret: s.SecretID})
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,40 @@
+package configascode
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+)
+
+/*
+PipelineYAMLCodeWrite writes the code of a node from YAML into its node folder, creating sub folders as needed.
+Files are created or overwritten, files in the folder that are not in the YAML are kept.
+The node folder must exist, see filesystem.FolderNodeAddUpdate. Each file written is recorded as a revision by the user.
+*/
+func PipelineYAMLCodeWrite(pipelineID string, environmentID string, nodeID string, userID string, files []PipelineYAMLFile) error {
+
+	for _, f := range files {
+
+		content := []byte(f.Content)
+		if f.ContentBase64 != "" {
+			var err error
+			content, err = base64.StdEncoding.DecodeString(f.ContentBase64)
+			if err != nil {
+				return errors.New("Content is not valid base64 for " + f.Path)
+			}
+		}
+
+		file, err := filesystem.NodeFileWrite(pipelineID, environmentID, nodeID, f.Path, content)
+		if err != nil {
+			return err
+		}
+
+		err = filesystem.FileRevisionCreate(file, content, userID, "import")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
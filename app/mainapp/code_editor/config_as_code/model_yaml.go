@@ -1,13 +1,123 @@
 package configascode
 
-import "time"
-
-type PipeineYAML struct {
-	Kind          string     `yaml:"kind"`
-	Version       string     `yaml:"version"`
-	EnvironmentID string     `yaml:"environment_id"`
-	PipelineID    string     `yaml:"pipeline_id"`
-	PipelineName  string     `yaml:"pipeline_name"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     *time.Time `json:"updated_at"`
+/*
+PipelineYAMLKind is the kind and schema version of a pipeline YAML file.
+A new schema version gets a new kind, files of older kinds stay importable.
+*/
+const PipelineYAMLKind = "Dataplane/pipeline/v1"
+
+/*
+PipelineYAML is a pipeline as code: settings, graph, triggers, secrets it needs and the code of its nodes.
+Nothing environment specific is kept so that the same file imports into any environment.
+*/
+type PipelineYAML struct {
+	Kind     string               `yaml:"kind"`
+	Metadata PipelineYAMLMetadata `yaml:"metadata"`
+	Spec     PipelineYAMLSpec     `yaml:"spec"`
+}
+
+type PipelineYAMLMetadata struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description,omitempty"`
+}
+
+type PipelineYAMLSpec struct {
+	WorkerGroup       string                  `yaml:"worker_group"`
+	TimeoutSeconds    int                     `yaml:"timeout_seconds,omitempty"`     // default for all nodes, 0 = no timeout
+	MaxConcurrentRuns int                     `yaml:"max_concurrent_runs,omitempty"` // 0 = no limit
+	ConcurrencyPolicy string                  `yaml:"concurrency_policy,omitempty"`  // queue (default), skip, cancel-previous
+	Parameters        []PipelineYAMLParameter `yaml:"parameters,omitempty"`
+	Secrets           []PipelineYAMLSecret    `yaml:"secrets,omitempty"`
+	Nodes             []PipelineYAMLNode      `yaml:"nodes"`
+	Edges             []PipelineYAMLEdge      `yaml:"edges,omitempty"`
+}
+
+type PipelineYAMLParameter struct {
+	Name        string      `yaml:"name"`
+	Type        string      `yaml:"type"` // string, number, integer, boolean, json
+	Default     interface{} `yaml:"default,omitempty"`
+	Required    bool        `yaml:"required,omitempty"`
+	Description string      `yaml:"description,omitempty"`
+}
+
+/*
+PipelineYAMLSecret is a secret the pipeline's worker group needs. Only the name is kept, never the value,
+the secret must exist in the environment imported to.
+*/
+type PipelineYAMLSecret struct {
+	WorkerGroup string `yaml:"worker_group"`
+	Secret      string `yaml:"secret"`
+}
+
+type PipelineYAMLNode struct {
+	ID             string                 `yaml:"id"` // edges refer to nodes by this ID
+	Name           string                 `yaml:"name"`
+	Type           string                 `yaml:"type"`      // trigger, process, checkpoint
+	TypeDesc       string                 `yaml:"type_desc"` // play, schedule, api, file, nats, pipeline, python, bash, rpa-python, subpipeline, approval, checkpoint
+	Description    string                 `yaml:"description,omitempty"`
+	WorkerGroup    string                 `yaml:"worker_group,omitempty"` // overrides the pipeline worker group
+	Online         bool                   `yaml:"online,omitempty"`       // triggers only, play is always online
+	Commands       []string               `yaml:"commands,omitempty"`
+	Retry          *PipelineYAMLRetry     `yaml:"retry,omitempty"`
+	TimeoutSeconds int                    `yaml:"timeout_seconds,omitempty"` // 0 = use the pipeline timeout
+	Schedule       *PipelineYAMLSchedule  `yaml:"schedule,omitempty"`        // schedule triggers only
+	API            *PipelineYAMLAPI       `yaml:"api,omitempty"`             // api triggers only
+	Settings       map[string]interface{} `yaml:"settings,omitempty"`        // other node settings as set in the editor: event triggers, sub-pipelines, approvals
+	Language       string                 `yaml:"language,omitempty"`
+	Position       *PipelineYAMLPosition  `yaml:"position,omitempty"`
+	Code           []PipelineYAMLFile     `yaml:"code,omitempty"`
+}
+
+type PipelineYAMLRetry struct {
+	MaxAttempts  int    `yaml:"max_attempts"`
+	Backoff      string `yaml:"backoff"` // fixed, exponential
+	DelaySeconds int    `yaml:"delay_seconds,omitempty"`
+	ExitCodes    []int  `yaml:"exit_codes,omitempty"`
+}
+
+type PipelineYAMLSchedule struct {
+	Schedule     string                 `yaml:"schedule"`
+	ScheduleType string                 `yaml:"schedule_type"` // cron, cronseconds
+	Timezone     string                 `yaml:"timezone,omitempty"`
+	CatchUp      bool                   `yaml:"catch_up,omitempty"`
+	Parameters   map[string]interface{} `yaml:"parameters,omitempty"` // run parameter values for scheduled runs
+}
+
+type PipelineYAMLAPI struct {
+	PublicLive   bool `yaml:"public_live"`
+	PrivateLive  bool `yaml:"private_live"`
+	APIKeyActive bool `yaml:"api_key_active"`
+}
+
+type PipelineYAMLPosition struct {
+	X float64 `yaml:"x"`
+	Y float64 `yaml:"y"`
+}
+
+/*
+PipelineYAMLFile is a file of a node's code, the path is relative to the node folder.
+Files that are not UTF-8 text are kept base64 encoded.
+*/
+type PipelineYAMLFile struct {
+	Path          string `yaml:"path"`
+	Content       string `yaml:"content,omitempty"`
+	ContentBase64 string `yaml:"content_base64,omitempty"`
+}
+
+type PipelineYAMLEdge struct {
+	From         string `yaml:"from"`
+	To           string `yaml:"to"`
+	Condition    string `yaml:"condition,omitempty"` // success (default), failure, always, expression
+	Expression   string `yaml:"expression,omitempty"`
+	SourceHandle string `yaml:"source_handle,omitempty"`
+	TargetHandle string `yaml:"target_handle,omitempty"`
+}
+
+/*
+PipelineYAMLError is a validation error at a path in the YAML file, e.g. spec.nodes[2].commands, with its line when found.
+*/
+type PipelineYAMLError struct {
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
 }
@@ -0,0 +1,531 @@
+package configascode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
+	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	"gopkg.in/yaml.v3"
+)
+
+var yamlErrorLineRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+var yamlPathIndexRegex = regexp.MustCompile(`^(.*)\[(\d+)\]$`)
+
+// Code folders are named as they are on disk, see filesystem.FolderFriendly
+var codeFolderNameRegex = regexp.MustCompile(`^\w+$`)
+
+/*
+PipelineYAMLParse reads and validates a pipeline YAML file. All errors found are returned, each at the path and line it was found.
+The pipeline is only valid to import when there are no errors.
+*/
+func PipelineYAMLParse(data []byte) (PipelineYAML, []PipelineYAMLError) {
+
+	var out PipelineYAML
+	var root yaml.Node
+
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return out, yamlDecodeErrors(err, &root)
+	}
+
+	if len(root.Content) == 0 {
+		return out, []PipelineYAMLError{{Line: 1, Message: "File is empty"}}
+	}
+
+	// Unknown fields are errors so that typos don't silently drop settings
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	err = decoder.Decode(&out)
+	if err != nil && err != io.EOF {
+		return out, yamlDecodeErrors(err, &root)
+	}
+
+	errs := PipelineYAMLValidate(out)
+
+	for i := range errs {
+		errs[i].Line = yamlLine(&root, errs[i].Path)
+	}
+
+	return out, errs
+}
+
+/*
+PipelineYAMLLine finds the line of a path in a YAML file, for errors found after it was parsed.
+*/
+func PipelineYAMLLine(data []byte, path string) int {
+
+	var root yaml.Node
+	yaml.Unmarshal(data, &root)
+
+	return yamlLine(&root, path)
+}
+
+/*
+PipelineYAMLValidate checks a pipeline read from YAML before it is imported. Lines are not known here, see PipelineYAMLParse.
+*/
+func PipelineYAMLValidate(p PipelineYAML) []PipelineYAMLError {
+
+	errs := []PipelineYAMLError{}
+	add := func(path string, message string) {
+		errs = append(errs, PipelineYAMLError{Path: path, Message: message})
+	}
+
+	switch p.Kind {
+	case PipelineYAMLKind:
+	case "":
+		add("kind", "Kind is required, expected "+PipelineYAMLKind)
+	default:
+		add("kind", "Unknown kind "+p.Kind+", expected "+PipelineYAMLKind)
+	}
+
+	if strings.TrimSpace(p.Metadata.Name) == "" {
+		add("metadata.name", "Pipeline name is required")
+	}
+
+	spec := p.Spec
+
+	if spec.TimeoutSeconds < 0 {
+		add("spec.timeout_seconds", "Timeout seconds can't be negative")
+	}
+
+	policy := spec.ConcurrencyPolicy
+	if policy == "" {
+		policy = "queue"
+	}
+	if err := pipelines.ValidateConcurrency(spec.MaxConcurrentRuns, policy); err != nil {
+		if spec.MaxConcurrentRuns < 0 {
+			add("spec.max_concurrent_runs", err.Error())
+		} else {
+			add("spec.concurrency_policy", err.Error())
+		}
+	}
+
+	// ----- Parameters, checked one by one to point at the one in error
+	defs, defErrs := PipelineYAMLParameterDefs(spec.Parameters)
+	for i, err := range defErrs {
+		if err != nil {
+			add("spec.parameters["+strconv.Itoa(i)+"]", err.Error())
+		}
+	}
+	names := map[string]bool{}
+	for i := range defs {
+		if defErrs[i] != nil {
+			continue
+		}
+		if err := pipelines.ValidateRunParameterDefs(defs[i : i+1]); err != nil {
+			add("spec.parameters["+strconv.Itoa(i)+"]", err.Error())
+			continue
+		}
+		// Names are case insensitive as environment variables are upper case
+		if names[strings.ToUpper(defs[i].Name)] {
+			add("spec.parameters["+strconv.Itoa(i)+"].name", "Run parameter declared twice: "+defs[i].Name)
+		}
+		names[strings.ToUpper(defs[i].Name)] = true
+	}
+
+	for i, s := range spec.Secrets {
+		path := "spec.secrets[" + strconv.Itoa(i) + "]"
+		if s.WorkerGroup == "" {
+			add(path+".worker_group", "Secret worker group is required")
+		}
+		if s.Secret == "" {
+			add(path+".secret", "Secret name is required")
+		}
+	}
+
+	// ----- Nodes
+	if len(spec.Nodes) == 0 {
+		add("spec.nodes", "A pipeline needs at least one node")
+	}
+
+	nodeTypes := map[string]string{}
+	triggers := 0
+
+	for i, n := range spec.Nodes {
+
+		path := "spec.nodes[" + strconv.Itoa(i) + "]"
+
+		if n.ID == "" {
+			add(path+".id", "Node ID is required")
+		} else if _, ok := nodeTypes[n.ID]; ok {
+			add(path+".id", "Node ID "+n.ID+" is used twice")
+		} else {
+			nodeTypes[n.ID] = n.Type
+		}
+
+		if strings.TrimSpace(n.Name) == "" {
+			add(path+".name", "Node name is required")
+		}
+
+		switch n.Type {
+		case "trigger":
+			triggers++
+			if triggers > 1 {
+				add(path+".type", "There can only be one trigger")
+			}
+			switch n.TypeDesc {
+			case "play", "schedule", "api", "file", "nats", "pipeline":
+			default:
+				add(path+".type_desc", "Trigger type must be play, schedule, api, file, nats or pipeline")
+			}
+		case "process":
+			if n.TypeDesc == "" {
+				add(path+".type_desc", "Node type description is required, e.g. python or bash")
+			}
+			if n.WorkerGroup == "" && spec.WorkerGroup == "" {
+				add(path+".worker_group", "Worker group is required, set it on the node or in spec.worker_group")
+			}
+		case "checkpoint":
+			if n.TypeDesc != "" && n.TypeDesc != "checkpoint" {
+				add(path+".type_desc", "Checkpoint type must be checkpoint")
+			}
+		default:
+			add(path+".type", "Node type must be trigger, process or checkpoint")
+		}
+
+		if n.Retry != nil {
+			if n.Retry.MaxAttempts < 1 {
+				add(path+".retry.max_attempts", "Retry max attempts must be at least 1")
+			}
+			if n.Retry.Backoff != "fixed" && n.Retry.Backoff != "exponential" {
+				add(path+".retry.backoff", "Retry backoff must be fixed or exponential")
+			}
+			if n.Retry.DelaySeconds < 0 {
+				add(path+".retry.delay_seconds", "Retry delay can't be negative")
+			}
+		}
+
+		if n.TimeoutSeconds < 0 {
+			add(path+".timeout_seconds", "Timeout seconds can't be negative")
+		}
+
+		// ----- Schedule
+		if n.TypeDesc == "schedule" {
+			if n.Schedule == nil {
+				add(path+".schedule", "Schedule trigger requires a schedule")
+			} else {
+				if n.Schedule.Schedule == "" {
+					add(path+".schedule.schedule", "Schedule missing")
+				}
+				if n.Schedule.ScheduleType == "" {
+					add(path+".schedule.schedule_type", "Schedule type missing")
+				}
+				if n.Schedule.ScheduleType != "cronseconds" {
+					if _, err := time.LoadLocation(n.Schedule.Timezone); err != nil {
+						add(path+".schedule.timezone", "Schedule trigger timezone invalid")
+					}
+				}
+			}
+		} else if n.Schedule != nil {
+			add(path+".schedule", "Schedule is only for schedule triggers")
+		}
+
+		if n.API != nil && n.TypeDesc != "api" {
+			add(path+".api", "API settings are only for api triggers")
+		}
+
+		// ----- Settings kept in the node meta
+		switch n.TypeDesc {
+		case "file", "nats", "pipeline":
+			if n.Type != "trigger" {
+				break
+			}
+			workerGroup := settingString(n.Settings, "workerGroup")
+			if workerGroup == "" {
+				workerGroup = n.WorkerGroup
+			}
+			if workerGroup == "" {
+				workerGroup = spec.WorkerGroup
+			}
+			sourceStatus := settingString(n.Settings, "sourceStatus")
+			if sourceStatus == "" {
+				sourceStatus = "Success"
+			}
+			err := pipelines.ValidateEventTrigger(models.EventTriggers{
+				TriggerType:      n.TypeDesc,
+				WorkerGroup:      workerGroup,
+				Path:             settingString(n.Settings, "path"),
+				Pattern:          settingString(n.Settings, "pattern"),
+				Subject:          settingString(n.Settings, "subject"),
+				SourcePipelineID: settingString(n.Settings, "sourcePipelineID"),
+				SourceStatus:     sourceStatus,
+			})
+			if err != nil {
+				add(path+".settings", err.Error())
+			}
+		case "approval":
+			if _, err := pipelines.ApprovalTimeoutAction(settingString(n.Settings, "timeoutAction")); err != nil {
+				add(path+".settings.timeoutAction", err.Error())
+			}
+		case "subpipeline":
+			if settingString(n.Settings, "pipelineID") == "" {
+				add(path+".settings.pipelineID", "Sub-pipeline requires a pipeline")
+			}
+		}
+
+		// ----- Code
+		files := map[string]bool{}
+		for j, f := range n.Code {
+
+			filePath := path + ".code[" + strconv.Itoa(j) + "]"
+
+			if err := codePathCheck(f.Path); err != nil {
+				add(filePath+".path", err.Error())
+				continue
+			}
+
+			if files[f.Path] {
+				add(filePath+".path", "File "+f.Path+" is listed twice")
+			}
+			files[f.Path] = true
+
+			if f.Content != "" && f.ContentBase64 != "" {
+				add(filePath, "Set content or content_base64, not both")
+			}
+
+			if f.ContentBase64 != "" {
+				if _, err := base64.StdEncoding.DecodeString(f.ContentBase64); err != nil {
+					add(filePath+".content_base64", "Content is not valid base64")
+				}
+			}
+		}
+	}
+
+	// ----- Edges
+	edges := map[string][]string{}
+	for i, e := range spec.Edges {
+
+		path := "spec.edges[" + strconv.Itoa(i) + "]"
+
+		fromType, fromOK := nodeTypes[e.From]
+		if !fromOK {
+			add(path+".from", "Node "+e.From+" not found")
+		}
+		if _, ok := nodeTypes[e.To]; !ok {
+			add(path+".to", "Node "+e.To+" not found")
+		}
+
+		condition := models.EdgeCondition{Condition: e.Condition, Expression: e.Expression}
+		if condition.Condition == "" {
+			condition.Condition = "success"
+		}
+
+		if err := pipelines.ValidateEdgeCondition(condition); err != nil {
+			add(path+".condition", err.Error())
+		} else if fromType == "trigger" && condition.Condition != "success" && condition.Condition != "always" {
+			add(path+".condition", "Edges from a trigger can only be success or always")
+		}
+
+		if fromOK {
+			edges[e.From] = append(edges[e.From], e.To)
+		}
+	}
+
+	if cycle := utilities.GraphCycle(edges); cycle != "" {
+		add("spec.edges", "Cycle detected, only acyclical pipelines allowed: "+cycle)
+	}
+
+	return errs
+}
+
+/*
+PipelineYAMLParameterDefs converts run parameters read from YAML to the parameters saved on a pipeline.
+The error of each parameter is at the same index, nil if it converted.
+*/
+func PipelineYAMLParameterDefs(params []PipelineYAMLParameter) ([]models.RunParameter, []error) {
+
+	defs := make([]models.RunParameter, len(params))
+	errs := make([]error, len(params))
+
+	for i, p := range params {
+
+		defs[i] = models.RunParameter{
+			Name:        p.Name,
+			Type:        p.Type,
+			Required:    p.Required,
+			Description: p.Description,
+		}
+
+		if p.Default != nil {
+			defaultJSON, err := json.Marshal(p.Default)
+			if err != nil {
+				errs[i] = errors.New("Default of " + p.Name + " is not valid")
+				continue
+			}
+			defs[i].Default = defaultJSON
+		}
+	}
+
+	return defs, errs
+}
+
+// Paths are relative to the node folder, folders are named as they are on disk
+func codePathCheck(path string) error {
+
+	if path == "" {
+		return errors.New("File path is required")
+	}
+
+	if strings.HasPrefix(path, "/") || strings.Contains(path, "\\") {
+		return errors.New("File path must be relative to the node folder with / between folders: " + path)
+	}
+
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+
+		if part == "" || part == "." || part == ".." {
+			return errors.New("File path can't have empty, . or .. parts: " + path)
+		}
+
+		if i < len(parts)-1 && !codeFolderNameRegex.MatchString(part) {
+			return errors.New("Folder names can only be letters, numbers or _: " + path)
+		}
+	}
+
+	return nil
+}
+
+func settingString(settings map[string]interface{}, key string) string {
+
+	v, ok := settings[key]
+	if !ok || v == nil {
+		return ""
+	}
+
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Decode errors of yaml.v3 carry the line in the message, the path is looked up from it
+func yamlDecodeErrors(err error, root *yaml.Node) []PipelineYAMLError {
+
+	messages := []string{err.Error()}
+
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		messages = typeErr.Errors
+	}
+
+	errs := []PipelineYAMLError{}
+	for _, m := range messages {
+
+		out := PipelineYAMLError{Message: m}
+
+		if match := yamlErrorLineRegex.FindStringSubmatch(m); match != nil {
+			out.Line, _ = strconv.Atoi(match[1])
+			out.Message = match[2]
+			out.Path = yamlPathAtLine(root, out.Line)
+		}
+
+		errs = append(errs, out)
+	}
+
+	return errs
+}
+
+// Line of a path such as spec.nodes[2].commands, or of the closest parent found
+func yamlLine(root *yaml.Node, path string) int {
+
+	if root == nil || len(root.Content) == 0 {
+		return 0
+	}
+
+	node := root.Content[0]
+	line := node.Line
+
+	if path == "" {
+		return line
+	}
+
+	for _, part := range strings.Split(path, ".") {
+
+		key := part
+		index := -1
+		if match := yamlPathIndexRegex.FindStringSubmatch(part); match != nil {
+			key = match[1]
+			index, _ = strconv.Atoi(match[2])
+		}
+
+		if key != "" {
+			if node.Kind != yaml.MappingNode {
+				return line
+			}
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					line = node.Content[i].Line
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+			if !found {
+				return line
+			}
+		}
+
+		if index >= 0 {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return line
+			}
+			node = node.Content[index]
+			line = node.Line
+		}
+	}
+
+	return line
+}
+
+// Deepest path that starts on a line, the reverse of yamlLine
+func yamlPathAtLine(root *yaml.Node, line int) string {
+
+	if root == nil || len(root.Content) == 0 {
+		return ""
+	}
+
+	best := ""
+
+	var walk func(node *yaml.Node, path string)
+	walk = func(node *yaml.Node, path string) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				p := node.Content[i].Value
+				if path != "" {
+					p = path + "." + p
+				}
+				if node.Content[i].Line == line {
+					best = p
+				}
+				walk(node.Content[i+1], p)
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				if item.Line == line && item.Kind == yaml.ScalarNode {
+					best = p
+				}
+				walk(item, p)
+			}
+		}
+	}
+
+	walk(root.Content[0], "")
+
+	return best
+}
@@ -0,0 +1,181 @@
+package configascode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+const testPipelineYAML = `kind: Dataplane/pipeline/v1
+metadata:
+  name: Daily load
+spec:
+  worker_group: python_1
+  parameters:
+    - name: region
+      type: string
+      default: eu
+  nodes:
+    - id: trigger
+      name: Schedule
+      type: trigger
+      type_desc: schedule
+      online: true
+      schedule:
+        schedule: "0 1 * * *"
+        schedule_type: cron
+        timezone: Europe/London
+    - id: load
+      name: Load
+      type: process
+      type_desc: python
+      commands:
+        - python3 -u ${{nodedirectory}}dp-entrypoint.py
+      retry:
+        max_attempts: 3
+        backoff: exponential
+      code:
+        - path: dp-entrypoint.py
+          content: print("load")
+        - path: lib/helpers.py
+          content: ""
+  edges:
+    - from: trigger
+      to: load
+`
+
+/*
+go test -timeout 30s -v -run ^TestPipelineYAMLParse$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/config_as_code
+*/
+func TestPipelineYAMLParse(t *testing.T) {
+
+	p, errs := PipelineYAMLParse([]byte(testPipelineYAML))
+	assert.Empty(t, errs)
+	assert.Equal(t, "Daily load", p.Metadata.Name)
+	assert.Len(t, p.Spec.Nodes, 2)
+	assert.Equal(t, "Europe/London", p.Spec.Nodes[0].Schedule.Timezone)
+	assert.Equal(t, 3, p.Spec.Nodes[1].Retry.MaxAttempts)
+
+	defs, defErrs := PipelineYAMLParameterDefs(p.Spec.Parameters)
+	assert.Nil(t, defErrs[0])
+	assert.Equal(t, `"eu"`, string(defs[0].Default))
+
+	// Round trips without changes
+	out, err := yaml.Marshal(p)
+	assert.NoError(t, err)
+	again, errs := PipelineYAMLParse(out)
+	assert.Empty(t, errs)
+	assert.Equal(t, p, again)
+
+	// Unknown fields point at their line
+	_, errs = PipelineYAMLParse([]byte("kind: Dataplane/pipeline/v1\nmetadata:\n  name: x\n  nmae: y\n"))
+	assert.Len(t, errs, 1)
+	assert.Equal(t, 4, errs[0].Line)
+	assert.Equal(t, "metadata.nmae", errs[0].Path)
+
+	// Syntax errors
+	_, errs = PipelineYAMLParse([]byte("kind: [\n"))
+	assert.Len(t, errs, 1)
+	assert.NotEqual(t, 0, errs[0].Line)
+
+	_, errs = PipelineYAMLParse([]byte(""))
+	assert.Len(t, errs, 1)
+}
+
+/*
+go test -timeout 30s -v -run ^TestPipelineYAMLValidate$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/config_as_code
+*/
+func TestPipelineYAMLValidate(t *testing.T) {
+
+	bad := `kind: Dataplane/pipeline/v2
+metadata:
+  name: ""
+spec:
+  concurrency_policy: later
+  parameters:
+    - name: region
+      type: string
+    - name: REGION
+      type: string
+    - name: 1x
+      type: string
+  nodes:
+    - id: a
+      name: A
+      type: trigger
+      type_desc: play
+      schedule:
+        schedule: "* * * * *"
+        schedule_type: cron
+    - id: b
+      name: B
+      type: process
+      type_desc: python
+      retry:
+        max_attempts: 0
+        backoff: linear
+      code:
+        - path: ../secret.py
+        - path: ok.py
+          content_base64: "%%%"
+    - id: b
+      name: C
+      type: trigger
+      type_desc: api
+    - id: d
+      name: D
+      type: checkpoint
+      type_desc: checkpoint
+  edges:
+    - from: a
+      to: b
+      condition: failure
+    - from: b
+      to: d
+    - from: d
+      to: b
+    - from: b
+      to: missing
+`
+
+	_, errs := PipelineYAMLParse([]byte(bad))
+
+	byPath := map[string]PipelineYAMLError{}
+	for _, e := range errs {
+		byPath[e.Path] = e
+	}
+
+	expected := []string{
+		"kind",
+		"metadata.name",
+		"spec.concurrency_policy",
+		"spec.parameters[1].name",
+		"spec.parameters[2]",
+		"spec.nodes[0].schedule",
+		"spec.nodes[1].worker_group",
+		"spec.nodes[1].retry.max_attempts",
+		"spec.nodes[1].retry.backoff",
+		"spec.nodes[1].code[0].path",
+		"spec.nodes[1].code[1].content_base64",
+		"spec.nodes[2].id",
+		"spec.nodes[2].type",
+		"spec.edges[0].condition",
+		"spec.edges[3].to",
+		"spec.edges",
+	}
+
+	for _, path := range expected {
+		assert.Contains(t, byPath, path)
+	}
+	assert.Len(t, errs, len(expected))
+
+	// Lines point at the field in error
+	assert.Equal(t, 1, byPath["kind"].Line)
+	assert.Equal(t, 9, byPath["spec.parameters[1].name"].Line)
+	assert.Equal(t, 27, byPath["spec.nodes[1].retry.backoff"].Line)
+	assert.Equal(t, 31, byPath["spec.nodes[1].code[1].content_base64"].Line)
+	assert.Equal(t, 32, byPath["spec.nodes[2].id"].Line)
+	assert.Equal(t, 40, byPath["spec.edges"].Line)
+	assert.Contains(t, byPath["spec.edges"].Message, "b -> d -> b")
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
@@ -58,11 +59,18 @@ func MoveCodeFilesToDB(db *gorm.DB) error {
 			md5byte := md5.Sum(dat)
 			md5string := fmt.Sprintf("%x", md5byte)
 
+			fileStore, external, objectKey, err := objectstorage.Store(dat)
+			if err != nil {
+				log.Println("Create file in object storage:", err)
+				continue
+			}
+
 			codefile := models.CodeFilesStore{
 				FileID:        x.FileID,
-				FileStore:     dat,
+				FileStore:     fileStore,
 				RunInclude:    true,
-				External:      false,
+				External:      external,
+				ObjectKey:     objectKey,
 				ChecksumMD5:   md5string,
 				EnvironmentID: x.EnvironmentID,
 			}
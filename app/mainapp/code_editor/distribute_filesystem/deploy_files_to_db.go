@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
@@ -58,10 +59,18 @@ func DeployFilesToDB(db *gorm.DB) error {
 			md5byte := md5.Sum(dat)
 			md5string := fmt.Sprintf("%x", md5byte)
 
+			fileStore, external, objectKey, err := objectstorage.Store(dat)
+			if err != nil {
+				log.Println("Create file in object storage:", err)
+				continue
+			}
+
 			codefile := models.DeployFilesStore{
 				FileID:        x.FileID,
 				Version:       x.Version,
-				FileStore:     dat,
+				FileStore:     fileStore,
+				External:      external,
+				ObjectKey:     objectKey,
 				ChecksumMD5:   md5string,
 				EnvironmentID: x.EnvironmentID,
 			}
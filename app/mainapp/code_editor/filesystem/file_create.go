@@ -9,6 +9,7 @@ import (
 	"time"
 
 	dfscache "github.com/dataplane-app/dataplane/app/mainapp/code_editor/dfs_cache"
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
@@ -74,11 +75,18 @@ func CreateFile(input models.CodeFiles, Folder string, Content []byte) (models.C
 	md5byte := md5.Sum(Content)
 	md5string := fmt.Sprintf("%x", md5byte)
 
+	fileStore, external, objectKey, err := objectstorage.Store(Content)
+	if err != nil {
+		log.Println("Create file in object storage:", err)
+		return input, returnpath, errors.New("Create file in object storage error")
+	}
+
 	codefile := models.CodeFilesStore{
 		FileID:        input.FileID,
-		FileStore:     Content,
+		FileStore:     fileStore,
 		RunInclude:    true,
-		External:      false,
+		External:      external,
+		ObjectKey:     objectKey,
 		ChecksumMD5:   md5string,
 		EnvironmentID: input.EnvironmentID,
 	}
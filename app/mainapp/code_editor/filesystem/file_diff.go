@@ -0,0 +1,217 @@
+package filesystem
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+LineOp is a line of a diff: ' ' kept, '-' removed or '+' added. Before and After are the line indexes, -1 when the line is not on that side.
+*/
+type LineOp struct {
+	Op     byte
+	Before int
+	After  int
+	Text   string
+}
+
+// Changes too large to compare line by line are shown as all lines replaced
+const lineDiffMaxCells = 4000000
+
+// The diff context around changes, as git
+const diffContext = 3
+
+// The last line of content without a newline is marked so that adding or removing the newline is a change
+const noNewline = "\x00"
+
+/*
+DiffLines splits content into lines for LineDiff, a last line without a newline is marked as different from the same line with one.
+*/
+func DiffLines(content []byte) []string {
+
+	if len(content) == 0 {
+		return []string{}
+	}
+
+	lines := strings.Split(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+
+	lines[len(lines)-1] += noNewline
+	return lines
+}
+
+/*
+LineDiff returns the lines kept, removed and added from before to after. Kept lines are the longest common
+subsequence of the lines between the common start and end.
+*/
+func LineDiff(before []string, after []string) []LineOp {
+
+	ops := []LineOp{}
+
+	start := 0
+	for start < len(before) && start < len(after) && before[start] == after[start] {
+		start++
+	}
+
+	end := 0
+	for end < len(before)-start && end < len(after)-start && before[len(before)-1-end] == after[len(after)-1-end] {
+		end++
+	}
+
+	for i := 0; i < start; i++ {
+		ops = append(ops, LineOp{Op: ' ', Before: i, After: i, Text: before[i]})
+	}
+
+	b := before[start : len(before)-end]
+	a := after[start : len(after)-end]
+
+	if len(b) > 0 && len(a) > 0 && len(b)*len(a) <= lineDiffMaxCells {
+
+		// lcs[i][j] is the length of the longest common subsequence of b[i:] and a[j:]
+		width := len(a) + 1
+		lcs := make([]int32, (len(b)+1)*width)
+		for i := len(b) - 1; i >= 0; i-- {
+			for j := len(a) - 1; j >= 0; j-- {
+				if b[i] == a[j] {
+					lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+				} else if lcs[(i+1)*width+j] >= lcs[i*width+j+1] {
+					lcs[i*width+j] = lcs[(i+1)*width+j]
+				} else {
+					lcs[i*width+j] = lcs[i*width+j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(b) || j < len(a) {
+			switch {
+			case i < len(b) && j < len(a) && b[i] == a[j]:
+				ops = append(ops, LineOp{Op: ' ', Before: start + i, After: start + j, Text: b[i]})
+				i++
+				j++
+			case j >= len(a) || (i < len(b) && lcs[(i+1)*width+j] >= lcs[i*width+j+1]):
+				ops = append(ops, LineOp{Op: '-', Before: start + i, After: -1, Text: b[i]})
+				i++
+			default:
+				ops = append(ops, LineOp{Op: '+', Before: -1, After: start + j, Text: a[j]})
+				j++
+			}
+		}
+
+	} else {
+
+		for i := range b {
+			ops = append(ops, LineOp{Op: '-', Before: start + i, After: -1, Text: b[i]})
+		}
+		for j := range a {
+			ops = append(ops, LineOp{Op: '+', Before: -1, After: start + j, Text: a[j]})
+		}
+	}
+
+	for k := 0; k < end; k++ {
+		ops = append(ops, LineOp{Op: ' ', Before: len(before) - end + k, After: len(after) - end + k, Text: before[len(before)-end+k]})
+	}
+
+	return ops
+}
+
+/*
+UnifiedDiff returns the changes from before to after as a unified diff, empty when there are none.
+*/
+func UnifiedDiff(before []byte, after []byte, fromName string, toName string) string {
+
+	if bytes.Equal(before, after) {
+		return ""
+	}
+
+	header := "--- " + fromName + "\n+++ " + toName + "\n"
+
+	if !diffText(before) || !diffText(after) {
+		return header + "Binary files differ\n"
+	}
+
+	ops := LineDiff(DiffLines(before), DiffLines(after))
+
+	// Lines of each side before each op
+	oldPos := make([]int, len(ops)+1)
+	newPos := make([]int, len(ops)+1)
+	for i, op := range ops {
+		oldPos[i+1] = oldPos[i]
+		newPos[i+1] = newPos[i]
+		if op.Op != '+' {
+			oldPos[i+1]++
+		}
+		if op.Op != '-' {
+			newPos[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString(header)
+
+	i := 0
+	for i < len(ops) {
+
+		if ops[i].Op == ' ' {
+			i++
+			continue
+		}
+
+		// A hunk runs from the context before the change to the context after the last change close to it
+		from := i - diffContext
+		if from < 0 {
+			from = 0
+		}
+
+		to := i
+		for k := i; k < len(ops) && k-to <= 2*diffContext; k++ {
+			if ops[k].Op != ' ' {
+				to = k
+			}
+		}
+
+		until := to + diffContext + 1
+		if until > len(ops) {
+			until = len(ops)
+		}
+
+		oldStart, oldCount := oldPos[from], oldPos[until]-oldPos[from]
+		newStart, newCount := newPos[from], newPos[until]-newPos[from]
+		if oldCount > 0 {
+			oldStart++
+		}
+		if newCount > 0 {
+			newStart++
+		}
+
+		out.WriteString("@@ -" + hunkRange(oldStart, oldCount) + " +" + hunkRange(newStart, newCount) + " @@\n")
+
+		for _, op := range ops[from:until] {
+			out.WriteByte(op.Op)
+			if strings.HasSuffix(op.Text, noNewline) {
+				out.WriteString(strings.TrimSuffix(op.Text, noNewline) + "\n\\ No newline at end of file\n")
+			} else {
+				out.WriteString(op.Text + "\n")
+			}
+		}
+
+		i = until
+	}
+
+	return out.String()
+}
+
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
+
+func diffText(content []byte) bool {
+	return utf8.Valid(content) && !bytes.Contains(content, []byte{0})
+}
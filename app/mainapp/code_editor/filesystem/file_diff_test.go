@@ -0,0 +1,77 @@
+package filesystem
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestUnifiedDiff$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem
+*/
+func TestUnifiedDiff(t *testing.T) {
+
+	before := []string{}
+	for i := 1; i <= 20; i++ {
+		before = append(before, strconv.Itoa(i))
+	}
+	after := append([]string{}, before...)
+	after[2] = "x"
+	after = append(after[:18], "20", "end")
+
+	// Same as git diff
+	expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++x
+ 4
+ 5
+ 6
+@@ -16,5 +16,5 @@
+ 16
+ 17
+ 18
+-19
+ 20
++end
+\ No newline at end of file
+`
+	assert.Equal(t, expected, UnifiedDiff([]byte(strings.Join(before, "\n")+"\n"), []byte(strings.Join(after, "\n")), "a", "b"))
+
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n", UnifiedDiff([]byte(""), []byte("a\nb\n"), "a", "b"))
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n", UnifiedDiff([]byte("a\nb\n"), []byte(""), "a", "b"))
+	assert.Equal(t, "", UnifiedDiff([]byte("a\n"), []byte("a\n"), "a", "b"))
+	assert.Equal(t, "--- a\n+++ b\nBinary files differ\n", UnifiedDiff([]byte("a\n"), []byte{0, 1}, "a", "b"))
+
+	// Changes close together are one hunk
+	diff := UnifiedDiff([]byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n"), []byte("1\nx\n3\n4\n5\n6\n7\ny\n9\n"), "a", "b")
+	assert.Equal(t, 1, strings.Count(diff, "@@ -"))
+	assert.Contains(t, diff, "@@ -1,9 +1,9 @@")
+}
+
+/*
+go test -timeout 30s -v -run ^TestLineDiff$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem
+*/
+func TestLineDiff(t *testing.T) {
+
+	ops := LineDiff([]string{"1", "2", "3", "4", "5"}, []string{"1", "x", "4", "3", "5", "y"})
+
+	result := ""
+	for _, op := range ops {
+		result += string(op.Op) + op.Text + " "
+	}
+	assert.Equal(t, " 1 -2 -3 +x  4 +3  5 +y ", result)
+
+	// Line indexes of each side
+	assert.Equal(t, LineOp{Op: ' ', Before: 3, After: 2, Text: "4"}, ops[4])
+	assert.Equal(t, LineOp{Op: '+', Before: -1, After: 5, Text: "y"}, ops[7])
+
+	assert.Equal(t, []string{"a", "b" + noNewline}, DiffLines([]byte("a\nb")))
+	assert.Equal(t, []string{"a", ""}, DiffLines([]byte("a\n\n")))
+	assert.Equal(t, []string{}, DiffLines(nil))
+}
@@ -0,0 +1,217 @@
+package filesystem
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/google/uuid"
+)
+
+/*
+FileRevisionCreate records the content of a file as a revision. Saves, imports, pulls and deletes of the same
+content as the latest revision are not recorded again, restores and undeletes always are.
+*/
+func FileRevisionCreate(file models.CodeFiles, content []byte, userID string, action string) error {
+
+	checksum := fmt.Sprintf("%x", md5.Sum(content))
+
+	if action != "restore" && action != "undelete" {
+
+		latest := models.CodeFileRevisions{}
+		err := database.DBConn.Select("checksum_md5").Where("file_id = ? and environment_id = ?", file.FileID, file.EnvironmentID).Order("created_at desc").Limit(1).Find(&latest).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve file revision database error.")
+		}
+
+		if latest.ChecksumMD5 == checksum {
+			return nil
+		}
+	}
+
+	fileStore, external, objectKey, err := objectstorage.Store(content)
+	if err != nil {
+		return err
+	}
+
+	err = database.DBConn.Create(&models.CodeFileRevisions{
+		RevisionID:    uuid.NewString(),
+		FileID:        file.FileID,
+		EnvironmentID: file.EnvironmentID,
+		PipelineID:    file.PipelineID,
+		NodeID:        file.NodeID,
+		FileName:      file.FileName,
+		FileStore:     fileStore,
+		External:      external,
+		ObjectKey:     objectKey,
+		Size:          len(content),
+		ChecksumMD5:   checksum,
+		UserID:        userID,
+		Action:        action,
+	}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Create file revision database error.")
+	}
+
+	return nil
+}
+
+/*
+FileRevisionSnapshot records the saved content of a file before it is deleted so that it can be undeleted.
+*/
+func FileRevisionSnapshot(file models.CodeFiles, userID string) error {
+
+	content, _, err := FileStoreContent(file.FileID, file.EnvironmentID)
+	if err != nil {
+		return err
+	}
+
+	return FileRevisionCreate(file, content, userID, "delete")
+}
+
+/*
+FileRevisionGet returns a revision of a file of the pipeline.
+*/
+func FileRevisionGet(revisionID string, environmentID string, pipelineID string) (models.CodeFileRevisions, error) {
+
+	revision := models.CodeFileRevisions{}
+	err := database.DBConn.Where("revision_id = ? and environment_id = ? and pipeline_id = ?", revisionID, environmentID, pipelineID).First(&revision).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return revision, errors.New("Revision not found.")
+	}
+
+	return revision, nil
+}
+
+/*
+FileRevisionContent returns the content of a revision, from the database or object storage.
+*/
+func FileRevisionContent(revision models.CodeFileRevisions) ([]byte, error) {
+
+	return objectstorage.Content(revision.FileStore, revision.External, revision.ObjectKey)
+}
+
+/*
+FileRevisionRestore makes the content of a revision the current content of its file. The content it replaces is recorded first if it was not.
+*/
+func FileRevisionRestore(revisionID string, environmentID string, userID string) (models.CodeFiles, error) {
+
+	revision := models.CodeFileRevisions{}
+	err := database.DBConn.Where("revision_id = ? and environment_id = ?", revisionID, environmentID).First(&revision).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.CodeFiles{}, errors.New("Revision not found.")
+	}
+
+	file := models.CodeFiles{}
+	err = database.DBConn.Where("file_id = ? and environment_id = ?", revision.FileID, environmentID).Limit(1).Find(&file).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.CodeFiles{}, errors.New("Retrieve file database error.")
+	}
+
+	if file.FileID == "" {
+		return models.CodeFiles{}, errors.New("The file is deleted, undelete it first.")
+	}
+
+	content, err := FileRevisionContent(revision)
+	if err != nil {
+		return models.CodeFiles{}, err
+	}
+
+	current, _, err := FileStoreContent(file.FileID, environmentID)
+	if err != nil {
+		return models.CodeFiles{}, err
+	}
+
+	err = FileRevisionCreate(file, current, "", "save")
+	if err != nil {
+		return models.CodeFiles{}, err
+	}
+
+	folderPath, err := FolderConstructByID(database.DBConn, file.FolderID, environmentID, "pipelines")
+	if err != nil {
+		return models.CodeFiles{}, errors.New("Build folder path failed.")
+	}
+
+	file, _, err = CreateFile(file, folderPath, content)
+	if err != nil {
+		return models.CodeFiles{}, errors.New("Restore file failed: " + err.Error())
+	}
+
+	err = FileRevisionCreate(file, content, userID, "restore")
+	if err != nil {
+		return models.CodeFiles{}, err
+	}
+
+	return file, nil
+}
+
+/*
+FileRevisionLatest returns the latest content kept for a file, from its revisions or else its store. False when there is none.
+*/
+func FileRevisionLatest(fileID string, environmentID string) ([]byte, bool, error) {
+
+	revision := models.CodeFileRevisions{}
+	err := database.DBConn.Where("file_id = ? and environment_id = ?", fileID, environmentID).Order("created_at desc").Limit(1).Find(&revision).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, false, errors.New("Retrieve file revision database error.")
+	}
+
+	if revision.RevisionID != "" {
+		content, err := FileRevisionContent(revision)
+		if err != nil {
+			return nil, false, err
+		}
+		return content, true, nil
+	}
+
+	return FileStoreContent(fileID, environmentID)
+}
+
+/*
+FileStoreContent returns the saved content of a file, from the database or object storage. False when the file has no content saved.
+*/
+func FileStoreContent(fileID string, environmentID string) ([]byte, bool, error) {
+
+	store := models.CodeFilesStore{}
+	err := database.DBConn.Where("file_id = ? and environment_id = ?", fileID, environmentID).Limit(1).Find(&store).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, false, errors.New("Retrieve file store database error.")
+	}
+
+	if store.FileID == "" {
+		return nil, false, nil
+	}
+
+	content, err := objectstorage.Content(store.FileStore, store.External, store.ObjectKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return content, true, nil
+}
@@ -0,0 +1,92 @@
+package filesystem
+
+import (
+	"errors"
+	"strings"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/google/uuid"
+)
+
+/*
+NodeFileWrite creates or overwrites a file of a node, the path is relative to the node folder with / between folders.
+Sub folders are created as needed. The node folder must exist, see FolderNodeAddUpdate.
+*/
+func NodeFileWrite(pipelineID string, environmentID string, nodeID string, path string, content []byte) (models.CodeFiles, error) {
+
+	nodeFolder := models.CodeFolders{}
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ? and node_id = ? and level = ?", pipelineID, environmentID, nodeID, "node").First(&nodeFolder).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.CodeFiles{}, errors.New("Node folder not found.")
+	}
+
+	parts := strings.Split(path, "/")
+	fileName := parts[len(parts)-1]
+	folderID := nodeFolder.FolderID
+
+	// ----- Sub folders
+	for _, part := range parts[:len(parts)-1] {
+
+		existing := models.CodeFolders{}
+		err := database.DBConn.Where("parent_id = ? and environment_id = ? and node_id = ? and folder_name = ?", folderID, environmentID, nodeID, part).Limit(1).Find(&existing).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return models.CodeFiles{}, errors.New("Retrieve code folder database error.")
+		}
+
+		if existing.FolderID == "" {
+
+			parentFolder, err := FolderConstructByID(database.DBConn, folderID, environmentID, "pipelines")
+			if err != nil {
+				return models.CodeFiles{}, errors.New("Build folder path failed for " + path)
+			}
+
+			// Level is unique per node, sub folders are told apart by ID as in createFolderNode
+			existing, _, err = CreateFolder(models.CodeFolders{
+				EnvironmentID: environmentID,
+				PipelineID:    pipelineID,
+				NodeID:        nodeID,
+				ParentID:      folderID,
+				FolderName:    part,
+				FType:         "node-folder",
+				Level:         uuid.NewString(),
+				Active:        true,
+			}, parentFolder)
+			if err != nil {
+				return models.CodeFiles{}, errors.New("Create folder failed for " + path)
+			}
+		}
+
+		folderID = existing.FolderID
+	}
+
+	// ----- File
+	folderPath, err := FolderConstructByID(database.DBConn, folderID, environmentID, "pipelines")
+	if err != nil {
+		return models.CodeFiles{}, errors.New("Build folder path failed for " + path)
+	}
+
+	file, _, err := CreateFile(models.CodeFiles{
+		FolderID:      folderID,
+		EnvironmentID: environmentID,
+		PipelineID:    pipelineID,
+		NodeID:        nodeID,
+		FileName:      fileName,
+		Level:         "node_file",
+		FType:         "file",
+		Active:        true,
+	}, folderPath, content)
+	if err != nil {
+		return models.CodeFiles{}, errors.New("Save file failed for " + path + ": " + err.Error())
+	}
+
+	return file, nil
+}
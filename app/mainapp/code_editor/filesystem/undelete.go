@@ -0,0 +1,409 @@
+package filesystem
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	dfscache "github.com/dataplane-app/dataplane/app/mainapp/code_editor/dfs_cache"
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+FolderSnapshot is what is removed from the database with a folder: the folder, its sub folders and its files.
+Deeper folders and files keep their records and come back with the folder.
+*/
+type FolderSnapshot struct {
+	Folder  models.CodeFolders   `json:"folder"`
+	Folders []models.CodeFolders `json:"folders"`
+	Files   []models.CodeFiles   `json:"files"`
+}
+
+/*
+FolderSnapshotCreate keeps the records of a folder about to be deleted, and the content of its files as revisions.
+*/
+func FolderSnapshotCreate(folder models.CodeFolders, userID string) (datatypes.JSON, error) {
+
+	snapshot := FolderSnapshot{Folder: folder}
+
+	err := database.DBConn.Where("parent_id = ? and environment_id = ?", folder.FolderID, folder.EnvironmentID).Find(&snapshot.Folders).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve sub folders database error.")
+	}
+
+	err = database.DBConn.Where("folder_id = ? and environment_id = ?", folder.FolderID, folder.EnvironmentID).Find(&snapshot.Files).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve folder files database error.")
+	}
+
+	for _, f := range snapshot.Files {
+		err = FileRevisionSnapshot(f, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	out, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, errors.New("Folder snapshot encode error.")
+	}
+
+	return datatypes.JSON(out), nil
+}
+
+/*
+DeletedPath returns where a deleted file or folder was, from its record or else from its folder if that still exists.
+*/
+func DeletedPath(deleted models.FolderDeleted) string {
+
+	if deleted.Path != "" {
+		return deleted.Path
+	}
+
+	if deleted.FType == "file" && deleted.FolderID != "" {
+		folderPath, err := FolderConstructByID(database.DBConn, deleted.FolderID, deleted.EnvironmentID, "pipelines")
+		if err == nil {
+			return folderPath + deleted.FileName
+		}
+	}
+
+	if deleted.FType == "file" {
+		return deleted.FileName
+	}
+
+	return deleted.FolderName
+}
+
+/*
+Undelete brings back a deleted file or folder where it was. The folder it was in must exist and not have a file or folder of the same name.
+*/
+func Undelete(id string, environmentID string, pipelineID string, userID string) (models.FolderDeleted, error) {
+
+	deleted := models.FolderDeleted{}
+	err := database.DBConn.Where("id = ? and environment_id = ? and pipeline_id = ?", id, environmentID, pipelineID).First(&deleted).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return deleted, errors.New("Deleted record not found.")
+	}
+
+	if deleted.RestoredAt != nil {
+		return deleted, errors.New("Already undeleted.")
+	}
+
+	if deleted.FType == "file" {
+		err = fileUndelete(deleted, userID)
+	} else {
+		err = folderUndelete(deleted, userID)
+	}
+	if err != nil {
+		return deleted, err
+	}
+
+	now := time.Now().UTC()
+	deleted.RestoredAt = &now
+	err = database.DBConn.Model(&models.FolderDeleted{}).Where("id = ?", deleted.ID).Update("restored_at", now).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return deleted, errors.New("Update deleted record database error.")
+	}
+
+	return deleted, nil
+}
+
+func fileUndelete(deleted models.FolderDeleted, userID string) error {
+
+	// Files deleted before their folder was recorded cannot be placed
+	if deleted.FolderID == "" || deleted.FileID == "" {
+		return errors.New("The folder of this file was not recorded, it cannot be undeleted.")
+	}
+
+	parent, err := undeleteParent(deleted.FolderID, deleted.EnvironmentID)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	err = database.DBConn.Model(&models.CodeFiles{}).Where("folder_id = ? and environment_id = ? and file_name = ?", parent.FolderID, deleted.EnvironmentID, deleted.FileName).Count(&count).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Retrieve code files database error.")
+	}
+	if count > 0 {
+		return errors.New("A file named " + deleted.FileName + " already exists in the folder.")
+	}
+
+	content, ok, err := FileRevisionLatest(deleted.FileID, deleted.EnvironmentID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("No content was kept for this file, it cannot be undeleted.")
+	}
+
+	// The same file ID keeps its revisions
+	file := models.CodeFiles{
+		FileID:        deleted.FileID,
+		FolderID:      parent.FolderID,
+		EnvironmentID: deleted.EnvironmentID,
+		PipelineID:    deleted.PipelineID,
+		NodeID:        deleted.NodeID,
+		FileName:      deleted.FileName,
+		Level:         "node_file",
+		FType:         "file",
+		Active:        true,
+	}
+	err = database.DBConn.Create(&file).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Create file database error.")
+	}
+
+	folderPath, err := FolderConstructByID(database.DBConn, parent.FolderID, deleted.EnvironmentID, "pipelines")
+	if err != nil {
+		return errors.New("Build folder path failed.")
+	}
+
+	file, _, err = CreateFile(file, folderPath, content)
+	if err != nil {
+		return errors.New("Undelete file failed: " + err.Error())
+	}
+
+	return FileRevisionCreate(file, content, userID, "undelete")
+}
+
+func folderUndelete(deleted models.FolderDeleted, userID string) error {
+
+	if len(deleted.Snapshot) == 0 {
+		return errors.New("The contents of this folder were not recorded, it cannot be undeleted.")
+	}
+
+	snapshot := FolderSnapshot{}
+	err := json.Unmarshal(deleted.Snapshot, &snapshot)
+	if err != nil {
+		return errors.New("Folder snapshot decode error.")
+	}
+
+	parent, err := undeleteParent(snapshot.Folder.ParentID, deleted.EnvironmentID)
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	err = database.DBConn.Model(&models.CodeFolders{}).Where("parent_id = ? and environment_id = ? and folder_name = ?", parent.FolderID, deleted.EnvironmentID, snapshot.Folder.FolderName).Count(&count).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Retrieve code folders database error.")
+	}
+	if count > 0 {
+		return errors.New("A folder named " + snapshot.Folder.FolderName + " already exists in the folder.")
+	}
+
+	// The same IDs link the deeper folders and files that were left behind
+	err = database.DBConn.Transaction(func(tx *gorm.DB) error {
+
+		err := tx.Create(&snapshot.Folder).Error
+		if err != nil {
+			return err
+		}
+
+		for _, f := range snapshot.Folders {
+			err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&f).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, f := range snapshot.Files {
+			err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&f).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Undelete folder database error.")
+	}
+
+	for _, f := range snapshot.Files {
+
+		content, ok, err := FileRevisionLatest(f.FileID, f.EnvironmentID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		fileStore, external, objectKey, err := objectstorage.Store(content)
+		if err != nil {
+			return err
+		}
+
+		err = database.DBConn.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.CodeFilesStore{
+			FileID:        f.FileID,
+			FileStore:     fileStore,
+			External:      external,
+			ObjectKey:     objectKey,
+			RunInclude:    true,
+			ChecksumMD5:   fmt.Sprintf("%x", md5.Sum(content)),
+			EnvironmentID: f.EnvironmentID,
+		}).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Undelete file store database error.")
+		}
+
+		err = FileRevisionCreate(f, content, userID, "undelete")
+		if err != nil {
+			return err
+		}
+	}
+
+	folderPath, err := FolderConstructByID(database.DBConn, snapshot.Folder.FolderID, deleted.EnvironmentID, "pipelines")
+	if err != nil {
+		return errors.New("Build folder path failed.")
+	}
+
+	if dpconfig.FSCodeFileStorage == "LocalFile" {
+		err = folderRestoreDisk(snapshot.Folder.FolderID, deleted.EnvironmentID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dfscache.InvalidateCacheNode(deleted.NodeID, deleted.EnvironmentID, folderPath)
+	if err != nil {
+		log.Println("Undelete folder invalidate file cache", err)
+	}
+
+	return nil
+}
+
+func undeleteParent(folderID string, environmentID string) (models.CodeFolders, error) {
+
+	parent := models.CodeFolders{}
+	err := database.DBConn.Where("folder_id = ? and environment_id = ?", folderID, environmentID).Limit(1).Find(&parent).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return parent, errors.New("Retrieve code folder database error.")
+	}
+
+	if parent.FolderID == "" {
+		return parent, errors.New("The folder it was in is deleted, undelete that folder first.")
+	}
+
+	return parent, nil
+}
+
+// Writes a folder and everything under it back to the code directory, the whole tree was removed from disk on delete
+func folderRestoreDisk(folderID string, environmentID string) error {
+
+	queue := []string{folderID}
+
+	// The limit guards against a broken parent chain
+	for i := 0; len(queue) > 0 && i < 10000; i++ {
+
+		current := queue[0]
+		queue = queue[1:]
+
+		folderPath, err := FolderConstructByID(database.DBConn, current, environmentID, "pipelines")
+		if err != nil {
+			return errors.New("Build folder path failed.")
+		}
+
+		err = os.MkdirAll(dpconfig.CodeDirectory+folderPath, 0755)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Create folder in directory failed.")
+		}
+
+		files := []codeFileContent{}
+		err = database.DBConn.Raw(`
+		select f.file_name, s.file_store, s.external, s.object_key
+		from code_files f
+		inner join code_files_store s on s.file_id = f.file_id and s.environment_id = f.environment_id
+		where f.folder_id = ? and f.environment_id = ?
+		`, current, environmentID).Scan(&files).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve folder files database error.")
+		}
+
+		for _, f := range files {
+			content, err := objectstorage.Content(f.FileStore, f.External, f.ObjectKey)
+			if err != nil {
+				return err
+			}
+
+			err = os.WriteFile(dpconfig.CodeDirectory+folderPath+f.FileName, content, 0644)
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				return errors.New("Write file in directory failed.")
+			}
+		}
+
+		children := []models.CodeFolders{}
+		err = database.DBConn.Select("folder_id").Where("parent_id = ? and environment_id = ?", current, environmentID).Find(&children).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve sub folders database error.")
+		}
+
+		for _, c := range children {
+			queue = append(queue, c.FolderID)
+		}
+	}
+
+	return nil
+}
+
+type codeFileContent struct {
+	FileName  string
+	FileStore []byte
+	External  bool
+	ObjectKey string
+}
@@ -0,0 +1,696 @@
+package gitsync
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	"github.com/go-git/go-billy/v5/osfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+/*
+GitBranch is the branch the code of a pipeline is committed to in its repository.
+*/
+const GitBranch = "main"
+
+const remoteName = "origin"
+
+func init() {
+	// Local remotes are served in process so that no git binary is needed
+	client.InstallProtocol("file", server.NewClient(server.NewFilesystemLoader(osfs.New("/"))))
+}
+
+/*
+FileChange is a file that changed between two commits. Content is empty for deletes.
+*/
+type FileChange struct {
+	Path    string
+	Action  string // insert, modify, delete
+	Content []byte
+}
+
+/*
+PullPlan is what pulling from a remote branch would do.
+Status is up-to-date, ahead (the remote has nothing new), empty (the remote branch does not exist yet) or fast-forward.
+*/
+type PullPlan struct {
+	Status  string
+	From    plumbing.Hash
+	To      plumbing.Hash
+	Changes []FileChange
+}
+
+/*
+RepoOpen opens the bare repository at path, it is created on first use.
+*/
+func RepoOpen(path string) (*git.Repository, error) {
+
+	repo, err := git.PlainOpen(path)
+	if err == nil {
+		return repo, nil
+	}
+
+	if err != git.ErrRepositoryNotExists {
+		return nil, err
+	}
+
+	repo, err = git.PlainInit(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(GitBranch)))
+	if err != nil {
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+/*
+RepoHead returns the last commit on GitBranch, the zero hash before the first commit.
+*/
+func RepoHead(repo *git.Repository) (plumbing.Hash, error) {
+
+	ref, err := repo.Reference(plumbing.NewBranchReferenceName(GitBranch), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return plumbing.ZeroHash, nil
+	}
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return ref.Hash(), nil
+}
+
+/*
+CommitTree commits files by path as the whole tree of GitBranch. Nothing is committed when the tree has not changed,
+the head is returned with changed false. Kept are files by path whose blob is already in the repository, see HeadUnchanged.
+*/
+func CommitTree(repo *git.Repository, files map[string][]byte, kept map[string]plumbing.Hash, author object.Signature, message string) (plumbing.Hash, bool, error) {
+
+	head, err := RepoHead(repo)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	treeHash, err := writeTree(repo.Storer, files, kept)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	parents := []plumbing.Hash{}
+	if !head.IsZero() {
+
+		parent, err := repo.CommitObject(head)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+
+		if parent.TreeHash == treeHash {
+			return head, false, nil
+		}
+
+		parents = append(parents, head)
+	}
+
+	commit := &object.Commit{
+		Author:       author,
+		Committer:    author,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	}
+
+	obj := repo.Storer.NewEncodedObject()
+	err = commit.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	err = RepoMoveHead(repo, hash)
+	if err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+
+	return hash, true, nil
+}
+
+/*
+HeadUnchanged returns the blobs of the head tree by path whose content still has the MD5 checksum of the path in checksums.
+The content of these files doesn't have to be read again to commit them.
+*/
+func HeadUnchanged(repo *git.Repository, checksums map[string]string) (map[string]plumbing.Hash, error) {
+
+	unchanged := map[string]plumbing.Hash{}
+
+	head, err := RepoHead(repo)
+	if err != nil || head.IsZero() {
+		return unchanged, err
+	}
+
+	blobs, err := treeBlobs(repo, head)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, f := range blobs {
+
+		checksum, ok := checksums[path]
+		if !ok || checksum == "" {
+			continue
+		}
+
+		content, err := fileContent(f)
+		if err != nil {
+			return nil, err
+		}
+
+		if fmt.Sprintf("%x", md5.Sum(content)) == checksum {
+			unchanged[path] = f.Hash
+		}
+	}
+
+	return unchanged, nil
+}
+
+/*
+RepoMoveHead points GitBranch at a commit.
+*/
+func RepoMoveHead(repo *git.Repository, hash plumbing.Hash) error {
+	return repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(GitBranch), hash))
+}
+
+/*
+TreeFiles returns the files of a commit by path, none for the zero hash.
+*/
+func TreeFiles(repo *git.Repository, hash plumbing.Hash) (map[string][]byte, error) {
+
+	files := map[string][]byte{}
+	if hash.IsZero() {
+		return files, nil
+	}
+
+	blobs, err := treeBlobs(repo, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, f := range blobs {
+		content, err := fileContent(f)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = content
+	}
+
+	return files, nil
+}
+
+/*
+History returns the commits of GitBranch that changed a path, newest first. An empty path is all commits.
+*/
+func History(repo *git.Repository, path string, limit int) ([]*object.Commit, error) {
+
+	commits := []*object.Commit{}
+
+	head, err := RepoHead(repo)
+	if err != nil || head.IsZero() {
+		return commits, err
+	}
+
+	options := &git.LogOptions{From: head}
+	if path != "" {
+		options.PathFilter = func(p string) bool {
+			return p == path || strings.HasPrefix(p, path+"/")
+		}
+	}
+
+	iter, err := repo.Log(options)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	err = iter.ForEach(func(c *object.Commit) error {
+		if limit > 0 && len(commits) >= limit {
+			return storer.ErrStop
+		}
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+/*
+BlameLine is a line of a file with the commit that last changed it.
+*/
+type BlameLine struct {
+	Line   int
+	Text   string
+	Commit *object.Commit
+}
+
+/*
+Blame returns who last changed each line of a file as of a commit, the zero hash is the head.
+Lines are followed through the commits that changed the file in history order, git.Blame orders them by time
+which is wrong for commits in the same second.
+*/
+func Blame(repo *git.Repository, hash plumbing.Hash, path string) ([]BlameLine, error) {
+
+	if hash.IsZero() {
+		head, err := RepoHead(repo)
+		if err != nil {
+			return nil, err
+		}
+		if head.IsZero() {
+			return nil, errors.New("No commits yet.")
+		}
+		hash = head
+	}
+
+	iter, err := repo.Log(&git.LogOptions{
+		From:       hash,
+		PathFilter: func(p string) bool { return p == path },
+	})
+	if err != nil {
+		return nil, errors.New("Commit not found.")
+	}
+	defer iter.Close()
+
+	revs := []*object.Commit{}
+	err = iter.ForEach(func(c *object.Commit) error {
+		revs = append(revs, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revs) == 0 {
+		return nil, errors.New("File not found in commit.")
+	}
+
+	lines := []string{}
+	owners := []*object.Commit{}
+
+	// Oldest first
+	for i := len(revs) - 1; i >= 0; i-- {
+
+		next := []string{}
+		f, err := revs[i].File(path)
+		if err == nil {
+			content, err := f.Contents()
+			if err != nil {
+				return nil, err
+			}
+			next = splitLines(content)
+		} else if err != object.ErrFileNotFound {
+			return nil, err
+		}
+
+		owners = lineOwners(lines, next, owners, revs[i])
+		lines = next
+	}
+
+	blame := []BlameLine{}
+	for i, text := range lines {
+		blame = append(blame, BlameLine{Line: i + 1, Text: text, Commit: owners[i]})
+	}
+
+	return blame, nil
+}
+
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// Lines kept from before keep their commit, the rest are owned by commit
+func lineOwners(before []string, after []string, owners []*object.Commit, commit *object.Commit) []*object.Commit {
+
+	result := make([]*object.Commit, len(after))
+	for _, op := range filesystem.LineDiff(before, after) {
+		switch op.Op {
+		case ' ':
+			result[op.After] = owners[op.Before]
+		case '+':
+			result[op.After] = commit
+		}
+	}
+
+	return result
+}
+
+/*
+ValidateRemoteURL checks the URL of a remote: http, https or, when allowed, a local repository path.
+*/
+func ValidateRemoteURL(url string, allowFile bool) error {
+
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return errors.New("Remote URL invalid: " + err.Error())
+	}
+
+	switch endpoint.Protocol {
+	case "http", "https":
+		if endpoint.Host == "" {
+			return errors.New("Remote URL is missing a host")
+		}
+		return nil
+	case "file":
+		if !allowFile {
+			return errors.New("Local repositories are not allowed as remotes")
+		}
+		return nil
+	}
+
+	return errors.New("Remote URL must be http or https")
+}
+
+/*
+RemotePlan fetches a branch of a remote and works out what pulling it would do. Only fast-forwards are pulled,
+when both sides have new commits the remote needs to be merged outside first.
+*/
+func RemotePlan(repo *git.Repository, url string, branch string, auth transport.AuthMethod) (PullPlan, error) {
+
+	plan := PullPlan{}
+
+	head, err := RepoHead(repo)
+	if err != nil {
+		return plan, err
+	}
+	plan.From = head
+
+	err = remoteSet(repo, url)
+	if err != nil {
+		return plan, err
+	}
+
+	remoteRef := plumbing.NewRemoteReferenceName(remoteName, branch)
+
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec("+" + plumbing.NewBranchReferenceName(branch).String() + ":" + remoteRef.String())},
+		Auth:       auth,
+	})
+	if errors.Is(err, git.NoMatchingRefSpecError{}) || err == transport.ErrEmptyRemoteRepository {
+		plan.Status = "empty"
+		return plan, nil
+	}
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return plan, errors.New("Fetch from remote failed: " + err.Error())
+	}
+
+	ref, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return plan, errors.New("Remote branch " + branch + " not found.")
+	}
+	plan.To = ref.Hash()
+
+	if plan.To == plan.From {
+		plan.Status = "up-to-date"
+		return plan, nil
+	}
+
+	remoteCommit, err := repo.CommitObject(plan.To)
+	if err != nil {
+		return plan, err
+	}
+
+	if !head.IsZero() {
+
+		localCommit, err := repo.CommitObject(head)
+		if err != nil {
+			return plan, err
+		}
+
+		ahead, err := remoteCommit.IsAncestor(localCommit)
+		if err != nil {
+			return plan, err
+		}
+		if ahead {
+			plan.Status = "ahead"
+			return plan, nil
+		}
+
+		behind, err := localCommit.IsAncestor(remoteCommit)
+		if err != nil {
+			return plan, err
+		}
+		if !behind {
+			return plan, errors.New("The remote and the pipeline both have new commits, merge the remote branch with the pipeline's first.")
+		}
+	}
+
+	plan.Status = "fast-forward"
+	plan.Changes, err = treeChanges(repo, plan.From, plan.To)
+	if err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+/*
+RemotePush pushes GitBranch to a branch of a remote. The remote branch must not have commits the pipeline does not have.
+*/
+func RemotePush(repo *git.Repository, url string, branch string, auth transport.AuthMethod) error {
+
+	err := remoteSet(repo, url)
+	if err != nil {
+		return err
+	}
+
+	err = repo.Push(&git.PushOptions{
+		RemoteName: remoteName,
+		RefSpecs:   []config.RefSpec{config.RefSpec(plumbing.NewBranchReferenceName(GitBranch).String() + ":" + plumbing.NewBranchReferenceName(branch).String())},
+		Auth:       auth,
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "non-fast-forward") {
+			return errors.New("The remote has commits the pipeline does not have, pull first.")
+		}
+		return errors.New("Push to remote failed: " + err.Error())
+	}
+
+	return nil
+}
+
+// The remote is set on each use as its URL can change between uses
+func remoteSet(repo *git.Repository, url string) error {
+
+	err := repo.DeleteRemote(remoteName)
+	if err != nil && err != git.ErrRemoteNotFound {
+		return err
+	}
+
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: remoteName, URLs: []string{url}})
+	return err
+}
+
+func treeChanges(repo *git.Repository, from plumbing.Hash, to plumbing.Hash) ([]FileChange, error) {
+
+	before := map[string]*object.File{}
+	if !from.IsZero() {
+		var err error
+		before, err = treeBlobs(repo, from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	after, err := treeBlobs(repo, to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FileChange{}
+
+	for path, f := range after {
+
+		action := "insert"
+		if old, ok := before[path]; ok {
+			if old.Hash == f.Hash {
+				continue
+			}
+			action = "modify"
+		}
+
+		content, err := fileContent(f)
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, FileChange{Path: path, Action: action, Content: content})
+	}
+
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Action: "delete"})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+func treeBlobs(repo *git.Repository, hash plumbing.Hash) (map[string]*object.File, error) {
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	blobs := map[string]*object.File{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		blobs[f.Name] = f
+		return nil
+	})
+
+	return blobs, err
+}
+
+func fileContent(f *object.File) ([]byte, error) {
+
+	reader, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+type treeDir struct {
+	files map[string]plumbing.Hash
+	dirs  map[string]*treeDir
+}
+
+func writeTree(s storer.EncodedObjectStorer, files map[string][]byte, kept map[string]plumbing.Hash) (plumbing.Hash, error) {
+
+	root := &treeDir{files: map[string]plumbing.Hash{}, dirs: map[string]*treeDir{}}
+
+	for path, hash := range kept {
+		treeDirGet(root, path).files[treeFileName(path)] = hash
+	}
+
+	for path, content := range files {
+
+		blob := s.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		blob.SetSize(int64(len(content)))
+
+		w, err := blob.Writer()
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		_, err = w.Write(content)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		w.Close()
+
+		hash, err := s.SetEncodedObject(blob)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		treeDirGet(root, path).files[treeFileName(path)] = hash
+	}
+
+	return writeTreeDir(s, root)
+}
+
+// The folder of a file path, added to the tree if it is not there yet
+func treeDirGet(root *treeDir, path string) *treeDir {
+
+	parts := strings.Split(path, "/")
+	dir := root
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := dir.dirs[part]
+		if !ok {
+			next = &treeDir{files: map[string]plumbing.Hash{}, dirs: map[string]*treeDir{}}
+			dir.dirs[part] = next
+		}
+		dir = next
+	}
+
+	return dir
+}
+
+func treeFileName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+func writeTreeDir(s storer.EncodedObjectStorer, dir *treeDir) (plumbing.Hash, error) {
+
+	entries := []object.TreeEntry{}
+
+	for name, sub := range dir.dirs {
+		hash, err := writeTreeDir(s, sub)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+
+	for name, hash := range dir.files {
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Regular, Hash: hash})
+	}
+
+	// Git orders tree entries by name with folders compared as if they end in /
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+
+	tree := &object.Tree{Entries: entries}
+	obj := s.NewEncodedObject()
+	err := tree.Encode(obj)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
+}
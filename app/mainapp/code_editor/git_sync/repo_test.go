@@ -0,0 +1,260 @@
+package gitsync
+
+import (
+	"crypto/md5"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func testAuthor(email string) object.Signature {
+	return object.Signature{Name: email, Email: email, When: time.Now()}
+}
+
+/*
+go test -timeout 30s -v -run ^TestCommitTree$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestCommitTree(t *testing.T) {
+
+	repo, err := RepoOpen(filepath.Join(t.TempDir(), "p.git"))
+	assert.NoError(t, err)
+
+	files := map[string][]byte{
+		"n1_Load/dp-entrypoint.py": []byte("print('a')\n"),
+		"n1_Load/lib/helpers.py":   []byte("x = 1\n"),
+		"n1_Load/lib.py":           []byte("y = 2\n"),
+	}
+
+	first, changed, err := CommitTree(repo, files, nil, testAuthor("a@example.com"), "First")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	// Same tree, no commit
+	again, changed, err := CommitTree(repo, files, nil, testAuthor("a@example.com"), "Again")
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, first, again)
+
+	stored, err := TreeFiles(repo, first)
+	assert.NoError(t, err)
+	assert.Equal(t, files, stored)
+
+	// The tree is valid for git, the whole tree walks without errors
+	commit, err := repo.CommitObject(first)
+	assert.NoError(t, err)
+	tree, err := commit.Tree()
+	assert.NoError(t, err)
+	_, err = tree.File("n1_Load/lib/helpers.py")
+	assert.NoError(t, err)
+
+	files["n1_Load/dp-entrypoint.py"] = []byte("print('a')\nprint('b')\n")
+	delete(files, "n1_Load/lib.py")
+	second, changed, err := CommitTree(repo, files, nil, testAuthor("b@example.com"), "Second")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+
+	history, err := History(repo, "n1_Load/dp-entrypoint.py", 0)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, second, history[0].Hash)
+
+	history, err = History(repo, "n1_Load/lib/helpers.py", 0)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	history, err = History(repo, "", 1)
+	assert.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	blame, err := Blame(repo, plumbing.ZeroHash, "n1_Load/dp-entrypoint.py")
+	assert.NoError(t, err)
+	assert.Len(t, blame, 2)
+	assert.Equal(t, "a@example.com", blame[0].Commit.Author.Email)
+	assert.Equal(t, "b@example.com", blame[1].Commit.Author.Email)
+	assert.Equal(t, second, blame[1].Commit.Hash)
+	assert.Equal(t, "print('b')", blame[1].Text)
+
+	// As of the first commit
+	blame, err = Blame(repo, first, "n1_Load/dp-entrypoint.py")
+	assert.NoError(t, err)
+	assert.Len(t, blame, 1)
+}
+
+/*
+go test -timeout 30s -v -run ^TestHeadUnchanged$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestHeadUnchanged(t *testing.T) {
+
+	repo, err := RepoOpen(filepath.Join(t.TempDir(), "p.git"))
+	assert.NoError(t, err)
+
+	// No head yet, nothing is unchanged
+	unchanged, err := HeadUnchanged(repo, map[string]string{"n1_Load/a.py": testMD5("a\n")})
+	assert.NoError(t, err)
+	assert.Empty(t, unchanged)
+
+	first, _, err := CommitTree(repo, map[string][]byte{
+		"n1_Load/a.py":     []byte("a\n"),
+		"n1_Load/lib/b.py": []byte("b\n"),
+	}, nil, testAuthor("a@example.com"), "First")
+	assert.NoError(t, err)
+
+	unchanged, err = HeadUnchanged(repo, map[string]string{
+		"n1_Load/a.py":     testMD5("a\n"),
+		"n1_Load/lib/b.py": testMD5("b changed\n"),
+		"n1_Load/c.py":     testMD5("c\n"),
+	})
+	assert.NoError(t, err)
+	assert.Len(t, unchanged, 1)
+	assert.Contains(t, unchanged, "n1_Load/a.py")
+
+	// Kept blobs are committed as they are, only the changed files are given with their content
+	second, changed, err := CommitTree(repo, map[string][]byte{
+		"n1_Load/lib/b.py": []byte("b changed\n"),
+		"n1_Load/c.py":     []byte("c\n"),
+	}, unchanged, testAuthor("a@example.com"), "Second")
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.NotEqual(t, first, second)
+
+	stored, err := TreeFiles(repo, second)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"n1_Load/a.py":     []byte("a\n"),
+		"n1_Load/lib/b.py": []byte("b changed\n"),
+		"n1_Load/c.py":     []byte("c\n"),
+	}, stored)
+
+	// Nothing changed, all kept
+	unchanged, err = HeadUnchanged(repo, map[string]string{
+		"n1_Load/a.py":     testMD5("a\n"),
+		"n1_Load/lib/b.py": testMD5("b changed\n"),
+		"n1_Load/c.py":     testMD5("c\n"),
+	})
+	assert.NoError(t, err)
+	again, changed, err := CommitTree(repo, map[string][]byte{}, unchanged, testAuthor("a@example.com"), "Again")
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, second, again)
+}
+
+func testMD5(content string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(content)))
+}
+
+/*
+go test -timeout 30s -v -run ^TestRemoteSync$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestRemoteSync(t *testing.T) {
+
+	dir := t.TempDir()
+	remotePath := filepath.Join(dir, "remote.git")
+	_, err := git.PlainInit(remotePath, true)
+	assert.NoError(t, err)
+
+	repo, err := RepoOpen(filepath.Join(dir, "p.git"))
+	assert.NoError(t, err)
+
+	// Nothing on the remote yet
+	plan, err := RemotePlan(repo, remotePath, "dev", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "empty", plan.Status)
+
+	files := map[string][]byte{"n1_Load/a.py": []byte("a\n"), "n1_Load/b.py": []byte("b\n")}
+	_, _, err = CommitTree(repo, files, nil, testAuthor("a@example.com"), "First")
+	assert.NoError(t, err)
+
+	assert.NoError(t, RemotePush(repo, remotePath, "dev", nil))
+
+	plan, err = RemotePlan(repo, remotePath, "dev", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "up-to-date", plan.Status)
+
+	// A developer commits to the remote from their own clone
+	clone, err := RepoOpen(filepath.Join(dir, "clone.git"))
+	assert.NoError(t, err)
+	plan, err = RemotePlan(clone, remotePath, "dev", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "fast-forward", plan.Status)
+	assert.NoError(t, RepoMoveHead(clone, plan.To))
+
+	cloneFiles := map[string][]byte{"n1_Load/a.py": []byte("a2\n"), "n1_Load/c/d.py": []byte("d\n")}
+	_, _, err = CommitTree(clone, cloneFiles, nil, testAuthor("dev@example.com"), "From IDE")
+	assert.NoError(t, err)
+	assert.NoError(t, RemotePush(clone, remotePath, "dev", nil))
+
+	// Pulling is a fast-forward with the changes to apply
+	plan, err = RemotePlan(repo, remotePath, "dev", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "fast-forward", plan.Status)
+	assert.Equal(t, []FileChange{
+		{Path: "n1_Load/a.py", Action: "modify", Content: []byte("a2\n")},
+		{Path: "n1_Load/b.py", Action: "delete"},
+		{Path: "n1_Load/c/d.py", Action: "insert", Content: []byte("d\n")},
+	}, plan.Changes)
+
+	// Both sides changed
+	_, _, err = CommitTree(repo, map[string][]byte{"n1_Load/a.py": []byte("local\n")}, nil, testAuthor("a@example.com"), "Local")
+	assert.NoError(t, err)
+
+	_, err = RemotePlan(repo, remotePath, "dev", nil)
+	assert.Error(t, err)
+
+	err = RemotePush(repo, remotePath, "dev", nil)
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "pull first"))
+
+	// After a fast-forward the pipeline is ahead
+	repo2, err := RepoOpen(filepath.Join(dir, "p2.git"))
+	assert.NoError(t, err)
+	plan, err = RemotePlan(repo2, remotePath, "dev", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, RepoMoveHead(repo2, plan.To))
+	_, _, err = CommitTree(repo2, map[string][]byte{"n1_Load/a.py": []byte("a3\n")}, nil, testAuthor("a@example.com"), "Ahead")
+	assert.NoError(t, err)
+	plan, err = RemotePlan(repo2, remotePath, "dev", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ahead", plan.Status)
+}
+
+/*
+go test -timeout 30s -v -run ^TestValidateRemoteURL$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestValidateRemoteURL(t *testing.T) {
+
+	assert.NoError(t, ValidateRemoteURL("https://github.com/org/repo.git", false))
+	assert.NoError(t, ValidateRemoteURL("http://gitea.local:3000/org/repo.git", false))
+	assert.Error(t, ValidateRemoteURL("/tmp/repo.git", false))
+	assert.Error(t, ValidateRemoteURL("file:///tmp/repo.git", false))
+	assert.NoError(t, ValidateRemoteURL("/tmp/repo.git", true))
+	assert.Error(t, ValidateRemoteURL("git@github.com:org/repo.git", false))
+	assert.Error(t, ValidateRemoteURL("ssh://git@github.com/org/repo.git", false))
+}
+
+/*
+go test -timeout 30s -v -run ^TestLineOwners$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestLineOwners(t *testing.T) {
+
+	a := &object.Commit{Message: "a"}
+	b := &object.Commit{Message: "b"}
+
+	before := []string{"1", "2", "3", "4", "5"}
+	owners := []*object.Commit{a, a, a, a, a}
+
+	// Changed in the middle, one line moved
+	after := []string{"1", "x", "4", "3", "5", "y"}
+	assert.Equal(t, []*object.Commit{a, b, a, b, a, b}, lineOwners(before, after, owners, b))
+
+	assert.Equal(t, []*object.Commit{}, lineOwners(before, []string{}, owners, b))
+	assert.Equal(t, []*object.Commit{b}, lineOwners([]string{}, []string{"1"}, []*object.Commit{}, b))
+	assert.Equal(t, []string{}, splitLines(""))
+	assert.Equal(t, []string{"a", ""}, splitLines("a\n\n"))
+}
@@ -0,0 +1,846 @@
+package gitsync
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	dfscache "github.com/dataplane-app/dataplane/app/mainapp/code_editor/dfs_cache"
+	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+/*
+CommitInfo is a commit of a pipeline's code with the user that made it in Dataplane.
+*/
+type CommitInfo struct {
+	Hash        string
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+	UserID      string
+	Action      string
+	CreatedAt   time.Time
+}
+
+// One writer per repository, saves of the same pipeline can arrive together
+var repoLocks sync.Map
+
+func repoLock(pipelineID string, environmentID string) *sync.Mutex {
+	lock, _ := repoLocks.LoadOrStore(environmentID+"/"+pipelineID, &sync.Mutex{})
+	return lock.(*sync.Mutex)
+}
+
+/*
+repoWriteLock takes the lock of a pipeline's repository and a Postgres advisory lock on it,
+so that main app replicas sharing the git directory do not write the same repository together.
+The advisory lock is held on its own connection, the returned func releases both.
+*/
+func repoWriteLock(pipelineID string, environmentID string) (func(), error) {
+
+	lock := repoLock(pipelineID, environmentID)
+	lock.Lock()
+
+	ctx := context.Background()
+	key := "git-repo-" + environmentID + "/" + pipelineID
+
+	sqlDB, err := database.DBConn.DB()
+	if err != nil {
+		lock.Unlock()
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Lock code repository database error.")
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		lock.Unlock()
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Lock code repository database error.")
+	}
+
+	_, err = conn.ExecContext(ctx, "select pg_advisory_lock(hashtext($1))", key)
+	if err != nil {
+		conn.Close()
+		lock.Unlock()
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Lock code repository database error.")
+	}
+
+	return func() {
+		_, err := conn.ExecContext(ctx, "select pg_advisory_unlock(hashtext($1))", key)
+		if err != nil {
+			logging.PrintSecretsRedact("Unlock code repository:", err)
+		}
+		conn.Close()
+		lock.Unlock()
+	}, nil
+}
+
+/*
+RepoPath is where the bare repository of a pipeline's code is kept.
+*/
+func RepoPath(pipelineID string, environmentID string) string {
+	return dpconfig.GitDirectory + environmentID + "/" + pipelineID + ".git"
+}
+
+/*
+Commit commits the code of a pipeline as it is saved, attributed to the user. The hash is empty when nothing changed.
+*/
+func Commit(pipelineID string, environmentID string, userID string, message string) (string, error) {
+
+	unlock, err := repoWriteLock(pipelineID, environmentID)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	repo, err := RepoOpen(RepoPath(pipelineID, environmentID))
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Open code repository failed.")
+	}
+
+	return commitSaved(repo, pipelineID, environmentID, userID, message)
+}
+
+/*
+CommitChanges commits after an editor change. Failures are logged, the change itself has already been saved.
+*/
+func CommitChanges(pipelineID string, environmentID string, userID string, message string) {
+
+	_, err := Commit(pipelineID, environmentID, userID, message)
+	if err != nil {
+		log.Println("Code git commit:", pipelineID, err)
+	}
+}
+
+func commitSaved(repo *git.Repository, pipelineID string, environmentID string, userID string, message string) (string, error) {
+
+	files, kept, err := pipelineFiles(repo, pipelineID, environmentID)
+	if err != nil {
+		return "", err
+	}
+
+	hash, changed, err := CommitTree(repo, files, kept, commitAuthor(userID), message)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Commit code failed.")
+	}
+
+	if !changed {
+		return "", nil
+	}
+
+	err = commitRecord(hash, pipelineID, environmentID, userID, message, "commit")
+	if err != nil {
+		return "", err
+	}
+
+	return hash.String(), nil
+}
+
+func commitRecord(hash plumbing.Hash, pipelineID string, environmentID string, userID string, message string, action string) error {
+
+	err := database.DBConn.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.CodeGitCommits{
+		GitID:         hash.String(),
+		PipelineID:    pipelineID,
+		EnvironmentID: environmentID,
+		UserID:        userID,
+		Message:       message,
+		Action:        action,
+	}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Record code commit database error.")
+	}
+
+	return nil
+}
+
+func commitAuthor(userID string) object.Signature {
+
+	author := object.Signature{Name: "Dataplane", Email: "dataplane@localhost", When: time.Now().UTC()}
+
+	user := models.Users{}
+	database.DBConn.Select("first_name", "last_name", "email").Where("user_id = ?", userID).Limit(1).Find(&user)
+	if user.Email != "" {
+		author.Name = strings.TrimSpace(user.FirstName + " " + user.LastName)
+		author.Email = user.Email
+	}
+
+	return author
+}
+
+type codeFileRow struct {
+	FileID      string
+	FolderID    string
+	FileName    string
+	FileStore   []byte
+	External    bool
+	ObjectKey   string
+	ChecksumMD5 string
+}
+
+/*
+pipelineFiles returns the code of a pipeline by path in the repository, the same paths as the node folders on disk.
+Files unchanged since the head commit are returned as kept with their blob, only changed files are read from object storage.
+*/
+func pipelineFiles(repo *git.Repository, pipelineID string, environmentID string) (map[string][]byte, map[string]plumbing.Hash, error) {
+
+	folders, err := pipelineFolders(pipelineID, environmentID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := []codeFileRow{}
+	err = database.DBConn.Raw(`
+	select
+	f.file_id,
+	f.folder_id,
+	f.file_name,
+	s.file_store,
+	coalesce(s.external, false) as external,
+	coalesce(s.object_key, '') as object_key,
+	coalesce(s.checksum_md5, '') as checksum_md5
+	from code_files f
+	left join code_files_store s on s.file_id = f.file_id and s.environment_id = f.environment_id
+	where f.pipeline_id = ? and f.environment_id = ?
+	`, pipelineID, environmentID).Scan(&rows).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, nil, errors.New("Retrieve pipeline files database error.")
+	}
+
+	paths := map[string]codeFileRow{}
+	checksums := map[string]string{}
+	for _, r := range rows {
+
+		folderPath, ok := folderRepoPath(r.FolderID, folders)
+		if !ok {
+			continue
+		}
+
+		paths[folderPath+r.FileName] = r
+		checksums[folderPath+r.FileName] = r.ChecksumMD5
+	}
+
+	kept, err := HeadUnchanged(repo, checksums)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, nil, errors.New("Read code repository failed.")
+	}
+
+	files := map[string][]byte{}
+	for path, r := range paths {
+
+		if _, ok := kept[path]; ok {
+			continue
+		}
+
+		content, err := objectstorage.Content(r.FileStore, r.External, r.ObjectKey)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files[path] = content
+	}
+
+	return files, kept, nil
+}
+
+func pipelineFolders(pipelineID string, environmentID string) (map[string]models.CodeFolders, error) {
+
+	rows := []models.CodeFolders{}
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&rows).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve pipeline folders database error.")
+	}
+
+	folders := map[string]models.CodeFolders{}
+	for _, f := range rows {
+		folders[f.FolderID] = f
+	}
+
+	return folders, nil
+}
+
+// Path of a folder from its node folder with a trailing /, false for folders outside a node
+func folderRepoPath(folderID string, folders map[string]models.CodeFolders) (string, bool) {
+
+	path := ""
+
+	// The depth limit guards against a broken parent chain
+	for i := 0; i < 100; i++ {
+
+		folder, ok := folders[folderID]
+		if !ok {
+			return "", false
+		}
+
+		if folder.Level == "node" {
+			return folder.FolderID + "_" + folder.FolderName + "/" + path, true
+		}
+
+		if folder.FType != "node-folder" {
+			return "", false
+		}
+
+		path = folder.FolderName + "/" + path
+		folderID = folder.ParentID
+	}
+
+	return "", false
+}
+
+/*
+FilePath returns the path of a file in its pipeline's repository.
+*/
+func FilePath(fileID string, environmentID string) (string, string, error) {
+
+	file := models.CodeFiles{}
+	err := database.DBConn.Where("file_id = ? and environment_id = ?", fileID, environmentID).First(&file).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", "", errors.New("File not found.")
+	}
+
+	folders, err := pipelineFolders(file.PipelineID, environmentID)
+	if err != nil {
+		return "", "", err
+	}
+
+	folderPath, ok := folderRepoPath(file.FolderID, folders)
+	if !ok {
+		return "", "", errors.New("File is not in a node folder.")
+	}
+
+	return file.PipelineID, folderPath + file.FileName, nil
+}
+
+/*
+Commits returns the commits of a pipeline's code that changed a path, newest first. An empty path is all commits.
+*/
+func Commits(pipelineID string, environmentID string, path string, limit int) ([]CommitInfo, error) {
+
+	lock := repoLock(pipelineID, environmentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	out := []CommitInfo{}
+
+	repo, err := RepoOpen(RepoPath(pipelineID, environmentID))
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return out, errors.New("Open code repository failed.")
+	}
+
+	commits, err := History(repo, path, limit)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return out, errors.New("Retrieve code history failed.")
+	}
+
+	hashes := []string{}
+	for _, c := range commits {
+		hashes = append(hashes, c.Hash.String())
+	}
+
+	records := []models.CodeGitCommits{}
+	if len(hashes) > 0 {
+		err = database.DBConn.Where("pipeline_id = ? and git_id in ?", pipelineID, hashes).Find(&records).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return out, errors.New("Retrieve code commits database error.")
+		}
+	}
+
+	byHash := map[string]models.CodeGitCommits{}
+	for _, r := range records {
+		byHash[r.GitID] = r
+	}
+
+	for _, c := range commits {
+
+		// Commits pulled from a remote were not made in Dataplane
+		record, ok := byHash[c.Hash.String()]
+		if !ok {
+			record.Action = "remote"
+		}
+
+		out = append(out, CommitInfo{
+			Hash:        c.Hash.String(),
+			Message:     strings.TrimSpace(c.Message),
+			AuthorName:  c.Author.Name,
+			AuthorEmail: c.Author.Email,
+			UserID:      record.UserID,
+			Action:      record.Action,
+			CreatedAt:   c.Author.When,
+		})
+	}
+
+	return out, nil
+}
+
+/*
+FileBlame returns who last changed each line of a file as of a commit, an empty hash is the latest commit.
+*/
+func FileBlame(pipelineID string, environmentID string, path string, commitHash string) ([]BlameLine, error) {
+
+	lock := repoLock(pipelineID, environmentID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	repo, err := RepoOpen(RepoPath(pipelineID, environmentID))
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Open code repository failed.")
+	}
+
+	hash := plumbing.ZeroHash
+	if commitHash != "" {
+		if !plumbing.IsHash(commitHash) {
+			return nil, errors.New("Commit hash invalid.")
+		}
+		hash = plumbing.NewHash(commitHash)
+	}
+
+	return Blame(repo, hash, path)
+}
+
+/*
+RemoteUpdate sets the remote a pipeline's code is pulled from and pushed to, an empty URL removes it.
+A nil password keeps the password already set.
+*/
+func RemoteUpdate(pipelineID string, environmentID string, remoteURL string, branch string, username string, password *string) error {
+
+	if strings.TrimSpace(remoteURL) == "" {
+		err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Delete(&models.CodeGitRemotes{}).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Remove git remote database error.")
+		}
+		return nil
+	}
+
+	err := ValidateRemoteURL(remoteURL, dpconfig.GitAllowFileRemote == "true")
+	if err != nil {
+		return err
+	}
+
+	if branch == "" || !plumbing.NewBranchReferenceName(branch).IsBranch() || strings.ContainsAny(branch, " ~^:?*[\\") || strings.Contains(branch, "..") {
+		return errors.New("Branch name invalid.")
+	}
+
+	remote := models.CodeGitRemotes{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Limit(1).Find(&remote).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Retrieve git remote database error.")
+	}
+
+	remote.PipelineID = pipelineID
+	remote.EnvironmentID = environmentID
+	remote.RemoteURL = remoteURL
+	remote.Branch = branch
+	remote.Username = username
+
+	if password != nil {
+		remote.Password = ""
+		if *password != "" {
+			remote.Password, err = utilities.Encrypt(*password)
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				return errors.New("Encrypt git password failed.")
+			}
+		}
+	}
+
+	err = database.DBConn.Clauses(clause.OnConflict{UpdateAll: true}).Create(&remote).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Update git remote database error.")
+	}
+
+	return nil
+}
+
+func remoteGet(pipelineID string, environmentID string) (models.CodeGitRemotes, transport.AuthMethod, error) {
+
+	remote := models.CodeGitRemotes{}
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Limit(1).Find(&remote).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return remote, nil, errors.New("Retrieve git remote database error.")
+	}
+
+	if remote.RemoteURL == "" {
+		return remote, nil, errors.New("No git remote set for this pipeline.")
+	}
+
+	// Checked again as the setting can change after the remote was set
+	err = ValidateRemoteURL(remote.RemoteURL, dpconfig.GitAllowFileRemote == "true")
+	if err != nil {
+		return remote, nil, err
+	}
+
+	if remote.Username == "" && remote.Password == "" {
+		return remote, nil, nil
+	}
+
+	password := ""
+	if remote.Password != "" {
+		password, err = utilities.Decrypt(remote.Password)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return remote, nil, errors.New("Decrypt git password failed.")
+		}
+	}
+
+	return remote, &http.BasicAuth{Username: remote.Username, Password: password}, nil
+}
+
+/*
+Pull brings commits from the pipeline's remote into its code folders. Saved changes are committed first,
+only fast-forwards are pulled. Every top level folder in the remote must be the folder of a node of the pipeline.
+*/
+func Pull(pipelineID string, environmentID string, userID string) (PullPlan, error) {
+
+	remote, auth, err := remoteGet(pipelineID, environmentID)
+	if err != nil {
+		return PullPlan{}, err
+	}
+
+	unlock, err := repoWriteLock(pipelineID, environmentID)
+	if err != nil {
+		return PullPlan{}, err
+	}
+	defer unlock()
+
+	repo, err := RepoOpen(RepoPath(pipelineID, environmentID))
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return PullPlan{}, errors.New("Open code repository failed.")
+	}
+
+	_, err = commitSaved(repo, pipelineID, environmentID, userID, "Save before pull")
+	if err != nil {
+		return PullPlan{}, err
+	}
+
+	plan, err := RemotePlan(repo, remote.RemoteURL, remote.Branch, auth)
+	if err != nil {
+		return plan, err
+	}
+
+	if plan.Status == "fast-forward" {
+
+		folders, err := pipelineFolders(pipelineID, environmentID)
+		if err != nil {
+			return plan, err
+		}
+
+		nodeFolders := map[string]models.CodeFolders{}
+		for _, f := range folders {
+			if f.Level == "node" {
+				nodeFolders[f.FolderID] = f
+			}
+		}
+
+		// Checked before anything is written
+		targets := make([]pullTarget, len(plan.Changes))
+		for i, c := range plan.Changes {
+			targets[i], err = pullTargetGet(c.Path, nodeFolders)
+			if err != nil {
+				return plan, err
+			}
+		}
+
+		applyErr := error(nil)
+		for i, c := range plan.Changes {
+
+			if c.Action == "delete" {
+				applyErr = nodeFileDelete(pipelineID, environmentID, userID, targets[i])
+			} else {
+				var file models.CodeFiles
+				file, applyErr = filesystem.NodeFileWrite(pipelineID, environmentID, targets[i].nodeID, targets[i].path, c.Content)
+				if applyErr == nil {
+					applyErr = filesystem.FileRevisionCreate(file, c.Content, userID, "pull")
+				}
+			}
+
+			if applyErr != nil {
+				applyErr = errors.New("Pull stopped at " + c.Path + ": " + applyErr.Error())
+				break
+			}
+		}
+
+		err = RepoMoveHead(repo, plan.To)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return plan, errors.New("Update code repository failed.")
+		}
+
+		err = commitRecord(plan.To, pipelineID, environmentID, userID, "Pull from "+remote.Branch, "pull")
+		if err != nil {
+			return plan, err
+		}
+
+		// What was written is committed on top so that the history matches the code folders
+		if applyErr != nil {
+			commitSaved(repo, pipelineID, environmentID, userID, "Pull partially applied")
+			return plan, applyErr
+		}
+	}
+
+	err = database.DBConn.Model(&models.CodeGitRemotes{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Update("last_pull_at", time.Now().UTC()).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+	}
+
+	return plan, nil
+}
+
+/*
+Push commits saved changes and pushes the pipeline's code to its remote.
+*/
+func Push(pipelineID string, environmentID string, userID string) error {
+
+	remote, auth, err := remoteGet(pipelineID, environmentID)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := repoWriteLock(pipelineID, environmentID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	repo, err := RepoOpen(RepoPath(pipelineID, environmentID))
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Open code repository failed.")
+	}
+
+	_, err = commitSaved(repo, pipelineID, environmentID, userID, "Save before push")
+	if err != nil {
+		return err
+	}
+
+	head, err := RepoHead(repo)
+	if err != nil || head.IsZero() {
+		return errors.New("No code to push.")
+	}
+
+	err = RemotePush(repo, remote.RemoteURL, remote.Branch, auth)
+	if err != nil {
+		return err
+	}
+
+	err = database.DBConn.Model(&models.CodeGitRemotes{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Update("last_push_at", time.Now().UTC()).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+	}
+
+	return nil
+}
+
+type pullTarget struct {
+	nodeID       string
+	nodeFolderID string
+	path         string // from the node folder
+}
+
+// The top level folder is the node folder, matched on its ID so that renamed nodes still match
+func pullTargetGet(path string, nodeFolders map[string]models.CodeFolders) (pullTarget, error) {
+
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 {
+		return pullTarget{}, errors.New("Pull rejected, " + path + " is not in a node folder.")
+	}
+
+	folder, ok := nodeFolders[strings.SplitN(parts[0], "_", 2)[0]]
+	if !ok {
+		return pullTarget{}, errors.New("Pull rejected, " + parts[0] + " is not a node folder of this pipeline.")
+	}
+
+	for _, part := range parts[1 : len(parts)-1] {
+		if part == "" || filesystem.FolderFriendly(part) != part {
+			return pullTarget{}, errors.New("Pull rejected, folder names can only have letters, numbers and _: " + path)
+		}
+	}
+
+	fileName := parts[len(parts)-1]
+	if fileName == "" || fileName == "." || fileName == ".." {
+		return pullTarget{}, errors.New("Pull rejected, file name invalid: " + path)
+	}
+
+	return pullTarget{nodeID: folder.NodeID, nodeFolderID: folder.FolderID, path: strings.Join(parts[1:], "/")}, nil
+}
+
+// Deletes a file that was deleted on the remote, as the editor does so that it can be undeleted
+func nodeFileDelete(pipelineID string, environmentID string, userID string, target pullTarget) error {
+
+	parts := strings.Split(target.path, "/")
+	folderID := target.nodeFolderID
+
+	for _, part := range parts[:len(parts)-1] {
+
+		folder := models.CodeFolders{}
+		err := database.DBConn.Where("parent_id = ? and environment_id = ? and node_id = ? and folder_name = ?", folderID, environmentID, target.nodeID, part).Limit(1).Find(&folder).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve code folder database error.")
+		}
+
+		// Already gone
+		if folder.FolderID == "" {
+			return nil
+		}
+		folderID = folder.FolderID
+	}
+
+	file := models.CodeFiles{}
+	err := database.DBConn.Where("folder_id = ? and environment_id = ? and node_id = ? and file_name = ?", folderID, environmentID, target.nodeID, parts[len(parts)-1]).Limit(1).Find(&file).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Retrieve code file database error.")
+	}
+
+	if file.FileID == "" {
+		return nil
+	}
+
+	folderPath, err := filesystem.FolderConstructByID(database.DBConn, folderID, environmentID, "pipelines")
+	if err != nil {
+		return errors.New("Build folder path failed.")
+	}
+
+	err = database.DBConn.Create(&models.FolderDeleted{
+		ID:            uuid.NewString(),
+		FileID:        file.FileID,
+		FolderID:      folderID,
+		FileName:      file.FileName,
+		EnvironmentID: environmentID,
+		PipelineID:    pipelineID,
+		NodeID:        target.nodeID,
+		FType:         "file",
+		Path:          folderPath + file.FileName,
+		UserID:        userID,
+	}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Failed to create backup trash record in database.")
+	}
+
+	err = filesystem.FileRevisionSnapshot(file, userID)
+	if err != nil {
+		return err
+	}
+
+	if dpconfig.FSCodeFileStorage == "LocalFile" {
+		err = os.Remove(dpconfig.CodeDirectory + folderPath + file.FileName)
+		if err != nil && !os.IsNotExist(err) {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Failed to delete file in directory.")
+		}
+	}
+
+	err = database.DBConn.Where("file_id = ? and environment_id = ?", file.FileID, environmentID).Delete(&models.CodeFiles{}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Delete file database error.")
+	}
+
+	err = database.DBConn.Where("file_id = ? and environment_id = ?", file.FileID, environmentID).Delete(&models.CodeFilesStore{}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return errors.New("Delete file store in database error.")
+	}
+
+	err = dfscache.InvalidateCacheNode(target.nodeID, environmentID, folderPath+file.FileName)
+	if err != nil {
+		log.Println("Pull delete file invalidate file cache", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package gitsync
+
+import (
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestFolderRepoPath$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestFolderRepoPath(t *testing.T) {
+
+	folders := map[string]models.CodeFolders{
+		"p1": {FolderID: "p1", FolderName: "Pipeline", Level: "pipeline", FType: "folder"},
+		"n1": {FolderID: "n1", ParentID: "p1", FolderName: "Load", Level: "node", FType: "folder"},
+		"s1": {FolderID: "s1", ParentID: "n1", FolderName: "lib", Level: "a", FType: "node-folder"},
+		"s2": {FolderID: "s2", ParentID: "s1", FolderName: "sql", Level: "b", FType: "node-folder"},
+		"s3": {FolderID: "s3", ParentID: "missing", FolderName: "x", Level: "c", FType: "node-folder"},
+	}
+
+	path, ok := folderRepoPath("n1", folders)
+	assert.True(t, ok)
+	assert.Equal(t, "n1_Load/", path)
+
+	path, ok = folderRepoPath("s2", folders)
+	assert.True(t, ok)
+	assert.Equal(t, "n1_Load/lib/sql/", path)
+
+	_, ok = folderRepoPath("p1", folders)
+	assert.False(t, ok)
+
+	_, ok = folderRepoPath("s3", folders)
+	assert.False(t, ok)
+}
+
+/*
+go test -timeout 30s -v -run ^TestPullTargetGet$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync
+*/
+func TestPullTargetGet(t *testing.T) {
+
+	nodeFolders := map[string]models.CodeFolders{
+		"n1": {FolderID: "n1", NodeID: "node1", FolderName: "Load", Level: "node"},
+	}
+
+	target, err := pullTargetGet("n1_Load/lib/helpers.py", nodeFolders)
+	assert.NoError(t, err)
+	assert.Equal(t, pullTarget{nodeID: "node1", nodeFolderID: "n1", path: "lib/helpers.py"}, target)
+
+	// The node was renamed since
+	target, err = pullTargetGet("n1_Extract/dp-entrypoint.py", nodeFolders)
+	assert.NoError(t, err)
+	assert.Equal(t, "dp-entrypoint.py", target.path)
+
+	for _, path := range []string{
+		"README.md",
+		"n2_Other/a.py",
+		"n1_Load/my lib/a.py",
+		"n1_Load/../a.py",
+		"n1_Load/lib/..",
+	} {
+		_, err = pullTargetGet(path, nodeFolders)
+		assert.Error(t, err, path)
+	}
+}
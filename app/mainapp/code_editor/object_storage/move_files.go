@@ -0,0 +1,107 @@
+package objectstorage
+
+import (
+	"log"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"gorm.io/gorm"
+)
+
+/*
+MoveFilesToObjectStorage uploads the code files, their revisions and deployment files still kept in the database, when a platform
+changes to S3 storage. Rows are moved a batch at a time and a row that fails stays in the database, where it is still read from.
+*/
+func MoveFilesToObjectStorage(db *gorm.DB) error {
+
+	moved := 0
+
+	last := ""
+	for {
+		stores := []models.CodeFilesStore{}
+		err := db.Where("external = false and file_id > ?", last).Order("file_id").Limit(100).Find(&stores).Error
+		if err != nil {
+			return err
+		}
+
+		if len(stores) == 0 {
+			break
+		}
+
+		for _, s := range stores {
+			last = s.FileID
+
+			key, err := Put(s.FileStore)
+			if err == nil {
+				err = db.Model(&models.CodeFilesStore{}).Where("file_id = ? and environment_id = ?", s.FileID, s.EnvironmentID).
+					Updates(map[string]interface{}{"file_store": nil, "external": true, "object_key": key}).Error
+			}
+			if err != nil {
+				log.Println("Move code file to object storage:", s.FileID, err)
+				continue
+			}
+			moved++
+		}
+	}
+
+	last = ""
+	for {
+		revisions := []models.CodeFileRevisions{}
+		err := db.Where("external = false and revision_id > ?", last).Order("revision_id").Limit(100).Find(&revisions).Error
+		if err != nil {
+			return err
+		}
+
+		if len(revisions) == 0 {
+			break
+		}
+
+		for _, r := range revisions {
+			last = r.RevisionID
+
+			key, err := Put(r.FileStore)
+			if err == nil {
+				err = db.Model(&models.CodeFileRevisions{}).Where("revision_id = ?", r.RevisionID).
+					Updates(map[string]interface{}{"file_store": nil, "external": true, "object_key": key, "size": len(r.FileStore)}).Error
+			}
+			if err != nil {
+				log.Println("Move code file revision to object storage:", r.RevisionID, err)
+				continue
+			}
+			moved++
+		}
+	}
+
+	lastFile, lastVersion := "", ""
+	for {
+		stores := []models.DeployFilesStore{}
+		err := db.Where("external = false and (file_id > ? or (file_id = ? and version > ?))", lastFile, lastFile, lastVersion).Order("file_id, version").Limit(100).Find(&stores).Error
+		if err != nil {
+			return err
+		}
+
+		if len(stores) == 0 {
+			break
+		}
+
+		for _, s := range stores {
+			lastFile, lastVersion = s.FileID, s.Version
+
+			key, err := Put(s.FileStore)
+			if err == nil {
+				err = db.Model(&models.DeployFilesStore{}).Where("file_id = ? and version = ? and environment_id = ?", s.FileID, s.Version, s.EnvironmentID).
+					Updates(map[string]interface{}{"file_store": nil, "external": true, "object_key": key}).Error
+			}
+			if err != nil {
+				log.Println("Move deployment file to object storage:", s.FileID, s.Version, err)
+				continue
+			}
+			moved++
+		}
+	}
+
+	if moved > 0 {
+		log.Println("Files moved to object storage:", moved)
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package objectstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"log"
+	"strings"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+/*
+Code files are stored in S3 compatible storage, such as AWS S3 or MinIO, when DP_CODE_FILE_STORAGE is S3.
+Objects are keyed <prefix>/sha256/<checksum> by their content, so the same file across pipelines and
+deployment versions is stored once. The store tables then keep the key with External set instead of the bytes.
+*/
+var Client *minio.Client
+
+/* Connect creates the client and the bucket if it doesn't exist. */
+func Connect() error {
+
+	if dpconfig.FSCodeS3Endpoint == "" || dpconfig.FSCodeS3Bucket == "" {
+		return errors.New("S3 code file storage requires DP_CODE_FILE_S3_ENDPOINT and DP_CODE_FILE_S3_BUCKET")
+	}
+
+	client, err := minio.New(dpconfig.FSCodeS3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(dpconfig.FSCodeS3AccessKey, dpconfig.FSCodeS3SecretKey, ""),
+		Secure: dpconfig.FSCodeS3SSL,
+		Region: dpconfig.FSCodeS3Region,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, dpconfig.FSCodeS3Bucket)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		err = client.MakeBucket(ctx, dpconfig.FSCodeS3Bucket, minio.MakeBucketOptions{Region: dpconfig.FSCodeS3Region})
+		if err != nil {
+			return err
+		}
+		log.Println("🪣 Code file bucket created:", dpconfig.FSCodeS3Bucket)
+	}
+
+	Client = client
+
+	return nil
+}
+
+/* ObjectKey is the key of content in the bucket. */
+func ObjectKey(prefix string, content []byte) string {
+
+	hash := sha256.Sum256(content)
+
+	return strings.TrimSuffix(prefix, "/") + "/sha256/" + hex.EncodeToString(hash[:])
+}
+
+/* ObjectKeyValid checks that a key asked for by a worker is a content key under the prefix. */
+func ObjectKeyValid(prefix string, key string) bool {
+
+	checksum := strings.TrimPrefix(key, strings.TrimSuffix(prefix, "/")+"/sha256/")
+	if checksum == key || len(checksum) != sha256.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(checksum)
+
+	return err == nil && strings.ToLower(checksum) == checksum
+}
+
+/* Put uploads content unless an object with its key is already there and returns the key. */
+func Put(content []byte) (string, error) {
+
+	if Client == nil {
+		return "", errors.New("Object storage not connected.")
+	}
+
+	key := ObjectKey(dpconfig.FSCodeS3Prefix, content)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	_, err := Client.StatObject(ctx, dpconfig.FSCodeS3Bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return key, nil
+	}
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Object storage error.")
+	}
+
+	_, err = Client.PutObject(ctx, dpconfig.FSCodeS3Bucket, key, bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Object storage upload error.")
+	}
+
+	return key, nil
+}
+
+/* Get downloads the content of a key. */
+func Get(key string) ([]byte, error) {
+
+	if Client == nil {
+		return nil, errors.New("Object storage not connected.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	object, err := Client.GetObject(ctx, dpconfig.FSCodeS3Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Object storage download error.")
+	}
+	defer object.Close()
+
+	content, err := ioutil.ReadAll(object)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Object storage download error.")
+	}
+
+	return content, nil
+}
+
+/* Presign returns a URL to download a key without credentials, valid for DP_CODE_FILE_S3_PRESIGN_SECONDS. */
+func Presign(key string) (string, error) {
+
+	if Client == nil {
+		return "", errors.New("Object storage not connected.")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	u, err := Client.PresignedGetObject(ctx, dpconfig.FSCodeS3Bucket, key, time.Duration(dpconfig.FSCodeS3PresignSeconds)*time.Second, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return u.String(), nil
+}
+
+/*
+Store is used wherever a code file store row is written. With S3 storage the content is uploaded
+and returned as a key with no bytes, otherwise the bytes are kept in the database as before.
+*/
+func Store(content []byte) (fileStore []byte, external bool, objectKey string, err error) {
+
+	if dpconfig.FSCodeFileStorage != "S3" {
+		return content, false, "", nil
+	}
+
+	objectKey, err = Put(content)
+	if err != nil {
+		return nil, false, "", err
+	}
+
+	return nil, true, objectKey, nil
+}
+
+/* Content returns the content of a code file store row, wherever it is kept. */
+func Content(fileStore []byte, external bool, objectKey string) ([]byte, error) {
+
+	if !external {
+		return fileStore, nil
+	}
+
+	return Get(objectKey)
+}
@@ -0,0 +1,146 @@
+package objectstorage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestObjectKey$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage
+*/
+func TestObjectKey(t *testing.T) {
+
+	key := ObjectKey("dataplane-code/", []byte("print('hello')\n"))
+	assert.True(t, strings.HasPrefix(key, "dataplane-code/sha256/"))
+	assert.Equal(t, key, ObjectKey("dataplane-code", []byte("print('hello')\n")))
+	assert.NotEqual(t, key, ObjectKey("dataplane-code", []byte("print('hello')")))
+
+	assert.True(t, ObjectKeyValid("dataplane-code", key))
+	assert.False(t, ObjectKeyValid("other", key))
+	assert.False(t, ObjectKeyValid("dataplane-code", "dataplane-code/sha256/../secret"))
+	assert.False(t, ObjectKeyValid("dataplane-code", strings.ToUpper(key)))
+	assert.False(t, ObjectKeyValid("dataplane-code", key[:len(key)-1]))
+	assert.False(t, ObjectKeyValid("dataplane-code", "dataplane-code/"+key[len(key)-64:]))
+}
+
+/*
+go test -timeout 30s -v -run ^TestStore$ github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage
+*/
+func TestStore(t *testing.T) {
+
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	puts := 0
+
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Bucket requests
+		if strings.Count(strings.Trim(r.URL.Path, "/"), "/") == 0 {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Content-Sha256") == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
+				body = awsChunkedDecode(body)
+			}
+			objects[r.URL.Path] = body
+			puts++
+			w.Header().Set("ETag", `"etag"`)
+			w.WriteHeader(http.StatusOK)
+		case http.MethodHead, http.MethodGet:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("ETag", `"etag"`)
+			w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				w.Write(body)
+			}
+		}
+	}))
+	defer s3.Close()
+
+	dpconfig.FSCodeFileStorage = "S3"
+	dpconfig.FSCodeS3Endpoint = strings.TrimPrefix(s3.URL, "http://")
+	dpconfig.FSCodeS3SSL = false
+	dpconfig.FSCodeS3Region = "us-east-1"
+	dpconfig.FSCodeS3Bucket = "code"
+	dpconfig.FSCodeS3Prefix = "dataplane-code"
+	dpconfig.FSCodeS3PresignSeconds = 60
+	dpconfig.FSCodeS3AccessKey = "access"
+	dpconfig.FSCodeS3SecretKey = "secret"
+	defer func() {
+		dpconfig.FSCodeFileStorage = "Database"
+		Client = nil
+	}()
+
+	assert.NoError(t, Connect())
+
+	content := []byte("import pandas\n")
+
+	fileStore, external, key, err := Store(content)
+	assert.NoError(t, err)
+	assert.Nil(t, fileStore)
+	assert.True(t, external)
+	assert.Equal(t, ObjectKey("dataplane-code", content), key)
+
+	// The same content is stored once
+	_, _, key2, err := Store(content)
+	assert.NoError(t, err)
+	assert.Equal(t, key, key2)
+	assert.Equal(t, 1, puts)
+
+	got, err := Content(nil, true, key)
+	assert.NoError(t, err)
+	assert.Equal(t, content, got)
+
+	u, err := Presign(key)
+	assert.NoError(t, err)
+	assert.Contains(t, u, "/code/"+key)
+	assert.Contains(t, u, "X-Amz-Signature=")
+
+	// Database storage keeps the bytes
+	dpconfig.FSCodeFileStorage = "Database"
+	fileStore, external, key, err = Store(content)
+	assert.NoError(t, err)
+	assert.Equal(t, content, fileStore)
+	assert.False(t, external)
+	assert.Equal(t, "", key)
+}
+
+/* Signed uploads over http are sent as <size hex>;chunk-signature=<sig>\r\n<data>\r\n chunks */
+func awsChunkedDecode(body []byte) []byte {
+
+	out := []byte{}
+	for {
+		i := bytes.Index(body, []byte("\r\n"))
+		if i < 0 {
+			return out
+		}
+		size, err := strconv.ParseInt(strings.SplitN(string(body[:i]), ";", 2)[0], 16, 64)
+		if err != nil || size == 0 {
+			return out
+		}
+		body = body[i+2:]
+		out = append(out, body[:size]...)
+		body = body[size+2:]
+	}
+}
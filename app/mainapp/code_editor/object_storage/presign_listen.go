@@ -0,0 +1,52 @@
+package objectstorage
+
+import (
+	"log"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+)
+
+/*
+Workers ask for presigned URLs of the code files they need and download them straight from
+object storage, so that neither the database nor the main app carries the content and workers need no credentials.
+*/
+func PresignListen() {
+
+	_, err := messageq.NATSencoded.QueueSubscribe("code-file-presign", "codefilepresign", func(subj, reply string, msg models.CodeFilesPresign) {
+
+		x := models.CodeFilesPresignResponse{R: "ok", M: "ok", URLs: map[string]string{}}
+
+		for _, key := range msg.ObjectKeys {
+
+			if !ObjectKeyValid(dpconfig.FSCodeS3Prefix, key) {
+				x = models.CodeFilesPresignResponse{R: "fail", M: "Invalid object key: " + key}
+				break
+			}
+
+			u, err := Presign(key)
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				x = models.CodeFilesPresignResponse{R: "fail", M: "Presign failed: " + key}
+				break
+			}
+			x.URLs[key] = u
+		}
+
+		err := messageq.NATSencoded.Publish(reply, x)
+		if err != nil {
+			log.Println(err.Error())
+		}
+	})
+
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+	}
+
+}
@@ -13,9 +13,30 @@ var PlatformID string
 var MainAppID string = ""
 var Leader string = ""
 
+/* Leader election backend - redis or postgres */
+var LeaderElection string = "redis"
+
 /* Routine removal of stale data */
 var CleanTasks int = 30
 var CleanLogs int = 30
+var CleanEvents int = 30
+
+/* Code file revisions - the latest revision of a file is always kept */
+var CleanRevisions int = 90
+var CleanRevisionsMax int = 50
+
+/* Task watchdog - seconds before a task on a worker that stopped heartbeating or past its timeout is failed */
+var WorkerLostSeconds int = 30
+
+/* Schedule catch up - most missed fire times run per schedule when schedules load */
+var ScheduleCatchUpMax int = 10
+
+/* Notifications - SMTP server for email channels */
+var SMTPHost string
+var SMTPPort string = "587"
+var SMTPUser string
+var SMTPPassword string
+var SMTPFrom string
 
 // Scheduler
 var PipelineScheduler = cmap.New()
@@ -39,6 +60,20 @@ var FSCodeFileStorage string
 var FSCodeFileBatches int
 var FSCodeDirectory string
 
+/* Object storage for code files when DP_CODE_FILE_STORAGE is S3 */
+var FSCodeS3Endpoint string
+var FSCodeS3SSL bool
+var FSCodeS3Region string
+var FSCodeS3Bucket string
+var FSCodeS3Prefix string
+var FSCodeS3AccessKey string
+var FSCodeS3SecretKey string
+var FSCodeS3PresignSeconds int
+
+// Git repositories of pipeline code
+var GitDirectory string
+var GitAllowFileRemote string = "false"
+
 // Redis
 var DPRedisHost string
 var DPRedisPort string
@@ -95,6 +130,48 @@ func LoadConfig() {
 		CleanLogs = 30
 	}
 
+	CleanEvents, _ = strconv.Atoi(os.Getenv("DP_CLEANEVENTS_DAYS"))
+	if CleanEvents == 0 {
+		CleanEvents = 30
+	}
+
+	CleanRevisions, _ = strconv.Atoi(os.Getenv("DP_CLEANREVISIONS_DAYS"))
+	if CleanRevisions == 0 {
+		CleanRevisions = 90
+	}
+
+	CleanRevisionsMax, _ = strconv.Atoi(os.Getenv("DP_CLEANREVISIONS_MAX"))
+	if CleanRevisionsMax == 0 {
+		CleanRevisionsMax = 50
+	}
+
+	WorkerLostSeconds, _ = strconv.Atoi(os.Getenv("DP_WORKER_LOST_SECONDS"))
+	if WorkerLostSeconds == 0 {
+		WorkerLostSeconds = 30
+	}
+
+	ScheduleCatchUpMax, _ = strconv.Atoi(os.Getenv("DP_SCHEDULE_CATCHUP_MAX"))
+	if ScheduleCatchUpMax == 0 {
+		ScheduleCatchUpMax = 10
+	}
+
+	LeaderElection = os.Getenv("DP_LEADER_ELECTION")
+	if LeaderElection != "postgres" {
+		LeaderElection = "redis"
+	}
+
+	SMTPHost = os.Getenv("DP_SMTP_HOST")
+	SMTPPort = os.Getenv("DP_SMTP_PORT")
+	if SMTPPort == "" {
+		SMTPPort = "587"
+	}
+	SMTPUser = os.Getenv("DP_SMTP_USER")
+	SMTPPassword = os.Getenv("DP_SMTP_PASSWORD")
+	SMTPFrom = os.Getenv("DP_SMTP_FROM")
+	if SMTPFrom == "" {
+		SMTPFrom = SMTPUser
+	}
+
 	Debug = os.Getenv("DP_DEBUG")
 	if Debug == "" {
 		Debug = "false"
@@ -138,4 +215,35 @@ func LoadConfig() {
 		FSCodeDirectory = "/appdev/dfs-code-files/"
 	}
 
+	FSCodeS3Endpoint = os.Getenv("DP_CODE_FILE_S3_ENDPOINT")
+	FSCodeS3SSL = os.Getenv("DP_CODE_FILE_S3_SSL") != "false"
+	FSCodeS3Region = os.Getenv("DP_CODE_FILE_S3_REGION")
+	if FSCodeS3Region == "" {
+		FSCodeS3Region = "us-east-1"
+	}
+	FSCodeS3Bucket = os.Getenv("DP_CODE_FILE_S3_BUCKET")
+	FSCodeS3Prefix = os.Getenv("DP_CODE_FILE_S3_PREFIX")
+	if FSCodeS3Prefix == "" {
+		FSCodeS3Prefix = "dataplane-code"
+	}
+	FSCodeS3AccessKey = os.Getenv("DP_CODE_FILE_S3_ACCESS_KEY")
+	FSCodeS3SecretKey = os.Getenv("secret_code_file_s3_secret_key")
+
+	// Workers download with presigned URLs that are valid for this long
+	FSCodeS3PresignSeconds, _ = strconv.Atoi(os.Getenv("DP_CODE_FILE_S3_PRESIGN_SECONDS"))
+	if FSCodeS3PresignSeconds == 0 {
+		FSCodeS3PresignSeconds = 900
+	}
+
+	GitDirectory = os.Getenv("DP_GIT_FOLDER")
+	if GitDirectory == "" {
+		GitDirectory = "/appdev/git-repos/"
+	}
+
+	// Local repositories as remotes, for testing
+	GitAllowFileRemote = os.Getenv("DP_GIT_ALLOW_FILE_REMOTES")
+	if GitAllowFileRemote == "" {
+		GitAllowFileRemote = "false"
+	}
+
 }
@@ -27,7 +27,7 @@ import (
 
 func Migrate() {
 
-	migrateVersion := "0.0.70"
+	migrateVersion := "0.0.94"
 
 	connectURL := fmt.Sprintf(
 		"postgres://%s:%s@%s:%s/%s?sslmode=%s",
@@ -105,9 +105,18 @@ func Migrate() {
 			&models.LogsCodeRun{},
 			&models.WorkerTasks{},
 			&models.WorkerTaskLock{},
+			&models.WorkerTaskOutputs{},
 			&models.PlatformLeader{},
 			&models.Scheduler{},
 			&models.SchedulerLock{},
+			&models.SchedulerLastFire{},
+			&models.SchedulerHistory{},
+			&models.EventTriggers{},
+			&models.EventTriggerEvents{},
+			&models.NotificationChannels{},
+			&models.NotificationRules{},
+			&models.NotificationDeliveries{},
+			&models.ApprovalRequests{},
 			&models.RemoteProcessGroups{},
 			&models.RemoteWorkerEnvironments{},
 			&models.RemoteWorkers{},
@@ -120,7 +129,9 @@ func Migrate() {
 			&models.CodeFolders{},
 			&models.CodeFiles{},
 			&models.CodeGitCommits{},
+			&models.CodeGitRemotes{},
 			&models.FolderDeleted{},
+			&models.CodeFileRevisions{},
 			&models.CodeRun{},
 			&models.CodeRunLock{},
 			&models.CodePackages{},
@@ -136,6 +147,7 @@ func Migrate() {
 			&models.DeployCodeNodeCache{},
 			&models.DeploymentApiTriggers{},
 			&models.DeploymentApiTriggerRuns{},
+			&models.DeploymentPromotions{},
 			&models.DeploymentApiKeys{},
 
 			// &models.Test{},
@@ -194,6 +206,18 @@ func Migrate() {
 			}
 		}
 
+		// Full text search of run and code run logs
+		logsearch := []string{
+			"CREATE INDEX IF NOT EXISTS idx_logs_workers_search ON logs_workers USING gin (to_tsvector('simple', log));",
+			"CREATE INDEX IF NOT EXISTS idx_logs_code_run_search ON logs_code_run USING gin (to_tsvector('simple', log));",
+		}
+
+		for _, idx := range logsearch {
+			if err := dbConn.Exec(idx).Error; err != nil {
+				panic(err)
+			}
+		}
+
 		// Create any sub folders
 		var environs []*models.Environment
 		dbConn.Find(&environs)
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+func (ApprovalRequests) IsEntity() {}
+
+func (ApprovalRequests) TableName() string {
+	return "approval_requests"
+}
+
+/*
+ApprovalRequests are the approvals asked for by approval node tasks and the record of who decided and why.
+Status: Waiting, Approved, Rejected, Cancelled.
+DecidedVia: graphql, link - a signed approval link, timeout - decided by the timeout action, stop - the run was stopped.
+*/
+type ApprovalRequests struct {
+	TaskID        string     `gorm:"PRIMARY_KEY;type:varchar(48);" json:"task_id"`
+	RunID         string     `gorm:"index:idx_approval_run;" json:"run_id"`
+	NodeID        string     `json:"node_id"`
+	PipelineID    string     `json:"pipeline_id"`
+	EnvironmentID string     `gorm:"index:idx_approval_env_status;" json:"environment_id"`
+	RunType       string     `json:"run_type"`
+	Message       string     `json:"message"`
+	Status        string     `gorm:"index:idx_approval_env_status;" json:"status"`
+	TimeoutAction string     `json:"timeout_action"`
+	ExpiresAt     *time.Time `json:"expires_at"` // nil = no timeout
+	DecidedBy     string     `json:"decided_by"` // user ID, empty when decided by the timeout or a stop
+	DecidedVia    string     `json:"decided_via"`
+	Comment       string     `json:"comment"`
+	CreatedAt     time.Time  `json:"created_at"`
+	DecidedAt     *time.Time `json:"decided_at"`
+}
@@ -23,6 +23,11 @@ type DeployPipelines struct {
 	Description       string         `json:"description"`
 	Active            bool           `json:"active"`
 	WorkerGroup       string         `json:"worker_group"`
+	TimeoutSeconds    int            `gorm:"default:0;" json:"timeout_seconds"` // default for all nodes, 0 = no timeout
+	Parameters        []RunParameter `gorm:"serializer:json;" json:"parameters"`
+	MaxConcurrentRuns int            `gorm:"default:0;" json:"max_concurrent_runs"`    // 0 = no limit
+	ConcurrencyPolicy string         `gorm:"default:queue;" json:"concurrency_policy"` // queue, skip, cancel-previous
+	PauseRuns         bool           `gorm:"default:false;" json:"pause_runs"`         // new runs are queued until unpaused
 	Meta              datatypes.JSON `json:"meta"`
 	Json              datatypes.JSON `json:"json"`
 	UpdateLock        bool           `gorm:"default:false;" json:"update_lock"`
@@ -38,21 +43,25 @@ func (DeployPipelineNodes) TableName() string {
 }
 
 type DeployPipelineNodes struct {
-	NodeID        string         `gorm:"PRIMARY_KEY;type:varchar(128);" json:"node_id"`
-	Version       string         `gorm:"PRIMARY_KEY;type:varchar(64);" json:"version"`
-	PipelineID    string         `gorm:"PRIMARY_KEY;type:varchar(64);" json:"pipeline_id"`
-	Name          string         `gorm:"type:varchar(255);" json:"name"`
-	EnvironmentID string         `gorm:"PRIMARY_KEY;" json:"environment_id"`
-	NodeType      string         `json:"node_type"`      //trigger, process, checkpoint
-	NodeTypeDesc  string         `json:"node_type_desc"` //python, bash, play, scheduler, checkpoint, api
-	TriggerOnline bool           `gorm:"default:false;" json:"trigger_online"`
-	Description   string         `json:"description"`
-	Commands      datatypes.JSON `json:"commands"`
-	Meta          datatypes.JSON `json:"meta"`
-	Dependency    datatypes.JSON `json:"dependency"`
-	Destination   datatypes.JSON `json:"destination"`
-	WorkerGroup   string         `gorm:"index:idx_dp_workergroup_nodes;" json:"worker_group"` //Inherits Pipeline workergroup unless specified
-	Active        bool           `json:"active"`
+	NodeID         string         `gorm:"PRIMARY_KEY;type:varchar(128);" json:"node_id"`
+	Version        string         `gorm:"PRIMARY_KEY;type:varchar(64);" json:"version"`
+	PipelineID     string         `gorm:"PRIMARY_KEY;type:varchar(64);" json:"pipeline_id"`
+	Name           string         `gorm:"type:varchar(255);" json:"name"`
+	EnvironmentID  string         `gorm:"PRIMARY_KEY;" json:"environment_id"`
+	NodeType       string         `json:"node_type"`      //trigger, process, checkpoint
+	NodeTypeDesc   string         `json:"node_type_desc"` //python, bash, play, scheduler, checkpoint, api, subpipeline, approval
+	TriggerOnline  bool           `gorm:"default:false;" json:"trigger_online"`
+	Description    string         `json:"description"`
+	Commands       datatypes.JSON `json:"commands"`
+	Meta           datatypes.JSON `json:"meta"`
+	Dependency     datatypes.JSON `json:"dependency"`
+	Destination    datatypes.JSON `json:"destination"`
+	WorkerGroup    string         `gorm:"index:idx_dp_workergroup_nodes;" json:"worker_group"` //Inherits Pipeline workergroup unless specified
+	Active         bool           `json:"active"`
+	RetryPolicy    RetryPolicy    `gorm:"embedded;embeddedPrefix:retry_;" json:"retry_policy"`
+	TimeoutSeconds int            `gorm:"default:0;" json:"timeout_seconds"` // 0 = use the pipeline timeout
+	SubPipeline    SubPipeline    `gorm:"embedded;embeddedPrefix:sub_;" json:"sub_pipeline"`
+	Approval       Approval       `gorm:"embedded;embeddedPrefix:approval_;" json:"approval"`
 	// FolderID       string         `json:"folder_id"`
 	// ParentFolderID string         `json:"parent_folder_id"`
 	CreatedAt time.Time  `json:"created_at"`
@@ -74,6 +83,8 @@ type DeployPipelineEdges struct {
 	To            string         `gorm:"index:idx_deployid_edge;" json:"to"`
 	EnvironmentID string         `gorm:"PRIMARY_KEY;" json:"environment_id"`
 	Meta          datatypes.JSON `json:"meta"`
+	Condition     string         `gorm:"default:success;" json:"condition"` // success, failure, always, expression
+	Expression    string         `json:"expression"`
 	Active        bool           `json:"active"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     *time.Time     `json:"updated_at"`
@@ -134,7 +145,8 @@ type DeployFilesStore struct {
 	FileStore     []byte     `gorm:"type:bytea; json:"file_store"`
 	EnvironmentID string     `gorm:"type:varchar(55); json:"environment_id"`
 	ChecksumMD5   string     `gorm:"type:varchar(55); json:"checksum_md5"`
-	External      bool       `gorm:"default:False" json:"external"`
+	External      bool       `gorm:"default:False" json:"external"` // true when the content is in object storage under ObjectKey
+	ObjectKey     string     `gorm:"type:varchar(255);" json:"object_key"`
 	RunInclude    bool       `gorm:"default:True" json:"run_include"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     *time.Time `json:"updated_at"`
@@ -213,3 +225,26 @@ type DeploymentApiTriggerRuns struct {
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     *time.Time     `json:"updated_at"`
 }
+
+func (DeploymentPromotions) IsEntity() {}
+
+func (DeploymentPromotions) TableName() string {
+	return "deployment_promotions"
+}
+
+/*
+DeploymentPromotions is the log of who deployed which version of a deployment, from which environment, and of rollbacks.
+Action: deploy, rollback.
+*/
+type DeploymentPromotions struct {
+	ID                string    `gorm:"PRIMARY_KEY;type:varchar(64);" json:"id"`
+	DeploymentID      string    `gorm:"index:idx_deployment_promotions;" json:"deployment_id"`
+	EnvironmentID     string    `gorm:"index:idx_deployment_promotions;" json:"environment_id"`
+	Version           string    `json:"version"`
+	PreviousVersion   string    `json:"previous_version"` // the version active before, empty for a first deployment
+	FromEnvironmentID string    `json:"from_environment_id"`
+	FromPipelineID    string    `json:"from_pipeline_id"`
+	Action            string    `json:"action"`
+	UserID            string    `json:"user_id"`
+	CreatedAt         time.Time `json:"created_at"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+func (EventTriggers) IsEntity() {}
+
+func (EventTriggers) TableName() string {
+	return "event_triggers"
+}
+
+/*
+EventTriggers start a pipeline or deployment on an event instead of a schedule:
+file - a file appears under a directory watched by a worker group.
+nats - a message arrives on a NATS subject.
+pipeline - another pipeline or deployment in the environment finishes.
+*/
+type EventTriggers struct {
+	NodeID           string         `gorm:"primaryKey;" json:"node_id"`
+	PipelineID       string         `gorm:"primaryKey;" json:"pipeline_id"`
+	EnvironmentID    string         `gorm:"primaryKey;" json:"environment_id"`
+	TriggerType      string         `json:"trigger_type"` // file, nats or pipeline
+	RunType          string         `json:"run_type"`     // pipeline or deployment
+	Online           bool           `json:"online"`
+	Parameters       datatypes.JSON `json:"parameters"`                                                 // run parameter values for triggered runs
+	WorkerGroup      string         `json:"worker_group"`                                               // file: worker group that watches the directory
+	Path             string         `json:"path"`                                                       // file: watched directory
+	Pattern          string         `json:"pattern"`                                                    // file: file name pattern, all files if empty
+	Subject          string         `json:"subject"`                                                    // nats: subject, wildcards allowed
+	SourcePipelineID string         `gorm:"index:idx_event_triggers_source;" json:"source_pipeline_id"` // pipeline: pipeline or deployment that finishes
+	SourceStatus     string         `json:"source_status"`                                              // pipeline: Success, Fail or Any
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        *time.Time     `json:"updated_at"`
+}
+
+func (EventTriggerEvents) IsEntity() {}
+
+func (EventTriggerEvents) TableName() string {
+	return "event_trigger_events"
+}
+
+/*
+EventTriggerEvents records each event a trigger received, the event key makes sure an event starts one run.
+*/
+type EventTriggerEvents struct {
+	NodeID        string         `gorm:"primaryKey;" json:"node_id"`
+	EnvironmentID string         `gorm:"primaryKey;" json:"environment_id"`
+	EventKey      string         `gorm:"primaryKey;" json:"event_key"`
+	PipelineID    string         `json:"pipeline_id"`
+	TriggerType   string         `json:"trigger_type"`
+	Payload       datatypes.JSON `json:"payload"`
+	Status        string         `json:"status"` // Fired, Skipped, Failed
+	RunID         string         `json:"run_id"`
+	Error         string         `json:"error"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+/*
+EventTriggerFile is sent by a worker when a file arrives under a watched directory.
+*/
+type EventTriggerFile struct {
+	NodeID        string    `json:"node_id"`
+	PipelineID    string    `json:"pipeline_id"`
+	EnvironmentID string    `json:"environment_id"`
+	FilePath      string    `json:"file_path"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"mod_time"`
+	WorkerID      string    `json:"worker_id"`
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/datatypes"
 )
 
 func (CodeFolders) IsEntity() {}
@@ -55,7 +57,8 @@ type CodeFilesStore struct {
 	FileStore     []byte     `gorm:"type:bytea;" json:"file_store"`
 	EnvironmentID string     `gorm:"type:varchar(55);" json:"environment_id"`
 	ChecksumMD5   string     `gorm:"type:varchar(55);" json:"checksum_md5"`
-	External      bool       `gorm:"default:False" json:"external"`
+	External      bool       `gorm:"default:False" json:"external"` // true when the content is in object storage under ObjectKey
+	ObjectKey     string     `gorm:"type:varchar(255);" json:"object_key"`
 	RunInclude    bool       `gorm:"default:True" json:"run_include"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     *time.Time `json:"updated_at"`
@@ -69,11 +72,34 @@ func (CodeGitCommits) TableName() string {
 }
 
 type CodeGitCommits struct {
-	GitID      string     `gorm:"PRIMARY_KEY;type:varchar(55);" json:"git_id"`
-	PipelineID string     `gorm:"PRIMARY_KEY;" json:"pipeline_id"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  *time.Time `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	GitID         string     `gorm:"PRIMARY_KEY;type:varchar(55);" json:"git_id"`
+	PipelineID    string     `gorm:"PRIMARY_KEY;" json:"pipeline_id"`
+	EnvironmentID string     `gorm:"type:varchar(55);index:idx_gitcommits;" json:"environment_id"`
+	UserID        string     `gorm:"type:varchar(55);" json:"user_id"`
+	Message       string     `json:"message"`
+	Action        string     `gorm:"type:varchar(20);" json:"action"` //commit, pull
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+}
+
+func (CodeGitRemotes) IsEntity() {}
+
+func (CodeGitRemotes) TableName() string {
+	return "code_git_remotes"
+}
+
+type CodeGitRemotes struct {
+	PipelineID    string     `gorm:"PRIMARY_KEY;type:varchar(55);" json:"pipeline_id"`
+	EnvironmentID string     `gorm:"PRIMARY_KEY;type:varchar(55);" json:"environment_id"`
+	RemoteURL     string     `json:"remote_url"`
+	Branch        string     `gorm:"type:varchar(255);" json:"branch"`
+	Username      string     `json:"username"`
+	Password      string     `json:"-"` // encrypted
+	LastPullAt    *time.Time `json:"last_pull_at"`
+	LastPushAt    *time.Time `json:"last_push_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
 }
 
 func (FolderDeleted) IsEntity() {}
@@ -83,18 +109,45 @@ func (FolderDeleted) TableName() string {
 }
 
 type FolderDeleted struct {
-	ID            string     `gorm:"PRIMARY_KEY;type:varchar(48);" json:"id"`
-	FileID        string     `gorm:"type:varchar(48);" json:"file_id"`
-	FolderID      string     `gorm:"size:55;" json:"folder_id"`
-	EnvironmentID string     `gorm:"type:varchar(55); " json:"environment_id"`
-	PipelineID    string     `gorm:"type:varchar(55);" json:"pipeline_id"`
-	NodeID        string     `gorm:"type:varchar(55); " json:"node_id"`
-	FileName      string     `gorm:"type:varchar(255); " json:"file_name"`
-	FolderName    string     `gorm:"type:varchar(255);" json:"folder_name"`
-	FType         string     `json:"f_type"` //folder, file, bin
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     *time.Time `json:"updated_at"`
-	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	ID            string         `gorm:"PRIMARY_KEY;type:varchar(48);" json:"id"`
+	FileID        string         `gorm:"type:varchar(48);" json:"file_id"`
+	FolderID      string         `gorm:"size:55;" json:"folder_id"` // the deleted folder, for files the folder it was in
+	EnvironmentID string         `gorm:"type:varchar(55); " json:"environment_id"`
+	PipelineID    string         `gorm:"type:varchar(55);" json:"pipeline_id"`
+	NodeID        string         `gorm:"type:varchar(55); " json:"node_id"`
+	FileName      string         `gorm:"type:varchar(255); " json:"file_name"`
+	FolderName    string         `gorm:"type:varchar(255);" json:"folder_name"`
+	FType         string         `json:"f_type"` //folder, file, bin
+	Path          string         `json:"path"`   // from the pipelines folder at the time of delete
+	UserID        string         `gorm:"type:varchar(48);" json:"user_id"`
+	Snapshot      datatypes.JSON `json:"snapshot"` // folder records removed with a folder, see filesystem.FolderSnapshot
+	RestoredAt    *time.Time     `json:"restored_at"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     *time.Time     `json:"updated_at"`
+	DeletedAt     *time.Time     `json:"deleted_at,omitempty"`
+}
+
+func (CodeFileRevisions) IsEntity() {}
+
+func (CodeFileRevisions) TableName() string {
+	return "code_file_revisions"
+}
+
+type CodeFileRevisions struct {
+	RevisionID    string    `gorm:"PRIMARY_KEY;type:varchar(48);" json:"revision_id"`
+	FileID        string    `gorm:"type:varchar(48);index:idx_filerevisions;" json:"file_id"`
+	EnvironmentID string    `gorm:"type:varchar(55);index:idx_filerevisions;" json:"environment_id"`
+	PipelineID    string    `gorm:"type:varchar(55);" json:"pipeline_id"`
+	NodeID        string    `gorm:"type:varchar(55);" json:"node_id"`
+	FileName      string    `gorm:"type:varchar(255);" json:"file_name"`
+	FileStore     []byte    `gorm:"type:bytea;" json:"file_store"`
+	External      bool      `gorm:"default:False" json:"external"` // true when the content is in object storage under ObjectKey
+	ObjectKey     string    `gorm:"type:varchar(255);" json:"object_key"`
+	Size          int       `gorm:"default:0;" json:"size"`
+	ChecksumMD5   string    `gorm:"type:varchar(55);" json:"checksum_md5"`
+	UserID        string    `gorm:"type:varchar(48);" json:"user_id"`
+	Action        string    `gorm:"type:varchar(20);" json:"action"` //save, import, pull, restore, delete, undelete
+	CreatedAt     time.Time `gorm:"index:idx_filerevisions;" json:"created_at"`
 }
 
 type FolderDuplicate struct {
@@ -149,6 +202,8 @@ type CodeFilesCacheOutput struct {
 	FileName    string `json:"file_name"`
 	ChecksumMD5 string `json:"checksum_md5"`
 	FileStore   []byte `gorm:"type:bytea;" json:"file_store"`
+	External    bool   `json:"external"`
+	ObjectKey   string `json:"object_key"`
 }
 
 type CodeFilesCompress struct {
@@ -158,6 +213,8 @@ type CodeFilesCompress struct {
 	FolderPath  string `json:"folder_path"`
 	ChecksumMD5 string `json:"checksum_md5"`
 	FileStore   []byte `gorm:"type:bytea;" json:"file_store"`
+	External    bool   `json:"external"`
+	ObjectKey   string `json:"object_key"`
 }
 
 func (DeployCodeFilesCache) IsEntity() {}
@@ -205,3 +262,14 @@ type DeployCodeNodeCache struct {
 // 	Size string `gorm:"primaryKey;size:55;" json:"size"`
 // 	Me   string `gorm:"size:65;" json:"me"`
 // }
+
+/* Request and reply of workers for presigned URLs of code files in object storage */
+type CodeFilesPresign struct {
+	ObjectKeys []string `json:"object_keys"`
+}
+
+type CodeFilesPresignResponse struct {
+	R    string            `json:"r"`
+	M    string            `json:"m"`
+	URLs map[string]string `json:"urls"`
+}
@@ -2,5 +2,6 @@ package models
 
 type LeaderElection struct {
 	NodeID    string `redis:"nodeid"`
+	Token     int64  `redis:"token"`
 	Timestamp int64  `redis:"timestamp"`
 }
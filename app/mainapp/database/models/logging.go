@@ -44,12 +44,12 @@ func (LogsWorkers) TableName() string {
 }
 
 type LogsWorkers struct {
-	CreatedAt     time.Time `json:"created_at"`
-	EnvironmentID string    `gorm:"index:idx_noderun;type:varchar(64);" json:"environment_id"`
+	CreatedAt     time.Time `gorm:"index:idx_nodelogpage,priority:4;index:idx_runlogpage,priority:3;" json:"created_at"`
+	EnvironmentID string    `gorm:"index:idx_noderun;index:idx_nodelogpage,priority:1;index:idx_runlogpage,priority:1;type:varchar(64);" json:"environment_id"`
 	Category      string    `json:"category"`
-	UID           string    `gorm:"type:varchar(64);" json:"uid"`
-	RunID         string    `gorm:"index:idx_noderun;type:varchar(64);" json:"run_id"`
-	NodeID        string    `gorm:"index:idx_noderun;type:varchar(64);" json:"node_id"`
+	UID           string    `gorm:"index:idx_nodelogpage,priority:5;index:idx_runlogpage,priority:4;type:varchar(64);" json:"uid"`
+	RunID         string    `gorm:"index:idx_noderun;index:idx_nodelogpage,priority:2;index:idx_runlogpage,priority:2;type:varchar(64);" json:"run_id"`
+	NodeID        string    `gorm:"index:idx_noderun;index:idx_nodelogpage,priority:3;type:varchar(64);" json:"node_id"`
 	TaskID        string    `gorm:"index:idx_logtasks;type:varchar(64);" json:"task_id"`
 	Log           string    `json:"log"`
 	LogType       string    `json:"log_type"` //info, error, debug
@@ -71,10 +71,10 @@ func (LogsCodeRun) TableName() string {
 }
 
 type LogsCodeRun struct {
-	CreatedAt     time.Time `json:"created_at"`
-	EnvironmentID string    `gorm:"index:idx_coderun;type:varchar(64);" json:"environment_id"`
-	UID           string    `gorm:"type:varchar(64);" json:"uid"`
-	RunID         string    `gorm:"index:idx_coderun;type:varchar(64);" json:"run_id"`
+	CreatedAt     time.Time `gorm:"index:idx_coderunpage,priority:3;" json:"created_at"`
+	EnvironmentID string    `gorm:"index:idx_coderun;index:idx_coderunpage,priority:1;type:varchar(64);" json:"environment_id"`
+	UID           string    `gorm:"index:idx_coderunpage,priority:4;type:varchar(64);" json:"uid"`
+	RunID         string    `gorm:"index:idx_coderun;index:idx_coderunpage,priority:2;type:varchar(64);" json:"run_id"`
 	NodeID        string    `gorm:"index:idx_coderun;type:varchar(64);" json:"node_id"`
 	Log           string    `json:"log"`
 	LogType       string    `json:"log_type"` //info, error, debug
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+)
+
+func (NotificationChannels) IsEntity() {}
+
+func (NotificationChannels) TableName() string {
+	return "notification_channels"
+}
+
+/*
+NotificationChannels are where notifications are sent:
+email - SMTP to a list of recipients, the SMTP server is set with DP_SMTP_*.
+webhook - JSON POST of the notification to a URL.
+slack - Slack compatible incoming webhook.
+*/
+type NotificationChannels struct {
+	ChannelID     string     `gorm:"PRIMARY_KEY;type:varchar(64);" json:"channel_id"`
+	EnvironmentID string     `gorm:"index:idx_notification_channels_env;" json:"environment_id"`
+	Name          string     `json:"name"`
+	ChannelType   string     `json:"channel_type"` // email, webhook, slack
+	Recipients    string     `json:"recipients"`   // email: comma separated addresses
+	URL           string     `json:"-"`            // webhook, slack: encrypted as webhook URLs carry tokens
+	Active        bool       `json:"active"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+func (NotificationRules) IsEntity() {}
+
+func (NotificationRules) TableName() string {
+	return "notification_rules"
+}
+
+/*
+NotificationRules send a notification to a channel when an event happens on a pipeline or deployment:
+fail - a run fails.
+success - a run succeeds.
+sla - a run is still going, or took longer, than the SLA seconds.
+schedule_missed - a schedule trigger did not fire when it was due.
+*/
+type NotificationRules struct {
+	RuleID        string     `gorm:"PRIMARY_KEY;type:varchar(64);" json:"rule_id"`
+	EnvironmentID string     `gorm:"index:idx_notification_rules_pipeline;" json:"environment_id"`
+	PipelineID    string     `gorm:"index:idx_notification_rules_pipeline;" json:"pipeline_id"`
+	RunType       string     `json:"run_type"` // pipeline or deployment
+	Event         string     `json:"event"`    // fail, success, sla, schedule_missed
+	SLASeconds    int        `gorm:"default:0;" json:"sla_seconds"`
+	ChannelID     string     `gorm:"index:idx_notification_rules_channel;" json:"channel_id"`
+	Template      string     `json:"template"` // Go text/template, the default template for the event if empty
+	Active        bool       `json:"active"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     *time.Time `json:"updated_at"`
+}
+
+func (NotificationDeliveries) IsEntity() {}
+
+func (NotificationDeliveries) TableName() string {
+	return "notification_deliveries"
+}
+
+/*
+NotificationDeliveries record each notification sent. The event key makes sure an event is sent once per rule.
+*/
+type NotificationDeliveries struct {
+	DeliveryID    string     `gorm:"PRIMARY_KEY;type:varchar(64);" json:"delivery_id"`
+	RuleID        string     `gorm:"index:idx_notification_deliveries_event,unique;" json:"rule_id"`
+	EventKey      string     `gorm:"index:idx_notification_deliveries_event,unique;" json:"event_key"`
+	ChannelID     string     `json:"channel_id"`
+	EnvironmentID string     `gorm:"index:idx_notification_deliveries_pipeline;" json:"environment_id"`
+	PipelineID    string     `gorm:"index:idx_notification_deliveries_pipeline;" json:"pipeline_id"`
+	RunID         string     `json:"run_id"`
+	Event         string     `json:"event"`
+	Subject       string     `json:"subject"`
+	Message       string     `json:"message"`
+	Status        string     `json:"status"` // Pending, Sent, Failed
+	Attempts      int        `json:"attempts"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	Error         string     `json:"error"`
+	CreatedAt     time.Time  `json:"created_at"`
+	SentAt        *time.Time `json:"sent_at"`
+}
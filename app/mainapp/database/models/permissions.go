@@ -48,6 +48,7 @@ var ResourceType = []*ResourceTypeStruct{
 	{Code: "environment_run_all_deployments", Level: "environment", Label: "Run all deployments", Access: "write"},
 	{Code: "environment_create_pipelines", Level: "environment", Label: "Create pipelines", Access: "write"},
 	{Code: "environment_permissions_pipelines", Level: "environment", Label: "Manage pipeline permissions", Access: "write"},
+	{Code: "environment_approve_runs", Level: "environment", Label: "Approve runs", Access: "write"},
 
 	{Code: "environment_secrets", Level: "environment", Label: "Manage secrets", Access: "write"},
 	{Code: "environment_edit_workers", Level: "environment", Label: "Manage workers", Access: "write"},
@@ -18,15 +18,32 @@ type Pipelines struct {
 	// Version       string         `gorm:"type:varchar(125);index:idx_pipelines,unique;" json:"version"`
 	EnvironmentID string `json:"environment_id"`
 	// YAMLHash      string         `json:"yaml_hash"`
+	Description       string         `json:"description"`
+	Active            bool           `json:"active"`
+	WorkerGroup       string         `json:"worker_group"`
+	TimeoutSeconds    int            `gorm:"default:0;" json:"timeout_seconds"` // default for all nodes, 0 = no timeout
+	Parameters        []RunParameter `gorm:"serializer:json;" json:"parameters"`
+	MaxConcurrentRuns int            `gorm:"default:0;" json:"max_concurrent_runs"`    // 0 = no limit
+	ConcurrencyPolicy string         `gorm:"default:queue;" json:"concurrency_policy"` // queue, skip, cancel-previous
+	PauseRuns         bool           `gorm:"default:false;" json:"pause_runs"`         // new runs are queued until unpaused
+	Meta              datatypes.JSON `json:"meta"`
+	Json              datatypes.JSON `json:"json"`
+	UpdateLock        bool           `gorm:"default:false;" json:"update_lock"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         *time.Time     `json:"updated_at"`
+	DeletedAt         *time.Time     `json:"deleted_at,omitempty"`
+}
+
+/*
+Run parameter declared on a pipeline. Values are set when a run starts
+and passed to every task as DP_PARAM_<NAME> and the file in DP_PARAMETERS_FILE.
+*/
+type RunParameter struct {
+	Name        string         `json:"name"`
+	Type        string         `json:"type"` // string, number, integer, boolean, json
+	Default     datatypes.JSON `json:"default"`
+	Required    bool           `json:"required"`
 	Description string         `json:"description"`
-	Active      bool           `json:"active"`
-	WorkerGroup string         `json:"worker_group"`
-	Meta        datatypes.JSON `json:"meta"`
-	Json        datatypes.JSON `json:"json"`
-	UpdateLock  bool           `gorm:"default:false;" json:"update_lock"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   *time.Time     `json:"updated_at"`
-	DeletedAt   *time.Time     `json:"deleted_at,omitempty"`
 }
 
 func (PipelineNodes) IsEntity() {}
@@ -36,20 +53,24 @@ func (PipelineNodes) TableName() string {
 }
 
 type PipelineNodes struct {
-	NodeID        string         `gorm:"PRIMARY_KEY;type:varchar(128);" json:"node_id"`
-	PipelineID    string         `gorm:"index:idx_pipelineid_nodes;" json:"pipeline_id"`
-	Name          string         `gorm:"type:varchar(255);" json:"name"`
-	EnvironmentID string         `json:"environment_id"`
-	NodeType      string         `json:"node_type"`      //trigger, process, checkpoint
-	NodeTypeDesc  string         `json:"node_type_desc"` //python, bash, play, scheduler, checkpoint, api
-	TriggerOnline bool           `gorm:"default:false;" json:"trigger_online"`
-	Description   string         `json:"description"`
-	Commands      datatypes.JSON `json:"commands"`
-	Meta          datatypes.JSON `json:"meta"`
-	Dependency    datatypes.JSON `json:"dependency"`
-	Destination   datatypes.JSON `json:"destination"`
-	WorkerGroup   string         `gorm:"index:idx_workergroup_nodes;" json:"worker_group"` //Inherits Pipeline workergroup unless specified
-	Active        bool           `json:"active"`
+	NodeID         string         `gorm:"PRIMARY_KEY;type:varchar(128);" json:"node_id"`
+	PipelineID     string         `gorm:"index:idx_pipelineid_nodes;" json:"pipeline_id"`
+	Name           string         `gorm:"type:varchar(255);" json:"name"`
+	EnvironmentID  string         `json:"environment_id"`
+	NodeType       string         `json:"node_type"`      //trigger, process, checkpoint
+	NodeTypeDesc   string         `json:"node_type_desc"` //python, bash, play, scheduler, checkpoint, api, subpipeline, approval
+	TriggerOnline  bool           `gorm:"default:false;" json:"trigger_online"`
+	Description    string         `json:"description"`
+	Commands       datatypes.JSON `json:"commands"`
+	Meta           datatypes.JSON `json:"meta"`
+	Dependency     datatypes.JSON `json:"dependency"`
+	Destination    datatypes.JSON `json:"destination"`
+	WorkerGroup    string         `gorm:"index:idx_workergroup_nodes;" json:"worker_group"` //Inherits Pipeline workergroup unless specified
+	Active         bool           `json:"active"`
+	RetryPolicy    RetryPolicy    `gorm:"embedded;embeddedPrefix:retry_;" json:"retry_policy"`
+	TimeoutSeconds int            `gorm:"default:0;" json:"timeout_seconds"` // 0 = use the pipeline timeout
+	SubPipeline    SubPipeline    `gorm:"embedded;embeddedPrefix:sub_;" json:"sub_pipeline"`
+	Approval       Approval       `gorm:"embedded;embeddedPrefix:approval_;" json:"approval"`
 	// FolderID       string         `json:"folder_id"`
 	// ParentFolderID string         `json:"parent_folder_id"`
 	CreatedAt time.Time  `json:"created_at"`
@@ -57,6 +78,38 @@ type PipelineNodes struct {
 	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
+/*
+Retry policy for a node. Copied onto each task at the start of a run
+so that the workers and the main app agree on whether a failed attempt is retried.
+*/
+type RetryPolicy struct {
+	MaxAttempts  int    `gorm:"default:1;" json:"max_attempts"` // 1 = no retries
+	Backoff      string `json:"backoff"`                        // fixed, exponential
+	DelaySeconds int    `gorm:"default:0;" json:"delay_seconds"`
+	ExitCodes    []int  `gorm:"serializer:json;" json:"exit_codes"` // retry only on these exit codes, empty = retry on any failure
+}
+
+/*
+Pipeline or deployment a sub-pipeline node runs as a step. The task waits for the child run
+to finish and takes on its status.
+*/
+type SubPipeline struct {
+	PipelineID string         `json:"pipeline_id"`
+	RunType    string         `json:"run_type"`   // pipeline or deployment
+	Version    string         `json:"version"`    // deployment: pinned version, empty = the active version
+	Parameters datatypes.JSON `json:"parameters"` // run parameter values for the child run
+}
+
+/*
+Approval gate of an approval node. The task waits at status Waiting until it is approved or rejected,
+see ApprovalRequests.
+*/
+type Approval struct {
+	Message        string `json:"message"`                               // shown to the approvers
+	TimeoutSeconds int    `gorm:"default:0;" json:"timeout_seconds"`     // 0 = wait until decided
+	TimeoutAction  string `gorm:"default:reject;" json:"timeout_action"` // reject or approve once timed out
+}
+
 func (PipelineEdges) IsEntity() {}
 
 func (PipelineEdges) TableName() string {
@@ -70,12 +123,23 @@ type PipelineEdges struct {
 	To            string         `gorm:"index:idx_pipelineid_edge;" json:"to"`
 	EnvironmentID string         `json:"environment_id"`
 	Meta          datatypes.JSON `json:"meta"`
+	Condition     string         `gorm:"default:success;" json:"condition"` // success, failure, always, expression
+	Expression    string         `json:"expression"`
 	Active        bool           `json:"active"`
 	CreatedAt     time.Time      `json:"created_at"`
 	UpdatedAt     *time.Time     `json:"updated_at"`
 	DeletedAt     *time.Time     `json:"deleted_at,omitempty"`
 }
 
+/*
+Condition on an edge for the destination node to run, checked once the source node has finished.
+Copied onto each task at the start of a run keyed by the source node.
+*/
+type EdgeCondition struct {
+	Condition  string `json:"condition"`
+	Expression string `json:"expression"`
+}
+
 func (PipelineRuns) IsEntity() {}
 
 func (PipelineRuns) TableName() string {
@@ -83,17 +147,26 @@ func (PipelineRuns) TableName() string {
 }
 
 type PipelineRuns struct {
-	RunID         string         `gorm:"PRIMARY_KEY;type:varchar(64);" json:"run_id"`
-	PipelineID    string         `gorm:"index:idx_pipelineid_runs;" json:"pipeline_id"`
-	Status        string         `json:"status"`
-	Reason        string         `json:"reason"`
-	EnvironmentID string         `json:"environment_id"`
-	RunType       string         `json:"run_type"` //deploy or pipeline
-	DeployVersion string         `json:"deploy_version"`
-	RunJSON       datatypes.JSON `json:"run_json"`
-	CreatedAt     time.Time      `json:"created_at"`
-	EndedAt       time.Time      `json:"ended_at"`
-	UpdatedAt     *time.Time     `json:"updated_at"`
+	RunID            string         `gorm:"PRIMARY_KEY;type:varchar(64);" json:"run_id"`
+	PipelineID       string         `gorm:"index:idx_pipelineid_runs;" json:"pipeline_id"`
+	Status           string         `json:"status"` // Queued, Running, Paused, Success, Fail
+	Reason           string         `json:"reason"`
+	EnvironmentID    string         `json:"environment_id"`
+	RunType          string         `json:"run_type"` //deploy or pipeline
+	DeployVersion    string         `json:"deploy_version"`
+	RunJSON          datatypes.JSON `json:"run_json"`
+	Parameters       datatypes.JSON `json:"parameters"`                                      // parameter values of the run by name
+	LogicalDate      *time.Time     `json:"logical_date"`                                    // the schedule fire time a scheduled, catch-up or backfill run is for
+	LogicalTimezone  string         `json:"logical_timezone"`                                // timezone of the schedule the logical date is passed to tasks in
+	ParentRunID      string         `gorm:"index:idx_parent_run_runs;" json:"parent_run_id"` // run of the sub-pipeline node that started this run
+	ParentTaskID     string         `json:"parent_task_id"`
+	ParentPipelineID string         `json:"parent_pipeline_id"`
+	Depth            int            `gorm:"default:0;" json:"depth"`   // sub-pipeline nesting, 0 = top level run
+	Attempt          int            `gorm:"default:1;" json:"attempt"` // goes up each time the run is re-run
+	RerunNodes       datatypes.JSON `json:"rerun_nodes"`               // nodes a queued re-run starts from, cleared once it starts
+	CreatedAt        time.Time      `json:"created_at"`
+	EndedAt          time.Time      `json:"ended_at"`
+	UpdatedAt        *time.Time     `json:"updated_at"`
 }
 
 func (PipelineApiTriggers) IsEntity() {}
@@ -37,6 +37,7 @@ func (PlatformLeader) TableName() string {
 type PlatformLeader struct {
 	Leader    bool       `gorm:"PRIMARY_KEY;" json:"leader"`
 	NodeID    string     `gorm:"type:varchar(48);" json:"node_id"`
+	Token     int64      `gorm:"default:0;" json:"token"` // fencing token, goes up each time the leadership changes hands
 	UpdatedAt *time.Time `json:"updated_at"`
 }
 
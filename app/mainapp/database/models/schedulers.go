@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 func (Scheduler) IsEntity() {}
 
@@ -9,16 +13,54 @@ func (Scheduler) TableName() string {
 }
 
 type Scheduler struct {
-	NodeID        string     `gorm:"primaryKey;" json:"node_id"`
-	PipelineID    string     `gorm:"primaryKey;" json:"pipeline_id"`
-	EnvironmentID string     `gorm:"primaryKey;" json:"environment_id"`
-	ScheduleType  string     `json:"schedule_type"`
-	Schedule      string     `json:"schedule"`
-	Timezone      string     `json:"timezone"`
-	Online        bool       `json:"online"`
-	RunType       string     `json:"run_type"`
-	CreatedAt     time.Time  `json:"created_at"`
-	UpdatedAt     *time.Time `json:"updated_at"`
+	NodeID        string         `gorm:"primaryKey;" json:"node_id"`
+	PipelineID    string         `gorm:"primaryKey;" json:"pipeline_id"`
+	EnvironmentID string         `gorm:"primaryKey;" json:"environment_id"`
+	ScheduleType  string         `json:"schedule_type"`
+	Schedule      string         `json:"schedule"`
+	Timezone      string         `json:"timezone"`
+	Online        bool           `json:"online"`
+	RunType       string         `json:"run_type"`
+	Parameters    datatypes.JSON `json:"parameters"` // run parameter values for scheduled runs
+	CatchUp       bool           `json:"catch_up"`   // run fire times missed while no leader was up when schedules load
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     *time.Time     `json:"updated_at"`
+}
+
+func (SchedulerLastFire) IsEntity() {}
+
+func (SchedulerLastFire) TableName() string {
+	return "scheduler_last_fire"
+}
+
+/*
+SchedulerLastFire is kept apart from the scheduler table which is recreated each time a pipeline is saved.
+*/
+type SchedulerLastFire struct {
+	NodeID        string    `gorm:"primaryKey;" json:"node_id"`
+	EnvironmentID string    `gorm:"primaryKey;" json:"environment_id"`
+	PipelineID    string    `json:"pipeline_id"`
+	FiredAt       time.Time `json:"fired_at"`
+}
+
+func (SchedulerHistory) IsEntity() {}
+
+func (SchedulerHistory) TableName() string {
+	return "scheduler_history"
+}
+
+type SchedulerHistory struct {
+	ID            string    `gorm:"PRIMARY_KEY;type:varchar(48);" json:"id"`
+	NodeID        string    `gorm:"index:idx_scheduler_history_node;" json:"node_id"`
+	EnvironmentID string    `gorm:"index:idx_scheduler_history_node;" json:"environment_id"`
+	PipelineID    string    `json:"pipeline_id"`
+	RunType       string    `json:"run_type"`
+	Trigger       string    `json:"trigger"` // schedule, catchup or backfill
+	FiredAt       time.Time `gorm:"index:idx_scheduler_history_node;" json:"fired_at"`
+	Status        string    `json:"status"` // Fired, Locked, Skipped, Fenced, Failed
+	RunID         string    `json:"run_id"`
+	Error         string    `json:"error"`
+	CreatedAt     time.Time `json:"created_at"`
 }
 
 func (SchedulerLock) IsEntity() {}
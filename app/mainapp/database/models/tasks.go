@@ -18,26 +18,32 @@ func (WorkerTasks) TableName() string {
 }
 
 type WorkerTasks struct {
-	TaskID        string         `gorm:"PRIMARY_KEY;type:varchar(48);" json:"task_id"`
-	CreatedAt     time.Time      `json:"created_at"`
-	EnvironmentID string         `json:"environment_id"`
-	RunID         string         `gorm:"index:idx_task_runid;index:idx_task_nodeid;" json:"run_id"`
-	RunType       string         `json:"run_type"`
-	WorkerGroup   string         `json:"worker_group"`
-	WorkerID      string         `json:"worker_id"`
-	WorkerType    string         `json:"worker_type"`
-	PipelineID    string         `json:"pipeline_id"`
-	NodeID        string         `gorm:"index:idx_task_nodeid;" json:"node_id"`
-	Folder        string         `json:"folder"`
-	FolderID      string         `json:"folder_id"`
-	Dependency    datatypes.JSON `json:"dependency"`
-	Destination   datatypes.JSON `json:"destination"`
-	StartDT       time.Time      `json:"start_dt"`
-	EndDT         time.Time      `json:"end_dt"`
-	Status        string         `json:"status"`
-	Reason        string         `json:"reason"`
-	Commands      datatypes.JSON `json:"commands"`
-	Version       string         `json:"version"`
+	TaskID         string         `gorm:"PRIMARY_KEY;type:varchar(48);" json:"task_id"`
+	CreatedAt      time.Time      `gorm:"index:idx_task_analytics,priority:3;" json:"created_at"`
+	EnvironmentID  string         `gorm:"index:idx_task_analytics,priority:1;" json:"environment_id"`
+	RunID          string         `gorm:"index:idx_task_runid;index:idx_task_nodeid;" json:"run_id"`
+	RunType        string         `json:"run_type"`
+	WorkerGroup    string         `json:"worker_group"`
+	WorkerID       string         `json:"worker_id"`
+	WorkerType     string         `json:"worker_type"`
+	PipelineID     string         `gorm:"index:idx_task_analytics,priority:2;" json:"pipeline_id"`
+	NodeID         string         `gorm:"index:idx_task_nodeid;" json:"node_id"`
+	Folder         string         `json:"folder"`
+	FolderID       string         `json:"folder_id"`
+	Dependency     datatypes.JSON `json:"dependency"`
+	Conditions     datatypes.JSON `json:"conditions"` // edge condition by dependency node
+	Destination    datatypes.JSON `json:"destination"`
+	StartDT        time.Time      `json:"start_dt"`
+	EndDT          time.Time      `json:"end_dt"`
+	Status         string         `json:"status"` // Queue, Run, Waiting, Paused, Success, Fail, Retry, Skipped
+	Reason         string         `json:"reason"`
+	Commands       datatypes.JSON `json:"commands"`
+	Version        string         `json:"version"`
+	Attempt        int            `gorm:"default:1;" json:"attempt"`
+	ExitCode       int            `gorm:"default:0;" json:"exit_code"`
+	RetryPolicy    RetryPolicy    `gorm:"embedded;embeddedPrefix:retry_;" json:"retry_policy"`
+	TimeoutSeconds int            `gorm:"default:0;" json:"timeout_seconds"`                   // 0 = no timeout
+	NextAttemptAt  *time.Time     `gorm:"index:idx_task_next_attempt;" json:"next_attempt_at"` // set while a retry attempt waits on its backoff
 }
 
 func (WorkerTaskLock) IsEntity() {}
@@ -52,6 +58,27 @@ type WorkerTaskLock struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+func (WorkerTaskOutputs) IsEntity() {}
+
+func (WorkerTaskOutputs) TableName() string {
+	return "worker_task_outputs"
+}
+
+/*
+Outputs written by a task, available to the downstream tasks of the same run.
+The last attempt of a node overwrites the outputs of earlier attempts.
+*/
+type WorkerTaskOutputs struct {
+	RunID         string         `gorm:"PRIMARY_KEY;type:varchar(48);" json:"run_id"`
+	NodeID        string         `gorm:"PRIMARY_KEY;type:varchar(128);" json:"node_id"`
+	OutputKey     string         `gorm:"PRIMARY_KEY;type:varchar(128);" json:"output_key"`
+	TaskID        string         `gorm:"type:varchar(48);" json:"task_id"`
+	EnvironmentID string         `json:"environment_id"`
+	PipelineID    string         `json:"pipeline_id"`
+	Value         datatypes.JSON `json:"value"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
 type WorkerTaskSend struct {
 	TaskID        string    `json:"task_id"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -66,6 +93,7 @@ type WorkerTaskSend struct {
 	Commands      []string  `json:"commands"`
 	Version       string    `json:"version"`
 	RunType       string    `json:"run_type"`
+	TraceParent   string    `json:"traceparent"` // W3C trace context of the dispatch span
 }
 
 type WorkerPipelineNext struct {
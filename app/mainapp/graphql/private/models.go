@@ -5,6 +5,7 @@ package privategraphql
 import (
 	"time"
 
+	configascode "github.com/dataplane-app/dataplane/app/mainapp/code_editor/config_as_code"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 )
 
@@ -42,6 +43,30 @@ type AddUpdateMeInput struct {
 	Timezone  string `json:"timezone"`
 }
 
+// channelID is empty to add a channel. The URL of a webhook or slack channel is kept if left empty on update.
+type AddUpdateNotificationChannelInput struct {
+	ChannelID     string `json:"channelID"`
+	EnvironmentID string `json:"environmentID"`
+	Name          string `json:"name"`
+	ChannelType   string `json:"channelType"`
+	Recipients    string `json:"recipients"`
+	URL           string `json:"url"`
+	Active        bool   `json:"active"`
+}
+
+// ruleID is empty to add a rule. An empty template uses the default message of the event.
+type AddUpdateNotificationRuleInput struct {
+	RuleID        string `json:"ruleID"`
+	EnvironmentID string `json:"environmentID"`
+	PipelineID    string `json:"pipelineID"`
+	RunType       string `json:"runType"`
+	Event         string `json:"event"`
+	SLASeconds    int    `json:"slaSeconds"`
+	ChannelID     string `json:"channelID"`
+	Template      string `json:"template"`
+	Active        bool   `json:"active"`
+}
+
 type AddUsersInput struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -68,6 +93,66 @@ type ChangePasswordInput struct {
 	UserID   string `json:"user_id"`
 }
 
+type CodeDeleted struct {
+	ID        string    `json:"id"`
+	FType     string    `json:"fType"`
+	FileID    string    `json:"fileID"`
+	FolderID  string    `json:"folderID"`
+	NodeID    string    `json:"nodeID"`
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	UserID    string    `json:"userID"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CodeFileRevision struct {
+	RevisionID  string    `json:"revisionID"`
+	FileID      string    `json:"fileID"`
+	FileName    string    `json:"fileName"`
+	ChecksumMd5 string    `json:"checksumMD5"`
+	Size        int       `json:"size"`
+	UserID      string    `json:"userID"`
+	UserName    string    `json:"userName"`
+	Action      string    `json:"action"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CodeGitBlameLine struct {
+	Line        int       `json:"line"`
+	Text        string    `json:"text"`
+	CommitHash  string    `json:"commitHash"`
+	AuthorName  string    `json:"authorName"`
+	AuthorEmail string    `json:"authorEmail"`
+	Date        time.Time `json:"date"`
+}
+
+type CodeGitCommit struct {
+	CommitHash  string    `json:"commitHash"`
+	Message     string    `json:"message"`
+	AuthorName  string    `json:"authorName"`
+	AuthorEmail string    `json:"authorEmail"`
+	UserID      string    `json:"userID"`
+	Action      string    `json:"action"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CodeGitPull struct {
+	Status     string `json:"status"`
+	CommitHash string `json:"commitHash"`
+	Changes    int    `json:"changes"`
+}
+
+type CodeGitRemote struct {
+	PipelineID    string     `json:"pipelineID"`
+	EnvironmentID string     `json:"environmentID"`
+	RemoteURL     string     `json:"remoteURL"`
+	Branch        string     `json:"branch"`
+	Username      string     `json:"username"`
+	PasswordSet   bool       `json:"passwordSet"`
+	LastPullAt    *time.Time `json:"lastPullAt"`
+	LastPushAt    *time.Time `json:"lastPushAt"`
+}
+
 type CodePackages struct {
 	WorkerGroup   string `json:"workerGroup"`
 	Language      string `json:"language"`
@@ -85,6 +170,26 @@ type DataInput struct {
 	Genericdata interface{} `json:"genericdata"`
 }
 
+type DeploymentDiff struct {
+	FromVersion string                  `json:"fromVersion"`
+	ToVersion   string                  `json:"toVersion"`
+	Changes     []*DeploymentDiffChange `json:"changes"`
+}
+
+// One difference between two sides of a deployment diff.
+// + kind: node, edge or file
+// + change: added, removed or changed, for changed the field changed and its from and to values
+// + Node and edge IDs are without the d- prefix of deployments, files are compared by checksum
+type DeploymentDiffChange struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	Field  string `json:"field"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
 type DeploymentFlow struct {
 	Edges []*models.DeployPipelineEdges `json:"edges"`
 	Nodes []*models.DeployPipelineNodes `json:"nodes"`
@@ -125,6 +230,16 @@ type Deployments struct {
 	Schedule          string    `json:"schedule"`
 	ScheduleType      string    `json:"schedule_type"`
 	Timezone          string    `json:"timezone"`
+	TimeoutSeconds    int       `json:"timeoutSeconds"`
+	MaxConcurrentRuns int       `json:"maxConcurrentRuns"`
+	ConcurrencyPolicy string    `json:"concurrencyPolicy"`
+	PauseRuns         bool      `json:"pauseRuns"`
+}
+
+type FailureReasonCount struct {
+	Reason       string    `json:"reason"`
+	Failures     int       `json:"failures"`
+	LastFailedAt time.Time `json:"last_failed_at"`
 }
 
 type FolderNodeInput struct {
@@ -138,6 +253,43 @@ type FolderNodeInput struct {
 	Active        bool   `json:"active"`
 }
 
+type LogsCodeRunPage struct {
+	Logs       []*models.LogsCodeRun `json:"logs"`
+	NextCursor string                `json:"next_cursor"`
+}
+
+// Filters of a page of logs:
+// + cursor is the next_cursor of the previous page, empty for the first page.
+// + limit defaults to 500, at most 5000.
+// + levels is any of info, error, debug or action, empty for all.
+// + search matches whole words of the log, empty for all.
+type LogsFilter struct {
+	Cursor string   `json:"cursor"`
+	Limit  int      `json:"limit"`
+	Levels []string `json:"levels"`
+	Search string   `json:"search"`
+}
+
+// A page of logs, next_cursor is empty on the last page.
+type LogsWorkersPage struct {
+	Logs       []*models.LogsWorkers `json:"logs"`
+	NextCursor string                `json:"next_cursor"`
+}
+
+// Task stats of a node, durations are from start to end and queue wait from queued to started, in seconds.
+type NodeRunStats struct {
+	NodeID          string                `json:"node_id"`
+	Tasks           int                   `json:"tasks"`
+	Success         int                   `json:"success"`
+	Fail            int                   `json:"fail"`
+	FailureRate     float64               `json:"failure_rate"`
+	P50Seconds      float64               `json:"p50_seconds"`
+	P95Seconds      float64               `json:"p95_seconds"`
+	P50QueueSeconds float64               `json:"p50_queue_seconds"`
+	P95QueueSeconds float64               `json:"p95_queue_seconds"`
+	Reasons         []*FailureReasonCount `json:"reasons"`
+}
+
 type NonDefaultNodes struct {
 	NodeID        string `json:"nodeID"`
 	PipelineID    string `json:"pipelineID"`
@@ -158,6 +310,9 @@ type PipelineEdgesInput struct {
 	To     string                  `json:"to"`
 	Meta   *PipelineEdgesMetaInput `json:"meta"`
 	Active bool                    `json:"active"`
+	// success (default), failure, always or expression
+	Condition  *string `json:"condition"`
+	Expression *string `json:"expression"`
 }
 
 type PipelineEdgesMetaInput struct {
@@ -179,16 +334,18 @@ type PipelineFlowInput struct {
 }
 
 type PipelineNodesInput struct {
-	NodeID        string                  `json:"nodeID"`
-	Name          string                  `json:"name"`
-	NodeType      string                  `json:"nodeType"`
-	NodeTypeDesc  string                  `json:"nodeTypeDesc"`
-	TriggerOnline bool                    `json:"triggerOnline"`
-	Description   string                  `json:"description"`
-	Commands      interface{}             `json:"commands"`
-	Meta          *PipelineNodesMetaInput `json:"meta"`
-	WorkerGroup   string                  `json:"workerGroup"`
-	Active        bool                    `json:"active"`
+	NodeID         string                  `json:"nodeID"`
+	Name           string                  `json:"name"`
+	NodeType       string                  `json:"nodeType"`
+	NodeTypeDesc   string                  `json:"nodeTypeDesc"`
+	TriggerOnline  bool                    `json:"triggerOnline"`
+	Description    string                  `json:"description"`
+	Commands       interface{}             `json:"commands"`
+	Meta           *PipelineNodesMetaInput `json:"meta"`
+	WorkerGroup    string                  `json:"workerGroup"`
+	Active         bool                    `json:"active"`
+	RetryPolicy    *RetryPolicyInput       `json:"retryPolicy"`
+	TimeoutSeconds *int                    `json:"timeoutSeconds"`
 }
 
 type PipelineNodesMetaInput struct {
@@ -212,22 +369,32 @@ type PipelinePermissionsOutput struct {
 	JobTitle      string `json:"JobTitle"`
 }
 
+type PipelineYAMLImport struct {
+	PipelineID string                            `json:"pipelineID"`
+	DryRun     bool                              `json:"dryRun"`
+	Errors     []*configascode.PipelineYAMLError `json:"errors"`
+}
+
 type Pipelines struct {
-	PipelineID    string    `json:"pipelineID"`
-	Name          string    `json:"name"`
-	EnvironmentID string    `json:"environmentID"`
-	Description   string    `json:"description"`
-	Active        bool      `json:"active"`
-	Online        bool      `json:"online"`
-	Current       string    `json:"current"`
-	WorkerGroup   string    `json:"workerGroup"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	NodeType      string    `json:"node_type"`
-	NodeTypeDesc  string    `json:"node_type_desc"`
-	Schedule      string    `json:"schedule"`
-	ScheduleType  string    `json:"schedule_type"`
-	Timezone      string    `json:"timezone"`
+	PipelineID        string    `json:"pipelineID"`
+	Name              string    `json:"name"`
+	EnvironmentID     string    `json:"environmentID"`
+	Description       string    `json:"description"`
+	Active            bool      `json:"active"`
+	Online            bool      `json:"online"`
+	Current           string    `json:"current"`
+	WorkerGroup       string    `json:"workerGroup"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	NodeType          string    `json:"node_type"`
+	NodeTypeDesc      string    `json:"node_type_desc"`
+	Schedule          string    `json:"schedule"`
+	ScheduleType      string    `json:"schedule_type"`
+	Timezone          string    `json:"timezone"`
+	TimeoutSeconds    int       `json:"timeoutSeconds"`
+	MaxConcurrentRuns int       `json:"maxConcurrentRuns"`
+	ConcurrencyPolicy string    `json:"concurrencyPolicy"`
+	PauseRuns         bool      `json:"pauseRuns"`
 }
 
 type Platform struct {
@@ -291,6 +458,31 @@ type RemoteWorkersProcessGroups struct {
 	Active               bool   `json:"active"`
 }
 
+type RetryPolicyInput struct {
+	MaxAttempts  int    `json:"maxAttempts"`
+	Backoff      string `json:"backoff"`
+	DelaySeconds int    `json:"delaySeconds"`
+	ExitCodes    []int  `json:"exitCodes"`
+}
+
+type RunParameterInput struct {
+	Name string `json:"name"`
+	// string, number, integer, boolean or json
+	Type        string      `json:"type"`
+	Default     interface{} `json:"default"`
+	Required    *bool       `json:"required"`
+	Description *string     `json:"description"`
+}
+
+// Runs started in a time bucket with a status, durations are of finished runs in seconds.
+type RunTrendBucket struct {
+	Bucket     time.Time `json:"bucket"`
+	Status     string    `json:"status"`
+	Runs       int       `json:"runs"`
+	P50Seconds float64   `json:"p50_seconds"`
+	P95Seconds float64   `json:"p95_seconds"`
+}
+
 type UpdateEnvironment struct {
 	ID          string  `json:"id"`
 	Name        string  `json:"name"`
@@ -323,23 +515,38 @@ type WorkerGroup struct {
 	WorkerType  string    `json:"WorkerType"`
 }
 
+// Task stats of a worker group in a time bucket, in seconds.
+type WorkerGroupStatsBucket struct {
+	Bucket          time.Time `json:"bucket"`
+	WorkerGroup     string    `json:"worker_group"`
+	Tasks           int       `json:"tasks"`
+	Fail            int       `json:"fail"`
+	P50Seconds      float64   `json:"p50_seconds"`
+	P95Seconds      float64   `json:"p95_seconds"`
+	P50QueueSeconds float64   `json:"p50_queue_seconds"`
+	P95QueueSeconds float64   `json:"p95_queue_seconds"`
+}
+
 type WorkerGroupsNodes struct {
 	NodeID      string `json:"NodeID"`
 	WorkerGroup string `json:"WorkerGroup"`
 }
 
 type WorkerTasks struct {
-	TaskID        string     `json:"task_id"`
-	EnvironmentID string     `json:"environment_id"`
-	RunID         string     `json:"run_id"`
-	WorkerGroup   string     `json:"worker_group"`
-	WorkerID      string     `json:"worker_id"`
-	PipelineID    string     `json:"pipeline_id"`
-	NodeID        string     `json:"node_id"`
-	StartDt       *time.Time `json:"start_dt"`
-	EndDt         *time.Time `json:"end_dt"`
-	Status        string     `json:"status"`
-	Reason        string     `json:"reason"`
+	TaskID         string     `json:"task_id"`
+	EnvironmentID  string     `json:"environment_id"`
+	RunID          string     `json:"run_id"`
+	WorkerGroup    string     `json:"worker_group"`
+	WorkerID       string     `json:"worker_id"`
+	PipelineID     string     `json:"pipeline_id"`
+	NodeID         string     `json:"node_id"`
+	StartDt        *time.Time `json:"start_dt"`
+	EndDt          *time.Time `json:"end_dt"`
+	Status         string     `json:"status"`
+	Reason         string     `json:"reason"`
+	Attempt        int        `json:"attempt"`
+	ExitCode       int        `json:"exit_code"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
 }
 
 type Workers struct {
@@ -0,0 +1,143 @@
+package privateresolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/auth"
+	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
+)
+
+// DecideApproval is the resolver for the decideApproval field.
+func (r *mutationResolver) DecideApproval(ctx context.Context, environmentID string, taskID string, approve bool, comment *string) (*models.ApprovalRequests, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_approve_runs", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return &models.ApprovalRequests{}, errors.New("Requires permission")
+	}
+
+	var request models.ApprovalRequests
+	err := database.DBConn.Select("task_id").Where("task_id = ? and environment_id = ?", taskID, environmentID).First(&request).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.ApprovalRequests{}, errors.New("Approval not found")
+	}
+
+	commentText := ""
+	if comment != nil {
+		commentText = *comment
+	}
+
+	request, err = pipelines.ApprovalDecide(taskID, approve, currentUser, "graphql", commentText)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.ApprovalRequests{}, err
+	}
+
+	return &request, nil
+}
+
+// GetApprovals is the resolver for the getApprovals field.
+func (r *queryResolver) GetApprovals(ctx context.Context, environmentID string, pipelineID *string, runID *string, status *string) ([]*models.ApprovalRequests, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_approve_runs", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_deployments", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return []*models.ApprovalRequests{}, errors.New("Requires permission")
+	}
+
+	query := database.DBConn.Where("environment_id = ?", environmentID)
+
+	if pipelineID != nil && *pipelineID != "" {
+		query = query.Where("pipeline_id = ?", *pipelineID)
+	}
+
+	if runID != nil && *runID != "" {
+		query = query.Where("run_id = ?", *runID)
+	}
+
+	if status != nil && *status != "" {
+		query = query.Where("status = ?", *status)
+	}
+
+	approvals := []*models.ApprovalRequests{}
+	err := query.Order("created_at desc").Limit(500).Find(&approvals).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return []*models.ApprovalRequests{}, errors.New("Retrieve approvals database error")
+	}
+
+	return approvals, nil
+}
+
+// GetApprovalLink is the resolver for the getApprovalLink field.
+func (r *queryResolver) GetApprovalLink(ctx context.Context, environmentID string, taskID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_approve_runs", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	var request models.ApprovalRequests
+	err := database.DBConn.Where("task_id = ? and environment_id = ?", taskID, environmentID).First(&request).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Approval not found")
+	}
+
+	if request.Status != "Waiting" {
+		return "", errors.New("Approval already " + request.Status)
+	}
+
+	expires := pipelines.ApprovalLinkExpires(time.Now().UTC(), request.ExpiresAt)
+
+	return "/app/approval/" + pipelines.ApprovalTokenSign(auth.JwtKey, request.TaskID, currentUser, expires), nil
+}
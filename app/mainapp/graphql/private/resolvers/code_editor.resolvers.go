@@ -15,6 +15,7 @@ import (
 	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
 	dfscache "github.com/dataplane-app/dataplane/app/mainapp/code_editor/dfs_cache"
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	gitsync "github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
@@ -123,6 +124,8 @@ func (r *mutationResolver) MoveFolderNode(ctx context.Context, folderID string,
 		return "", errors.New("Failed to update parent folder in database.")
 	}
 
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Move folder")
+
 	return "Success", nil
 }
 
@@ -194,6 +197,12 @@ func (r *mutationResolver) DeleteFolderNode(ctx context.Context, environmentID s
 		}
 	}
 
+	// Keep the records and file contents for undelete
+	snapshot, err := filesystem.FolderSnapshotCreate(f, currentUser)
+	if err != nil {
+		return "", err
+	}
+
 	// Add to database
 	d := models.FolderDeleted{
 		ID:            id,
@@ -203,6 +212,9 @@ func (r *mutationResolver) DeleteFolderNode(ctx context.Context, environmentID s
 		PipelineID:    pipelineID,
 		NodeID:        nodeID,
 		FType:         "folder",
+		Path:          folderpath,
+		UserID:        currentUser,
+		Snapshot:      snapshot,
 	}
 	err = database.DBConn.Create(&d).Error
 	if err != nil {
@@ -270,6 +282,8 @@ func (r *mutationResolver) DeleteFolderNode(ctx context.Context, environmentID s
 		return "", errors.New("Remove file invalidate file cache.")
 	}
 
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Delete folder")
+
 	return "Success", nil
 }
 
@@ -342,6 +356,8 @@ func (r *mutationResolver) RenameFolder(ctx context.Context, environmentID strin
 		return "", errors.New("Rename folder invalidate file cache.")
 	}
 
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Rename folder to "+newName)
+
 	return "Success", nil
 }
 
@@ -394,11 +410,14 @@ func (r *mutationResolver) DeleteFileNode(ctx context.Context, environmentID str
 	d := models.FolderDeleted{
 		ID:            id,
 		FileID:        fileID,
+		FolderID:      f.FolderID,
 		FileName:      f.FileName,
 		EnvironmentID: environmentID,
 		PipelineID:    pipelineID,
 		NodeID:        nodeID,
 		FType:         "file",
+		Path:          folderpath,
+		UserID:        currentUser,
 	}
 	err = database.DBConn.Create(&d).Error
 	if err != nil {
@@ -408,6 +427,12 @@ func (r *mutationResolver) DeleteFileNode(ctx context.Context, environmentID str
 		return "", errors.New("Failed to create backup trash record in database.")
 	}
 
+	// Keep the content for undelete
+	err = filesystem.FileRevisionSnapshot(f, currentUser)
+	if err != nil {
+		return "", err
+	}
+
 	// Delete file from folder
 
 	// Get environment folder id
@@ -483,6 +508,8 @@ func (r *mutationResolver) DeleteFileNode(ctx context.Context, environmentID str
 		return "", errors.New("Remove file invalidate file cache.")
 	}
 
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Delete file")
+
 	return "Success", nil
 }
 
@@ -545,6 +572,8 @@ func (r *mutationResolver) RenameFile(ctx context.Context, environmentID string,
 		logging.PrintSecretsRedact("Rename: ", dpconfig.CodeDirectory+filepath, " -> ", dpconfig.CodeDirectory+folderpath+newName)
 	}
 
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Rename file to "+newName)
+
 	return "Success", nil
 }
 
@@ -606,6 +635,8 @@ func (r *mutationResolver) MoveFileNode(ctx context.Context, fileID string, toFo
 		return "", errors.New("Move file in database failed")
 	}
 
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Move file")
+
 	return "Success", nil
 }
 
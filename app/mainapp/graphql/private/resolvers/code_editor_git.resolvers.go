@@ -0,0 +1,265 @@
+package privateresolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+
+	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	gitsync "github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+)
+
+// UpdateCodeGitRemote is the resolver for the updateCodeGitRemote field.
+func (r *mutationResolver) UpdateCodeGitRemote(ctx context.Context, pipelineID string, environmentID string, remoteURL string, branch string, username string, password *string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	err := gitsync.RemoteUpdate(pipelineID, environmentID, remoteURL, branch, username, password)
+	if err != nil {
+		return "", err
+	}
+
+	return "Success", nil
+}
+
+// PullCodeGit is the resolver for the pullCodeGit field.
+func (r *mutationResolver) PullCodeGit(ctx context.Context, pipelineID string, environmentID string) (*privategraphql.CodeGitPull, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	plan, err := gitsync.Pull(pipelineID, environmentID, currentUser)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &privategraphql.CodeGitPull{
+		Status:  plan.Status,
+		Changes: len(plan.Changes),
+	}
+	if !plan.To.IsZero() {
+		out.CommitHash = plan.To.String()
+	}
+
+	return out, nil
+}
+
+// PushCodeGit is the resolver for the pushCodeGit field.
+func (r *mutationResolver) PushCodeGit(ctx context.Context, pipelineID string, environmentID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	err := gitsync.Push(pipelineID, environmentID, currentUser)
+	if err != nil {
+		return "", err
+	}
+
+	return "Success", nil
+}
+
+// GetCodeGitCommits is the resolver for the getCodeGitCommits field.
+func (r *queryResolver) GetCodeGitCommits(ctx context.Context, pipelineID string, environmentID string, fileID *string, limit *int) ([]*privategraphql.CodeGitCommit, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	path := ""
+	if fileID != nil && *fileID != "" {
+		filePipelineID, filePath, err := gitsync.FilePath(*fileID, environmentID)
+		if err != nil {
+			return nil, err
+		}
+		if filePipelineID != pipelineID {
+			return nil, errors.New("File not found.")
+		}
+		path = filePath
+	}
+
+	max := 100
+	if limit != nil && *limit > 0 {
+		max = *limit
+	}
+
+	commits, err := gitsync.Commits(pipelineID, environmentID, path, max)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*privategraphql.CodeGitCommit{}
+	for _, c := range commits {
+		out = append(out, &privategraphql.CodeGitCommit{
+			CommitHash:  c.Hash,
+			Message:     c.Message,
+			AuthorName:  c.AuthorName,
+			AuthorEmail: c.AuthorEmail,
+			UserID:      c.UserID,
+			Action:      c.Action,
+			CreatedAt:   c.CreatedAt,
+		})
+	}
+
+	return out, nil
+}
+
+// GetCodeGitBlame is the resolver for the getCodeGitBlame field.
+func (r *queryResolver) GetCodeGitBlame(ctx context.Context, pipelineID string, environmentID string, fileID string, commitHash *string) ([]*privategraphql.CodeGitBlameLine, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	filePipelineID, path, err := gitsync.FilePath(fileID, environmentID)
+	if err != nil {
+		return nil, err
+	}
+	if filePipelineID != pipelineID {
+		return nil, errors.New("File not found.")
+	}
+
+	hash := ""
+	if commitHash != nil {
+		hash = *commitHash
+	}
+
+	lines, err := gitsync.FileBlame(pipelineID, environmentID, path, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	out := []*privategraphql.CodeGitBlameLine{}
+	for _, l := range lines {
+		out = append(out, &privategraphql.CodeGitBlameLine{
+			Line:        l.Line,
+			Text:        l.Text,
+			CommitHash:  l.Commit.Hash.String(),
+			AuthorName:  l.Commit.Author.Name,
+			AuthorEmail: l.Commit.Author.Email,
+			Date:        l.Commit.Author.When,
+		})
+	}
+
+	return out, nil
+}
+
+// GetCodeGitRemote is the resolver for the getCodeGitRemote field.
+func (r *queryResolver) GetCodeGitRemote(ctx context.Context, pipelineID string, environmentID string) (*privategraphql.CodeGitRemote, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	remote := models.CodeGitRemotes{}
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Limit(1).Find(&remote).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve git remote database error.")
+	}
+
+	if remote.RemoteURL == "" {
+		return nil, nil
+	}
+
+	return &privategraphql.CodeGitRemote{
+		PipelineID:    remote.PipelineID,
+		EnvironmentID: remote.EnvironmentID,
+		RemoteURL:     remote.RemoteURL,
+		Branch:        remote.Branch,
+		Username:      remote.Username,
+		PasswordSet:   remote.Password != "",
+		LastPullAt:    remote.LastPullAt,
+		LastPushAt:    remote.LastPushAt,
+	}, nil
+}
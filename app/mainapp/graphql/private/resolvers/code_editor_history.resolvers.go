@@ -0,0 +1,318 @@
+package privateresolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	gitsync "github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+)
+
+// RestoreCodeFileRevision is the resolver for the restoreCodeFileRevision field.
+func (r *mutationResolver) RestoreCodeFileRevision(ctx context.Context, environmentID string, pipelineID string, revisionID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	_, err := filesystem.FileRevisionGet(revisionID, environmentID, pipelineID)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := filesystem.FileRevisionRestore(revisionID, environmentID, currentUser)
+	if err != nil {
+		return "", err
+	}
+
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Restore "+file.FileName)
+
+	return "Success", nil
+}
+
+// UndeleteCode is the resolver for the undeleteCode field.
+func (r *mutationResolver) UndeleteCode(ctx context.Context, environmentID string, pipelineID string, id string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	deleted, err := filesystem.Undelete(id, environmentID, pipelineID, currentUser)
+	if err != nil {
+		return "", err
+	}
+
+	name := deleted.FileName
+	if deleted.FType != "file" {
+		name = deleted.FolderName
+	}
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Undelete "+name)
+
+	return filesystem.DeletedPath(deleted), nil
+}
+
+// GetCodeFileRevisions is the resolver for the getCodeFileRevisions field.
+func (r *queryResolver) GetCodeFileRevisions(ctx context.Context, environmentID string, pipelineID string, fileID string) ([]*privategraphql.CodeFileRevision, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	type revisionRow struct {
+		RevisionID  string
+		FileID      string
+		FileName    string
+		ChecksumMD5 string
+		Size        int
+		UserID      string
+		FirstName   string
+		LastName    string
+		Action      string
+		CreatedAt   time.Time
+	}
+
+	rows := []revisionRow{}
+	err := database.DBConn.Raw(`
+	select
+	r.revision_id,
+	r.file_id,
+	r.file_name,
+	r.checksum_md5,
+	coalesce(octet_length(r.file_store), r.size) as size,
+	r.user_id,
+	coalesce(u.first_name, '') as first_name,
+	coalesce(u.last_name, '') as last_name,
+	r.action,
+	r.created_at
+	from code_file_revisions r
+	left join users u on u.user_id = r.user_id
+	where r.file_id = ? and r.environment_id = ? and r.pipeline_id = ?
+	order by r.created_at desc
+	`, fileID, environmentID, pipelineID).Scan(&rows).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve file revisions database error.")
+	}
+
+	out := []*privategraphql.CodeFileRevision{}
+	for _, row := range rows {
+		out = append(out, &privategraphql.CodeFileRevision{
+			RevisionID:  row.RevisionID,
+			FileID:      row.FileID,
+			FileName:    row.FileName,
+			ChecksumMd5: row.ChecksumMD5,
+			Size:        row.Size,
+			UserID:      row.UserID,
+			UserName:    strings.TrimSpace(row.FirstName + " " + row.LastName),
+			Action:      row.Action,
+			CreatedAt:   row.CreatedAt,
+		})
+	}
+
+	return out, nil
+}
+
+// GetCodeFileRevisionContent is the resolver for the getCodeFileRevisionContent field.
+func (r *queryResolver) GetCodeFileRevisionContent(ctx context.Context, environmentID string, pipelineID string, revisionID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	revision, err := filesystem.FileRevisionGet(revisionID, environmentID, pipelineID)
+	if err != nil {
+		return "", err
+	}
+
+	content, err := filesystem.FileRevisionContent(revision)
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// DiffCodeFileRevisions is the resolver for the diffCodeFileRevisions field.
+func (r *queryResolver) DiffCodeFileRevisions(ctx context.Context, environmentID string, pipelineID string, fromRevisionID string, toRevisionID *string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	from, err := filesystem.FileRevisionGet(fromRevisionID, environmentID, pipelineID)
+	if err != nil {
+		return "", err
+	}
+
+	fromContent, err := filesystem.FileRevisionContent(from)
+	if err != nil {
+		return "", err
+	}
+
+	toName := "current"
+	var toContent []byte
+
+	if toRevisionID != nil && *toRevisionID != "" {
+
+		to, err := filesystem.FileRevisionGet(*toRevisionID, environmentID, pipelineID)
+		if err != nil {
+			return "", err
+		}
+		if to.FileID != from.FileID {
+			return "", errors.New("Revisions are of different files.")
+		}
+
+		toName = to.RevisionID
+		toContent, err = filesystem.FileRevisionContent(to)
+		if err != nil {
+			return "", err
+		}
+
+	} else {
+
+		toContent, _, err = filesystem.FileStoreContent(from.FileID, environmentID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return filesystem.UnifiedDiff(fromContent, toContent, from.FileName+" "+from.RevisionID, from.FileName+" "+toName), nil
+}
+
+// GetCodeDeleted is the resolver for the getCodeDeleted field.
+func (r *queryResolver) GetCodeDeleted(ctx context.Context, environmentID string, pipelineID string, nodeID *string) ([]*privategraphql.CodeDeleted, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	query := database.DBConn.Where("environment_id = ? and pipeline_id = ? and restored_at is null", environmentID, pipelineID)
+	if nodeID != nil && *nodeID != "" {
+		query = query.Where("node_id = ?", *nodeID)
+	}
+
+	deleted := []models.FolderDeleted{}
+	err := query.Order("created_at desc").Find(&deleted).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve deleted code database error.")
+	}
+
+	out := []*privategraphql.CodeDeleted{}
+	for _, d := range deleted {
+
+		name := d.FileName
+		if d.FType != "file" {
+			name = d.FolderName
+		}
+
+		out = append(out, &privategraphql.CodeDeleted{
+			ID:        d.ID,
+			FType:     d.FType,
+			FileID:    d.FileID,
+			FolderID:  d.FolderID,
+			NodeID:    d.NodeID,
+			Name:      name,
+			Path:      filesystem.DeletedPath(d),
+			UserID:    d.UserID,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+
+	return out, nil
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
 	dfscache "github.com/dataplane-app/dataplane/app/mainapp/code_editor/dfs_cache"
@@ -20,7 +21,9 @@ import (
 	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
 	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
@@ -44,11 +47,15 @@ func (r *deploymentRunsResolver) RunJSON(ctx context.Context, obj *models.Pipeli
 	return obj.RunJSON, nil
 }
 
+// Parameters is the resolver for the parameters field.
+func (r *deploymentRunsResolver) Parameters(ctx context.Context, obj *models.PipelineRuns) (interface{}, error) {
+	return obj.Parameters, nil
+}
+
 // AddDeployment is the resolver for the addDeployment field.
 func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string, fromEnvironmentID string, toEnvironmentID string, version string, workerGroup string, liveactive bool, nodeWorkerGroup []*privategraphql.WorkerGroupsNodes) (string, error) {
 	currentUser := ctx.Value("currentUser").(string)
 	platformID := ctx.Value("platformID").(string)
-	var triggerType string = ""
 
 	// ----- Deploy To Permissions
 	perms := []models.Permissions{
@@ -193,6 +200,16 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 
 		jsonstring = strings.ReplaceAll(jsonstring, pipelineID, "d-"+pipeline.PipelineID)
 
+		// A paused deployment stays paused on the new version
+		var pausedVersions int64
+		err = tx.Model(&models.DeployPipelines{}).Where("pipeline_id = ? and environment_id = ? and pause_runs = ?", "d-"+pipeline.PipelineID, toEnvironmentID, true).Count(&pausedVersions).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve deployment pause database error")
+		}
+
 		// Pipeline
 		createPipeline := models.DeployPipelines{
 			PipelineID:        "d-" + pipeline.PipelineID,
@@ -205,6 +222,11 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 			Description:       pipeline.Description,
 			Active:            pipeline.Active,
 			WorkerGroup:       workerGroup,
+			TimeoutSeconds:    pipeline.TimeoutSeconds,
+			Parameters:        pipeline.Parameters,
+			MaxConcurrentRuns: pipeline.MaxConcurrentRuns,
+			ConcurrencyPolicy: pipeline.ConcurrencyPolicy,
+			PauseRuns:         pausedVersions > 0,
 			Meta:              pipeline.Meta,
 			// Json:              pipeline.Json,
 			UpdateLock: true,
@@ -225,6 +247,8 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 				To:            "d-" + edge.To,
 				EnvironmentID: createPipeline.EnvironmentID,
 				Meta:          edge.Meta,
+				Condition:     edge.Condition,
+				Expression:    edge.Expression,
 				Active:        edge.Active,
 			})
 
@@ -244,6 +268,7 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 		// Nodes
 		var online bool
 		deployNodes := []*models.DeployPipelineNodes{}
+		subPipelineReferences := []string{}
 		for _, node := range pipelineNodes {
 
 			dependJSON, err := json.Marshal(dependencies["d-"+node.NodeID])
@@ -275,8 +300,15 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 
 			}
 
-			if node.NodeType == "trigger" && node.NodeTypeDesc == "schedule" {
-				triggerType = "schedule"
+			// Sub-pipelines run the active deployment of the pipeline in the environment deployed to
+			subPipeline := node.SubPipeline
+			if node.NodeTypeDesc == "subpipeline" {
+				if subPipeline.RunType == "pipeline" {
+					subPipeline.RunType = "deployment"
+					subPipeline.PipelineID = "d-" + subPipeline.PipelineID
+					subPipeline.Version = ""
+				}
+				subPipelineReferences = append(subPipelineReferences, subPipeline.PipelineID)
 			}
 
 			deployNodes = append(deployNodes, &models.DeployPipelineNodes{
@@ -297,14 +329,27 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 				Destination: destinationJSON,
 
 				// Needs to updated via front end sub nodes
-				WorkerGroup: workergroupassign,
-				Active:      node.Active,
+				WorkerGroup:    workergroupassign,
+				Active:         node.Active,
+				RetryPolicy:    node.RetryPolicy,
+				TimeoutSeconds: node.TimeoutSeconds,
+				SubPipeline:    subPipeline,
+				Approval:       node.Approval,
 			})
 
 			// Replace all nodes
 			jsonstring = strings.ReplaceAll(jsonstring, node.NodeID, "d-"+node.NodeID)
 		}
 
+		// Sub-pipelines can't lead back to this deployment
+		err = pipelines.SubPipelineCycleCheck(createPipeline.PipelineID, toEnvironmentID, subPipelineReferences)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Deployment error: Sub-pipeline " + err.Error())
+		}
+
 		// folders
 		deployFolders := []*models.DeployCodeFolders{}
 		for _, n := range folders {
@@ -349,6 +394,7 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 				EnvironmentID: createPipeline.EnvironmentID,
 				ChecksumMD5:   n.ChecksumMD5,
 				External:      n.External,
+				ObjectKey:     n.ObjectKey,
 				RunInclude:    n.RunInclude,
 			})
 		}
@@ -424,6 +470,16 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 			}
 		}
 
+		// Version active before this deployment, for the promotion log
+		var previousVersion string
+		err = tx.Model(&models.DeployPipelines{}).Select("version").Where("pipeline_id = ? and environment_id = ? and deploy_active = ?", "d-"+pipelineID, toEnvironmentID, true).Limit(1).Scan(&previousVersion).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve active deployment database error")
+		}
+
 		// Switch to active and take off update lock
 		err = tx.Exec(`
 	update deploy_pipelines set 
@@ -450,6 +506,26 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 			}
 		}
 
+		// Promotion log
+		err = tx.Create(&models.DeploymentPromotions{
+			ID:                uuid.NewString(),
+			DeploymentID:      createPipeline.PipelineID,
+			EnvironmentID:     toEnvironmentID,
+			Version:           version,
+			PreviousVersion:   previousVersion,
+			FromEnvironmentID: fromEnvironmentID,
+			FromPipelineID:    pipelineID,
+			Action:            "deploy",
+			UserID:            currentUser,
+			CreatedAt:         time.Now().UTC(),
+		}).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Add promotion log database error")
+		}
+
 		// Give current user full permissions
 		// Give access permissions for the user who added the pipeline
 		AccessTypes := models.DeploymentAccessTypes
@@ -474,40 +550,30 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 
 		}
 
-		// Add back to schedule
-		// ======= Update the schedule trigger ==========
-		if triggerType == "schedule" {
-			// Add back to schedule
-			var plSchedules []*models.Scheduler
-
-			// Adding an existing schedule from pipeline into deployment - needs to select pipeline
-			err := tx.Where("pipeline_id = ? and environment_id =? and run_type=?", pipelineID, fromEnvironmentID, "pipeline").Find(&plSchedules).Error
-			if err != nil && err != gorm.ErrRecordNotFound {
-				log.Println("Removal of changed trigger schedules:", err)
-				return err
+		// ======= Update the schedule and event triggers ==========
+		triggerNodes := []models.DeployPipelineNodes{}
+		for _, n := range deployNodes {
+			if n.NodeType == "trigger" {
+				triggerNodes = append(triggerNodes, *n)
 			}
+		}
 
-			if len(plSchedules) > 0 {
-				for _, psc := range plSchedules {
+		deploySchedules, deployEventTriggers := pipelines.DeploymentTriggers(triggerNodes, createPipeline.PipelineID, toEnvironmentID)
 
-					pipelineSchedules := models.Scheduler{
-						NodeID:        "d-" + psc.NodeID,
-						PipelineID:    "d-" + psc.PipelineID,
-						EnvironmentID: toEnvironmentID,
-						ScheduleType:  psc.ScheduleType,
-						Schedule:      psc.Schedule,
-						Timezone:      psc.Timezone,
-						Online:        liveactive,
-						RunType:       "deployment",
-					}
-					// Add back to schedule
-					err := messageq.MsgSend("pipeline-scheduler", pipelineSchedules)
-					if err != nil {
-						logging.PrintSecretsRedact("NATS error:", err)
-					}
-				}
+		for _, s := range deploySchedules {
+			// Add back to schedule
+			err := messageq.MsgSend("pipeline-scheduler", s)
+			if err != nil {
+				logging.PrintSecretsRedact("NATS error:", err)
 			}
+		}
 
+		err = pipelines.EventTriggersSave(tx, createPipeline.PipelineID, toEnvironmentID, deployEventTriggers)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Add deployment event triggers database error.")
 		}
 
 		return nil
@@ -517,6 +583,8 @@ func (r *mutationResolver) AddDeployment(ctx context.Context, pipelineID string,
 		return "", errors.New("Add deployment: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
 	return "OK", nil
 }
 
@@ -641,6 +709,15 @@ func (r *mutationResolver) DeleteDeployment(ctx context.Context, environmentID s
 					}
 				}
 			}
+
+			// Delete event triggers
+			err = pipelines.EventTriggersSave(tx, pipelineID, environmentID, nil)
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				return errors.New("Delete deployment event triggers database error.")
+			}
 		}
 
 		// Remove directory
@@ -713,6 +790,8 @@ func (r *mutationResolver) DeleteDeployment(ctx context.Context, environmentID s
 		return "", errors.New("Delete deployment: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
 	// Remove directory
 
 	response := "Pipeline deleted"
@@ -797,6 +876,8 @@ func (r *mutationResolver) TurnOnOffDeployment(ctx context.Context, environmentI
 						Schedule:      psc.Schedule,
 						Timezone:      psc.Timezone,
 						Online:        online,
+						Parameters:    psc.Parameters,
+						CatchUp:       psc.CatchUp,
 						RunType:       "deployment",
 					}
 					// Add back to schedule
@@ -809,6 +890,15 @@ func (r *mutationResolver) TurnOnOffDeployment(ctx context.Context, environmentI
 			}
 		}
 
+		// ======= Update the event triggers ==========
+		err = pipelines.EventTriggersOnline(tx, pipelineID, environmentID, online)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Failed to update event triggers.")
+		}
+
 		return nil
 	})
 
@@ -816,9 +906,118 @@ func (r *mutationResolver) TurnOnOffDeployment(ctx context.Context, environmentI
 		return "", errors.New("Pipeline trigger error: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
 	return "Pipeline trigger updated", nil
 }
 
+// UpdateDeploymentConcurrency is the resolver for the updateDeploymentConcurrency field.
+func (r *mutationResolver) UpdateDeploymentConcurrency(ctx context.Context, deploymentID string, environmentID string, maxConcurrentRuns int, concurrencyPolicy string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	err := pipelines.ValidateConcurrency(maxConcurrentRuns, concurrencyPolicy)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DBConn.Model(&models.DeployPipelines{}).Where("pipeline_id = ? and environment_id = ?", deploymentID, environmentID).Updates(map[string]interface{}{
+		"max_concurrent_runs": maxConcurrentRuns,
+		"concurrency_policy":  concurrencyPolicy,
+	}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Update deployment concurrency database error.")
+	}
+
+	// A higher limit lets queued runs start
+	go pipelines.RunQueueNext(deploymentID, environmentID, "deployment")
+
+	return "success", nil
+}
+
+// PauseDeploymentRuns is the resolver for the pauseDeploymentRuns field.
+func (r *mutationResolver) PauseDeploymentRuns(ctx context.Context, deploymentID string, environmentID string, paused bool) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	// All versions so that the pause holds when the online version changes
+	err := database.DBConn.Model(&models.DeployPipelines{}).Where("pipeline_id = ? and environment_id = ?", deploymentID, environmentID).Update("pause_runs", paused).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Update deployment pause database error.")
+	}
+
+	// Runs queued while paused start up to the concurrency limit
+	if !paused {
+		go pipelines.RunQueueResume(deploymentID, environmentID, "deployment")
+	}
+
+	return "success", nil
+}
+
+// RollbackDeployment is the resolver for the rollbackDeployment field.
+func (r *mutationResolver) RollbackDeployment(ctx context.Context, deploymentID string, environmentID string, version *string) (*models.DeploymentPromotions, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_deploy_here", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return &models.DeploymentPromotions{}, errors.New("Requires permission")
+	}
+
+	rollbackVersion := ""
+	if version != nil {
+		rollbackVersion = *version
+	}
+
+	promotion, err := pipelines.DeploymentRollback(deploymentID, environmentID, rollbackVersion, currentUser)
+	if err != nil {
+		return &models.DeploymentPromotions{}, errors.New("Rollback deployment: " + err.Error())
+	}
+
+	return &promotion, nil
+}
+
 // ClearFileCacheDeployment is the resolver for the clearFileCacheDeployment field.
 func (r *mutationResolver) ClearFileCacheDeployment(ctx context.Context, environmentID string, deploymentID string, version string) (string, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -881,6 +1080,10 @@ a.from_environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.updated_at,
 a.version,
@@ -918,6 +1121,10 @@ a.from_environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.updated_at,
 b.node_type,
@@ -1016,6 +1223,10 @@ a.from_environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.updated_at,
 a.version,
@@ -1053,6 +1264,10 @@ a.from_environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.updated_at,
 b.node_type,
@@ -1159,6 +1374,10 @@ a.from_environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.version,
 a.deploy_active,
@@ -1196,6 +1415,10 @@ a.from_environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.version,
 a.deploy_active,
@@ -1410,6 +1633,169 @@ func (r *queryResolver) GetDeploymentRuns(ctx context.Context, deploymentID stri
 	return pipelineRuns, nil
 }
 
+// GetDeploymentParameters is the resolver for the getDeploymentParameters field.
+func (r *queryResolver) GetDeploymentParameters(ctx context.Context, deploymentID string, environmentID string, version string) ([]*models.RunParameter, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_deployments", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	defs, err := pipelines.RunParameterDefs(deploymentID, environmentID, "deployment", version)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []*models.RunParameter{}
+	for i := range defs {
+		parameters = append(parameters, &defs[i])
+	}
+
+	return parameters, nil
+}
+
+// GetDeploymentDiff is the resolver for the getDeploymentDiff field.
+func (r *queryResolver) GetDeploymentDiff(ctx context.Context, deploymentID string, environmentID string, fromVersion string, toVersion *string) (*privategraphql.DeploymentDiff, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_deployments", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: deploymentID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return &privategraphql.DeploymentDiff{}, errors.New("Requires permission")
+	}
+
+	fromSide, err := pipelines.DeploymentDiffVersion(deploymentID, environmentID, fromVersion)
+	if err != nil {
+		return &privategraphql.DeploymentDiff{}, err
+	}
+
+	var toSide pipelines.DiffSide
+	var toLabel string
+
+	if toVersion != nil && *toVersion != "" {
+
+		toSide, err = pipelines.DeploymentDiffVersion(deploymentID, environmentID, *toVersion)
+		if err != nil {
+			return &privategraphql.DeploymentDiff{}, err
+		}
+		toLabel = *toVersion
+
+	} else {
+
+		// Compare with the pipeline the version was deployed from
+		d := models.DeployPipelines{}
+		err = database.DBConn.Select("from_pipeline_id", "from_environment_id").Where("pipeline_id = ? and environment_id = ? and version = ?", deploymentID, environmentID, fromVersion).First(&d).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return &privategraphql.DeploymentDiff{}, errors.New("Deployment version not found.")
+		}
+
+		// ----- Source pipeline permissions
+		perms = []models.Permissions{
+			{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+			{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: d.FromEnvironmentID, Access: "write", EnvironmentID: d.FromEnvironmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: d.FromEnvironmentID, Access: "read", EnvironmentID: d.FromEnvironmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: d.FromPipelineID, Access: "read", EnvironmentID: d.FromEnvironmentID},
+		}
+
+		permOutcome, _, _, _ = permissions.MultiplePermissionChecks(perms)
+
+		if permOutcome == "denied" {
+			return &privategraphql.DeploymentDiff{}, errors.New("Requires permission to the source pipeline")
+		}
+
+		toSide, err = pipelines.DeploymentDiffPipeline(d.FromPipelineID, d.FromEnvironmentID)
+		if err != nil {
+			return &privategraphql.DeploymentDiff{}, err
+		}
+		toLabel = "pipeline"
+	}
+
+	changes := []*privategraphql.DeploymentDiffChange{}
+	for _, c := range pipelines.DeploymentDiff(fromSide, toSide) {
+		changes = append(changes, &privategraphql.DeploymentDiffChange{
+			Kind:   c.Kind,
+			ID:     c.ID,
+			Name:   c.Name,
+			Change: c.Change,
+			Field:  c.Field,
+			From:   c.From,
+			To:     c.To,
+		})
+	}
+
+	return &privategraphql.DeploymentDiff{
+		FromVersion: fromVersion,
+		ToVersion:   toLabel,
+		Changes:     changes,
+	}, nil
+}
+
+// GetDeploymentPromotions is the resolver for the getDeploymentPromotions field.
+func (r *queryResolver) GetDeploymentPromotions(ctx context.Context, environmentID string, deploymentID *string) ([]*models.DeploymentPromotions, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_deployments", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	if deploymentID != nil && *deploymentID != "" {
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: *deploymentID, Access: "read", EnvironmentID: environmentID})
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return []*models.DeploymentPromotions{}, errors.New("Requires permission")
+	}
+
+	query := database.DBConn.Where("environment_id = ?", environmentID)
+
+	if deploymentID != nil && *deploymentID != "" {
+		query = query.Where("deployment_id = ?", *deploymentID)
+	}
+
+	promotions := []*models.DeploymentPromotions{}
+	err := query.Order("created_at desc").Limit(500).Find(&promotions).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return []*models.DeploymentPromotions{}, errors.New("Retrieve promotion log database error")
+	}
+
+	return promotions, nil
+}
+
 // DeploymentEdges returns privategraphql.DeploymentEdgesResolver implementation.
 func (r *Resolver) DeploymentEdges() privategraphql.DeploymentEdgesResolver {
 	return &deploymentEdgesResolver{r}
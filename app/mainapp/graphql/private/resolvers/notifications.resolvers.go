@@ -0,0 +1,424 @@
+package privateresolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/notifications"
+	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	"github.com/google/uuid"
+)
+
+// AddUpdateNotificationChannel is the resolver for the addUpdateNotificationChannel field.
+func (r *mutationResolver) AddUpdateNotificationChannel(ctx context.Context, input privategraphql.AddUpdateNotificationChannelInput) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: input.EnvironmentID, Access: "write", EnvironmentID: input.EnvironmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	channel := models.NotificationChannels{
+		ChannelID:     input.ChannelID,
+		EnvironmentID: input.EnvironmentID,
+		Name:          input.Name,
+		ChannelType:   input.ChannelType,
+		Recipients:    input.Recipients,
+		Active:        input.Active,
+	}
+
+	var existing models.NotificationChannels
+	if input.ChannelID != "" {
+		err := database.DBConn.Where("channel_id = ? and environment_id = ?", input.ChannelID, input.EnvironmentID).First(&existing).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return "", errors.New("Notification channel not found")
+		}
+	}
+
+	webhookURL := input.URL
+	if webhookURL == "" && existing.URL != "" && existing.ChannelType == input.ChannelType {
+		decrypted, err := utilities.Decrypt(existing.URL)
+		if err != nil {
+			return "", errors.New("Notification channel URL could not be decrypted")
+		}
+		webhookURL = decrypted
+	}
+
+	err := notifications.ValidateNotificationChannel(channel, webhookURL)
+	if err != nil {
+		return "", err
+	}
+
+	if channel.ChannelType != "email" {
+		channel.Recipients = ""
+		channel.URL, err = utilities.Encrypt(webhookURL)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return "", errors.New("Notification channel URL encryption failed")
+		}
+	}
+
+	if input.ChannelID == "" {
+		channel.ChannelID = uuid.NewString()
+		channel.CreatedAt = time.Now().UTC()
+		err = database.DBConn.Create(&channel).Error
+	} else {
+		err = database.DBConn.Model(&models.NotificationChannels{}).Where("channel_id = ? and environment_id = ?", input.ChannelID, input.EnvironmentID).Updates(map[string]interface{}{
+			"name":         channel.Name,
+			"channel_type": channel.ChannelType,
+			"recipients":   channel.Recipients,
+			"url":          channel.URL,
+			"active":       channel.Active,
+			"updated_at":   time.Now().UTC(),
+		}).Error
+	}
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Save notification channel database error")
+	}
+
+	return channel.ChannelID, nil
+}
+
+// DeleteNotificationChannel is the resolver for the deleteNotificationChannel field.
+func (r *mutationResolver) DeleteNotificationChannel(ctx context.Context, channelID string, environmentID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	var rules int64
+	err := database.DBConn.Model(&models.NotificationRules{}).Where("channel_id = ? and environment_id = ?", channelID, environmentID).Count(&rules).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Delete notification channel database error")
+	}
+
+	if rules > 0 {
+		return "", errors.New("Notification channel is used by notification rules")
+	}
+
+	err = database.DBConn.Where("channel_id = ? and environment_id = ?", channelID, environmentID).Delete(&models.NotificationChannels{}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Delete notification channel database error")
+	}
+
+	return "Success", nil
+}
+
+// TestNotificationChannel is the resolver for the testNotificationChannel field.
+func (r *mutationResolver) TestNotificationChannel(ctx context.Context, channelID string, environmentID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	var channel models.NotificationChannels
+	err := database.DBConn.Where("channel_id = ? and environment_id = ?", channelID, environmentID).First(&channel).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Notification channel not found")
+	}
+
+	err = notifications.Send(channel, models.NotificationDeliveries{
+		DeliveryID:    "test",
+		Event:         "test",
+		EnvironmentID: environmentID,
+		Subject:       "Dataplane: Test notification - " + channel.Name,
+		Message:       "This is a test notification from Dataplane.\n",
+		CreatedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		return "", errors.New("Test notification failed: " + err.Error())
+	}
+
+	return "Success", nil
+}
+
+// AddUpdateNotificationRule is the resolver for the addUpdateNotificationRule field.
+func (r *mutationResolver) AddUpdateNotificationRule(ctx context.Context, input privategraphql.AddUpdateNotificationRuleInput) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: input.EnvironmentID, Access: "write", EnvironmentID: input.EnvironmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: input.EnvironmentID, Access: "write", EnvironmentID: input.EnvironmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: input.PipelineID, Access: "write", EnvironmentID: input.EnvironmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: input.PipelineID, Access: "write", EnvironmentID: input.EnvironmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	rule := models.NotificationRules{
+		RuleID:        input.RuleID,
+		EnvironmentID: input.EnvironmentID,
+		PipelineID:    input.PipelineID,
+		RunType:       input.RunType,
+		Event:         input.Event,
+		SLASeconds:    input.SLASeconds,
+		ChannelID:     input.ChannelID,
+		Template:      input.Template,
+		Active:        input.Active,
+	}
+
+	err := notifications.ValidateNotificationRule(rule)
+	if err != nil {
+		return "", err
+	}
+
+	// The channel has to be in the same environment
+	var channels int64
+	err = database.DBConn.Model(&models.NotificationChannels{}).Where("channel_id = ? and environment_id = ?", input.ChannelID, input.EnvironmentID).Count(&channels).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Save notification rule database error")
+	}
+	if channels == 0 {
+		return "", errors.New("Notification channel not found")
+	}
+
+	if input.RuleID == "" {
+		rule.RuleID = uuid.NewString()
+		rule.CreatedAt = time.Now().UTC()
+		err = database.DBConn.Create(&rule).Error
+	} else {
+		result := database.DBConn.Model(&models.NotificationRules{}).Where("rule_id = ? and pipeline_id = ? and environment_id = ?", input.RuleID, input.PipelineID, input.EnvironmentID).Updates(map[string]interface{}{
+			"run_type":    rule.RunType,
+			"event":       rule.Event,
+			"sla_seconds": rule.SLASeconds,
+			"channel_id":  rule.ChannelID,
+			"template":    rule.Template,
+			"active":      rule.Active,
+			"updated_at":  time.Now().UTC(),
+		})
+		err = result.Error
+		if err == nil && result.RowsAffected == 0 {
+			return "", errors.New("Notification rule not found")
+		}
+	}
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Save notification rule database error")
+	}
+
+	return rule.RuleID, nil
+}
+
+// DeleteNotificationRule is the resolver for the deleteNotificationRule field.
+func (r *mutationResolver) DeleteNotificationRule(ctx context.Context, ruleID string, pipelineID string, environmentID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permission")
+	}
+
+	err := database.DBConn.Where("rule_id = ? and pipeline_id = ? and environment_id = ?", ruleID, pipelineID, environmentID).Delete(&models.NotificationRules{}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Delete notification rule database error")
+	}
+
+	return "Success", nil
+}
+
+// GetNotificationChannels is the resolver for the getNotificationChannels field.
+func (r *queryResolver) GetNotificationChannels(ctx context.Context, environmentID string) ([]*models.NotificationChannels, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	var channels []*models.NotificationChannels
+	err := database.DBConn.Where("environment_id = ?", environmentID).Order("name").Find(&channels).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive notification channels database error")
+	}
+
+	return channels, nil
+}
+
+// GetNotificationRules is the resolver for the getNotificationRules field.
+func (r *queryResolver) GetNotificationRules(ctx context.Context, pipelineID string, environmentID string) ([]*models.NotificationRules, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	var rules []*models.NotificationRules
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Order("created_at").Find(&rules).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive notification rules database error")
+	}
+
+	return rules, nil
+}
+
+// GetNotificationDeliveries is the resolver for the getNotificationDeliveries field.
+func (r *queryResolver) GetNotificationDeliveries(ctx context.Context, pipelineID string, environmentID string, limit int) ([]*models.NotificationDeliveries, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	if limit < 1 {
+		return nil, errors.New("Limit must be at least 1")
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	var deliveries []*models.NotificationDeliveries
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Order("created_at desc").Limit(limit).Find(&deliveries).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive notification deliveries database error")
+	}
+
+	return deliveries, nil
+}
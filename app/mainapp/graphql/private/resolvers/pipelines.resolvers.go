@@ -9,27 +9,32 @@ import (
 	"errors"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	configascode "github.com/dataplane-app/dataplane/app/mainapp/code_editor/config_as_code"
 	dfscache "github.com/dataplane-app/dataplane/app/mainapp/code_editor/dfs_cache"
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	gitsync "github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
 	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
 	git "github.com/go-git/go-git/v5"
 	"github.com/google/uuid"
 	jsoniter "github.com/json-iterator/go"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
 // AddPipeline is the resolver for the addPipeline field.
-func (r *mutationResolver) AddPipeline(ctx context.Context, name string, environmentID string, description string, workerGroup string) (string, error) {
+func (r *mutationResolver) AddPipeline(ctx context.Context, name string, environmentID string, description string, workerGroup string, timeoutSeconds *int) (string, error) {
 	currentUser := ctx.Value("currentUser").(string)
 	platformID := ctx.Value("platformID").(string)
 
@@ -53,18 +58,27 @@ func (r *mutationResolver) AddPipeline(ctx context.Context, name string, environ
 		return "", errors.New("Requires permissions.")
 	}
 
+	pipelineTimeout := 0
+	if timeoutSeconds != nil {
+		if *timeoutSeconds < 0 {
+			return "", errors.New("Timeout seconds can't be negative.")
+		}
+		pipelineTimeout = *timeoutSeconds
+	}
+
 	pipelineID := uuid.New().String()
 
 	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
 
 		e := models.Pipelines{
-			PipelineID:    pipelineID,
-			Name:          name,
-			Description:   description,
-			EnvironmentID: environmentID,
-			WorkerGroup:   workerGroup,
-			Active:        true,
-			UpdateLock:    true,
+			PipelineID:     pipelineID,
+			Name:           name,
+			Description:    description,
+			EnvironmentID:  environmentID,
+			WorkerGroup:    workerGroup,
+			TimeoutSeconds: pipelineTimeout,
+			Active:         true,
+			UpdateLock:     true,
 		}
 
 		err := tx.Create(&e).Error
@@ -152,7 +166,7 @@ func (r *mutationResolver) AddPipeline(ctx context.Context, name string, environ
 }
 
 // UpdatePipeline is the resolver for the updatePipeline field.
-func (r *mutationResolver) UpdatePipeline(ctx context.Context, pipelineID string, name string, environmentID string, description string, workerGroup string) (string, error) {
+func (r *mutationResolver) UpdatePipeline(ctx context.Context, pipelineID string, name string, environmentID string, description string, workerGroup string, timeoutSeconds *int) (string, error) {
 	currentUser := ctx.Value("currentUser").(string)
 	platformID := ctx.Value("platformID").(string)
 
@@ -170,15 +184,27 @@ func (r *mutationResolver) UpdatePipeline(ctx context.Context, pipelineID string
 		return "", errors.New("Requires permissions.")
 	}
 
+	// The timeout is left as is when not provided
+	updateColumns := []string{"description", "name", "worker_group"}
+	pipelineTimeout := 0
+	if timeoutSeconds != nil {
+		if *timeoutSeconds < 0 {
+			return "", errors.New("Timeout seconds can't be negative.")
+		}
+		pipelineTimeout = *timeoutSeconds
+		updateColumns = append(updateColumns, "timeout_seconds")
+	}
+
 	p := models.Pipelines{}
 
 	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
 
-		err := tx.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Select("description", "name", "worker_group").
+		err := tx.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Select(updateColumns).
 			Updates(models.Pipelines{
-				Name:        name,
-				Description: description,
-				WorkerGroup: workerGroup,
+				Name:           name,
+				Description:    description,
+				WorkerGroup:    workerGroup,
+				TimeoutSeconds: pipelineTimeout,
 			}).First(&p).Error
 
 		if err != nil {
@@ -345,14 +371,18 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 		pipelineIDNew := uuid.New().String()
 
 		e := models.Pipelines{
-			PipelineID:    pipelineIDNew,
-			Name:          name,
-			Description:   description,
-			EnvironmentID: environmentID,
-			WorkerGroup:   workerGroup,
-			Meta:          pipeline.Meta,
-			Active:        true,
-			UpdateLock:    true,
+			PipelineID:        pipelineIDNew,
+			Name:              name,
+			Description:       description,
+			EnvironmentID:     environmentID,
+			WorkerGroup:       workerGroup,
+			TimeoutSeconds:    pipeline.TimeoutSeconds,
+			Parameters:        pipeline.Parameters,
+			MaxConcurrentRuns: pipeline.MaxConcurrentRuns,
+			ConcurrencyPolicy: pipeline.ConcurrencyPolicy,
+			Meta:              pipeline.Meta,
+			Active:            true,
+			UpdateLock:        true,
 		}
 
 		// Give access permissions for the user who added the pipeline
@@ -507,6 +537,8 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 				To:            nodesOLDNew[edge.To],
 				EnvironmentID: environmentID,
 				Meta:          edge.Meta,
+				Condition:     edge.Condition,
+				Expression:    edge.Expression,
 				Active:        edge.Active,
 			})
 
@@ -582,8 +614,12 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 				Destination: destinationJSON,
 
 				// Needs to updated via front end sub nodes
-				WorkerGroup: node.WorkerGroup,
-				Active:      node.Active,
+				WorkerGroup:    node.WorkerGroup,
+				Active:         node.Active,
+				RetryPolicy:    node.RetryPolicy,
+				TimeoutSeconds: node.TimeoutSeconds,
+				SubPipeline:    node.SubPipeline,
+				Approval:       node.Approval,
 			})
 		}
 
@@ -643,6 +679,7 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 				EnvironmentID: n.EnvironmentID,
 				ChecksumMD5:   n.ChecksumMD5,
 				External:      n.External,
+				ObjectKey:     n.ObjectKey,
 				RunInclude:    n.RunInclude,
 			})
 		}
@@ -794,6 +831,8 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 						Schedule:      psc.Schedule,
 						Timezone:      psc.Timezone,
 						Online:        psc.Online,
+						Parameters:    psc.Parameters,
+						CatchUp:       psc.CatchUp,
 						RunType:       "pipeline",
 					}
 					// Add back to schedule
@@ -829,6 +868,31 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 			r.GeneratePipelineTrigger(ctx, e.PipelineID, e.EnvironmentID, triggerID, apiKeyActive, publicLive, privateLive)
 		}
 
+		// ======= Copy the event triggers ==========
+		var plEventTriggers []models.EventTriggers
+		err = tx.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&plEventTriggers).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrive pipeline event triggers database error.")
+		}
+
+		for i := range plEventTriggers {
+			plEventTriggers[i].NodeID = nodesOLDNew[plEventTriggers[i].NodeID]
+			plEventTriggers[i].PipelineID = pipelineIDNew
+			plEventTriggers[i].CreatedAt = time.Now().UTC()
+			plEventTriggers[i].UpdatedAt = nil
+		}
+
+		err = pipelines.EventTriggersSave(tx, pipelineIDNew, environmentID, plEventTriggers)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Duplicate pipeline event triggers database error.")
+		}
+
 		return nil
 	})
 
@@ -836,6 +900,8 @@ func (r *mutationResolver) DuplicatePipeline(ctx context.Context, pipelineID str
 		return "", errors.New("Duplicate pipeline error: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
 	return "Success", nil
 }
 
@@ -848,6 +914,7 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 	var dependencies = make(map[string][]string)
 	var triggerType string = ""
 	var pipelineSchedules = models.Scheduler{}
+	var eventTriggers = []models.EventTriggers{}
 
 	// ----- Permissions
 	perms := []models.Permissions{
@@ -871,10 +938,12 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 
 	// ---- check for duplicate triggers ------
 	var triggercount int
+	triggerNodes := make(map[string]bool)
 	for _, p := range input.NodesInput {
 
 		if p.NodeType == "trigger" {
 			triggercount++
+			triggerNodes[p.NodeID] = true
 		}
 
 	}
@@ -899,6 +968,25 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 				return err
 			}
 
+			// ----- Edge condition ----------
+			condition := models.EdgeCondition{Condition: "success"}
+			if p.Condition != nil && *p.Condition != "" {
+				condition.Condition = *p.Condition
+			}
+			if condition.Condition == "expression" && p.Expression != nil {
+				condition.Expression = *p.Expression
+			}
+
+			err = pipelines.ValidateEdgeCondition(condition)
+			if err != nil {
+				return errors.New("Update pipeline error: " + err.Error())
+			}
+
+			// A trigger always succeeds
+			if triggerNodes[p.From] && condition.Condition != "success" && condition.Condition != "always" {
+				return errors.New("Update pipeline error: Edges from a trigger can only be success or always")
+			}
+
 			edges = append(edges, &models.PipelineEdges{
 				EdgeID:        p.EdgeID,
 				PipelineID:    pipelineID,
@@ -906,6 +994,8 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 				To:            p.To,
 				EnvironmentID: environmentID,
 				Meta:          edgeMeta,
+				Condition:     condition.Condition,
+				Expression:    condition.Expression,
 				Active:        true,
 			})
 
@@ -953,6 +1043,7 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 		// ----- Add pipeline nodes to database
 
 		nodes := []models.PipelineNodes{}
+		subPipelineReferences := []string{}
 
 		for _, p := range input.NodesInput {
 
@@ -1006,6 +1097,17 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 						return errors.New("Update pipeline error: Schedule type missing")
 					}
 
+					// Run parameter values for scheduled runs
+					var scheduleParameters datatypes.JSON
+					if raw := jsoniter.Get(schedulejson, "parameters"); raw.ValueType() == jsoniter.ObjectValue {
+						scheduleParameters = datatypes.JSON(raw.ToString())
+					}
+
+					_, err = pipelines.RunParameters(pipelineID, environmentID, "pipeline", "", scheduleParameters)
+					if err != nil {
+						return errors.New("Update pipeline error: Schedule " + err.Error())
+					}
+
 					pipelineSchedules = models.Scheduler{
 						NodeID:        p.NodeID,
 						PipelineID:    pipelineID,
@@ -1014,11 +1116,147 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 						Schedule:      schedule,
 						Timezone:      timezone,
 						Online:        online,
+						Parameters:    scheduleParameters,
+						CatchUp:       jsoniter.Get(schedulejson, "catchUp").ToBool(),
 						RunType:       "pipeline",
 					}
 
 				}
 
+				// if the trigger is an event then register the event trigger
+				switch p.NodeTypeDesc {
+				case "file", "nats", "pipeline":
+
+					triggerjson, _ := json.Marshal(p.Meta.Data.Genericdata)
+
+					workerGroup := jsoniter.Get(triggerjson, "workerGroup").ToString()
+					if workerGroup == "" {
+						workerGroup = p.WorkerGroup
+					}
+
+					sourceStatus := jsoniter.Get(triggerjson, "sourceStatus").ToString()
+					if sourceStatus == "" {
+						sourceStatus = "Success"
+					}
+
+					// Run parameter values for triggered runs
+					var triggerParameters datatypes.JSON
+					if raw := jsoniter.Get(triggerjson, "parameters"); raw.ValueType() == jsoniter.ObjectValue {
+						triggerParameters = datatypes.JSON(raw.ToString())
+					}
+
+					_, err := pipelines.RunParameters(pipelineID, environmentID, "pipeline", "", triggerParameters)
+					if err != nil {
+						return errors.New("Update pipeline error: Trigger " + err.Error())
+					}
+
+					eventTrigger := models.EventTriggers{
+						NodeID:           p.NodeID,
+						PipelineID:       pipelineID,
+						EnvironmentID:    environmentID,
+						TriggerType:      p.NodeTypeDesc,
+						RunType:          "pipeline",
+						Online:           online,
+						Parameters:       triggerParameters,
+						WorkerGroup:      workerGroup,
+						Path:             jsoniter.Get(triggerjson, "path").ToString(),
+						Pattern:          jsoniter.Get(triggerjson, "pattern").ToString(),
+						Subject:          jsoniter.Get(triggerjson, "subject").ToString(),
+						SourcePipelineID: jsoniter.Get(triggerjson, "sourcePipelineID").ToString(),
+						SourceStatus:     sourceStatus,
+					}
+
+					err = pipelines.ValidateEventTrigger(eventTrigger)
+					if err != nil {
+						return errors.New("Update pipeline error: " + err.Error())
+					}
+
+					eventTriggers = append(eventTriggers, eventTrigger)
+				}
+
+			}
+
+			// ----- Sub-pipeline ----------
+			subPipeline := models.SubPipeline{}
+			if p.NodeTypeDesc == "subpipeline" {
+
+				subjson, _ := json.Marshal(p.Meta.Data.Genericdata)
+
+				subPipeline = models.SubPipeline{
+					PipelineID: jsoniter.Get(subjson, "pipelineID").ToString(),
+					RunType:    jsoniter.Get(subjson, "runType").ToString(),
+					Version:    jsoniter.Get(subjson, "version").ToString(),
+				}
+
+				if subPipeline.RunType == "" {
+					subPipeline.RunType = "pipeline"
+				}
+
+				// Run parameter values for the child run
+				if raw := jsoniter.Get(subjson, "parameters"); raw.ValueType() == jsoniter.ObjectValue {
+					subPipeline.Parameters = datatypes.JSON(raw.ToString())
+				}
+
+				err := pipelines.SubPipelineValidate(pipelineID, environmentID, subPipeline)
+				if err != nil {
+					return errors.New("Update pipeline error: " + err.Error())
+				}
+
+				subPipelineReferences = append(subPipelineReferences, subPipeline.PipelineID)
+			}
+
+			// ----- Approval ----------
+			approval := models.Approval{}
+			if p.NodeTypeDesc == "approval" {
+
+				approvaljson, _ := json.Marshal(p.Meta.Data.Genericdata)
+
+				approval = models.Approval{
+					Message:        jsoniter.Get(approvaljson, "message").ToString(),
+					TimeoutSeconds: jsoniter.Get(approvaljson, "timeoutSeconds").ToInt(),
+				}
+
+				if approval.TimeoutSeconds < 0 {
+					return errors.New("Update pipeline error: Approval timeout can't be negative")
+				}
+
+				approval.TimeoutAction, err = pipelines.ApprovalTimeoutAction(jsoniter.Get(approvaljson, "timeoutAction").ToString())
+				if err != nil {
+					return errors.New("Update pipeline error: " + err.Error())
+				}
+			}
+
+			// ----- Retry policy ----------
+			retryPolicy := models.RetryPolicy{MaxAttempts: 1}
+			if p.RetryPolicy != nil {
+
+				if p.RetryPolicy.MaxAttempts < 1 {
+					return errors.New("Update pipeline error: Retry max attempts must be at least 1")
+				}
+
+				if p.RetryPolicy.Backoff != "fixed" && p.RetryPolicy.Backoff != "exponential" {
+					return errors.New("Update pipeline error: Retry backoff must be fixed or exponential")
+				}
+
+				if p.RetryPolicy.DelaySeconds < 0 {
+					return errors.New("Update pipeline error: Retry delay can't be negative")
+				}
+
+				retryPolicy = models.RetryPolicy{
+					MaxAttempts:  p.RetryPolicy.MaxAttempts,
+					Backoff:      p.RetryPolicy.Backoff,
+					DelaySeconds: p.RetryPolicy.DelaySeconds,
+					ExitCodes:    p.RetryPolicy.ExitCodes,
+				}
+			}
+
+			// ----- Timeout, 0 = use the pipeline timeout ----------
+			timeout := 0
+			if p.TimeoutSeconds != nil {
+				if *p.TimeoutSeconds < 0 {
+					return errors.New("Update pipeline error: Timeout seconds can't be negative")
+				}
+				timeout = *p.TimeoutSeconds
 			}
 
 			nodeMeta, err := json.Marshal(p.Meta)
@@ -1046,24 +1284,37 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 			}
 
 			nodes = append(nodes, models.PipelineNodes{
-				NodeID:        p.NodeID,
-				PipelineID:    pipelineID,
-				Name:          p.Name,
-				EnvironmentID: environmentID,
-				NodeType:      p.NodeType,
-				NodeTypeDesc:  p.NodeTypeDesc,
-				WorkerGroup:   p.WorkerGroup,
-				Description:   p.Description,
-				Commands:      commandJSON,
-				Meta:          nodeMeta,
-				Dependency:    dependJSON,
-				Destination:   destinationJSON,
-				Active:        true,
-				TriggerOnline: online,
+				NodeID:         p.NodeID,
+				PipelineID:     pipelineID,
+				Name:           p.Name,
+				EnvironmentID:  environmentID,
+				NodeType:       p.NodeType,
+				NodeTypeDesc:   p.NodeTypeDesc,
+				WorkerGroup:    p.WorkerGroup,
+				Description:    p.Description,
+				Commands:       commandJSON,
+				Meta:           nodeMeta,
+				Dependency:     dependJSON,
+				Destination:    destinationJSON,
+				Active:         true,
+				TriggerOnline:  online,
+				RetryPolicy:    retryPolicy,
+				TimeoutSeconds: timeout,
+				SubPipeline:    subPipeline,
+				Approval:       approval,
 			})
 
 		}
 
+		// ----- Sub-pipelines can't lead back to this pipeline
+		err = pipelines.SubPipelineCycleCheck(pipelineID, environmentID, subPipelineReferences)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Update pipeline error: Sub-pipeline " + err.Error())
+		}
+
 		// ========== Remove the previous graph ==================:
 		// ----- Delete old edges
 		edge := models.PipelineEdges{}
@@ -1151,6 +1402,15 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 
 		}
 
+		// ======= Update the event triggers ==========
+		err = pipelines.EventTriggersSave(tx, pipelineID, environmentID, eventTriggers)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Update pipeline event triggers database error")
+		}
+
 		// ====== create folders =======
 		// var parentfolder models.CodeFolders
 		// database.DBConn.Where("environment_id = ? and pipeline_id = ? and level = ?", environmentID, pipelineID, "pipeline").First(&parentfolder)
@@ -1197,9 +1457,448 @@ func (r *mutationResolver) AddUpdatePipelineFlow(ctx context.Context, input *pri
 		return "", errors.New("Update pipeline error: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
+	// Node folders and their default files
+	gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Update pipeline flow")
+
+	return "success", nil
+}
+
+// UpdatePipelineParameters is the resolver for the updatePipelineParameters field.
+func (r *mutationResolver) UpdatePipelineParameters(ctx context.Context, pipelineID string, environmentID string, parameters []*privategraphql.RunParameterInput) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	defs := []models.RunParameter{}
+	for _, p := range parameters {
+
+		def := models.RunParameter{
+			Name: p.Name,
+			Type: p.Type,
+		}
+
+		if p.Default != nil {
+			defaultJSON, err := json.Marshal(p.Default)
+			if err != nil {
+				return "", errors.New("Update pipeline parameters error: default of " + p.Name + " is not valid")
+			}
+			def.Default = defaultJSON
+		}
+
+		if p.Required != nil {
+			def.Required = *p.Required
+		}
+
+		if p.Description != nil {
+			def.Description = *p.Description
+		}
+
+		defs = append(defs, def)
+	}
+
+	err := pipelines.ValidateRunParameterDefs(defs)
+	if err != nil {
+		return "", errors.New("Update pipeline parameters error: " + err.Error())
+	}
+
+	err = database.DBConn.Model(&models.Pipelines{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Select("parameters").Updates(&models.Pipelines{Parameters: defs}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Update pipeline parameters database error.")
+	}
+
+	return "success", nil
+}
+
+// UpdatePipelineConcurrency is the resolver for the updatePipelineConcurrency field.
+func (r *mutationResolver) UpdatePipelineConcurrency(ctx context.Context, pipelineID string, environmentID string, maxConcurrentRuns int, concurrencyPolicy string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	err := pipelines.ValidateConcurrency(maxConcurrentRuns, concurrencyPolicy)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DBConn.Model(&models.Pipelines{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Updates(map[string]interface{}{
+		"max_concurrent_runs": maxConcurrentRuns,
+		"concurrency_policy":  concurrencyPolicy,
+	}).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Update pipeline concurrency database error.")
+	}
+
+	// A higher limit lets queued runs start
+	go pipelines.RunQueueNext(pipelineID, environmentID, "pipeline")
+
+	return "success", nil
+}
+
+// PausePipelineRuns is the resolver for the pausePipelineRuns field.
+func (r *mutationResolver) PausePipelineRuns(ctx context.Context, pipelineID string, environmentID string, paused bool) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	err := database.DBConn.Model(&models.Pipelines{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Update("pause_runs", paused).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return "", errors.New("Update pipeline pause database error.")
+	}
+
+	// Runs queued while paused start up to the concurrency limit
+	if !paused {
+		go pipelines.RunQueueResume(pipelineID, environmentID, "pipeline")
+	}
+
 	return "success", nil
 }
 
+// ImportPipelineYaml is the resolver for the importPipelineYAML field.
+func (r *mutationResolver) ImportPipelineYaml(ctx context.Context, environmentID string, yaml string, pipelineID *string, dryRun *bool) (*privategraphql.PipelineYAMLImport, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	existingID := ""
+	if pipelineID != nil {
+		existingID = *pipelineID
+	}
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	if existingID == "" {
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_create_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+	} else {
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: existingID, Access: "write", EnvironmentID: environmentID})
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permissions.")
+	}
+
+	result := &privategraphql.PipelineYAMLImport{
+		PipelineID: existingID,
+		DryRun:     dryRun != nil && *dryRun,
+		Errors:     []*configascode.PipelineYAMLError{},
+	}
+
+	p, errs := configascode.PipelineYAMLParse([]byte(yaml))
+
+	// ----- Checks against this environment
+	if len(errs) == 0 {
+
+		for i, s := range p.Spec.Secrets {
+
+			var count int64
+			err := database.DBConn.Model(&models.WorkerSecrets{}).Where("secret_id = ? and worker_group_id = ? and environment_id = ?", s.Secret, s.WorkerGroup, environmentID).Count(&count).Error
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				return nil, errors.New("Retrieve worker secrets database error.")
+			}
+
+			if count == 0 {
+				path := "spec.secrets[" + strconv.Itoa(i) + "]"
+				errs = append(errs, configascode.PipelineYAMLError{
+					Path:    path,
+					Line:    configascode.PipelineYAMLLine([]byte(yaml), path),
+					Message: "Secret " + s.Secret + " is not set up for worker group " + s.WorkerGroup + " in this environment",
+				})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		for i := range errs {
+			result.Errors = append(result.Errors, &errs[i])
+		}
+		return result, nil
+	}
+
+	// Node IDs are unique across pipelines, only the nodes of the pipeline replaced keep theirs
+	keepIDs := map[string]bool{}
+	triggerID := uuid.NewString()
+
+	if existingID != "" {
+
+		var count int64
+		err := database.DBConn.Model(&models.Pipelines{}).Where("pipeline_id = ? and environment_id = ?", existingID, environmentID).Count(&count).Error
+		if err != nil || count == 0 {
+			return nil, errors.New("Pipeline not found.")
+		}
+
+		existingNodes := []models.PipelineNodes{}
+		err = database.DBConn.Select("node_id").Where("pipeline_id = ? and environment_id = ?", existingID, environmentID).Find(&existingNodes).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return nil, errors.New("Retrieve pipeline nodes database error.")
+		}
+
+		for _, n := range existingNodes {
+			keepIDs[n.NodeID] = true
+		}
+
+		existingTrigger := models.PipelineApiTriggers{}
+		database.DBConn.Where("pipeline_id = ? and environment_id = ?", existingID, environmentID).Limit(1).Find(&existingTrigger)
+		if existingTrigger.TriggerID != "" {
+			triggerID = existingTrigger.TriggerID
+		}
+	}
+
+	if result.DryRun {
+		return result, nil
+	}
+
+	nodeIDs := map[string]string{}
+	for _, n := range p.Spec.Nodes {
+		if keepIDs[n.ID] {
+			nodeIDs[n.ID] = n.ID
+		} else {
+			nodeIDs[n.ID] = uuid.NewString()
+		}
+	}
+
+	// ----- Pipeline settings
+	timeout := p.Spec.TimeoutSeconds
+	if existingID == "" {
+
+		newID, err := r.AddPipeline(ctx, p.Metadata.Name, environmentID, p.Metadata.Description, p.Spec.WorkerGroup, &timeout)
+		if err != nil {
+			return nil, err
+		}
+		result.PipelineID = newID
+
+	} else {
+
+		_, err := r.UpdatePipeline(ctx, existingID, p.Metadata.Name, environmentID, p.Metadata.Description, p.Spec.WorkerGroup, &timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A new pipeline that failed part way is removed, a replaced pipeline keeps what was saved
+	importFailed := func(err error) (*privategraphql.PipelineYAMLImport, error) {
+		if existingID == "" {
+			_, errdelete := r.DeletePipeline(ctx, environmentID, result.PipelineID)
+			if errdelete != nil && dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(errdelete)
+			}
+		}
+		return nil, errors.New("Import pipeline error: " + err.Error())
+	}
+
+	parameters := []*privategraphql.RunParameterInput{}
+	for _, prm := range p.Spec.Parameters {
+		prm := prm
+		parameters = append(parameters, &privategraphql.RunParameterInput{
+			Name:        prm.Name,
+			Type:        prm.Type,
+			Default:     prm.Default,
+			Required:    &prm.Required,
+			Description: &prm.Description,
+		})
+	}
+
+	_, err := r.UpdatePipelineParameters(ctx, result.PipelineID, environmentID, parameters)
+	if err != nil {
+		return importFailed(err)
+	}
+
+	concurrencyPolicy := p.Spec.ConcurrencyPolicy
+	if concurrencyPolicy == "" {
+		concurrencyPolicy = "queue"
+	}
+
+	_, err = r.UpdatePipelineConcurrency(ctx, result.PipelineID, environmentID, p.Spec.MaxConcurrentRuns, concurrencyPolicy)
+	if err != nil {
+		return importFailed(err)
+	}
+
+	// ----- Graph, saved as the editor saves it
+	flow := privategraphql.PipelineFlowInput{
+		NodesInput: []*privategraphql.PipelineNodesInput{},
+		EdgesInput: []*privategraphql.PipelineEdgesInput{},
+		JSON:       p,
+	}
+
+	for i, n := range p.Spec.Nodes {
+
+		genericdata := map[string]interface{}{}
+		for k, v := range n.Settings {
+			genericdata[k] = v
+		}
+
+		if n.Schedule != nil {
+			genericdata["schedule"] = n.Schedule.Schedule
+			genericdata["scheduleType"] = n.Schedule.ScheduleType
+			genericdata["timezone"] = n.Schedule.Timezone
+			genericdata["catchUp"] = n.Schedule.CatchUp
+			if len(n.Schedule.Parameters) > 0 {
+				genericdata["parameters"] = n.Schedule.Parameters
+			}
+		}
+
+		// Without a position nodes are laid out left to right
+		position := &privategraphql.PositionInput{X: float64(i) * 250, Y: 0}
+		if n.Position != nil {
+			position = &privategraphql.PositionInput{X: n.Position.X, Y: n.Position.Y}
+		}
+
+		workerGroup := n.WorkerGroup
+		if workerGroup == "" {
+			workerGroup = p.Spec.WorkerGroup
+		}
+
+		nodeTypeDesc := n.TypeDesc
+		if n.Type == "checkpoint" {
+			nodeTypeDesc = "checkpoint"
+		}
+
+		commands := []map[string]string{}
+		for _, c := range n.Commands {
+			commands = append(commands, map[string]string{"command": c})
+		}
+
+		timeoutSeconds := n.TimeoutSeconds
+
+		node := &privategraphql.PipelineNodesInput{
+			NodeID:        nodeIDs[n.ID],
+			Name:          n.Name,
+			NodeType:      n.Type,
+			NodeTypeDesc:  nodeTypeDesc,
+			TriggerOnline: n.Online,
+			Description:   n.Description,
+			Commands:      commands,
+			Meta: &privategraphql.PipelineNodesMetaInput{
+				Position: position,
+				Data: &privategraphql.DataInput{
+					Language:    n.Language,
+					Genericdata: genericdata,
+				},
+			},
+			WorkerGroup:    workerGroup,
+			Active:         true,
+			TimeoutSeconds: &timeoutSeconds,
+		}
+
+		if n.Retry != nil {
+			node.RetryPolicy = &privategraphql.RetryPolicyInput{
+				MaxAttempts:  n.Retry.MaxAttempts,
+				Backoff:      n.Retry.Backoff,
+				DelaySeconds: n.Retry.DelaySeconds,
+				ExitCodes:    n.Retry.ExitCodes,
+			}
+		}
+
+		flow.NodesInput = append(flow.NodesInput, node)
+	}
+
+	for _, e := range p.Spec.Edges {
+
+		condition := e.Condition
+		expression := e.Expression
+
+		flow.EdgesInput = append(flow.EdgesInput, &privategraphql.PipelineEdgesInput{
+			EdgeID: uuid.NewString(),
+			From:   nodeIDs[e.From],
+			To:     nodeIDs[e.To],
+			Meta: &privategraphql.PipelineEdgesMetaInput{
+				SourceHandle:  e.SourceHandle,
+				TargetHandle:  e.TargetHandle,
+				EdgeType:      "custom",
+				ArrowHeadType: "arrowclosed",
+			},
+			Active:     true,
+			Condition:  &condition,
+			Expression: &expression,
+		})
+	}
+
+	_, err = r.AddUpdatePipelineFlow(ctx, &flow, environmentID, result.PipelineID)
+	if err != nil {
+		return importFailed(err)
+	}
+
+	// ----- API trigger and code, node folders exist once the flow is saved
+	for _, n := range p.Spec.Nodes {
+
+		if n.TypeDesc == "api" && n.API != nil {
+			_, err = r.GeneratePipelineTrigger(ctx, result.PipelineID, environmentID, triggerID, n.API.APIKeyActive, n.API.PublicLive, n.API.PrivateLive)
+			if err != nil {
+				return importFailed(err)
+			}
+		}
+
+		err = configascode.PipelineYAMLCodeWrite(result.PipelineID, environmentID, nodeIDs[n.ID], currentUser, n.Code)
+		if err != nil {
+			return importFailed(err)
+		}
+	}
+
+	gitsync.CommitChanges(result.PipelineID, environmentID, currentUser, "Import pipeline YAML")
+
+	return result, nil
+}
+
 // DeletePipeline is the resolver for the deletePipeline field.
 func (r *mutationResolver) DeletePipeline(ctx context.Context, environmentID string, pipelineID string) (string, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -1268,6 +1967,15 @@ func (r *mutationResolver) DeletePipeline(ctx context.Context, environmentID str
 			return errors.New("Delete pipeline database error.")
 		}
 
+		f3 := models.CodeFileRevisions{}
+		errdb = tx.Where("pipeline_id = ? and environment_id =?", pipelineID, environmentID).Delete(&f3).Error
+		if errdb != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(errdb)
+			}
+			return errors.New("Delete pipeline file revisions database error.")
+		}
+
 		// Delete the pipeline
 		p := models.Pipelines{}
 
@@ -1323,6 +2031,15 @@ func (r *mutationResolver) DeletePipeline(ctx context.Context, environmentID str
 			}
 		}
 
+		// Delete event triggers
+		err = pipelines.EventTriggersSave(tx, pipelineID, environmentID, nil)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Delete pipeline event triggers database error.")
+		}
+
 		return nil
 	})
 
@@ -1330,6 +2047,8 @@ func (r *mutationResolver) DeletePipeline(ctx context.Context, environmentID str
 		return "", errors.New("Pipeline delete error: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
 	response := "Pipeline deleted"
 	return response, nil
 }
@@ -1407,6 +2126,8 @@ func (r *mutationResolver) TurnOnOffPipeline(ctx context.Context, environmentID
 						Schedule:      psc.Schedule,
 						Timezone:      psc.Timezone,
 						Online:        online,
+						Parameters:    psc.Parameters,
+						CatchUp:       psc.CatchUp,
 						RunType:       "pipeline",
 					}
 					// Add back to schedule
@@ -1419,6 +2140,15 @@ func (r *mutationResolver) TurnOnOffPipeline(ctx context.Context, environmentID
 			}
 		}
 
+		// ======= Update the event triggers ==========
+		err = pipelines.EventTriggersOnline(tx, pipelineID, environmentID, online)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Failed to update event triggers.")
+		}
+
 		return nil
 	})
 
@@ -1426,6 +2156,8 @@ func (r *mutationResolver) TurnOnOffPipeline(ctx context.Context, environmentID
 		return "", errors.New("Turn on or off pipeline error: " + err.Error())
 	}
 
+	pipelines.EventTriggersReload()
+
 	return "Pipeline trigger updated", nil
 }
 
@@ -1520,6 +2252,10 @@ a.environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.updated_at,
 b.node_type,
@@ -1554,6 +2290,10 @@ a.environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 a.updated_at,
 b.node_type,
@@ -1666,6 +2406,10 @@ a.environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 b.node_type,
 b.node_type_desc,
@@ -1700,6 +2444,10 @@ a.environment_id,
 a.description,
 a.active,
 a.worker_group,
+a.timeout_seconds,
+a.max_concurrent_runs,
+a.concurrency_policy,
+a.pause_runs,
 a.created_at,
 b.node_type,
 b.node_type_desc,
@@ -1868,6 +2616,76 @@ func (r *queryResolver) GetNode(ctx context.Context, nodeID string, environmentI
 	return &nodes, nil
 }
 
+// GetPipelineParameters is the resolver for the getPipelineParameters field.
+func (r *queryResolver) GetPipelineParameters(ctx context.Context, pipelineID string, environmentID string) ([]*models.RunParameter, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	defs, err := pipelines.RunParameterDefs(pipelineID, environmentID, "pipeline", "")
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := []*models.RunParameter{}
+	for i := range defs {
+		parameters = append(parameters, &defs[i])
+	}
+
+	return parameters, nil
+}
+
+// ExportPipelineYaml is the resolver for the exportPipelineYAML field.
+func (r *queryResolver) ExportPipelineYaml(ctx context.Context, pipelineID string, environmentID string) (string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return "", errors.New("Requires permissions.")
+	}
+
+	out, err := configascode.PipelineYAMLExport(pipelineID, environmentID)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// Default is the resolver for the default field.
+func (r *runParameterResolver) Default(ctx context.Context, obj *models.RunParameter) (interface{}, error) {
+	return obj.Default, nil
+}
+
 // PipelineEdges returns privategraphql.PipelineEdgesResolver implementation.
 func (r *Resolver) PipelineEdges() privategraphql.PipelineEdgesResolver {
 	return &pipelineEdgesResolver{r}
@@ -1878,5 +2696,11 @@ func (r *Resolver) PipelineNodes() privategraphql.PipelineNodesResolver {
 	return &pipelineNodesResolver{r}
 }
 
+// RunParameter returns privategraphql.RunParameterResolver implementation.
+func (r *Resolver) RunParameter() privategraphql.RunParameterResolver {
+	return &runParameterResolver{r}
+}
+
 type pipelineEdgesResolver struct{ *Resolver }
 type pipelineNodesResolver struct{ *Resolver }
+type runParameterResolver struct{ *Resolver }
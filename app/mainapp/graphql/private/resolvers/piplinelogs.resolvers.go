@@ -6,12 +6,15 @@ package privateresolvers
 import (
 	"context"
 	"errors"
+	"time"
 
 	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/logme"
 )
 
 // GetNodeLogs is the resolver for the getNodeLogs field.
@@ -85,3 +88,232 @@ func (r *queryResolver) GetCodeFileRunLogs(ctx context.Context, runID string, pi
 	}
 	return p, nil
 }
+
+// GetNodeLogsPage is the resolver for the getNodeLogsPage field.
+func (r *queryResolver) GetNodeLogsPage(ctx context.Context, runID string, pipelineID string, nodeID string, environmentID string, filter privategraphql.LogsFilter) (*privategraphql.LogsWorkersPage, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "view", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "edit", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	// ----- Filters
+	limit := logme.LogPageLimit(filter.Limit)
+
+	levels, err := logme.LogLevelsFilter(filter.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	query := database.DBConn.Select("created_at", "log", "uid", "log_type", "run_id", "node_id", "task_id").Where("environment_id = ? and run_id = ? and node_id = ?", environmentID, runID, nodeID)
+
+	if filter.Cursor != "" {
+		createdAt, uid, err := logme.LogCursorDecode(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(created_at, uid) > (?, ?)", createdAt, uid)
+	}
+
+	if len(levels) > 0 {
+		query = query.Where("log_type in (?)", levels)
+	}
+
+	if filter.Search != "" {
+		query = query.Where("to_tsvector('simple', log) @@ plainto_tsquery('simple', ?)", filter.Search)
+	}
+
+	// ----- One more than the page to know if there is a next page
+	p := []*models.LogsWorkers{}
+
+	err = query.Order("created_at asc, uid asc").Limit(limit + 1).Find(&p).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive logs database error.")
+	}
+
+	nextCursor := ""
+	if len(p) > limit {
+		p = p[:limit]
+		nextCursor = logme.LogCursorEncode(p[limit-1].CreatedAt, p[limit-1].UID)
+	}
+
+	return &privategraphql.LogsWorkersPage{Logs: p, NextCursor: nextCursor}, nil
+}
+
+// GetCodeFileRunLogsPage is the resolver for the getCodeFileRunLogsPage field.
+func (r *queryResolver) GetCodeFileRunLogsPage(ctx context.Context, runID string, pipelineID string, environmentID string, filter privategraphql.LogsFilter) (*privategraphql.LogsCodeRunPage, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "view", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "edit", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	// ----- Filters
+	limit := logme.LogPageLimit(filter.Limit)
+
+	levels, err := logme.LogLevelsFilter(filter.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	query := database.DBConn.Select("created_at", "log", "uid", "log_type", "run_id", "node_id").Where("environment_id = ? and run_id = ?", environmentID, runID)
+
+	if filter.Cursor != "" {
+		createdAt, uid, err := logme.LogCursorDecode(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(created_at, uid) > (?, ?)", createdAt, uid)
+	}
+
+	if len(levels) > 0 {
+		query = query.Where("log_type in (?)", levels)
+	}
+
+	if filter.Search != "" {
+		query = query.Where("to_tsvector('simple', log) @@ plainto_tsquery('simple', ?)", filter.Search)
+	}
+
+	// ----- One more than the page to know if there is a next page
+	p := []*models.LogsCodeRun{}
+
+	err = query.Order("created_at asc, uid asc").Limit(limit + 1).Find(&p).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive logs database error.")
+	}
+
+	nextCursor := ""
+	if len(p) > limit {
+		p = p[:limit]
+		nextCursor = logme.LogCursorEncode(p[limit-1].CreatedAt, p[limit-1].UID)
+	}
+
+	return &privategraphql.LogsCodeRunPage{Logs: p, NextCursor: nextCursor}, nil
+}
+
+// SearchLogs is the resolver for the searchLogs field.
+func (r *queryResolver) SearchLogs(ctx context.Context, pipelineID string, environmentID string, runID string, nodeID string, from *time.Time, to *time.Time, filter privategraphql.LogsFilter) (*privategraphql.LogsWorkersPage, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "view", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "edit", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	// ----- Filters
+	limit := logme.LogPageLimit(filter.Limit)
+
+	levels, err := logme.LogLevelsFilter(filter.Levels)
+	if err != nil {
+		return nil, err
+	}
+
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, errors.New("Search window ends before it starts.")
+	}
+
+	// ----- Only runs of the pipeline
+	runs := database.DBConn.Model(&models.PipelineRuns{}).Select("run_id").Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID)
+	if runID != "" {
+		runs = runs.Where("run_id = ?", runID)
+	}
+
+	query := database.DBConn.Select("created_at", "log", "uid", "log_type", "run_id", "node_id", "task_id").Where("environment_id = ? and run_id in (?)", environmentID, runs)
+
+	if nodeID != "" {
+		query = query.Where("node_id = ?", nodeID)
+	}
+
+	if from != nil {
+		query = query.Where("created_at >= ?", *from)
+	}
+
+	if to != nil {
+		query = query.Where("created_at < ?", *to)
+	}
+
+	if filter.Cursor != "" {
+		createdAt, uid, err := logme.LogCursorDecode(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("(created_at, uid) > (?, ?)", createdAt, uid)
+	}
+
+	if len(levels) > 0 {
+		query = query.Where("log_type in (?)", levels)
+	}
+
+	if filter.Search != "" {
+		query = query.Where("to_tsvector('simple', log) @@ plainto_tsquery('simple', ?)", filter.Search)
+	}
+
+	// ----- One more than the page to know if there is a next page
+	p := []*models.LogsWorkers{}
+
+	err = query.Order("created_at asc, uid asc").Limit(limit + 1).Find(&p).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive logs database error.")
+	}
+
+	nextCursor := ""
+	if len(p) > limit {
+		p = p[:limit]
+		nextCursor = logme.LogCursorEncode(p[limit-1].CreatedAt, p[limit-1].UID)
+	}
+
+	return &privategraphql.LogsWorkersPage{Logs: p, NextCursor: nextCursor}, nil
+}
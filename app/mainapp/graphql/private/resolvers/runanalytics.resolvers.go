@@ -0,0 +1,296 @@
+package privateresolvers
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
+)
+
+// GetRunTrend is the resolver for the getRunTrend field.
+func (r *queryResolver) GetRunTrend(ctx context.Context, pipelineID string, environmentID string, runType string, from time.Time, to time.Time, bucket string) ([]*privategraphql.RunTrendBucket, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	if runType != "pipeline" && runType != "deployment" {
+		return nil, errors.New("Run type must be pipeline or deployment.")
+	}
+
+	if err := pipelines.AnalyticsWindow(from, to, bucket); err != nil {
+		return nil, err
+	}
+
+	buckets := []*privategraphql.RunTrendBucket{}
+
+	err := database.DBConn.Raw(`
+	select
+	date_trunc(?, created_at at time zone 'UTC') at time zone 'UTC' as bucket,
+	status,
+	count(*) as runs,
+	coalesce(percentile_cont(0.5) within group (order by extract(epoch from (ended_at - created_at))) filter (where status in ('Success', 'Fail') and ended_at >= created_at), 0) as p50_seconds,
+	coalesce(percentile_cont(0.95) within group (order by extract(epoch from (ended_at - created_at))) filter (where status in ('Success', 'Fail') and ended_at >= created_at), 0) as p95_seconds
+	from pipeline_runs
+	where
+	pipeline_id = ? and
+	environment_id = ? and
+	run_type = ? and
+	created_at >= ? and
+	created_at < ?
+	group by 1, 2
+	order by 1, 2
+	`, bucket, pipelineID, environmentID, runType, from, to).Scan(&buckets).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve run analytics database error.")
+	}
+
+	return buckets, nil
+}
+
+// GetNodeRunStats is the resolver for the getNodeRunStats field.
+func (r *queryResolver) GetNodeRunStats(ctx context.Context, pipelineID string, environmentID string, runType string, from time.Time, to time.Time) ([]*privategraphql.NodeRunStats, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	if runType != "pipeline" && runType != "deployment" {
+		return nil, errors.New("Run type must be pipeline or deployment.")
+	}
+
+	if err := pipelines.AnalyticsWindow(from, to, "day"); err != nil {
+		return nil, err
+	}
+
+	// Tasks that never started have a zero start time
+	var notStarted time.Time
+
+	type nodeStats struct {
+		NodeID          string
+		Tasks           int
+		Success         int
+		Fail            int
+		P50Seconds      float64
+		P95Seconds      float64
+		P50QueueSeconds float64
+		P95QueueSeconds float64
+	}
+
+	stats := []nodeStats{}
+
+	err := database.DBConn.Raw(`
+	select
+	node_id,
+	count(*) as tasks,
+	count(*) filter (where status = 'Success') as success,
+	count(*) filter (where status in ('Fail', 'Retry')) as fail,
+	coalesce(percentile_cont(0.5) within group (order by extract(epoch from (end_dt - start_dt))) filter (where status in ('Success', 'Fail', 'Retry') and start_dt > @not_started and end_dt >= start_dt), 0) as p50_seconds,
+	coalesce(percentile_cont(0.95) within group (order by extract(epoch from (end_dt - start_dt))) filter (where status in ('Success', 'Fail', 'Retry') and start_dt > @not_started and end_dt >= start_dt), 0) as p95_seconds,
+	coalesce(percentile_cont(0.5) within group (order by extract(epoch from (start_dt - created_at))) filter (where start_dt > @not_started and start_dt >= created_at), 0) as p50_queue_seconds,
+	coalesce(percentile_cont(0.95) within group (order by extract(epoch from (start_dt - created_at))) filter (where start_dt > @not_started and start_dt >= created_at), 0) as p95_queue_seconds
+	from worker_tasks
+	where
+	pipeline_id = @pipeline_id and
+	environment_id = @environment_id and
+	run_type = @run_type and
+	created_at >= @from and
+	created_at < @to
+	group by node_id
+	order by fail desc, node_id
+	`, map[string]interface{}{"not_started": notStarted, "pipeline_id": pipelineID, "environment_id": environmentID, "run_type": runType, "from": from, "to": to}).Scan(&stats).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve run analytics database error.")
+	}
+
+	// ----- Failure reasons per node
+	type nodeReason struct {
+		NodeID       string
+		Reason       string
+		Failures     int
+		LastFailedAt time.Time
+	}
+
+	reasons := []nodeReason{}
+
+	err = database.DBConn.Raw(`
+	select
+	node_id,
+	reason,
+	count(*) as failures,
+	max(end_dt) as last_failed_at
+	from worker_tasks
+	where
+	pipeline_id = ? and
+	environment_id = ? and
+	run_type = ? and
+	created_at >= ? and
+	created_at < ? and
+	status in ('Fail', 'Retry')
+	group by node_id, reason
+	`, pipelineID, environmentID, runType, from, to).Scan(&reasons).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve run analytics database error.")
+	}
+
+	byNode := map[string][]pipelines.AnalyticsReasonCount{}
+	for _, r := range reasons {
+		byNode[r.NodeID] = append(byNode[r.NodeID], pipelines.AnalyticsReasonCount{Reason: r.Reason, Failures: r.Failures, LastFailedAt: r.LastFailedAt})
+	}
+
+	out := []*privategraphql.NodeRunStats{}
+
+	for _, s := range stats {
+
+		nodeReasons := []*privategraphql.FailureReasonCount{}
+		for _, r := range pipelines.AnalyticsTopReasons(byNode[s.NodeID], pipelines.AnalyticsMaxReasons) {
+			nodeReasons = append(nodeReasons, &privategraphql.FailureReasonCount{Reason: r.Reason, Failures: r.Failures, LastFailedAt: r.LastFailedAt})
+		}
+
+		out = append(out, &privategraphql.NodeRunStats{
+			NodeID:          s.NodeID,
+			Tasks:           s.Tasks,
+			Success:         s.Success,
+			Fail:            s.Fail,
+			FailureRate:     pipelines.AnalyticsFailureRate(s.Fail, s.Tasks),
+			P50Seconds:      s.P50Seconds,
+			P95Seconds:      s.P95Seconds,
+			P50QueueSeconds: s.P50QueueSeconds,
+			P95QueueSeconds: s.P95QueueSeconds,
+			Reasons:         nodeReasons,
+		})
+	}
+
+	return out, nil
+}
+
+// GetWorkerGroupStats is the resolver for the getWorkerGroupStats field.
+func (r *queryResolver) GetWorkerGroupStats(ctx context.Context, environmentID string, from time.Time, to time.Time, bucket string) ([]*privategraphql.WorkerGroupStatsBucket, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	if err := pipelines.AnalyticsWindow(from, to, bucket); err != nil {
+		return nil, err
+	}
+
+	// Tasks that never started have a zero start time
+	var notStarted time.Time
+
+	buckets := []*privategraphql.WorkerGroupStatsBucket{}
+
+	err := database.DBConn.Raw(`
+	select
+	date_trunc(@bucket, created_at at time zone 'UTC') at time zone 'UTC' as bucket,
+	worker_group,
+	count(*) as tasks,
+	count(*) filter (where status in ('Fail', 'Retry')) as fail,
+	coalesce(percentile_cont(0.5) within group (order by extract(epoch from (end_dt - start_dt))) filter (where status in ('Success', 'Fail', 'Retry') and start_dt > @not_started and end_dt >= start_dt), 0) as p50_seconds,
+	coalesce(percentile_cont(0.95) within group (order by extract(epoch from (end_dt - start_dt))) filter (where status in ('Success', 'Fail', 'Retry') and start_dt > @not_started and end_dt >= start_dt), 0) as p95_seconds,
+	coalesce(percentile_cont(0.5) within group (order by extract(epoch from (start_dt - created_at))) filter (where start_dt > @not_started and start_dt >= created_at), 0) as p50_queue_seconds,
+	coalesce(percentile_cont(0.95) within group (order by extract(epoch from (start_dt - created_at))) filter (where start_dt > @not_started and start_dt >= created_at), 0) as p95_queue_seconds
+	from worker_tasks
+	where
+	environment_id = @environment_id and
+	created_at >= @from and
+	created_at < @to
+	group by 1, 2
+	order by 1, 2
+	`, map[string]interface{}{"bucket": bucket, "not_started": notStarted, "environment_id": environmentID, "from": from, "to": to}).Scan(&buckets).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve run analytics database error.")
+	}
+
+	return buckets, nil
+}
@@ -5,8 +5,8 @@ package privateresolvers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"log"
 	"strings"
 	"time"
 
@@ -18,12 +18,15 @@ import (
 	privategraphql "github.com/dataplane-app/dataplane/app/mainapp/graphql/private"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
-	"github.com/dataplane-app/dataplane/app/mainapp/worker"
+	"github.com/dataplane-app/dataplane/app/mainapp/scheduler"
+	"github.com/dataplane-app/dataplane/app/mainapp/scheduler/firetimes"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
 	"gorm.io/gorm/clause"
 )
 
 // RunPipelines is the resolver for the runPipelines field.
-func (r *mutationResolver) RunPipelines(ctx context.Context, pipelineID string, environmentID string, runType string, runID string) (*models.PipelineRuns, error) {
+func (r *mutationResolver) RunPipelines(ctx context.Context, pipelineID string, environmentID string, runType string, runID string, parameters interface{}) (*models.PipelineRuns, error) {
 	currentUser := ctx.Value("currentUser").(string)
 	platformID := ctx.Value("platformID").(string)
 
@@ -59,11 +62,25 @@ func (r *mutationResolver) RunPipelines(ctx context.Context, pipelineID string,
 	var err error
 	var resp models.PipelineRuns
 
+	// ----- Run parameters
+	var submitted datatypes.JSON
+	if parameters != nil {
+		submitted, err = json.Marshal(parameters)
+		if err != nil {
+			return &resp, errors.New("Run parameters must be a JSON object")
+		}
+	}
+
+	runParameters, err := pipelines.RunParameters(pipelineID, environmentID, runType, "", submitted)
+	if err != nil {
+		return &resp, err
+	}
+
 	switch runType {
 	case "pipeline":
-		resp, err = pipelines.RunPipeline(pipelineID, environmentID, runID)
+		resp, err = pipelines.RunPipeline(pipelineID, environmentID, runID, pipelines.RunOptions{Parameters: runParameters})
 	case "deployment":
-		resp, err = pipelines.RunDeployment(pipelineID, environmentID, runID)
+		resp, err = pipelines.RunDeployment(pipelineID, environmentID, runID, pipelines.RunOptions{Parameters: runParameters})
 	default:
 		return &resp, errors.New("Run type not provided.")
 	}
@@ -73,6 +90,10 @@ func (r *mutationResolver) RunPipelines(ctx context.Context, pipelineID string,
 			logging.PrintSecretsRedact(err)
 		}
 
+		if err == pipelines.ErrRunSkipped {
+			return &models.PipelineRuns{}, err
+		}
+
 		return &models.PipelineRuns{}, errors.New("Run pipeline error")
 	}
 
@@ -106,67 +127,146 @@ func (r *mutationResolver) StopPipelines(ctx context.Context, pipelineID string,
 		return &models.PipelineRuns{}, errors.New("Requires permission")
 	}
 
-	// Get the run
-	var currentRun *models.PipelineRuns
-	err3 := database.DBConn.Where("run_id = ? and environment_id = ?", runID, environmentID).First(&currentRun).Error
-	if err3 != nil {
-		logging.PrintSecretsRedact(err3.Error())
+	run, err := pipelines.RunStop(runID, environmentID, "Cancelled by user")
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.PipelineRuns{}, err
 	}
 
-	// Cancel all future tasks
-	err := database.DBConn.Model(&models.WorkerTasks{}).Where("run_id = ? and environment_id = ? and status=?", runID, environmentID, "Queue").Updates(map[string]interface{}{"status": "Fail", "reason": "Upstream fail"}).Error
+	return &run, nil
+}
+
+// RerunPipeline is the resolver for the rerunPipeline field.
+func (r *mutationResolver) RerunPipeline(ctx context.Context, pipelineID string, environmentID string, runID string, nodeIDs []string) (*models.PipelineRuns, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	var run models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and pipeline_id = ? and environment_id = ?", runID, pipelineID, environmentID).First(&run).Error
 	if err != nil {
-		logging.PrintSecretsRedact(err.Error())
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.PipelineRuns{}, errors.New("Run not found")
 	}
 
-	// Get any current running tasks and cancel those tasks
-	currentTask := []*models.WorkerTasks{}
-	err = database.DBConn.Where("run_id = ? and environment_id = ? and status=?", runID, environmentID, "Run").Find(&currentTask).Error
-	if err3 != nil {
-		logging.PrintSecretsRedact(err3.Error())
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
 	}
 
-	var workerType string
+	switch run.RunType {
+	case "pipeline":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	case "deployment":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_deployments", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	}
 
-	if len(currentTask) > 0 {
-		for _, t := range currentTask {
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
 
-			/* Which type of worker */
-			switch t.WorkerType {
+	if permOutcome == "denied" {
+		return &models.PipelineRuns{}, errors.New("Requires permission")
+	}
 
-			/* --- Server worker types ---- */
-			case "python", "bash", "checkpoint":
+	run, err = pipelines.RunRerun(runID, environmentID, nodeIDs)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.PipelineRuns{}, err
+	}
 
-				workerType = "server"
-			/* Send the task to the RPA worker */
-			case "rpa-python":
-				workerType = "rpa"
+	return &run, nil
+}
 
-			default:
-				log.Println("Cancel run, worker type not found for node: ", t.NodeID)
-			}
+// PausePipelineRun is the resolver for the pausePipelineRun field.
+func (r *mutationResolver) PausePipelineRun(ctx context.Context, pipelineID string, environmentID string, runID string, nodeIDs []string) (*models.PipelineRuns, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
 
-			errt := worker.WorkerCancelTask(t.TaskID, environmentID, workerType)
-			if errt != nil {
-				logging.PrintSecretsRedact(errt.Error())
-			}
+	var run models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and pipeline_id = ? and environment_id = ?", runID, pipelineID, environmentID).First(&run).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
 		}
+		return &models.PipelineRuns{}, errors.New("Run not found")
 	}
-	// Update pipeline as cancelled
-	// Create a run
-	run := models.PipelineRuns{
-		RunID:         runID,
-		PipelineID:    pipelineID,
-		Status:        "Fail",
-		Reason:        "Cancelled by user",
-		EnvironmentID: environmentID,
-		CreatedAt:     currentRun.CreatedAt,
-		EndedAt:       time.Now().UTC(),
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	switch run.RunType {
+	case "pipeline":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	case "deployment":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_deployments", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
 	}
 
-	err = database.DBConn.Updates(&run).Error
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return &models.PipelineRuns{}, errors.New("Requires permission")
+	}
+
+	run, err = pipelines.RunPause(runID, environmentID, nodeIDs)
 	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.PipelineRuns{}, err
+	}
+
+	return &run, nil
+}
 
+// ResumePipelineRun is the resolver for the resumePipelineRun field.
+func (r *mutationResolver) ResumePipelineRun(ctx context.Context, pipelineID string, environmentID string, runID string, nodeIDs []string) (*models.PipelineRuns, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	var run models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and pipeline_id = ? and environment_id = ?", runID, pipelineID, environmentID).First(&run).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return &models.PipelineRuns{}, errors.New("Run not found")
+	}
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	switch run.RunType {
+	case "pipeline":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	case "deployment":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_deployments", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return &models.PipelineRuns{}, errors.New("Requires permission")
+	}
+
+	run, err = pipelines.RunResume(runID, environmentID, nodeIDs)
+	if err != nil {
 		if dpconfig.Debug == "true" {
 			logging.PrintSecretsRedact(err)
 		}
@@ -176,6 +276,66 @@ func (r *mutationResolver) StopPipelines(ctx context.Context, pipelineID string,
 	return &run, nil
 }
 
+// BackfillSchedule is the resolver for the backfillSchedule field.
+func (r *mutationResolver) BackfillSchedule(ctx context.Context, pipelineID string, environmentID string, nodeID string, from time.Time, to time.Time) ([]string, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	var schedule models.Scheduler
+	err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ?", nodeID, pipelineID, environmentID).First(&schedule).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return []string{}, errors.New("Schedule not found")
+	}
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+	}
+
+	switch schedule.RunType {
+	case "pipeline":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	case "deployment":
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_deployments", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID})
+		perms = append(perms, models.Permissions{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID})
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return []string{}, errors.New("Requires permission")
+	}
+
+	if to.After(time.Now()) {
+		return []string{}, errors.New("Backfill can only run past fire times")
+	}
+
+	fireTimes, err := firetimes.ScheduleFireTimes(schedule.ScheduleType, schedule.Schedule, schedule.Timezone, from, to, firetimes.ScheduleBackfillMax)
+	if err != nil {
+		return []string{}, err
+	}
+
+	// Check the parameters of the schedule are still valid before starting any runs
+	_, err = pipelines.RunParameters(pipelineID, environmentID, schedule.RunType, "", schedule.Parameters)
+	if err != nil {
+		return []string{}, err
+	}
+
+	runIDs := make([]string, len(fireTimes))
+	for i := range runIDs {
+		runIDs[i] = uuid.NewString()
+	}
+
+	go scheduler.BackfillSchedule(schedule, fireTimes, runIDs)
+
+	return runIDs, nil
+}
+
 // GeneratePipelineTrigger is the resolver for the generatePipelineTrigger field.
 func (r *mutationResolver) GeneratePipelineTrigger(ctx context.Context, pipelineID string, environmentID string, triggerID string, apiKeyActive bool, publicLive bool, privateLive bool) (string, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -425,6 +585,11 @@ func (r *pipelineRunsResolver) RunJSON(ctx context.Context, obj *models.Pipeline
 	return obj.RunJSON, nil
 }
 
+// Parameters is the resolver for the parameters field.
+func (r *pipelineRunsResolver) Parameters(ctx context.Context, obj *models.PipelineRuns) (interface{}, error) {
+	return obj.Parameters, nil
+}
+
 // PipelineTasksRun is the resolver for the pipelineTasksRun field.
 func (r *queryResolver) PipelineTasksRun(ctx context.Context, pipelineID string, runID string, environmentID string) ([]*privategraphql.WorkerTasks, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -473,6 +638,61 @@ func (r *queryResolver) PipelineTasksRun(ctx context.Context, pipelineID string,
 	return currentRun, nil
 }
 
+// PipelineTaskOutputs is the resolver for the pipelineTaskOutputs field.
+func (r *queryResolver) PipelineTaskOutputs(ctx context.Context, pipelineID string, runID string, environmentID string, nodeID *string) ([]*models.WorkerTaskOutputs, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_edit_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	query := database.DBConn.Where("run_id = ? and environment_id = ?", runID, environmentID)
+	if nodeID != nil && *nodeID != "" {
+		query = query.Where("node_id = ?", *nodeID)
+	}
+
+	var outputs []*models.WorkerTaskOutputs
+	err := query.Order("node_id, output_key").Find(&outputs).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err.Error())
+		}
+		return nil, errors.New("Retrieve task outputs database error.")
+	}
+
+	return outputs, nil
+}
+
 // GetSinglepipelineRun is the resolver for the getSinglepipelineRun field.
 func (r *queryResolver) GetSinglepipelineRun(ctx context.Context, pipelineID string, runID string, environmentID string) (*models.PipelineRuns, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -520,6 +740,51 @@ func (r *queryResolver) GetSinglepipelineRun(ctx context.Context, pipelineID str
 	return pipelineRun, nil
 }
 
+// GetChildRuns is the resolver for the getChildRuns field.
+func (r *queryResolver) GetChildRuns(ctx context.Context, pipelineID string, runID string, environmentID string, nodeID *string) ([]*models.PipelineRuns, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	if permOutcome == "denied" {
+		return []*models.PipelineRuns{}, errors.New("Requires permission")
+	}
+
+	query := database.DBConn.Select("run_id", "pipeline_id", "status", "reason", "environment_id", "run_type", "deploy_version", "parameters", "parent_run_id", "parent_task_id", "parent_pipeline_id", "created_at", "ended_at", "updated_at").
+		Where("parent_run_id = ? and parent_pipeline_id = ? and environment_id = ?", runID, pipelineID, environmentID)
+
+	// All attempts of the node
+	if nodeID != nil && *nodeID != "" {
+		query = query.Where("parent_task_id in (?)", database.DBConn.Model(&models.WorkerTasks{}).Select("task_id").Where("run_id = ? and node_id = ?", runID, *nodeID))
+	}
+
+	var childRuns []*models.PipelineRuns
+	err := query.Order("created_at").Find(&childRuns).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return []*models.PipelineRuns{}, errors.New("Retrieve child runs database error.")
+	}
+
+	return childRuns, nil
+}
+
 // GetPipelineRuns is the resolver for the getPipelineRuns field.
 func (r *queryResolver) GetPipelineRuns(ctx context.Context, pipelineID string, environmentID string) ([]*models.PipelineRuns, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -560,7 +825,7 @@ func (r *queryResolver) GetPipelineRuns(ctx context.Context, pipelineID string,
 
 	// Get pipeline runs
 	var pipelineRuns []*models.PipelineRuns
-	err := database.DBConn.Select("run_id", "pipeline_id", "status", "environment_id", "run_type", "created_at", "ended_at", "updated_at").Order("created_at desc").Limit(20).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&pipelineRuns).Error
+	err := database.DBConn.Select("run_id", "pipeline_id", "status", "environment_id", "run_type", "logical_date", "parent_run_id", "parent_pipeline_id", "created_at", "ended_at", "updated_at").Order("created_at desc").Limit(20).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&pipelineRuns).Error
 	if err != nil {
 		logging.PrintSecretsRedact(err.Error())
 	}
@@ -568,6 +833,135 @@ func (r *queryResolver) GetPipelineRuns(ctx context.Context, pipelineID string,
 	return pipelineRuns, nil
 }
 
+// GetScheduleHistory is the resolver for the getScheduleHistory field.
+func (r *queryResolver) GetScheduleHistory(ctx context.Context, pipelineID string, environmentID string, nodeID string, limit int) ([]*models.SchedulerHistory, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_edit_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	if limit < 1 {
+		return nil, errors.New("Limit must be at least 1")
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+
+	var history []*models.SchedulerHistory
+	err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ?", nodeID, pipelineID, environmentID).Order("fired_at desc, created_at desc").Limit(limit).Find(&history).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrive schedule history database error")
+	}
+
+	return history, nil
+}
+
+// GetScheduleNextFires is the resolver for the getScheduleNextFires field.
+func (r *queryResolver) GetScheduleNextFires(ctx context.Context, pipelineID string, environmentID string, nodeID string, limit int) ([]*time.Time, error) {
+	currentUser := ctx.Value("currentUser").(string)
+	platformID := ctx.Value("platformID").(string)
+
+	// ----- Permissions
+	perms := []models.Permissions{
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+		{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "write", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "read", EnvironmentID: environmentID},
+		{Subject: "user", SubjectID: currentUser, Resource: "specific_deployment", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+	}
+
+	permOutcome, outcomes, _, _ := permissions.MultiplePermissionChecks(perms)
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_edit_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	for _, outcome := range outcomes {
+		if outcome.Perm.Resource == "environment_all_pipelines" && outcome.Result == "grant" {
+			permOutcome = "yes"
+		}
+	}
+
+	if permOutcome == "denied" {
+		return nil, errors.New("Requires permission")
+	}
+
+	if limit < 1 {
+		return nil, errors.New("Limit must be at least 1")
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var schedule models.Scheduler
+	err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ?", nodeID, pipelineID, environmentID).First(&schedule).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Schedule not found")
+	}
+
+	// An offline schedule doesn't fire
+	if !schedule.Online {
+		return []*time.Time{}, nil
+	}
+
+	fireTimes, err := firetimes.ScheduleNextFireTimes(schedule.ScheduleType, schedule.Schedule, schedule.Timezone, time.Now(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	next := make([]*time.Time, len(fireTimes))
+	for i := range fireTimes {
+		next[i] = &fireTimes[i]
+	}
+
+	return next, nil
+}
+
 // GetPipelineTrigger is the resolver for the getPipelineTrigger field.
 func (r *queryResolver) GetPipelineTrigger(ctx context.Context, pipelineID string, environmentID string) (*models.PipelineApiTriggers, error) {
 	currentUser := ctx.Value("currentUser").(string)
@@ -695,9 +1089,20 @@ func (r *queryResolver) GetDeploymentAPIKeys(ctx context.Context, deploymentID s
 	return e, nil
 }
 
+// Value is the resolver for the value field.
+func (r *workerTaskOutputsResolver) Value(ctx context.Context, obj *models.WorkerTaskOutputs) (interface{}, error) {
+	return obj.Value, nil
+}
+
 // PipelineRuns returns privategraphql.PipelineRunsResolver implementation.
 func (r *Resolver) PipelineRuns() privategraphql.PipelineRunsResolver {
 	return &pipelineRunsResolver{r}
 }
 
+// WorkerTaskOutputs returns privategraphql.WorkerTaskOutputsResolver implementation.
+func (r *Resolver) WorkerTaskOutputs() privategraphql.WorkerTaskOutputsResolver {
+	return &workerTaskOutputsResolver{r}
+}
+
 type pipelineRunsResolver struct{ *Resolver }
+type workerTaskOutputsResolver struct{ *Resolver }
@@ -0,0 +1,133 @@
+package leaderelection
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+
+	"gorm.io/gorm"
+)
+
+const postgresLockKey = "dataplane-platform-leader"
+
+/*
+postgresBackend elects the replica holding a session advisory lock, for deployments without Redis HA.
+The lock is held on a connection kept out of the pool and is released by Postgres if the replica goes away,
+so the lease is the life of the connection. The leader and fencing token are kept in platform_leader.
+*/
+type postgresBackend struct {
+	mu    sync.Mutex
+	conn  *sql.Conn
+	token int64
+}
+
+func (b *postgresBackend) Elect(ctx context.Context, nodeID string, lease time.Duration) (string, int64, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Already the leader while the connection holding the lock is open
+	if b.conn != nil {
+		err := b.conn.PingContext(ctx)
+		if err == nil {
+			_, err = b.conn.ExecContext(ctx, "update platform_leader set updated_at = now() where leader = true and node_id = $1 and token = $2", nodeID, b.token)
+		}
+		if err == nil {
+			return nodeID, b.token, nil
+		}
+
+		logging.PrintSecretsRedact("Leader election lost lock connection:", err)
+		b.release()
+	}
+
+	sqlDB, err := database.DBConn.DB()
+	if err != nil {
+		return "", 0, err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, "select pg_try_advisory_lock(hashtext($1))", postgresLockKey).Scan(&locked)
+	if err != nil {
+		conn.Close()
+		return "", 0, err
+	}
+
+	if !locked {
+		conn.Close()
+
+		var current models.PlatformLeader
+		err := database.DBConn.WithContext(ctx).Where("leader = ?", true).First(&current).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", 0, nil
+		}
+		if err != nil {
+			return "", 0, err
+		}
+		return current.NodeID, current.Token, nil
+	}
+
+	// Taking over moves the fencing token on
+	var token int64
+	err = conn.QueryRowContext(ctx, `insert into platform_leader (leader, node_id, token, updated_at) values (true, $1, 1, now())
+		on conflict (leader) do update set node_id = excluded.node_id, token = platform_leader.token + 1, updated_at = excluded.updated_at
+		returning token`, nodeID).Scan(&token)
+	if err != nil {
+		b.conn = conn
+		b.release()
+		return "", 0, err
+	}
+
+	b.conn = conn
+	b.token = token
+
+	return nodeID, token, nil
+}
+
+func (b *postgresBackend) Check(ctx context.Context, nodeID string, token int64) error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil || b.token != token {
+		return ErrNotLeader
+	}
+
+	// Read on the lock connection so a lost session fails the check
+	var current models.PlatformLeader
+	err := b.conn.QueryRowContext(ctx, "select node_id, token from platform_leader where leader = true").Scan(&current.NodeID, &current.Token)
+	if err != nil {
+		return err
+	}
+
+	if current.NodeID != nodeID || current.Token != token {
+		return ErrNotLeader
+	}
+
+	return nil
+}
+
+/*
+release unlocks before the connection goes back to the pool, a closed session has already dropped the lock.
+*/
+func (b *postgresBackend) release() {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	b.conn.ExecContext(ctx, "select pg_advisory_unlock(hashtext($1))", postgresLockKey)
+	b.conn.Close()
+
+	b.conn = nil
+	b.token = 0
+}
@@ -0,0 +1,76 @@
+package leaderelection
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+Run against a database without a main app running, the test takes the platform leadership.
+go test -count=1 -timeout 30s -v -run ^TestPostgresBackend$ github.com/dataplane-app/dataplane/app/mainapp/leaderelection
+*/
+func TestPostgresBackend(t *testing.T) {
+
+	dpconfig.LoadConfig()
+	database.DBConnect()
+	database.DBConn.AutoMigrate(&models.PlatformLeader{})
+
+	ctx := context.Background()
+	lease := 10 * time.Second
+
+	a := &postgresBackend{}
+	b := &postgresBackend{}
+
+	// ----- Acquire
+	leader, token, err := a.Elect(ctx, "node-a", lease)
+	assert.NoError(t, err, "Acquire")
+	assert.Equal(t, "node-a", leader, "Acquire leader")
+	assert.NotZero(t, token, "Acquire token")
+
+	leader, tokenB, err := b.Elect(ctx, "node-b", lease)
+	assert.NoError(t, err, "Second replica")
+	assert.Equal(t, "node-a", leader, "Second replica sees the leader")
+	assert.Equal(t, token, tokenB, "Second replica sees the token")
+
+	// ----- Renew keeps the token
+	leader, renewed, err := a.Elect(ctx, "node-a", lease)
+	assert.NoError(t, err, "Renew")
+	assert.Equal(t, "node-a", leader, "Renew leader")
+	assert.Equal(t, token, renewed, "Renew token")
+
+	// ----- Fence check
+	assert.NoError(t, a.Check(ctx, "node-a", token), "Leader passes")
+	assert.ErrorIs(t, a.Check(ctx, "node-a", token-1), ErrNotLeader, "Stale token")
+	assert.ErrorIs(t, b.Check(ctx, "node-b", token), ErrNotLeader, "Not the leader")
+
+	// ----- Loss: the session holding the lock goes away
+	var pid int
+	err = a.conn.QueryRowContext(ctx, "select pg_backend_pid()").Scan(&pid)
+	assert.NoError(t, err, "Lock session")
+	err = database.DBConn.Exec("select pg_terminate_backend(?)", pid).Error
+	assert.NoError(t, err, "End lock session")
+
+	assert.Error(t, a.Check(ctx, "node-a", token), "Lost session fails the check")
+
+	leader, tokenB, err = b.Elect(ctx, "node-b", lease)
+	assert.NoError(t, err, "Take over")
+	assert.Equal(t, "node-b", leader, "Take over leader")
+	assert.Greater(t, tokenB, token, "Take over moves the token on")
+
+	leader, tokenA, err := a.Elect(ctx, "node-a", lease)
+	assert.NoError(t, err, "Old leader")
+	assert.Equal(t, "node-b", leader, "Old leader sees the new leader")
+	assert.Equal(t, tokenB, tokenA, "Old leader sees the new token")
+	assert.ErrorIs(t, a.Check(ctx, "node-a", token), ErrNotLeader, "Old leader fenced")
+	assert.NoError(t, b.Check(ctx, "node-b", tokenB), "New leader passes")
+
+	b.mu.Lock()
+	b.release()
+	b.mu.Unlock()
+}
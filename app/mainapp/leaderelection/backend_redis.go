@@ -0,0 +1,72 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+
+	"github.com/go-redis/redis/v8"
+)
+
+/*
+The election runs as one script so that reading and setting the leader can't interleave between replicas.
+KEYS[1] leader hash, KEYS[2] fencing token counter
+ARGV[1] node id, ARGV[2] lease in milliseconds, ARGV[3] unix timestamp
+*/
+var redisElect = redis.NewScript(`
+local leader = redis.call('HGET', KEYS[1], 'nodeid')
+if not leader then
+	local token = redis.call('INCR', KEYS[2])
+	redis.call('HSET', KEYS[1], 'nodeid', ARGV[1], 'token', token, 'timestamp', ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return {ARGV[1], token}
+end
+if leader == ARGV[1] then
+	redis.call('HSET', KEYS[1], 'timestamp', ARGV[3])
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return {leader, tonumber(redis.call('HGET', KEYS[1], 'token') or '0')}
+`)
+
+/*
+redisBackend keeps the leader in the Redis hash leader with a lease and the fencing token in leader-token.
+*/
+type redisBackend struct{}
+
+func (b *redisBackend) Elect(ctx context.Context, nodeID string, lease time.Duration) (string, int64, error) {
+
+	result, err := redisElect.Run(ctx, database.RedisConn, []string{"leader", "leader-token"}, nodeID, lease.Milliseconds(), time.Now().UTC().Unix()).Result()
+	if err != nil {
+		return "", 0, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return "", 0, errors.New("Leader election unexpected reply from Redis")
+	}
+
+	leader, _ := values[0].(string)
+	token, _ := values[1].(int64)
+
+	return leader, token, nil
+}
+
+func (b *redisBackend) Check(ctx context.Context, nodeID string, token int64) error {
+
+	values, err := database.RedisConn.HMGet(ctx, "leader", "nodeid", "token").Result()
+	if err != nil {
+		return err
+	}
+
+	leader, _ := values[0].(string)
+	current, _ := values[1].(string)
+
+	if leader != nodeID || current != strconv.FormatInt(token, 10) {
+		return ErrNotLeader
+	}
+
+	return nil
+}
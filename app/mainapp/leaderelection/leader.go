@@ -0,0 +1,96 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+)
+
+/*
+Backend elects one main app replica as the leader with a lease.
+Each time the leadership changes hands the fencing token goes up, so work started under an
+old token can be told apart from work started by the current leader.
+*/
+type Backend interface {
+	// Elect takes the lease if there is no leader or renews it if nodeID is the leader.
+	// Returns the leader after the election and its fencing token.
+	Elect(ctx context.Context, nodeID string, lease time.Duration) (string, int64, error)
+
+	// Check returns an error if nodeID no longer holds the lease with the fencing token.
+	Check(ctx context.Context, nodeID string, token int64) error
+}
+
+/*
+ErrNotLeader is returned by FenceCheck when this replica is not the leader or its fencing token is stale.
+*/
+var ErrNotLeader = errors.New("Not the leader or leadership has moved on")
+
+var backend Backend
+var state struct {
+	sync.RWMutex
+	leader string
+	token  int64
+}
+
+/*
+NewBackend returns the backend set by DP_LEADER_ELECTION: redis or postgres.
+*/
+func NewBackend() Backend {
+
+	if backend != nil {
+		return backend
+	}
+
+	switch dpconfig.LeaderElection {
+	case "postgres":
+		backend = &postgresBackend{}
+	default:
+		backend = &redisBackend{}
+	}
+
+	return backend
+}
+
+/*
+SetLeader records the outcome of the last election on this replica.
+*/
+func SetLeader(leader string, token int64) {
+	state.Lock()
+	state.leader = leader
+	state.token = token
+	state.Unlock()
+}
+
+/*
+Leader returns the leader and fencing token of the last election seen by this replica.
+*/
+func Leader() (string, int64) {
+	state.RLock()
+	defer state.RUnlock()
+	return state.leader, state.token
+}
+
+/*
+FenceCheck confirms with the backend that this replica still holds the leadership it was elected with.
+Leader only work such as starting a scheduled run calls it just before acting.
+*/
+func FenceCheck() error {
+
+	leader, token := Leader()
+	if leader == "" || leader != dpconfig.MainAppID {
+		return ErrNotLeader
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	err := NewBackend().Check(ctx, dpconfig.MainAppID, token)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package leaderelection
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBackend struct {
+	leader string
+	token  int64
+	err    error
+}
+
+func (b *fakeBackend) Elect(ctx context.Context, nodeID string, lease time.Duration) (string, int64, error) {
+	return b.leader, b.token, nil
+}
+
+func (b *fakeBackend) Check(ctx context.Context, nodeID string, token int64) error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.leader != nodeID || b.token != token {
+		return ErrNotLeader
+	}
+	return nil
+}
+
+/*
+go test -timeout 30s -v -run ^TestFenceCheck$ github.com/dataplane-app/dataplane/app/mainapp/leaderelection
+*/
+func TestFenceCheck(t *testing.T) {
+
+	fake := &fakeBackend{leader: "node-a", token: 3}
+	backend = fake
+	dpconfig.MainAppID = "node-a"
+	defer func() {
+		backend = nil
+		SetLeader("", 0)
+	}()
+
+	// Never elected
+	SetLeader("", 0)
+	assert.ErrorIs(t, FenceCheck(), ErrNotLeader, "No election")
+
+	// Another replica is the leader
+	SetLeader("node-b", 3)
+	assert.ErrorIs(t, FenceCheck(), ErrNotLeader, "Other leader")
+
+	// Elected and still holding the lease
+	SetLeader("node-a", 3)
+	assert.NoError(t, FenceCheck(), "Leader")
+
+	// Leadership moved on since the last election seen here
+	fake.leader, fake.token = "node-b", 4
+	assert.ErrorIs(t, FenceCheck(), ErrNotLeader, "Stale token")
+
+	// Backend errors fail the check
+	fake.leader, fake.token = "node-a", 3
+	fake.err = errors.New("connection lost")
+	assert.EqualError(t, FenceCheck(), "connection lost", "Backend error")
+}
@@ -0,0 +1,98 @@
+package logme
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+)
+
+/* Page size of log queries when none is given and the most a page can return */
+const LogPageDefault = 500
+const LogPageMax = 5000
+
+/* Log types written by the workers and main app */
+var LogLevels = []string{"info", "error", "debug", "action"}
+
+/*
+LogPageLimit returns the page size to use for a requested limit.
+*/
+func LogPageLimit(limit int) int {
+
+	if limit <= 0 {
+		return LogPageDefault
+	}
+
+	if limit > LogPageMax {
+		return LogPageMax
+	}
+
+	return limit
+}
+
+/*
+LogCursorEncode returns the cursor after a log line. Log lines are ordered by created_at then uid,
+so the cursor is the position of the last line of a page.
+*/
+func LogCursorEncode(createdAt time.Time, uid string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + uid))
+}
+
+/*
+LogCursorDecode returns the position of a cursor from LogCursorEncode.
+*/
+func LogCursorDecode(cursor string) (time.Time, string, error) {
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", errors.New("Invalid log cursor.")
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", errors.New("Invalid log cursor.")
+	}
+
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", errors.New("Invalid log cursor.")
+	}
+
+	return time.Unix(0, nanos).UTC(), parts[1], nil
+}
+
+/*
+LogLevelsFilter validates the levels to filter logs by, no levels returns all logs.
+*/
+func LogLevelsFilter(levels []string) ([]string, error) {
+
+	out := []string{}
+
+	for _, l := range levels {
+
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" {
+			continue
+		}
+
+		if !utilities.InArray(l, LogLevels) {
+			return nil, errors.New("Log level not recognised: " + l)
+		}
+
+		if !utilities.InArray(l, out) {
+			out = append(out, l)
+		}
+	}
+
+	return out, nil
+}
+
+/*
+LogLine formats a log line for a plain text download.
+*/
+func LogLine(createdAt time.Time, logType string, log string) string {
+	return createdAt.UTC().Format("2006-01-02T15:04:05.000Z07:00") + " [" + strings.ToUpper(logType) + "] " + log + "\n"
+}
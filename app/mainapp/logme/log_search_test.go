@@ -0,0 +1,49 @@
+package logme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestLogSearch$ github.com/dataplane-app/dataplane/app/mainapp/logme
+*/
+func TestLogSearch(t *testing.T) {
+
+	// ----- Page size
+	assert.Equalf(t, LogPageDefault, LogPageLimit(0), "Default page")
+	assert.Equalf(t, 100, LogPageLimit(100), "Requested page")
+	assert.Equalf(t, LogPageMax, LogPageLimit(LogPageMax+1), "Page capped")
+
+	// ----- Cursor round trip keeps nanoseconds and a uid with the separator
+	at := time.Date(2022, 6, 1, 10, 30, 0, 123456789, time.UTC)
+	cursor := LogCursorEncode(at, "a|b")
+
+	createdAt, uid, err := LogCursorDecode(cursor)
+	assert.NoError(t, err, "Decode cursor")
+	assert.Truef(t, at.Equal(createdAt), "Cursor time")
+	assert.Equalf(t, "a|b", uid, "Cursor uid")
+
+	_, _, err = LogCursorDecode("not a cursor")
+	assert.Error(t, err, "Invalid base64")
+
+	_, _, err = LogCursorDecode(LogCursorEncode(at, "")[:4])
+	assert.Error(t, err, "Truncated cursor")
+
+	// ----- Levels
+	levels, err := LogLevelsFilter([]string{"Error", " info ", "error", ""})
+	assert.NoError(t, err, "Valid levels")
+	assert.Equalf(t, []string{"error", "info"}, levels, "Levels cleaned")
+
+	levels, err = LogLevelsFilter(nil)
+	assert.NoError(t, err, "No levels")
+	assert.Equalf(t, 0, len(levels), "No levels is all logs")
+
+	_, err = LogLevelsFilter([]string{"warning"})
+	assert.Error(t, err, "Unknown level")
+
+	// ----- Plain text line
+	assert.Equalf(t, "2022-06-01T10:30:00.123Z [ERROR] failed\n", LogLine(at, "error", "failed"), "Text line")
+}
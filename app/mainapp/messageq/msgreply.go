@@ -2,13 +2,15 @@ package messageq
 
 import (
 	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
 )
 
 func MsgReply(channel string, msg interface{}, response interface{}) (interface{}, error) {
 
 	err := NATSencoded.Request(channel, msg, &response, time.Second)
 	if err != nil {
-
+		metrics.NATSRequestErrors.WithLabelValues(metrics.Subject(channel)).Inc()
 	}
 	// if dpconfig.MQDebug== "true" {
 	// 	log.Println("response:", response)
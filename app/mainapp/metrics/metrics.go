@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gofiber/adaptor/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/*
+Metrics of the main app served on /metrics in the Prometheus text format.
+Labels only carry IDs and fixed values so that the label sets stay stable,
+NATS subjects and websocket rooms are cut down to their first part e.g. taskupdate.
+*/
+
+var RunsStarted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "dataplane_runs_started_total",
+	Help: "Pipeline and deployment runs started, queued runs are counted when they start.",
+}, []string{"environment_id", "pipeline_id", "run_type"})
+
+var RunsCompleted = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "dataplane_runs_completed_total",
+	Help: "Pipeline and deployment runs finished by status: Success or Fail.",
+}, []string{"environment_id", "pipeline_id", "run_type", "status"})
+
+var TaskQueueSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "dataplane_task_queue_seconds",
+	Help:    "Time from a task being ready to run to a worker accepting it.",
+	Buckets: []float64{0.01, 0.05, 0.1, 0.5, 1, 2, 5, 10, 30},
+}, []string{"environment_id", "worker_group"})
+
+var SchedulerFires = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "dataplane_scheduler_fires_total",
+	Help: "Schedule fires by trigger (schedule, catchup, backfill) and status (Fired, Locked, Skipped, Fenced, Failed).",
+}, []string{"environment_id", "run_type", "trigger", "status"})
+
+var NATSRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "dataplane_nats_request_errors_total",
+	Help: "NATS requests that failed or timed out waiting for a reply.",
+}, []string{"subject"})
+
+var GraphQLSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "dataplane_graphql_request_seconds",
+	Help:    "GraphQL request latency by endpoint: public, private or desktop.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"endpoint"})
+
+var WebsocketClients = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dataplane_websocket_clients",
+	Help: "Websocket clients connected to real-time rooms.",
+}, []string{"room"})
+
+var RemoteWorkerConnections = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "dataplane_remote_worker_connections",
+	Help: "Remote workers connected over websockets.",
+})
+
+/*
+Handler serves the metrics.
+*/
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
+
+/*
+GraphQLTimer records the latency of the GraphQL requests of an endpoint.
+*/
+func GraphQLTimer(endpoint string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		GraphQLSeconds.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+/*
+Subject is the first part of a NATS subject or websocket room, the rest carries IDs.
+*/
+func Subject(subject string) string {
+	return strings.SplitN(subject, ".", 2)[0]
+}
@@ -0,0 +1,257 @@
+package notifications
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	"gorm.io/gorm"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+/* Covers connecting to the SMTP server and the whole conversation */
+var smtpTimeout = 30 * time.Second
+
+/*
+Deliver makes an attempt at sending a pending delivery.
+The attempt is claimed by moving the next attempt time on first, so a retry and the first send can't both go out.
+After the last attempt fails the delivery is marked as failed.
+*/
+func Deliver(d models.NotificationDeliveries) {
+
+	attempt := d.Attempts + 1
+	next := time.Now().UTC().Add(NotificationRetryDelay(attempt))
+
+	result := database.DBConn.Model(&models.NotificationDeliveries{}).Where("delivery_id = ? and status = ? and attempts = ?", d.DeliveryID, "Pending", d.Attempts).Updates(map[string]interface{}{
+		"attempts":        attempt,
+		"next_attempt_at": next,
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact("Notification claim:", result.Error)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var channel models.NotificationChannels
+	err := database.DBConn.Where("channel_id = ?", d.ChannelID).First(&channel).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = errors.New("Notification channel not found")
+		attempt = NotificationMaxAttempts
+	}
+
+	if err == nil {
+		if !channel.Active {
+			err = errors.New("Notification channel not active")
+			attempt = NotificationMaxAttempts
+		} else {
+			err = Send(channel, d)
+		}
+	}
+
+	update := map[string]interface{}{}
+
+	switch {
+	case err == nil:
+		update["status"] = "Sent"
+		update["sent_at"] = time.Now().UTC()
+		update["error"] = ""
+		update["next_attempt_at"] = nil
+	case attempt >= NotificationMaxAttempts:
+		update["status"] = "Failed"
+		update["error"] = err.Error()
+		update["next_attempt_at"] = nil
+	default:
+		update["error"] = err.Error()
+	}
+
+	if err != nil && dpconfig.Debug == "true" {
+		logging.PrintSecretsRedact("Notification send:", d.DeliveryID, attempt, err)
+	}
+
+	err = database.DBConn.Model(&models.NotificationDeliveries{}).Where("delivery_id = ?", d.DeliveryID).Updates(update).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification delivery update:", err)
+	}
+}
+
+/*
+Send sends a notification to a channel.
+*/
+func Send(channel models.NotificationChannels, d models.NotificationDeliveries) error {
+
+	switch channel.ChannelType {
+	case "email":
+		return sendEmail(channel.Recipients, d.Subject, d.Message)
+
+	case "webhook":
+		url, err := utilities.Decrypt(channel.URL)
+		if err != nil {
+			return errors.New("Notification channel URL could not be decrypted")
+		}
+		return postJSON(url, map[string]interface{}{
+			"delivery_id":    d.DeliveryID,
+			"event":          d.Event,
+			"environment_id": d.EnvironmentID,
+			"pipeline_id":    d.PipelineID,
+			"run_id":         d.RunID,
+			"subject":        d.Subject,
+			"message":        d.Message,
+			"created_at":     d.CreatedAt,
+		})
+
+	case "slack":
+		url, err := utilities.Decrypt(channel.URL)
+		if err != nil {
+			return errors.New("Notification channel URL could not be decrypted")
+		}
+		return postJSON(url, map[string]interface{}{
+			"text": "*" + d.Subject + "*\n```" + d.Message + "```",
+		})
+	}
+
+	return errors.New("Notification channel type not supported: " + channel.ChannelType)
+}
+
+func postJSON(url string, body interface{}) error {
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		// The error carries the URL, which holds the webhook token
+		return errors.New("Notification webhook request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Notification webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func sendEmail(recipients string, subject string, message string) error {
+
+	if dpconfig.SMTPHost == "" {
+		return errors.New("SMTP not set up, set DP_SMTP_HOST")
+	}
+
+	addresses, err := mail.ParseAddressList(recipients)
+	if err != nil {
+		return err
+	}
+
+	to := make([]string, 0, len(addresses))
+	for _, a := range addresses {
+		to = append(to, a.Address)
+	}
+
+	var msg strings.Builder
+	msg.WriteString("From: " + dpconfig.SMTPFrom + "\r\n")
+	msg.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	msg.WriteString("Subject: " + strings.NewReplacer("\r", "", "\n", " ").Replace(subject) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message, "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if dpconfig.SMTPUser != "" {
+		auth = smtp.PlainAuth("", dpconfig.SMTPUser, dpconfig.SMTPPassword, dpconfig.SMTPHost)
+	}
+
+	return smtpSendMail(net.JoinHostPort(dpconfig.SMTPHost, dpconfig.SMTPPort), auth, dpconfig.SMTPFrom, to, []byte(msg.String()))
+}
+
+/*
+smtpSendMail is smtp.SendMail with a deadline, so a server that accepts the connection and stalls
+can't hold a sender forever.
+*/
+func smtpSendMail(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(smtpTimeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(addr)
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("SMTP server doesn't support AUTH")
+		}
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range to {
+		err = c.Rcpt(addr)
+		if err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
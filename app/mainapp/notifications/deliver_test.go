@@ -0,0 +1,38 @@
+package notifications
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestSMTPSendMailTimeout$ github.com/dataplane-app/dataplane/app/mainapp/notifications
+*/
+func TestSMTPSendMailTimeout(t *testing.T) {
+
+	// A server that accepts the connection and never greets
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	smtpTimeout = 200 * time.Millisecond
+	defer func() { smtpTimeout = 30 * time.Second }()
+
+	start := time.Now()
+	err = smtpSendMail(ln.Addr().String(), nil, "dataplane@example.com", []string{"ops@example.com"}, []byte("Subject: test\r\n\r\ntest"))
+	assert.Error(t, err, "Stalled server")
+	assert.Less(t, time.Since(start), 5*time.Second, "Stalled server times out")
+}
@@ -0,0 +1,190 @@
+package notifications
+
+import (
+	"bytes"
+	"errors"
+	"net/mail"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+)
+
+/*
+NotificationMaxAttempts is how many times a notification is tried before it is marked as failed.
+*/
+const NotificationMaxAttempts = 5
+
+/*
+NotificationData is what a notification template can use.
+*/
+type NotificationData struct {
+	Event         string
+	EnvironmentID string
+	PipelineID    string
+	PipelineName  string
+	RunType       string
+	RunID         string
+	Status        string
+	StartedAt     time.Time
+	EndedAt       time.Time
+	Duration      time.Duration
+	SLASeconds    int
+	FailedNodes   []string
+	LogTail       []string
+	NodeID        string    // schedule_missed: the schedule trigger
+	DueAt         time.Time // schedule_missed: when the schedule should have fired
+}
+
+var notificationSubjects = map[string]string{
+	"fail":            "Run failed",
+	"success":         "Run succeeded",
+	"sla":             "Run over SLA",
+	"schedule_missed": "Schedule missed",
+}
+
+var notificationTemplates = map[string]string{
+	"fail": `Run {{.RunID}} of {{.PipelineName}} failed after {{.Duration}}.
+{{if .FailedNodes}}Failed nodes: {{join .FailedNodes ", "}}
+{{end}}{{if .LogTail}}
+Last log lines:
+{{join .LogTail "\n"}}
+{{end}}`,
+	"success": `Run {{.RunID}} of {{.PipelineName}} succeeded in {{.Duration}}.
+`,
+	"sla": `Run {{.RunID}} of {{.PipelineName}} has taken {{.Duration}}, over its SLA of {{.SLASeconds}} seconds. Status: {{.Status}}.
+`,
+	"schedule_missed": `Schedule {{.NodeID}} of {{.PipelineName}} did not fire at {{.DueAt.Format "2006-01-02T15:04:05Z07:00"}}.
+`,
+}
+
+var notificationFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+/*
+NotificationMessage renders the subject and message of a notification, the default template for the event is used if tmpl is empty.
+*/
+func NotificationMessage(tmpl string, data NotificationData) (string, string, error) {
+
+	if tmpl == "" {
+		tmpl = notificationTemplates[data.Event]
+	}
+
+	t, err := template.New("notification").Funcs(notificationFuncs).Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", "", errors.New("Notification template invalid: " + err.Error())
+	}
+
+	var message bytes.Buffer
+	err = t.Execute(&message, data)
+	if err != nil {
+		return "", "", errors.New("Notification template failed: " + err.Error())
+	}
+
+	name := data.PipelineName
+	if name == "" {
+		name = data.PipelineID
+	}
+
+	subject := "Dataplane: " + notificationSubjects[data.Event] + " - " + name
+
+	return subject, message.String(), nil
+}
+
+/*
+ValidateNotificationRule checks a notification rule before it is saved, the template is tried with sample data.
+*/
+func ValidateNotificationRule(r models.NotificationRules) error {
+
+	if r.PipelineID == "" {
+		return errors.New("Notification rule requires a pipeline")
+	}
+
+	if r.ChannelID == "" {
+		return errors.New("Notification rule requires a channel")
+	}
+
+	if r.RunType != "pipeline" && r.RunType != "deployment" {
+		return errors.New("Notification rule run type must be pipeline or deployment")
+	}
+
+	if _, ok := notificationTemplates[r.Event]; !ok {
+		return errors.New("Notification rule event must be fail, success, sla or schedule_missed")
+	}
+
+	if r.Event == "sla" && r.SLASeconds < 1 {
+		return errors.New("Notification rule SLA seconds must be at least 1")
+	}
+
+	if r.Template != "" {
+		_, _, err := NotificationMessage(r.Template, NotificationData{Event: r.Event})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ValidateNotificationChannel checks a notification channel before it is saved, url is the webhook URL before it is encrypted.
+*/
+func ValidateNotificationChannel(c models.NotificationChannels, webhookURL string) error {
+
+	if c.Name == "" {
+		return errors.New("Notification channel requires a name")
+	}
+
+	switch c.ChannelType {
+	case "email":
+		if _, err := mail.ParseAddressList(c.Recipients); err != nil {
+			return errors.New("Notification channel recipients invalid: " + err.Error())
+		}
+		return nil
+
+	case "webhook", "slack":
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("Notification channel URL must be an http or https URL")
+		}
+		return nil
+	}
+
+	return errors.New("Notification channel type must be email, webhook or slack")
+}
+
+/*
+NotificationRetryDelay is the wait before the next attempt of a notification: 30 seconds doubling each attempt up to 30 minutes.
+*/
+func NotificationRetryDelay(attempt int) time.Duration {
+
+	delay := 30 * time.Second
+	for i := 1; i < attempt; i++ {
+		delay = delay * 2
+		if delay >= 30*time.Minute {
+			return 30 * time.Minute
+		}
+	}
+
+	return delay
+}
+
+/*
+NotificationSLABreached checks if a run has taken longer than the SLA, a run still going is checked against now.
+*/
+func NotificationSLABreached(startedAt time.Time, endedAt time.Time, slaSeconds int, now time.Time) bool {
+
+	if slaSeconds < 1 {
+		return false
+	}
+
+	end := endedAt
+	if end.IsZero() {
+		end = now
+	}
+
+	return end.Sub(startedAt) > time.Duration(slaSeconds)*time.Second
+}
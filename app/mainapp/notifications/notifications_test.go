@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestNotifications$ github.com/dataplane-app/dataplane/app/mainapp/notifications
+*/
+func TestNotifications(t *testing.T) {
+
+	// ----- Messages
+	data := NotificationData{
+		Event:        "fail",
+		PipelineName: "Sales",
+		RunID:        "run1",
+		Duration:     90 * time.Second,
+		FailedNodes:  []string{"Load"},
+		LogTail:      []string{"line 1", "line 2"},
+	}
+
+	subject, message, err := NotificationMessage("", data)
+	assert.NoError(t, err, "Default template")
+	assert.Equal(t, "Dataplane: Run failed - Sales", subject, "Subject")
+	assert.Contains(t, message, "Run run1 of Sales failed after 1m30s.", "Message")
+	assert.Contains(t, message, "Failed nodes: Load", "Failed nodes")
+	assert.Contains(t, message, "line 1\nline 2", "Log tail")
+
+	_, message, err = NotificationMessage("{{.PipelineName}} {{.Status}}", NotificationData{Event: "success", PipelineName: "Sales", Status: "Success"})
+	assert.NoError(t, err, "Custom template")
+	assert.Equal(t, "Sales Success", message, "Custom message")
+
+	_, _, err = NotificationMessage("{{.Missing}}", data)
+	assert.Error(t, err, "Unknown field")
+
+	// ----- Rules
+	rule := models.NotificationRules{PipelineID: "p1", ChannelID: "c1", RunType: "pipeline", Event: "fail"}
+	assert.NoError(t, ValidateNotificationRule(rule), "Rule")
+
+	rule.Event = "sla"
+	assert.Error(t, ValidateNotificationRule(rule), "SLA without seconds")
+	rule.SLASeconds = 60
+	assert.NoError(t, ValidateNotificationRule(rule), "SLA")
+
+	rule.Event = "late"
+	assert.Error(t, ValidateNotificationRule(rule), "Unknown event")
+
+	rule.Event = "fail"
+	rule.Template = "{{.RunID"
+	assert.Error(t, ValidateNotificationRule(rule), "Bad template")
+
+	// ----- Channels
+	assert.NoError(t, ValidateNotificationChannel(models.NotificationChannels{Name: "Ops", ChannelType: "email", Recipients: "ops@example.com, Data <data@example.com>"}, ""), "Email")
+	assert.Error(t, ValidateNotificationChannel(models.NotificationChannels{Name: "Ops", ChannelType: "email", Recipients: "not an address"}, ""), "Email invalid")
+	assert.NoError(t, ValidateNotificationChannel(models.NotificationChannels{Name: "Hook", ChannelType: "slack"}, "https://hooks.example.com/abc"), "Slack")
+	assert.Error(t, ValidateNotificationChannel(models.NotificationChannels{Name: "Hook", ChannelType: "webhook"}, "ftp://example.com"), "Webhook scheme")
+	assert.Error(t, ValidateNotificationChannel(models.NotificationChannels{Name: "Hook", ChannelType: "sms"}, ""), "Unknown type")
+
+	// ----- Retries
+	assert.Equal(t, 30*time.Second, NotificationRetryDelay(1), "First retry")
+	assert.Equal(t, 2*time.Minute, NotificationRetryDelay(3), "Doubles")
+	assert.Equal(t, 30*time.Minute, NotificationRetryDelay(20), "Capped")
+
+	// ----- SLA
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, NotificationSLABreached(start, time.Time{}, 60, start.Add(2*time.Minute)), "Running over SLA")
+	assert.False(t, NotificationSLABreached(start, start.Add(30*time.Second), 60, start.Add(2*time.Minute)), "Finished within SLA")
+	assert.False(t, NotificationSLABreached(start, time.Time{}, 0, start.Add(2*time.Minute)), "No SLA")
+}
@@ -0,0 +1,217 @@
+package notifications
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+/* Log lines of the failed nodes added to a failure notification */
+const logTailLines = 20
+
+/*
+NotifyRunComplete sends the fail and success notifications of a run once it has finished.
+Called on every replica that closes off a run, the event key of each delivery makes sure a rule sends once per run.
+*/
+func NotifyRunComplete(runID string) {
+
+	var run models.PipelineRuns
+	err := database.DBConn.Select("run_id", "pipeline_id", "environment_id", "run_type", "status", "attempt", "created_at", "ended_at").Where("run_id = ?", runID).First(&run).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification run:", runID, err)
+		return
+	}
+
+	var event string
+	switch run.Status {
+	case "Fail":
+		event = "fail"
+	case "Success":
+		event = "success"
+	default:
+		return
+	}
+
+	var rules []models.NotificationRules
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ? and run_type = ? and event = ? and active = ?", run.PipelineID, run.EnvironmentID, run.RunType, event, true).Find(&rules).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification rules:", err)
+		return
+	}
+
+	if len(rules) == 0 {
+		return
+	}
+
+	data := runData(run, event)
+
+	if event == "fail" {
+		data.FailedNodes, data.LogTail = failedNodes(run)
+	}
+
+	// A re-run of the run notifies again
+	eventKey := "run-" + run.RunID + "-" + event
+	if run.Attempt > 1 {
+		eventKey += "-" + strconv.Itoa(run.Attempt)
+	}
+
+	for _, rule := range rules {
+		Notify(rule, data, eventKey)
+	}
+}
+
+/*
+Notify records a delivery of a rule for an event and queues it to be sent. Nothing is sent if the rule already has a delivery for the event key.
+*/
+func Notify(rule models.NotificationRules, data NotificationData, eventKey string) {
+
+	data.SLASeconds = rule.SLASeconds
+
+	subject, message, err := NotificationMessage(rule.Template, data)
+	if err != nil {
+		// A template that stopped working still sends the default message
+		logging.PrintSecretsRedact("Notification template:", rule.RuleID, err)
+		subject, message, _ = NotificationMessage("", data)
+		message = message + "\n(" + err.Error() + ")\n"
+	}
+
+	now := time.Now().UTC()
+
+	delivery := models.NotificationDeliveries{
+		DeliveryID:    uuid.NewString(),
+		RuleID:        rule.RuleID,
+		EventKey:      eventKey,
+		ChannelID:     rule.ChannelID,
+		EnvironmentID: rule.EnvironmentID,
+		PipelineID:    rule.PipelineID,
+		RunID:         data.RunID,
+		Event:         rule.Event,
+		Subject:       subject,
+		Message:       message,
+		Status:        "Pending",
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+	}
+
+	result := database.DBConn.Clauses(clause.OnConflict{DoNothing: true}).Create(&delivery)
+	if result.Error != nil {
+		logging.PrintSecretsRedact("Notification delivery:", result.Error)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	queueDelivery(delivery)
+}
+
+func runData(run models.PipelineRuns, event string) NotificationData {
+
+	data := NotificationData{
+		Event:         event,
+		EnvironmentID: run.EnvironmentID,
+		PipelineID:    run.PipelineID,
+		PipelineName:  pipelineName(run.PipelineID, run.EnvironmentID, run.RunType),
+		RunType:       run.RunType,
+		RunID:         run.RunID,
+		Status:        run.Status,
+		StartedAt:     run.CreatedAt,
+		EndedAt:       run.EndedAt,
+	}
+
+	end := run.EndedAt
+	if end.IsZero() || end.Before(run.CreatedAt) {
+		end = time.Now().UTC()
+	}
+	data.Duration = end.Sub(run.CreatedAt).Truncate(time.Second)
+
+	return data
+}
+
+func pipelineName(pipelineID string, environmentID string, runType string) string {
+
+	var name string
+	var err error
+
+	if runType == "deployment" {
+		err = database.DBConn.Model(&models.DeployPipelines{}).Select("name").Where("pipeline_id = ? and environment_id = ? and deploy_active = ?", pipelineID, environmentID, true).Limit(1).Scan(&name).Error
+	} else {
+		err = database.DBConn.Model(&models.Pipelines{}).Select("name").Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Limit(1).Scan(&name).Error
+	}
+	if err != nil {
+		logging.PrintSecretsRedact("Notification pipeline name:", err)
+	}
+
+	if name == "" {
+		return pipelineID
+	}
+
+	return name
+}
+
+/*
+failedNodes returns the names of the nodes that failed in a run and the last log lines of those nodes.
+*/
+func failedNodes(run models.PipelineRuns) ([]string, []string) {
+
+	var tasks []models.WorkerTasks
+	err := database.DBConn.Select("node_id").Where("run_id = ? and status = ?", run.RunID, "Fail").Find(&tasks).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification failed tasks:", err)
+		return nil, nil
+	}
+
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+
+	nodeIDs := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		nodeIDs = append(nodeIDs, t.NodeID)
+	}
+
+	names := make(map[string]string)
+	var nodes []models.PipelineNodes
+	if run.RunType == "deployment" {
+		err = database.DBConn.Model(&models.DeployPipelineNodes{}).Select("node_id", "name").Where("node_id in (?) and environment_id = ?", nodeIDs, run.EnvironmentID).Find(&nodes).Error
+	} else {
+		err = database.DBConn.Select("node_id", "name").Where("node_id in (?) and environment_id = ?", nodeIDs, run.EnvironmentID).Find(&nodes).Error
+	}
+	if err != nil {
+		logging.PrintSecretsRedact("Notification failed nodes:", err)
+	}
+	for _, n := range nodes {
+		names[n.NodeID] = n.Name
+	}
+
+	failed := make([]string, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if names[id] != "" {
+			failed = append(failed, names[id])
+		} else {
+			failed = append(failed, id)
+		}
+	}
+	sort.Strings(failed)
+
+	var logs []models.LogsWorkers
+	err = database.DBConn.Select("log").Where("run_id = ? and node_id in (?) and environment_id = ?", run.RunID, nodeIDs, run.EnvironmentID).Order("created_at desc").Limit(logTailLines).Find(&logs).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification log tail:", err)
+		return failed, nil
+	}
+
+	tail := make([]string, 0, len(logs))
+	for i := len(logs) - 1; i >= 0; i-- {
+		tail = append(tail, logs[i].Log)
+	}
+
+	return failed, tail
+}
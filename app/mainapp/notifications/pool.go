@@ -0,0 +1,37 @@
+package notifications
+
+import (
+	"sync"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+)
+
+/* Deliveries sent at the same time, so slow channels can't hold up the watch or each other */
+const deliveryWorkers = 8
+
+var deliveryQueue = make(chan models.NotificationDeliveries, 1000)
+var deliveryStart sync.Once
+
+/*
+queueDelivery hands a delivery to the pool of senders. If the queue is full the delivery stays
+pending and due, so the notification watch picks it up again on a later pass.
+*/
+func queueDelivery(d models.NotificationDeliveries) bool {
+
+	deliveryStart.Do(func() {
+		for i := 0; i < deliveryWorkers; i++ {
+			go func() {
+				for d := range deliveryQueue {
+					Deliver(d)
+				}
+			}()
+		}
+	})
+
+	select {
+	case deliveryQueue <- d:
+		return true
+	default:
+		return false
+	}
+}
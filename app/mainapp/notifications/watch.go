@@ -0,0 +1,187 @@
+package notifications
+
+import (
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/scheduler/firetimes"
+	"github.com/go-co-op/gocron"
+)
+
+/* A schedule is missed if there is no fire recorded this long after it was due */
+const scheduleGrace = 2 * time.Minute
+
+/* How far back the watch looks for missed schedules and finished runs over their SLA */
+const watchWindow = 10 * time.Minute
+
+/*
+NotificationWatch sends the notifications that aren't tied to a run finishing, every 30 seconds on the leader:
+sla - runs going, or finished in the last few minutes, longer than the SLA of the rule.
+schedule_missed - schedule fire times with no fire in the schedule history.
+It also retries deliveries that failed and are due another attempt.
+*/
+func NotificationWatch(s *gocron.Scheduler) {
+
+	s.Every(30).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		now := time.Now().UTC()
+
+		var rules []models.NotificationRules
+		err := database.DBConn.Where("event in (?) and active = ?", []string{"sla", "schedule_missed"}, true).Find(&rules).Error
+		if err != nil {
+			logging.PrintSecretsRedact("Notification watch rules:", err)
+			return
+		}
+
+		for _, rule := range rules {
+			switch rule.Event {
+			case "sla":
+				watchSLA(rule, now)
+			case "schedule_missed":
+				watchSchedules(rule, now)
+			}
+		}
+
+		var retries []models.NotificationDeliveries
+		err = database.DBConn.Where("status = ? and next_attempt_at <= ?", "Pending", now).Order("next_attempt_at").Limit(100).Find(&retries).Error
+		if err != nil {
+			logging.PrintSecretsRedact("Notification watch retries:", err)
+			return
+		}
+
+		for _, d := range retries {
+			if !queueDelivery(d) {
+				break
+			}
+		}
+	})
+}
+
+func watchSLA(rule models.NotificationRules, now time.Time) {
+
+	sla := time.Duration(rule.SLASeconds) * time.Second
+
+	since := now.Add(-watchWindow)
+	if rule.CreatedAt.After(since) {
+		since = rule.CreatedAt
+	}
+
+	var runs []models.PipelineRuns
+	err := database.DBConn.Select("run_id", "pipeline_id", "environment_id", "run_type", "status", "created_at", "ended_at").
+		Where("pipeline_id = ? and environment_id = ? and run_type = ?", rule.PipelineID, rule.EnvironmentID, rule.RunType).
+		Where("(status in (?) and created_at < ?) or (status in (?) and ended_at >= ?)", []string{"Running", "Paused"}, now.Add(-sla), []string{"Success", "Fail"}, since).
+		Find(&runs).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification watch SLA:", err)
+		return
+	}
+
+	for _, run := range runs {
+
+		endedAt := run.EndedAt
+		if run.Status == "Running" || run.Status == "Paused" {
+			endedAt = time.Time{}
+		}
+
+		if !NotificationSLABreached(run.CreatedAt, endedAt, rule.SLASeconds, now) {
+			continue
+		}
+
+		Notify(rule, runData(run, "sla"), "sla-"+run.RunID)
+	}
+}
+
+func watchSchedules(rule models.NotificationRules, now time.Time) {
+
+	var schedules []models.Scheduler
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ? and run_type = ? and online = ?", rule.PipelineID, rule.EnvironmentID, rule.RunType, true).Find(&schedules).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Notification watch schedules:", err)
+		return
+	}
+
+	to := now.Add(-scheduleGrace)
+
+	for _, sch := range schedules {
+
+		// Only fire times since the rule and schedule were last saved
+		from := now.Add(-watchWindow)
+		for _, t := range []time.Time{rule.CreatedAt, sch.CreatedAt} {
+			if t.After(from) {
+				from = t
+			}
+		}
+		if sch.UpdatedAt != nil && sch.UpdatedAt.After(from) {
+			from = *sch.UpdatedAt
+		}
+
+		if !from.Before(to) {
+			continue
+		}
+
+		due, err := firetimes.ScheduleFireTimes(sch.ScheduleType, sch.Schedule, sch.Timezone, from, to, 1000)
+		if err != nil {
+			logging.PrintSecretsRedact("Notification watch schedule fire times:", sch.NodeID, err)
+			continue
+		}
+
+		if len(due) == 0 {
+			continue
+		}
+
+		var history []models.SchedulerHistory
+		err = database.DBConn.Select("fired_at").Where("node_id = ? and environment_id = ? and fired_at >= ? and status in (?)", sch.NodeID, sch.EnvironmentID, due[0].Add(-time.Second), []string{"Fired", "Locked", "Skipped"}).Order("fired_at").Find(&history).Error
+		if err != nil {
+			logging.PrintSecretsRedact("Notification watch schedule history:", err)
+			continue
+		}
+
+		fired := make([]time.Time, 0, len(history))
+		for _, h := range history {
+			fired = append(fired, h.FiredAt)
+		}
+
+		for i, t := range due {
+
+			// A fire counts for a due time if it was recorded before the next due time or the grace ran out
+			until := t.Add(scheduleGrace)
+			if i+1 < len(due) && due[i+1].Before(until) {
+				until = due[i+1]
+			}
+
+			if firedBetween(fired, t.Add(-time.Second), until) {
+				continue
+			}
+
+			data := NotificationData{
+				Event:         "schedule_missed",
+				EnvironmentID: sch.EnvironmentID,
+				PipelineID:    sch.PipelineID,
+				PipelineName:  pipelineName(sch.PipelineID, sch.EnvironmentID, sch.RunType),
+				RunType:       sch.RunType,
+				NodeID:        sch.NodeID,
+				DueAt:         t,
+			}
+
+			Notify(rule, data, "schedule-"+sch.NodeID+"-"+t.UTC().Format(time.RFC3339))
+		}
+	}
+}
+
+func firedBetween(fired []time.Time, from time.Time, until time.Time) bool {
+
+	for _, f := range fired {
+		if !f.Before(from) && f.Before(until) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,272 @@
+package pipelines
+
+import (
+	"errors"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/worker"
+
+	"github.com/go-co-op/gocron"
+)
+
+/*
+ApprovalStart asks for the approval of an approval node task. The task waits at status Waiting
+until it is approved or rejected, see ApprovalDecide, or its timeout action is taken, see ApprovalWatch.
+*/
+func ApprovalStart(task models.WorkerTasks) error {
+
+	var approval models.Approval
+	var err error
+
+	switch task.RunType {
+	case "deployment":
+		node := models.DeployPipelineNodes{}
+		err = database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ? and version = ?", task.NodeID, task.PipelineID, task.EnvironmentID, task.Version).First(&node).Error
+		approval = node.Approval
+	default:
+		node := models.PipelineNodes{}
+		err = database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ?", task.NodeID, task.PipelineID, task.EnvironmentID).First(&node).Error
+		approval = node.Approval
+	}
+	if err != nil {
+		// The failure moves through the graph like any other failed task
+		worker.WSTaskLogError(task.EnvironmentID, task.RunID, "Approval: node not found", task.NodeID, task.TaskID)
+		RunNextFinishTask(task, "Fail", "Approval: node not found")
+		return nil
+	}
+
+	now := time.Now().UTC()
+
+	// Only the first run next to get here asks for the approval
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", task.TaskID, "Queue").Updates(map[string]interface{}{
+		"status":   "Waiting",
+		"start_dt": now,
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	timeoutAction, err := ApprovalTimeoutAction(approval.TimeoutAction)
+	if err != nil {
+		timeoutAction = "reject"
+	}
+
+	err = database.DBConn.Create(&models.ApprovalRequests{
+		TaskID:        task.TaskID,
+		RunID:         task.RunID,
+		NodeID:        task.NodeID,
+		PipelineID:    task.PipelineID,
+		EnvironmentID: task.EnvironmentID,
+		RunType:       task.RunType,
+		Message:       approval.Message,
+		Status:        "Waiting",
+		TimeoutAction: timeoutAction,
+		ExpiresAt:     ApprovalExpiresAt(now, approval.TimeoutSeconds),
+		CreatedAt:     now,
+	}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		ApprovalFinish(task.TaskID, "Fail", "Approval could not be requested")
+		return nil
+	}
+
+	task.Status = "Waiting"
+	task.StartDT = now
+
+	errnat := messageq.MsgSend("taskupdate."+task.EnvironmentID+"."+task.RunID, task)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	logline := "Waiting for approval"
+	if approval.Message != "" {
+		logline += ": " + approval.Message
+	}
+	worker.WSTaskLog(task.EnvironmentID, task.RunID, logline, "action", task.NodeID, task.TaskID)
+
+	return nil
+}
+
+/*
+ApprovalDecide approves or rejects a waiting approval and records who decided, how and why.
+via is graphql, link or timeout. An approved task succeeds, a rejected task fails and the run moves on from it.
+*/
+func ApprovalDecide(taskID string, approve bool, userID string, via string, comment string) (models.ApprovalRequests, error) {
+
+	status := "Rejected"
+	if approve {
+		status = "Approved"
+	}
+
+	now := time.Now().UTC()
+
+	// Only the first decision counts
+	result := database.DBConn.Model(&models.ApprovalRequests{}).Where("task_id = ? and status = ?", taskID, "Waiting").Updates(map[string]interface{}{
+		"status":      status,
+		"decided_by":  userID,
+		"decided_via": via,
+		"comment":     comment,
+		"decided_at":  now,
+	})
+	if result.Error != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(result.Error)
+		}
+		return models.ApprovalRequests{}, errors.New("Update approval database error.")
+	}
+
+	var request models.ApprovalRequests
+	err := database.DBConn.Where("task_id = ?", taskID).First(&request).Error
+	if err != nil {
+		return models.ApprovalRequests{}, errors.New("Approval not found.")
+	}
+
+	if result.RowsAffected == 0 {
+		return request, errors.New("Approval already " + request.Status + ".")
+	}
+
+	var logline, reason string
+	switch {
+	case via == "timeout":
+		logline = "Approval timed out: " + status
+		reason = "Approval timed out"
+	case approve:
+		logline = "Approved by " + userID
+	default:
+		logline = "Rejected by " + userID
+		reason = "Rejected"
+	}
+
+	if comment != "" {
+		logline += " - " + comment
+		if reason != "" {
+			reason += ": " + comment
+		}
+	}
+
+	worker.WSTaskLog(request.EnvironmentID, request.RunID, logline, "action", request.NodeID, request.TaskID)
+
+	if approve {
+		ApprovalFinish(taskID, "Success", reason)
+	} else {
+		ApprovalFinish(taskID, "Fail", reason)
+	}
+
+	return request, nil
+}
+
+/*
+ApprovalFinish finishes a waiting approval task and moves on to its destinations.
+*/
+func ApprovalFinish(taskID string, status string, reason string) {
+
+	exitCode := 0
+	if status == "Fail" {
+		exitCode = 1
+	}
+
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", taskID, "Waiting").Updates(map[string]interface{}{
+		"status":    status,
+		"reason":    reason,
+		"exit_code": exitCode,
+		"end_dt":    time.Now().UTC(),
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return
+	}
+
+	// Already finished e.g. the run was stopped
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var task models.WorkerTasks
+	err := database.DBConn.Where("task_id = ?", taskID).First(&task).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	errnat := messageq.MsgSend("taskupdate."+task.EnvironmentID+"."+task.RunID, task)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	RunNext(models.WorkerTaskSend{
+		TaskID:        task.TaskID,
+		CreatedAt:     task.CreatedAt,
+		EnvironmentID: task.EnvironmentID,
+		PipelineID:    task.PipelineID,
+		RunID:         task.RunID,
+		NodeID:        task.NodeID,
+	})
+}
+
+/*
+ApprovalStop cancels the waiting approvals of a run that is stopped.
+*/
+func ApprovalStop(runID string, environmentID string, reason string) {
+
+	now := time.Now().UTC()
+
+	err := database.DBConn.Model(&models.ApprovalRequests{}).Where("run_id = ? and environment_id = ? and status = ?", runID, environmentID, "Waiting").Updates(map[string]interface{}{
+		"status":      "Cancelled",
+		"decided_via": "stop",
+		"comment":     reason,
+		"decided_at":  now,
+	}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+
+	err = database.DBConn.Model(&models.WorkerTasks{}).Where("run_id = ? and environment_id = ? and status = ?", runID, environmentID, "Waiting").Updates(map[string]interface{}{
+		"status": "Fail",
+		"reason": "cancel",
+		"end_dt": now,
+	}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+}
+
+/*
+ApprovalWatch takes the timeout action of waiting approvals once they time out. Only the leader checks.
+*/
+func ApprovalWatch(s *gocron.Scheduler) {
+
+	s.Every(10).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		var expired []models.ApprovalRequests
+		err := database.DBConn.Select("task_id", "timeout_action").Where("status = ? and expires_at is not null and expires_at < ?", "Waiting", time.Now().UTC()).Find(&expired).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		for _, a := range expired {
+			_, err := ApprovalDecide(a.TaskID, a.TimeoutAction == "approve", "", "timeout", "")
+			if err != nil {
+				logging.PrintSecretsRedact("Approval timeout:", a.TaskID, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package pipelines
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ApprovalLinkMaxAge is how long a signed approval link can be used for.
+*/
+const ApprovalLinkMaxAge = 7 * 24 * time.Hour
+
+/*
+ApprovalTimeoutAction checks the action of an approval once it times out, reject if not set.
+*/
+func ApprovalTimeoutAction(action string) (string, error) {
+
+	switch action {
+	case "":
+		return "reject", nil
+	case "reject", "approve":
+		return action, nil
+	}
+
+	return "", errors.New("approval timeout action must be reject or approve")
+}
+
+/*
+ApprovalExpiresAt returns when an approval asked for at created times out, nil if it waits until decided.
+*/
+func ApprovalExpiresAt(created time.Time, timeoutSeconds int) *time.Time {
+
+	if timeoutSeconds <= 0 {
+		return nil
+	}
+
+	expires := created.Add(time.Duration(timeoutSeconds) * time.Second)
+	return &expires
+}
+
+/*
+ApprovalLinkExpires returns when a link made now for an approval stops working: the approval timeout or ApprovalLinkMaxAge, whichever is first.
+*/
+func ApprovalLinkExpires(now time.Time, approvalExpires *time.Time) time.Time {
+
+	expires := now.Add(ApprovalLinkMaxAge)
+	if approvalExpires != nil && approvalExpires.Before(expires) {
+		expires = *approvalExpires
+	}
+
+	return expires
+}
+
+/*
+ApprovalTokenSign signs an approval link token for a user to decide on the approval of a task without logging in.
+The token is the base64url payload "taskID|userID|expires unix" and its HMAC-SHA256.
+*/
+func ApprovalTokenSign(key []byte, taskID string, userID string, expires time.Time) string {
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(taskID + "|" + userID + "|" + strconv.FormatInt(expires.Unix(), 10)))
+
+	return payload + "." + approvalTokenMAC(key, payload)
+}
+
+/*
+ApprovalTokenVerify checks an approval link token and returns the task and user it was signed for.
+*/
+func ApprovalTokenVerify(key []byte, token string, now time.Time) (string, string, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", "", errors.New("malformed approval link")
+	}
+
+	if len(key) == 0 || !hmac.Equal([]byte(parts[1]), []byte(approvalTokenMAC(key, parts[0]))) {
+		return "", "", errors.New("invalid approval link")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", "", errors.New("malformed approval link")
+	}
+
+	fields := strings.Split(string(raw), "|")
+	if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+		return "", "", errors.New("malformed approval link")
+	}
+
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return "", "", errors.New("malformed approval link")
+	}
+
+	if now.Unix() > expires {
+		return "", "", errors.New("approval link expired")
+	}
+
+	return fields[0], fields[1], nil
+}
+
+func approvalTokenMAC(key []byte, payload string) string {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("approval:" + payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,55 @@
+package pipelines
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestApproval$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestApproval(t *testing.T) {
+
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	key := []byte("platform-key")
+
+	// ----- Timeout action
+	action, err := ApprovalTimeoutAction("")
+	assert.NoError(t, err)
+	assert.Equalf(t, "reject", action, "Reject by default")
+	_, err = ApprovalTimeoutAction("skip")
+	assert.Error(t, err, "Unknown action")
+
+	// ----- Expiry
+	assert.Nilf(t, ApprovalExpiresAt(now, 0), "No timeout")
+	assert.Equalf(t, now.Add(time.Hour), *ApprovalExpiresAt(now, 3600), "Timeout")
+	assert.Equalf(t, now.Add(ApprovalLinkMaxAge), ApprovalLinkExpires(now, nil), "Link max age")
+	assert.Equalf(t, now.Add(time.Hour), ApprovalLinkExpires(now, ApprovalExpiresAt(now, 3600)), "Link ends with the approval")
+
+	// ----- Signed links
+	token := ApprovalTokenSign(key, "task-1", "user-1", now.Add(time.Hour))
+
+	taskID, userID, err := ApprovalTokenVerify(key, token, now)
+	assert.NoError(t, err)
+	assert.Equalf(t, "task-1", taskID, "Task")
+	assert.Equalf(t, "user-1", userID, "User")
+
+	_, _, err = ApprovalTokenVerify(key, token, now.Add(2*time.Hour))
+	assert.Error(t, err, "Expired")
+
+	_, _, err = ApprovalTokenVerify([]byte("other-key"), token, now)
+	assert.Error(t, err, "Other key")
+
+	_, _, err = ApprovalTokenVerify([]byte{}, token, now)
+	assert.Error(t, err, "No key")
+
+	forged := ApprovalTokenSign(key, "task-2", "user-1", now.Add(time.Hour))
+	_, _, err = ApprovalTokenVerify(key, strings.Split(forged, ".")[0]+"."+strings.Split(token, ".")[1], now)
+	assert.Error(t, err, "Payload swapped")
+
+	_, _, err = ApprovalTokenVerify(key, "not-a-token", now)
+	assert.Error(t, err, "Malformed")
+}
@@ -0,0 +1,363 @@
+package pipelines
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+Expressions on conditional edges are evaluated against the result of the upstream task, for example:
+
+	status == "Success" && exit_code != 2
+
+Supported: numbers, 'strings' or "strings", true, false, variables, comparisons == != < <= > >=,
+&& || ! and brackets. Variables that are not set evaluate to null.
+*/
+func EvalConditionExpression(expression string, vars map[string]interface{}) (bool, error) {
+
+	tokens, err := conditionTokens(expression)
+	if err != nil {
+		return false, err
+	}
+
+	if len(tokens) == 0 {
+		return false, errors.New("Expression is empty")
+	}
+
+	p := conditionParser{tokens: tokens, vars: vars}
+
+	value, err := p.or()
+	if err != nil {
+		return false, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return false, fmt.Errorf("Expression unexpected %q", p.tokens[p.pos].text)
+	}
+
+	return conditionTruthy(value), nil
+}
+
+/*
+ValidateConditionExpression checks the syntax of an expression without any variables set.
+*/
+func ValidateConditionExpression(expression string) error {
+	_, err := EvalConditionExpression(expression, nil)
+	return err
+}
+
+type conditionToken struct {
+	kind string // number, string, ident, op
+	text string
+}
+
+func conditionTokens(expression string) ([]conditionToken, error) {
+
+	tokens := []conditionToken{}
+	i := 0
+
+	for i < len(expression) {
+
+		c := expression[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expression[i+1:], c)
+			if end < 0 {
+				return nil, errors.New("Expression string not closed")
+			}
+			tokens = append(tokens, conditionToken{kind: "string", text: expression[i+1 : i+1+end]})
+			i = i + end + 2
+
+		case (c >= '0' && c <= '9') || (c == '-' && i+1 < len(expression) && expression[i+1] >= '0' && expression[i+1] <= '9' && conditionNumberAllowed(tokens)):
+			start := i
+			i++
+			for i < len(expression) && ((expression[i] >= '0' && expression[i] <= '9') || expression[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, conditionToken{kind: "number", text: expression[start:i]})
+
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(expression) && (expression[i] == '_' || expression[i] == '.' || expression[i] == '-' ||
+				(expression[i] >= 'a' && expression[i] <= 'z') || (expression[i] >= 'A' && expression[i] <= 'Z') || (expression[i] >= '0' && expression[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, conditionToken{kind: "ident", text: expression[start:i]})
+
+		default:
+			if i+1 < len(expression) {
+				two := expression[i : i+2]
+				switch two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					tokens = append(tokens, conditionToken{kind: "op", text: two})
+					i = i + 2
+					continue
+				}
+			}
+
+			switch c {
+			case '<', '>', '!', '(', ')':
+				tokens = append(tokens, conditionToken{kind: "op", text: string(c)})
+				i++
+			default:
+				return nil, fmt.Errorf("Expression unexpected character %q", c)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+// A minus sign starts a number only where a value is expected e.g. not after a variable
+func conditionNumberAllowed(tokens []conditionToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	return last.kind == "op" && last.text != ")"
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+	vars   map[string]interface{}
+}
+
+func (p *conditionParser) peek() (conditionToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return conditionToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *conditionParser) or() (interface{}, error) {
+
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != "op" || t.text != "||" {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = conditionTruthy(left) || conditionTruthy(right)
+	}
+}
+
+func (p *conditionParser) and() (interface{}, error) {
+
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != "op" || t.text != "&&" {
+			return left, nil
+		}
+		p.pos++
+
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = conditionTruthy(left) && conditionTruthy(right)
+	}
+}
+
+func (p *conditionParser) unary() (interface{}, error) {
+
+	if t, ok := p.peek(); ok && t.kind == "op" && t.text == "!" {
+		p.pos++
+		value, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return !conditionTruthy(value), nil
+	}
+
+	return p.comparison()
+}
+
+func (p *conditionParser) comparison() (interface{}, error) {
+
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	t, ok := p.peek()
+	if !ok || t.kind != "op" {
+		return left, nil
+	}
+
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.pos++
+
+	right, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	return conditionCompare(t.text, left, right)
+}
+
+func (p *conditionParser) primary() (interface{}, error) {
+
+	t, ok := p.peek()
+	if !ok {
+		return nil, errors.New("Expression ended unexpectedly")
+	}
+	p.pos++
+
+	switch t.kind {
+	case "number":
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Expression invalid number %q", t.text)
+		}
+		return n, nil
+
+	case "string":
+		return t.text, nil
+
+	case "ident":
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		return conditionValue(p.vars[t.text]), nil
+
+	case "op":
+		if t.text == "(" {
+			value, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			closing, ok := p.peek()
+			if !ok || closing.text != ")" {
+				return nil, errors.New("Expression bracket not closed")
+			}
+			p.pos++
+			return value, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Expression unexpected %q", t.text)
+}
+
+// Variables are normalised to float64, string, bool or nil
+func conditionValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case int:
+		return float64(x)
+	case int32:
+		return float64(x)
+	case int64:
+		return float64(x)
+	case float32:
+		return float64(x)
+	case float64, string, bool, nil:
+		return x
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+func conditionNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func conditionCompare(op string, left interface{}, right interface{}) (bool, error) {
+
+	// Numbers compare as numbers, including numbers held as strings
+	ln, lok := conditionNumber(left)
+	rn, rok := conditionNumber(right)
+
+	if lok && rok {
+		switch op {
+		case "==":
+			return ln == rn, nil
+		case "!=":
+			return ln != rn, nil
+		case "<":
+			return ln < rn, nil
+		case "<=":
+			return ln <= rn, nil
+		case ">":
+			return ln > rn, nil
+		case ">=":
+			return ln >= rn, nil
+		}
+	}
+
+	switch op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+
+	ls, lok := left.(string)
+	rs, rok := right.(string)
+	if !lok || !rok {
+		// Missing variables never satisfy an ordering
+		if left == nil || right == nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("Expression can't compare %v %s %v", left, op, right)
+	}
+
+	switch op {
+	case "<":
+		return ls < rs, nil
+	case "<=":
+		return ls <= rs, nil
+	case ">":
+		return ls > rs, nil
+	default:
+		return ls >= rs, nil
+	}
+}
+
+func conditionTruthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return false
+}
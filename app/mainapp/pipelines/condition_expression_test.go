@@ -0,0 +1,49 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestConditionExpression$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestConditionExpression(t *testing.T) {
+
+	vars := map[string]interface{}{
+		"status":       "Success",
+		"exit_code":    0,
+		"attempt":      2,
+		"outputs.rows": "12",
+	}
+
+	cases := map[string]bool{
+		`status == "Success"`:                       true,
+		`status == 'Fail'`:                          false,
+		`status != "Fail" && exit_code == 0`:        true,
+		`exit_code > 0 || attempt >= 2`:             true,
+		`!(attempt < 2)`:                            true,
+		`outputs.rows > 10`:                         true,
+		`outputs.rows == 12`:                        true,
+		`missing == null`:                           true,
+		`missing > 1`:                               false,
+		`exit_code == -1`:                           false,
+		`true && (false || status == "Success")`:    true,
+		`status`:                                    true,
+		`(exit_code == 0 && attempt == 1) || false`: false,
+	}
+
+	for expression, expected := range cases {
+		result, err := EvalConditionExpression(expression, vars)
+		assert.NoErrorf(t, err, "Expression error: %s", expression)
+		assert.Equalf(t, expected, result, "Expression: %s", expression)
+	}
+
+	// Syntax errors
+	for _, expression := range []string{``, `status ==`, `(status == "Success"`, `status == "Success`, `exit_code = 1`, `status == "Success")`} {
+		assert.Errorf(t, ValidateConditionExpression(expression), "Expression should fail: %s", expression)
+	}
+
+	assert.NoError(t, ValidateConditionExpression(`outputs.rows > 0`), "Validate without variables")
+}
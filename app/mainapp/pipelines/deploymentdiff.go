@@ -0,0 +1,429 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+)
+
+type diffFileRow struct {
+	FileID      string
+	FolderID    string
+	FileName    string
+	ChecksumMD5 string
+}
+
+/*
+DeploymentDiffVersion loads a deployment version as one side of a deployment diff, see DeploymentDiff.
+*/
+func DeploymentDiffVersion(deploymentID string, environmentID string, version string) (DiffSide, error) {
+
+	var count int64
+	err := database.DBConn.Model(&models.DeployPipelines{}).Where("pipeline_id = ? and environment_id = ? and version = ?", deploymentID, environmentID, version).Count(&count).Error
+	if err != nil || count == 0 {
+		return DiffSide{}, errors.New("Deployment version " + version + " not found.")
+	}
+
+	nodes := []models.DeployPipelineNodes{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ? and version = ?", deploymentID, environmentID, version).Find(&nodes).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve deployment nodes database error.")
+	}
+
+	edges := []models.DeployPipelineEdges{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ? and version = ?", deploymentID, environmentID, version).Find(&edges).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve deployment edges database error.")
+	}
+
+	folders := []models.DeployCodeFolders{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ? and version = ?", deploymentID, environmentID, version).Find(&folders).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve deployment folders database error.")
+	}
+
+	files := []diffFileRow{}
+	err = database.DBConn.Raw(`
+	select
+	f.file_id,
+	f.folder_id,
+	f.file_name,
+	coalesce(s.checksum_md5, '') as checksum_md5
+	from deploy_code_files f
+	left join deploy_files_store s on s.file_id = f.file_id and s.environment_id = f.environment_id and s.version = f.version
+	where f.pipeline_id = ? and f.environment_id = ? and f.version = ?
+	`, deploymentID, environmentID, version).Scan(&files).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve deployment files database error.")
+	}
+
+	side := DiffSide{}
+
+	for _, n := range nodes {
+		side.Nodes = append(side.Nodes, DiffNode{
+			NodeID:       n.NodeID,
+			Name:         n.Name,
+			NodeTypeDesc: n.NodeTypeDesc,
+			WorkerGroup:  n.WorkerGroup,
+			Commands:     string(n.Commands),
+			Active:       n.Active,
+		})
+	}
+
+	for _, e := range edges {
+		side.Edges = append(side.Edges, DiffEdge{From: e.From, To: e.To, Condition: e.Condition, Expression: e.Expression})
+	}
+
+	parents := map[string]models.CodeFolders{}
+	for _, f := range folders {
+		parents[f.FolderID] = models.CodeFolders{ParentID: f.ParentID, FolderName: f.FolderName, Level: f.Level}
+	}
+
+	side.Files = diffFiles(files, parents)
+
+	return side, nil
+}
+
+/*
+DeploymentDiffPipeline loads a pipeline as it is now as one side of a deployment diff.
+*/
+func DeploymentDiffPipeline(pipelineID string, environmentID string) (DiffSide, error) {
+
+	var count int64
+	err := database.DBConn.Model(&models.Pipelines{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Count(&count).Error
+	if err != nil || count == 0 {
+		return DiffSide{}, errors.New("Source pipeline not found.")
+	}
+
+	nodes := []models.PipelineNodes{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&nodes).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve pipeline nodes database error.")
+	}
+
+	edges := []models.PipelineEdges{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&edges).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve pipeline edges database error.")
+	}
+
+	folders := []models.CodeFolders{}
+	err = database.DBConn.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Find(&folders).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve pipeline folders database error.")
+	}
+
+	files := []diffFileRow{}
+	err = database.DBConn.Raw(`
+	select
+	f.file_id,
+	f.folder_id,
+	f.file_name,
+	coalesce(s.checksum_md5, '') as checksum_md5
+	from code_files f
+	left join code_files_store s on s.file_id = f.file_id and s.environment_id = f.environment_id
+	where f.pipeline_id = ? and f.environment_id = ?
+	`, pipelineID, environmentID).Scan(&files).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return DiffSide{}, errors.New("Retrieve pipeline files database error.")
+	}
+
+	side := DiffSide{}
+
+	for _, n := range nodes {
+		side.Nodes = append(side.Nodes, DiffNode{
+			NodeID:       n.NodeID,
+			Name:         n.Name,
+			NodeTypeDesc: n.NodeTypeDesc,
+			WorkerGroup:  n.WorkerGroup,
+			Commands:     string(n.Commands),
+			Active:       n.Active,
+		})
+	}
+
+	for _, e := range edges {
+		side.Edges = append(side.Edges, DiffEdge{From: e.From, To: e.To, Condition: e.Condition, Expression: e.Expression})
+	}
+
+	parents := map[string]models.CodeFolders{}
+	for _, f := range folders {
+		parents[f.FolderID] = f
+	}
+
+	side.Files = diffFiles(files, parents)
+
+	return side, nil
+}
+
+// File paths are relative to the pipeline folder so that both sides of a diff match
+func diffFiles(files []diffFileRow, folders map[string]models.CodeFolders) []DiffFile {
+
+	out := []DiffFile{}
+
+	for _, f := range files {
+
+		path := f.FileName
+		folderID := f.FolderID
+
+		// The depth limit guards against a broken parent chain
+		for i := 0; i < 100; i++ {
+			folder, ok := folders[folderID]
+			if !ok || folder.Level == "pipeline" {
+				break
+			}
+			path = folder.FolderName + "/" + path
+			folderID = folder.ParentID
+		}
+
+		out = append(out, DiffFile{FileID: f.FileID, Path: path, ChecksumMD5: f.ChecksumMD5})
+	}
+
+	return out
+}
+
+/*
+DiffSide is one side of a deployment diff: a deployment version or the pipeline it was deployed from.
+Node IDs are compared without the d- prefix deployments add.
+*/
+type DiffSide struct {
+	Nodes []DiffNode
+	Edges []DiffEdge
+	Files []DiffFile
+}
+
+type DiffNode struct {
+	NodeID       string
+	Name         string
+	NodeTypeDesc string
+	WorkerGroup  string
+	Commands     string
+	Active       bool
+}
+
+type DiffEdge struct {
+	From       string
+	To         string
+	Condition  string
+	Expression string
+}
+
+type DiffFile struct {
+	FileID      string
+	Path        string
+	ChecksumMD5 string
+}
+
+/*
+DiffChange is one difference between two sides. Kind is node, edge or file, Change is added, removed or changed.
+For a changed item Field says what changed and From, To hold the old and new values.
+*/
+type DiffChange struct {
+	Kind   string
+	ID     string
+	Name   string
+	Change string
+	Field  string
+	From   string
+	To     string
+}
+
+/*
+DeploymentDiff returns what changed going from one side to the other: graph structure, node commands, worker groups and file contents by checksum.
+*/
+func DeploymentDiff(from DiffSide, to DiffSide) []DiffChange {
+
+	changes := []DiffChange{}
+
+	// ----- Nodes
+	fromNodes := map[string]DiffNode{}
+	for _, n := range from.Nodes {
+		fromNodes[DiffID(n.NodeID)] = n
+	}
+
+	toNodes := map[string]DiffNode{}
+	for _, n := range to.Nodes {
+		toNodes[DiffID(n.NodeID)] = n
+	}
+
+	for id, n := range fromNodes {
+		if _, ok := toNodes[id]; !ok {
+			changes = append(changes, DiffChange{Kind: "node", ID: id, Name: n.Name, Change: "removed"})
+		}
+	}
+
+	for id, n := range toNodes {
+
+		old, ok := fromNodes[id]
+		if !ok {
+			changes = append(changes, DiffChange{Kind: "node", ID: id, Name: n.Name, Change: "added"})
+			continue
+		}
+
+		fields := [][3]string{
+			{"name", old.Name, n.Name},
+			{"node_type_desc", old.NodeTypeDesc, n.NodeTypeDesc},
+			{"worker_group", old.WorkerGroup, n.WorkerGroup},
+			{"commands", diffJSON(old.Commands), diffJSON(n.Commands)},
+			{"active", diffBool(old.Active), diffBool(n.Active)},
+		}
+
+		for _, f := range fields {
+			if f[1] != f[2] {
+				changes = append(changes, DiffChange{Kind: "node", ID: id, Name: n.Name, Change: "changed", Field: f[0], From: f[1], To: f[2]})
+			}
+		}
+	}
+
+	// ----- Edges, an edge is the link between two nodes
+	fromEdges := map[string]DiffEdge{}
+	for _, e := range from.Edges {
+		fromEdges[DiffID(e.From)+" -> "+DiffID(e.To)] = e
+	}
+
+	toEdges := map[string]DiffEdge{}
+	for _, e := range to.Edges {
+		toEdges[DiffID(e.From)+" -> "+DiffID(e.To)] = e
+	}
+
+	for id := range fromEdges {
+		if _, ok := toEdges[id]; !ok {
+			changes = append(changes, DiffChange{Kind: "edge", ID: id, Name: id, Change: "removed"})
+		}
+	}
+
+	for id, e := range toEdges {
+
+		old, ok := fromEdges[id]
+		if !ok {
+			changes = append(changes, DiffChange{Kind: "edge", ID: id, Name: id, Change: "added"})
+			continue
+		}
+
+		if diffCondition(old.Condition) != diffCondition(e.Condition) {
+			changes = append(changes, DiffChange{Kind: "edge", ID: id, Name: id, Change: "changed", Field: "condition", From: diffCondition(old.Condition), To: diffCondition(e.Condition)})
+		}
+
+		if old.Expression != e.Expression {
+			changes = append(changes, DiffChange{Kind: "edge", ID: id, Name: id, Change: "changed", Field: "expression", From: old.Expression, To: e.Expression})
+		}
+	}
+
+	// ----- Files, file IDs are kept when a pipeline is deployed
+	fromFiles := map[string]DiffFile{}
+	for _, f := range from.Files {
+		fromFiles[f.FileID] = f
+	}
+
+	toFiles := map[string]DiffFile{}
+	for _, f := range to.Files {
+		toFiles[f.FileID] = f
+	}
+
+	for id, f := range fromFiles {
+		if _, ok := toFiles[id]; !ok {
+			changes = append(changes, DiffChange{Kind: "file", ID: id, Name: f.Path, Change: "removed"})
+		}
+	}
+
+	for id, f := range toFiles {
+
+		old, ok := fromFiles[id]
+		if !ok {
+			changes = append(changes, DiffChange{Kind: "file", ID: id, Name: f.Path, Change: "added"})
+			continue
+		}
+
+		if old.Path != f.Path {
+			changes = append(changes, DiffChange{Kind: "file", ID: id, Name: f.Path, Change: "changed", Field: "path", From: old.Path, To: f.Path})
+		}
+
+		if old.ChecksumMD5 != f.ChecksumMD5 {
+			changes = append(changes, DiffChange{Kind: "file", ID: id, Name: f.Path, Change: "changed", Field: "checksum_md5", From: old.ChecksumMD5, To: f.ChecksumMD5})
+		}
+	}
+
+	kindOrder := map[string]int{"node": 0, "edge": 1, "file": 2}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return kindOrder[changes[i].Kind] < kindOrder[changes[j].Kind]
+		}
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		if changes[i].ID != changes[j].ID {
+			return changes[i].ID < changes[j].ID
+		}
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes
+}
+
+/*
+DiffID removes the d- prefix deployments add to pipeline, node and edge IDs.
+*/
+func DiffID(id string) string {
+	return strings.TrimPrefix(id, "d-")
+}
+
+// Compare JSON by content so that key order and spacing don't show as changes
+func diffJSON(in string) string {
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(in), &v); err != nil {
+		return in
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return in
+	}
+
+	return string(out)
+}
+
+func diffBool(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+// Edges saved before conditions existed have none, which means success
+func diffCondition(c string) string {
+	if c == "" {
+		return "success"
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestDeploymentDiff$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestDeploymentDiff(t *testing.T) {
+
+	pipeline := DiffSide{
+		Nodes: []DiffNode{
+			{NodeID: "trigger", Name: "Play", NodeTypeDesc: "play", Commands: `[]`, Active: true},
+			{NodeID: "a", Name: "Load", NodeTypeDesc: "python", WorkerGroup: "python_1", Commands: `[{"command": "python load.py"}]`, Active: true},
+			{NodeID: "b", Name: "Report", NodeTypeDesc: "python", WorkerGroup: "python_1", Commands: `[{"command":"python report.py"}]`, Active: true},
+		},
+		Edges: []DiffEdge{
+			{From: "trigger", To: "a", Condition: "success"},
+			{From: "a", To: "b"},
+		},
+		Files: []DiffFile{
+			{FileID: "f1", Path: "Load/load.py", ChecksumMD5: "111"},
+			{FileID: "f2", Path: "Report/report.py", ChecksumMD5: "222"},
+		},
+	}
+
+	// A deployment of the same pipeline: d- prefixes, key spacing and a missing condition are not changes
+	deployment := DiffSide{
+		Nodes: []DiffNode{
+			{NodeID: "d-trigger", Name: "Play", NodeTypeDesc: "play", Commands: `[]`, Active: true},
+			{NodeID: "d-a", Name: "Load", NodeTypeDesc: "python", WorkerGroup: "python_1", Commands: `[{"command":"python load.py"}]`, Active: true},
+			{NodeID: "d-b", Name: "Report", NodeTypeDesc: "python", WorkerGroup: "python_1", Commands: `[{"command": "python report.py"}]`, Active: true},
+		},
+		Edges: []DiffEdge{
+			{From: "d-trigger", To: "d-a"},
+			{From: "d-a", To: "d-b", Condition: "success"},
+		},
+		Files: []DiffFile{
+			{FileID: "f1", Path: "Load/load.py", ChecksumMD5: "111"},
+			{FileID: "f2", Path: "Report/report.py", ChecksumMD5: "222"},
+		},
+	}
+
+	assert.Equalf(t, []DiffChange{}, DeploymentDiff(deployment, pipeline), "Same pipeline and deployment.")
+
+	// Changed worker group and command, node c added after b, b's file edited and a new file
+	changed := DiffSide{
+		Nodes: []DiffNode{
+			{NodeID: "trigger", Name: "Play", NodeTypeDesc: "play", Commands: `[]`, Active: true},
+			{NodeID: "a", Name: "Load", NodeTypeDesc: "python", WorkerGroup: "python_2", Commands: `[{"command":"python load.py --full"}]`, Active: true},
+			{NodeID: "b", Name: "Report", NodeTypeDesc: "python", WorkerGroup: "python_1", Commands: `[{"command":"python report.py"}]`, Active: true},
+			{NodeID: "c", Name: "Notify", NodeTypeDesc: "bash", WorkerGroup: "python_1", Commands: `[]`, Active: true},
+		},
+		Edges: []DiffEdge{
+			{From: "trigger", To: "a"},
+			{From: "a", To: "b", Condition: "always"},
+			{From: "b", To: "c"},
+		},
+		Files: []DiffFile{
+			{FileID: "f1", Path: "Load/load.py", ChecksumMD5: "111"},
+			{FileID: "f2", Path: "Report/report.py", ChecksumMD5: "333"},
+			{FileID: "f3", Path: "Notify/notify.sh", ChecksumMD5: "444"},
+		},
+	}
+
+	assert.Equalf(t, []DiffChange{
+		{Kind: "node", ID: "a", Name: "Load", Change: "changed", Field: "commands", From: `[{"command":"python load.py"}]`, To: `[{"command":"python load.py --full"}]`},
+		{Kind: "node", ID: "a", Name: "Load", Change: "changed", Field: "worker_group", From: "python_1", To: "python_2"},
+		{Kind: "node", ID: "c", Name: "Notify", Change: "added"},
+		{Kind: "edge", ID: "a -> b", Name: "a -> b", Change: "changed", Field: "condition", From: "success", To: "always"},
+		{Kind: "edge", ID: "b -> c", Name: "b -> c", Change: "added"},
+		{Kind: "file", ID: "f3", Name: "Notify/notify.sh", Change: "added"},
+		{Kind: "file", ID: "f2", Name: "Report/report.py", Change: "changed", Field: "checksum_md5", From: "222", To: "333"},
+	}, DeploymentDiff(deployment, changed), "Changes from deployment to pipeline.")
+
+	// The other way round what was added is removed
+	assert.Equalf(t, []DiffChange{
+		{Kind: "node", ID: "a", Name: "Load", Change: "changed", Field: "commands", From: `[{"command":"python load.py --full"}]`, To: `[{"command":"python load.py"}]`},
+		{Kind: "node", ID: "a", Name: "Load", Change: "changed", Field: "worker_group", From: "python_2", To: "python_1"},
+		{Kind: "node", ID: "c", Name: "Notify", Change: "removed"},
+		{Kind: "edge", ID: "a -> b", Name: "a -> b", Change: "changed", Field: "condition", From: "always", To: "success"},
+		{Kind: "edge", ID: "b -> c", Name: "b -> c", Change: "removed"},
+		{Kind: "file", ID: "f3", Name: "Notify/notify.sh", Change: "removed"},
+		{Kind: "file", ID: "f2", Name: "Report/report.py", Change: "changed", Field: "checksum_md5", From: "333", To: "222"},
+	}, DeploymentDiff(changed, deployment), "Changes from pipeline to deployment.")
+
+	// A moved file
+	moved := DiffSide{Files: []DiffFile{{FileID: "f1", Path: "Load/src/load.py", ChecksumMD5: "111"}}}
+	before := DiffSide{Files: []DiffFile{{FileID: "f1", Path: "Load/load.py", ChecksumMD5: "111"}}}
+	assert.Equalf(t, []DiffChange{
+		{Kind: "file", ID: "f1", Name: "Load/src/load.py", Change: "changed", Field: "path", From: "Load/load.py", To: "Load/src/load.py"},
+	}, DeploymentDiff(before, moved), "Moved file.")
+}
@@ -0,0 +1,277 @@
+package pipelines
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+/*
+DeploymentRollback makes another version of a deployment the active one in one step, by default the version deployed before the active one.
+The version rolled back to takes the trigger state of the active version and its schedule and event triggers replace the active version's.
+API triggers belong to the deployment and always run the active version, so they follow the rollback. Runs already going finish on their version.
+*/
+func DeploymentRollback(deploymentID string, environmentID string, version string, userID string) (models.DeploymentPromotions, error) {
+
+	var promotion models.DeploymentPromotions
+	var schedules []models.Scheduler
+	var removeSchedules []models.Scheduler
+
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+
+		active := models.DeployPipelines{}
+		err := tx.Where("pipeline_id = ? and environment_id = ? and deploy_active = ?", deploymentID, environmentID, true).First(&active).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Active deployment not found.")
+		}
+
+		target := models.DeployPipelines{}
+		query := tx.Where("pipeline_id = ? and environment_id = ?", deploymentID, environmentID)
+		if version == "" {
+			query = query.Where("created_at < ?", active.CreatedAt).Order("created_at desc")
+		} else {
+			query = query.Where("version = ?", version)
+		}
+
+		err = query.First(&target).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			if version == "" {
+				return errors.New("No earlier version to roll back to.")
+			}
+			return errors.New("Deployment version not found.")
+		}
+
+		if target.Version == active.Version {
+			return errors.New("Version " + target.Version + " is already active.")
+		}
+
+		if active.UpdateLock || target.UpdateLock {
+			return errors.New("Deployment is being updated, try again.")
+		}
+
+		activeTrigger := models.DeployPipelineNodes{}
+		err = tx.Select("trigger_online").Where("pipeline_id = ? and environment_id = ? and version = ? and node_type = ?", deploymentID, environmentID, active.Version, "trigger").First(&activeTrigger).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve trigger node database error.")
+		}
+		online := activeTrigger.TriggerOnline
+
+		// Switch the active version
+		err = tx.Exec(`
+	update deploy_pipelines set
+	deploy_active = CASE
+      WHEN version = ?  THEN true
+      ELSE false
+	END
+	where pipeline_id = ? and environment_id = ?
+	`, target.Version, deploymentID, environmentID).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Switch deployment version database error.")
+		}
+
+		// Turn off the triggers of all other versions
+		err = tx.Model(&models.DeployPipelineNodes{}).Where("pipeline_id = ? and environment_id = ? and version <> ? and trigger_online = true", deploymentID, environmentID, target.Version).Update("trigger_online", false).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Update trigger nodes database error.")
+		}
+
+		triggers := []models.DeployPipelineNodes{}
+		err = tx.Where("pipeline_id = ? and environment_id = ? and version = ? and node_type = ?", deploymentID, environmentID, target.Version, "trigger").Find(&triggers).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Retrieve trigger nodes database error.")
+		}
+
+		for i, n := range triggers {
+
+			nodeOnline := online
+			if n.NodeTypeDesc == "play" {
+				nodeOnline = true
+			}
+
+			err = tx.Model(&models.DeployPipelineNodes{}).Where("node_id = ? and pipeline_id = ? and environment_id = ? and version = ?", n.NodeID, deploymentID, environmentID, target.Version).Update("trigger_online", nodeOnline).Error
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				return errors.New("Update trigger nodes database error.")
+			}
+
+			triggers[i].TriggerOnline = nodeOnline
+		}
+
+		var eventTriggers []models.EventTriggers
+		schedules, eventTriggers = DeploymentTriggers(triggers, deploymentID, environmentID)
+
+		err = EventTriggersSave(tx, deploymentID, environmentID, eventTriggers)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Update deployment event triggers database error.")
+		}
+
+		// A new schedule replaces the old one, without one the old one is removed
+		if len(schedules) == 0 {
+			err = tx.Where("pipeline_id = ? and environment_id = ? and run_type = ?", deploymentID, environmentID, "deployment").Find(&removeSchedules).Error
+			if err != nil {
+				if dpconfig.Debug == "true" {
+					logging.PrintSecretsRedact(err)
+				}
+				return errors.New("Retrieve deployment schedules database error.")
+			}
+		}
+
+		promotion = models.DeploymentPromotions{
+			ID:                uuid.NewString(),
+			DeploymentID:      deploymentID,
+			EnvironmentID:     environmentID,
+			Version:           target.Version,
+			PreviousVersion:   active.Version,
+			FromEnvironmentID: target.FromEnvironmentID,
+			FromPipelineID:    target.FromPipelineID,
+			Action:            "rollback",
+			UserID:            userID,
+			CreatedAt:         time.Now().UTC(),
+		}
+
+		err = tx.Create(&promotion).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return errors.New("Add promotion log database error.")
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return models.DeploymentPromotions{}, err
+	}
+
+	// ----- Re-point the schedules on the leader
+	for _, s := range schedules {
+		errnat := messageq.MsgSend("pipeline-scheduler", s)
+		if errnat != nil {
+			logging.PrintSecretsRedact("NATS error:", errnat)
+		}
+	}
+
+	for _, s := range removeSchedules {
+		errnat := messageq.MsgSend("pipeline-scheduler-delete", s)
+		if errnat != nil {
+			logging.PrintSecretsRedact("NATS error:", errnat)
+		}
+	}
+
+	EventTriggersReload()
+
+	return promotion, nil
+}
+
+/*
+DeploymentTriggers builds the schedules and event triggers of a deployment version from the settings kept in the meta of its trigger nodes.
+A pipeline event trigger of a deployment waits on the deployment of the pipeline it waited on.
+*/
+func DeploymentTriggers(nodes []models.DeployPipelineNodes, deploymentID string, environmentID string) ([]models.Scheduler, []models.EventTriggers) {
+
+	schedules := []models.Scheduler{}
+	eventTriggers := []models.EventTriggers{}
+
+	for _, n := range nodes {
+
+		genericdata := jsoniter.Get(n.Meta, "data", "genericdata")
+
+		var parameters datatypes.JSON
+		if raw := genericdata.Get("parameters"); raw.ValueType() == jsoniter.ObjectValue {
+			parameters = datatypes.JSON(raw.ToString())
+		}
+
+		switch n.NodeTypeDesc {
+		case "schedule":
+
+			schedule := models.Scheduler{
+				NodeID:        n.NodeID,
+				PipelineID:    deploymentID,
+				EnvironmentID: environmentID,
+				ScheduleType:  genericdata.Get("scheduleType").ToString(),
+				Schedule:      genericdata.Get("schedule").ToString(),
+				Timezone:      genericdata.Get("timezone").ToString(),
+				Online:        n.TriggerOnline,
+				Parameters:    parameters,
+				CatchUp:       genericdata.Get("catchUp").ToBool(),
+				RunType:       "deployment",
+			}
+
+			if schedule.ScheduleType == "cronseconds" {
+				schedule.Timezone = "UTC"
+			}
+
+			schedules = append(schedules, schedule)
+
+		case "file", "nats", "pipeline":
+
+			workerGroup := genericdata.Get("workerGroup").ToString()
+			if workerGroup == "" {
+				workerGroup = n.WorkerGroup
+			}
+
+			sourceStatus := genericdata.Get("sourceStatus").ToString()
+			if sourceStatus == "" {
+				sourceStatus = "Success"
+			}
+
+			sourcePipelineID := genericdata.Get("sourcePipelineID").ToString()
+			if n.NodeTypeDesc == "pipeline" && !strings.HasPrefix(sourcePipelineID, "d-") {
+				sourcePipelineID = "d-" + sourcePipelineID
+			}
+
+			eventTriggers = append(eventTriggers, models.EventTriggers{
+				NodeID:           n.NodeID,
+				PipelineID:       deploymentID,
+				EnvironmentID:    environmentID,
+				TriggerType:      n.NodeTypeDesc,
+				RunType:          "deployment",
+				Online:           n.TriggerOnline,
+				Parameters:       parameters,
+				WorkerGroup:      workerGroup,
+				Path:             genericdata.Get("path").ToString(),
+				Pattern:          genericdata.Get("pattern").ToString(),
+				Subject:          genericdata.Get("subject").ToString(),
+				SourcePipelineID: sourcePipelineID,
+				SourceStatus:     sourceStatus,
+				CreatedAt:        time.Now().UTC(),
+			})
+		}
+	}
+
+	return schedules, eventTriggers
+}
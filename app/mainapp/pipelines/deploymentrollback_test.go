@@ -0,0 +1,50 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+/*
+go test -timeout 30s -v -run ^TestDeploymentTriggers$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestDeploymentTriggers(t *testing.T) {
+
+	nodes := []models.DeployPipelineNodes{
+		{NodeID: "d-play", NodeTypeDesc: "play", TriggerOnline: true, Meta: datatypes.JSON(`{"data":{"genericdata":{}}}`)},
+		{NodeID: "d-schedule", NodeTypeDesc: "schedule", TriggerOnline: true, Meta: datatypes.JSON(`{"data":{"genericdata":{"scheduleType":"cronseconds","schedule":"*/5 * * * * *","timezone":"Europe/London","catchUp":true,"parameters":{"region":"eu"}}}}`)},
+		{NodeID: "d-file", NodeTypeDesc: "file", WorkerGroup: "python_1", Meta: datatypes.JSON(`{"data":{"genericdata":{"path":"/data/in","pattern":"*.csv"}}}`)},
+		{NodeID: "d-upstream", NodeTypeDesc: "pipeline", TriggerOnline: true, Meta: datatypes.JSON(`{"data":{"genericdata":{"sourcePipelineID":"upstream","sourceStatus":"Fail"}}}`)},
+		{NodeID: "d-deployed", NodeTypeDesc: "pipeline", Meta: datatypes.JSON(`{"data":{"genericdata":{"sourcePipelineID":"d-deployed"}}}`)},
+	}
+
+	schedules, eventTriggers := DeploymentTriggers(nodes, "d-pipeline", "env")
+
+	assert.Len(t, schedules, 1)
+	assert.Equal(t, "d-schedule", schedules[0].NodeID)
+	assert.Equal(t, "d-pipeline", schedules[0].PipelineID)
+	assert.Equal(t, "*/5 * * * * *", schedules[0].Schedule)
+	assert.Equal(t, "UTC", schedules[0].Timezone, "Seconds schedules run in UTC")
+	assert.Equal(t, "deployment", schedules[0].RunType)
+	assert.True(t, schedules[0].Online)
+	assert.True(t, schedules[0].CatchUp)
+	assert.JSONEq(t, `{"region":"eu"}`, string(schedules[0].Parameters))
+
+	assert.Len(t, eventTriggers, 3)
+
+	assert.Equal(t, "file", eventTriggers[0].TriggerType)
+	assert.Equal(t, "python_1", eventTriggers[0].WorkerGroup, "Worker group falls back to the node's")
+	assert.Equal(t, "/data/in", eventTriggers[0].Path)
+	assert.Equal(t, "Success", eventTriggers[0].SourceStatus)
+	assert.False(t, eventTriggers[0].Online)
+
+	assert.Equal(t, "d-upstream", eventTriggers[1].NodeID)
+	assert.Equal(t, "d-upstream", eventTriggers[1].SourcePipelineID, "Waits on the deployment of the source pipeline")
+	assert.Equal(t, "Fail", eventTriggers[1].SourceStatus)
+	assert.Equal(t, "deployment", eventTriggers[1].RunType)
+
+	assert.Equal(t, "d-deployed", eventTriggers[2].SourcePipelineID, "An existing d- prefix is kept")
+}
@@ -0,0 +1,148 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"gorm.io/datatypes"
+)
+
+/*
+ValidateEdgeCondition checks an edge condition before it is saved.
+*/
+func ValidateEdgeCondition(condition models.EdgeCondition) error {
+
+	switch condition.Condition {
+	case "", "success", "failure", "always":
+		return nil
+	case "expression":
+		if condition.Expression == "" {
+			return errors.New("Expression condition requires an expression")
+		}
+		return ValidateConditionExpression(condition.Expression)
+	}
+
+	return errors.New("Edge condition must be success, failure, always or expression")
+}
+
+/*
+TaskFinished checks if a task status is final for the graph - retried attempts are not.
+*/
+func TaskFinished(status string) bool {
+	switch status {
+	case "Success", "Fail", "Skipped":
+		return true
+	}
+	return false
+}
+
+/*
+EdgeConditionVars are the variables an edge expression can use from the upstream task.
+Outputs of the upstream task are available as outputs.<key>
+*/
+func EdgeConditionVars(upstream models.WorkerTasks, outputs map[string]datatypes.JSON) map[string]interface{} {
+
+	vars := map[string]interface{}{
+		"status":    upstream.Status,
+		"reason":    upstream.Reason,
+		"exit_code": upstream.ExitCode,
+		"attempt":   upstream.Attempt,
+	}
+
+	for k, v := range outputs {
+		vars["outputs."+k] = OutputConditionValue(v)
+	}
+
+	return vars
+}
+
+/*
+EdgeConditionMet checks an edge condition against the finished upstream task.
+Skipped upstream tasks only satisfy always.
+*/
+func EdgeConditionMet(condition models.EdgeCondition, upstream models.WorkerTasks, vars map[string]interface{}) (bool, error) {
+
+	switch condition.Condition {
+	case "always":
+		return true, nil
+	case "failure":
+		return upstream.Status == "Fail", nil
+	case "expression":
+		if upstream.Status == "Skipped" {
+			return false, nil
+		}
+		return EvalConditionExpression(condition.Expression, vars)
+	default:
+		return upstream.Status == "Success", nil
+	}
+}
+
+/*
+NextTaskDecision decides what happens to a queued task once its upstream tasks have finished:
+Queue - an upstream task has not finished yet, wait.
+Run - every incoming edge condition is met.
+Fail - an upstream task failed and its edge does not handle the failure, reason "Upstream fail".
+Skipped - otherwise the branch is pruned.
+vars holds the expression variables of each upstream node.
+*/
+func NextTaskDecision(upstream []models.WorkerTasks, conditions map[string]models.EdgeCondition, vars map[string]map[string]interface{}) (string, string) {
+
+	for _, u := range upstream {
+		if !TaskFinished(u.Status) {
+			return "Queue", ""
+		}
+	}
+
+	upstreamFail := false
+	met := true
+
+	for _, u := range upstream {
+
+		condition := conditions[u.NodeID]
+
+		ok, err := EdgeConditionMet(condition, u, vars[u.NodeID])
+		if err != nil {
+			ok = false
+		}
+
+		if ok {
+			continue
+		}
+
+		met = false
+		if u.Status == "Fail" && condition.Condition != "failure" {
+			upstreamFail = true
+		}
+	}
+
+	if met {
+		return "Run", ""
+	}
+
+	if upstreamFail {
+		return "Fail", "Upstream fail"
+	}
+
+	return "Skipped", "Condition not met"
+}
+
+/*
+OutputConditionValue converts an output for edge condition expressions.
+Objects and arrays are compared as their JSON text.
+*/
+func OutputConditionValue(value datatypes.JSON) interface{} {
+
+	var v interface{}
+	if json.Unmarshal(value, &v) != nil {
+		return string(value)
+	}
+
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return string(value)
+	}
+
+	return v
+}
@@ -0,0 +1,82 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+/*
+go test -timeout 30s -v -run ^TestNextTaskDecision$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestNextTaskDecision(t *testing.T) {
+
+	success := models.WorkerTasks{NodeID: "a", Status: "Success"}
+	fail := models.WorkerTasks{NodeID: "a", Status: "Fail", ExitCode: 2}
+	skipped := models.WorkerTasks{NodeID: "a", Status: "Skipped"}
+	running := models.WorkerTasks{NodeID: "b", Status: "Run"}
+
+	decide := func(upstream []models.WorkerTasks, conditions map[string]models.EdgeCondition) string {
+		vars := make(map[string]map[string]interface{})
+		for _, u := range upstream {
+			vars[u.NodeID] = EdgeConditionVars(u, nil)
+		}
+		decision, _ := NextTaskDecision(upstream, conditions, vars)
+		return decision
+	}
+
+	onSuccess := map[string]models.EdgeCondition{}
+	onFailure := map[string]models.EdgeCondition{"a": {Condition: "failure"}}
+	always := map[string]models.EdgeCondition{"a": {Condition: "always"}}
+	expression := map[string]models.EdgeCondition{"a": {Condition: "expression", Expression: "exit_code == 2"}}
+
+	// Default success edges
+	assert.Equalf(t, "Run", decide([]models.WorkerTasks{success}, onSuccess), "Success edge after success")
+	assert.Equalf(t, "Fail", decide([]models.WorkerTasks{fail}, onSuccess), "Success edge after fail")
+	assert.Equalf(t, "Skipped", decide([]models.WorkerTasks{skipped}, onSuccess), "Success edge after skipped")
+
+	// Wait for all upstream tasks
+	assert.Equalf(t, "Queue", decide([]models.WorkerTasks{success, running}, onSuccess), "Upstream still running")
+
+	// Failure edges
+	assert.Equalf(t, "Run", decide([]models.WorkerTasks{fail}, onFailure), "Failure edge after fail")
+	assert.Equalf(t, "Skipped", decide([]models.WorkerTasks{success}, onFailure), "Failure edge after success")
+
+	// Always edges
+	assert.Equalf(t, "Run", decide([]models.WorkerTasks{fail}, always), "Always edge after fail")
+	assert.Equalf(t, "Run", decide([]models.WorkerTasks{skipped}, always), "Always edge after skipped")
+
+	// Expression edges
+	assert.Equalf(t, "Run", decide([]models.WorkerTasks{fail}, expression), "Expression met")
+	assert.Equalf(t, "Skipped", decide([]models.WorkerTasks{success}, expression), "Expression not met")
+
+	// Expressions on upstream outputs
+	rows := map[string]models.EdgeCondition{"a": {Condition: "expression", Expression: "outputs.rows > 0 && outputs.table == 'sales'"}}
+	vars := map[string]map[string]interface{}{"a": EdgeConditionVars(success, map[string]datatypes.JSON{"rows": datatypes.JSON(`120`), "table": datatypes.JSON(`"sales"`)})}
+	decision, _ := NextTaskDecision([]models.WorkerTasks{success}, rows, vars)
+	assert.Equalf(t, "Run", decision, "Expression on outputs met")
+
+	vars = map[string]map[string]interface{}{"a": EdgeConditionVars(success, map[string]datatypes.JSON{"rows": datatypes.JSON(`0`)})}
+	decision, _ = NextTaskDecision([]models.WorkerTasks{success}, rows, vars)
+	assert.Equalf(t, "Skipped", decision, "Expression on outputs not met")
+
+	// Validation
+	assert.NoError(t, ValidateEdgeCondition(models.EdgeCondition{}), "Default condition")
+	assert.Error(t, ValidateEdgeCondition(models.EdgeCondition{Condition: "sometimes"}), "Unknown condition")
+	assert.Error(t, ValidateEdgeCondition(models.EdgeCondition{Condition: "expression"}), "Missing expression")
+	assert.Error(t, ValidateEdgeCondition(models.EdgeCondition{Condition: "expression", Expression: "status =="}), "Invalid expression")
+}
+
+/*
+go test -timeout 30s -v -run ^TestOutputConditionValue$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestOutputConditionValue(t *testing.T) {
+
+	assert.Equalf(t, float64(120), OutputConditionValue(datatypes.JSON(`120`)), "Condition number")
+	assert.Equalf(t, "sales", OutputConditionValue(datatypes.JSON(`"sales"`)), "Condition string")
+	assert.Equalf(t, true, OutputConditionValue(datatypes.JSON(`true`)), "Condition bool")
+	assert.Equalf(t, `{"a":1}`, OutputConditionValue(datatypes.JSON(`{"a":1}`)), "Condition object as JSON text")
+}
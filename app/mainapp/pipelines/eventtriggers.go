@@ -0,0 +1,268 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+
+	"github.com/go-co-op/gocron"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/*
+EventTriggerRun starts a run for an event received by a trigger.
+The event key is recorded first so that an event seen by more than one worker or replica starts a single run.
+*/
+func EventTriggerRun(t models.EventTriggers, eventKey string, payload datatypes.JSON) {
+
+	event := models.EventTriggerEvents{
+		NodeID:        t.NodeID,
+		EnvironmentID: t.EnvironmentID,
+		EventKey:      eventKey,
+		PipelineID:    t.PipelineID,
+		TriggerType:   t.TriggerType,
+		Payload:       payload,
+		Status:        "Fired",
+		RunID:         uuid.NewString(),
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	result := database.DBConn.Clauses(clause.OnConflict{DoNothing: true}).Create(&event)
+	if result.Error != nil {
+		logging.PrintSecretsRedact("Event trigger:", t.NodeID, result.Error)
+		return
+	}
+
+	// Already started
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	if dpconfig.Debug == "true" {
+		log.Println("Event trigger run:", t.TriggerType, t.NodeID, eventKey)
+	}
+
+	// Parameters are checked at each run as the pipeline parameters may have changed since the trigger was saved
+	runParameters, err := RunParameters(t.PipelineID, t.EnvironmentID, t.RunType, "", t.Parameters)
+	if err == nil {
+		switch t.RunType {
+		case "deployment":
+			_, err = RunDeployment(t.PipelineID, t.EnvironmentID, event.RunID, RunOptions{Parameters: runParameters}, payload, datatypes.JSON(`{"version":"latest"}`))
+		default:
+			_, err = RunPipeline(t.PipelineID, t.EnvironmentID, event.RunID, RunOptions{Parameters: runParameters}, payload)
+		}
+	}
+
+	if err != nil {
+		status := "Failed"
+		if err == ErrRunSkipped {
+			status = "Skipped"
+		}
+
+		logging.PrintSecretsRedact("Event trigger run:", t.NodeID, err)
+
+		database.DBConn.Model(&models.EventTriggerEvents{}).Where("node_id = ? and environment_id = ? and event_key = ?", t.NodeID, t.EnvironmentID, eventKey).Updates(map[string]interface{}{
+			"status": status,
+			"run_id": "",
+			"error":  err.Error(),
+		})
+	}
+}
+
+/*
+EventTriggersRunComplete starts the pipeline triggers waiting on a run that has finished.
+*/
+func EventTriggersRunComplete(run models.PipelineRuns) {
+
+	if run.Status != "Success" && run.Status != "Fail" {
+		return
+	}
+
+	var triggers []models.EventTriggers
+	err := database.DBConn.Where("trigger_type = ? and source_pipeline_id = ? and environment_id = ? and online = true", "pipeline", run.PipelineID, run.EnvironmentID).Find(&triggers).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Pipeline triggers:", err)
+		return
+	}
+
+	for _, t := range triggers {
+
+		if !EventSourceStatusMatch(t.SourceStatus, run.Status) {
+			continue
+		}
+
+		// Runs that finished before the trigger was saved or turned on don't start it
+		since := t.CreatedAt
+		if t.UpdatedAt != nil && t.UpdatedAt.After(since) {
+			since = *t.UpdatedAt
+		}
+		if run.EndedAt.Before(since) {
+			continue
+		}
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"run_id":      run.RunID,
+			"pipeline_id": run.PipelineID,
+			"run_type":    run.RunType,
+			"status":      run.Status,
+			"ended_at":    run.EndedAt,
+		})
+
+		EventTriggerRun(t, "run-"+run.RunID, payload)
+	}
+}
+
+/*
+EventTriggersCompleteWatch starts pipeline triggers for runs that ended without a run next, e.g. no worker was available.
+Only the leader checks, runs already seen are skipped by their event key.
+*/
+func EventTriggersCompleteWatch(s *gocron.Scheduler) {
+
+	s.Every(10).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		var runs []models.PipelineRuns
+		err := database.DBConn.Select("run_id", "pipeline_id", "environment_id", "run_type", "status", "ended_at").
+			Where("status in (?) and ended_at > ?", []string{"Success", "Fail"}, time.Now().UTC().Add(-10*time.Minute)).
+			Where("pipeline_id in (?)", database.DBConn.Model(&models.EventTriggers{}).Select("source_pipeline_id").Where("trigger_type = ? and online = true", "pipeline")).
+			Find(&runs).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		for _, run := range runs {
+			EventTriggersRunComplete(run)
+		}
+	})
+}
+
+/*
+EventTriggersSave replaces the event triggers of a pipeline or deployment.
+*/
+func EventTriggersSave(tx *gorm.DB, pipelineID string, environmentID string, triggers []models.EventTriggers) error {
+
+	err := tx.Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Delete(&models.EventTriggers{}).Error
+	if err != nil {
+		return err
+	}
+
+	if len(triggers) == 0 {
+		return nil
+	}
+
+	return tx.Create(&triggers).Error
+}
+
+/*
+EventTriggersOnline turns the event triggers of a pipeline or deployment on or off.
+*/
+func EventTriggersOnline(tx *gorm.DB, pipelineID string, environmentID string, online bool) error {
+	return tx.Model(&models.EventTriggers{}).Where("pipeline_id = ? and environment_id = ?", pipelineID, environmentID).Update("online", online).Error
+}
+
+/*
+EventTriggersReload tells the main app replicas and workers to reload the event triggers.
+*/
+func EventTriggersReload() {
+	err := messageq.MsgSend("event-triggers-reload", map[string]interface{}{"reload": true})
+	if err != nil {
+		logging.PrintSecretsRedact("NATS error:", err)
+	}
+}
+
+/*
+EventSubjectPrefix keeps NATS event triggers away from the subjects the platform uses internally.
+*/
+const EventSubjectPrefix = "events."
+
+/*
+ValidateEventTrigger checks an event trigger before it is saved.
+*/
+func ValidateEventTrigger(t models.EventTriggers) error {
+
+	switch t.TriggerType {
+	case "file":
+		if t.Path == "" {
+			return errors.New("File trigger requires a directory")
+		}
+		if !filepath.IsAbs(t.Path) {
+			return errors.New("File trigger directory must be an absolute path")
+		}
+		if t.WorkerGroup == "" {
+			return errors.New("File trigger requires a worker group")
+		}
+		if _, err := filepath.Match(t.Pattern, ""); err != nil {
+			return errors.New("File trigger pattern invalid: " + t.Pattern)
+		}
+		return nil
+
+	case "nats":
+		return ValidateEventSubject(t.Subject)
+
+	case "pipeline":
+		if t.SourcePipelineID == "" {
+			return errors.New("Pipeline trigger requires a pipeline")
+		}
+		if t.SourcePipelineID == t.PipelineID {
+			return errors.New("Pipeline trigger can't start on its own pipeline")
+		}
+		switch t.SourceStatus {
+		case "Success", "Fail", "Any":
+			return nil
+		}
+		return errors.New("Pipeline trigger status must be Success, Fail or Any")
+	}
+
+	return errors.New("Event trigger type must be file, nats or pipeline")
+}
+
+/*
+ValidateEventSubject checks a NATS subject: tokens separated by dots, * matches a token and > the rest of the subject.
+*/
+func ValidateEventSubject(subject string) error {
+
+	if !strings.HasPrefix(subject, EventSubjectPrefix) {
+		return errors.New("NATS trigger subject must start with " + EventSubjectPrefix)
+	}
+
+	tokens := strings.Split(subject, ".")
+	for i, token := range tokens {
+		if token == "" {
+			return errors.New("NATS trigger subject has an empty token: " + subject)
+		}
+		if strings.ContainsAny(token, " \t\r\n") {
+			return errors.New("NATS trigger subject can't contain spaces: " + subject)
+		}
+		if strings.ContainsAny(token, "*>") && len(token) > 1 {
+			return errors.New("NATS trigger subject wildcards must be a whole token: " + subject)
+		}
+		if token == ">" && i != len(tokens)-1 {
+			return errors.New("NATS trigger subject > must be the last token: " + subject)
+		}
+	}
+
+	return nil
+}
+
+/*
+EventSourceStatusMatch checks if the status a pipeline finished with starts a pipeline trigger.
+*/
+func EventSourceStatusMatch(sourceStatus string, status string) bool {
+	return sourceStatus == "Any" || sourceStatus == status
+}
@@ -0,0 +1,135 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"gorm.io/datatypes"
+)
+
+type eventSubscription struct {
+	subject string
+	sub     *nats.Subscription
+}
+
+var eventSubscriptions = make(map[string]eventSubscription)
+var eventSubscriptionsLock sync.Mutex
+
+/*
+EventTriggersListen starts the NATS and file triggers on this replica.
+Each trigger subscribes with its own queue group so that a message starts one run across all replicas.
+*/
+func EventTriggersListen() {
+
+	messageq.NATSencoded.Subscribe("event-triggers-reload", func(subj, reply string, msg map[string]interface{}) {
+		EventTriggersSubscribe()
+	})
+
+	messageq.NATSencoded.QueueSubscribe("event-trigger-file", "eventtriggerfile", func(subj, reply string, msg models.EventTriggerFile) {
+
+		var t models.EventTriggers
+		err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ? and trigger_type = ?", msg.NodeID, msg.PipelineID, msg.EnvironmentID, "file").First(&t).Error
+		if err != nil {
+			logging.PrintSecretsRedact("File trigger:", msg.NodeID, err)
+			return
+		}
+
+		if !t.Online {
+			return
+		}
+
+		payload, _ := json.Marshal(map[string]interface{}{
+			"file_path": msg.FilePath,
+			"size":      msg.Size,
+			"mod_time":  msg.ModTime,
+		})
+
+		// A file is the same event while it is not modified
+		EventTriggerRun(t, fmt.Sprintf("file-%s-%d", msg.FilePath, msg.ModTime.UnixNano()), payload)
+	})
+
+	EventTriggersSubscribe()
+}
+
+/*
+EventTriggersSubscribe subscribes to the subjects of the online NATS triggers and drops the rest.
+*/
+func EventTriggersSubscribe() {
+
+	var triggers []models.EventTriggers
+	err := database.DBConn.Where("trigger_type = ? and online = true", "nats").Find(&triggers).Error
+	if err != nil {
+		logging.PrintSecretsRedact("NATS triggers:", err)
+		return
+	}
+
+	eventSubscriptionsLock.Lock()
+	defer eventSubscriptionsLock.Unlock()
+
+	keep := make(map[string]bool)
+
+	for _, t := range triggers {
+
+		key := t.EnvironmentID + "-" + t.PipelineID + "-" + t.NodeID
+		keep[key] = true
+
+		if existing, ok := eventSubscriptions[key]; ok {
+			if existing.subject == t.Subject {
+				continue
+			}
+			existing.sub.Unsubscribe()
+		}
+
+		nodeID, pipelineID, environmentID := t.NodeID, t.PipelineID, t.EnvironmentID
+		sub, err := messageq.NATS.QueueSubscribe(t.Subject, "eventtrigger-"+key, func(m *nats.Msg) {
+
+			// The trigger may have changed since the subscription was made
+			var trigger models.EventTriggers
+			err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ?", nodeID, pipelineID, environmentID).First(&trigger).Error
+			if err != nil {
+				logging.PrintSecretsRedact("NATS trigger:", nodeID, err)
+				return
+			}
+
+			if !trigger.Online || trigger.TriggerType != "nats" {
+				return
+			}
+
+			payload := datatypes.JSON(m.Data)
+			if !json.Valid(m.Data) {
+				payload, _ = json.Marshal(string(m.Data))
+			}
+
+			// NATS messages have no id, each message is a new event
+			EventTriggerRun(trigger, "nats-"+uuid.NewString(), payload)
+		})
+		if err != nil {
+			logging.PrintSecretsRedact("NATS trigger subscribe:", t.Subject, err)
+			delete(eventSubscriptions, key)
+			continue
+		}
+
+		eventSubscriptions[key] = eventSubscription{subject: t.Subject, sub: sub}
+
+		if dpconfig.Debug == "true" {
+			log.Println("NATS trigger subscribed:", t.Subject, t.NodeID)
+		}
+	}
+
+	for key, existing := range eventSubscriptions {
+		if !keep[key] {
+			existing.sub.Unsubscribe()
+			delete(eventSubscriptions, key)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestEventTriggers$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestEventTriggers(t *testing.T) {
+
+	// ----- File
+	assert.NoError(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "file", Path: "/data/in", WorkerGroup: "python_1", Pattern: "*.csv"}), "File")
+	assert.NoError(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "file", Path: "/data/in", WorkerGroup: "python_1"}), "File all files")
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "file", Path: "data/in", WorkerGroup: "python_1"}), "File relative path")
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "file", Path: "/data/in"}), "File no worker group")
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "file", Path: "/data/in", WorkerGroup: "python_1", Pattern: "[a-"}), "File bad pattern")
+
+	// ----- NATS
+	assert.NoError(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "nats", Subject: "events.orders.created"}), "Subject")
+	assert.NoError(t, ValidateEventSubject("events.orders.*"), "Subject token wildcard")
+	assert.NoError(t, ValidateEventSubject("events.>"), "Subject tail wildcard")
+	assert.Error(t, ValidateEventSubject("taskupdate.env.run"), "Subject outside prefix")
+	assert.Error(t, ValidateEventSubject("events..created"), "Subject empty token")
+	assert.Error(t, ValidateEventSubject("events.order s"), "Subject space")
+	assert.Error(t, ValidateEventSubject("events.orders*"), "Subject partial wildcard")
+	assert.Error(t, ValidateEventSubject("events.>.created"), "Subject tail wildcard not last")
+
+	// ----- Pipeline
+	assert.NoError(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "pipeline", PipelineID: "b", SourcePipelineID: "a", SourceStatus: "Success"}), "Pipeline")
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "pipeline", PipelineID: "b", SourceStatus: "Success"}), "Pipeline no source")
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "pipeline", PipelineID: "a", SourcePipelineID: "a", SourceStatus: "Any"}), "Pipeline on itself")
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "pipeline", PipelineID: "b", SourcePipelineID: "a", SourceStatus: "Done"}), "Pipeline bad status")
+
+	assert.True(t, EventSourceStatusMatch("Any", "Fail"), "Any status")
+	assert.True(t, EventSourceStatusMatch("Success", "Success"), "Same status")
+	assert.False(t, EventSourceStatusMatch("Success", "Fail"), "Other status")
+
+	assert.Error(t, ValidateEventTrigger(models.EventTriggers{TriggerType: "webhook"}), "Unknown type")
+}
@@ -0,0 +1,67 @@
+package pipelines
+
+import (
+	"math"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+)
+
+// Upper bound on the wait between two attempts of the same task.
+const RetryMaxDelay = 1 * time.Hour
+
+/*
+RetryAllowed checks if a failed attempt should be retried.
+Attempts start at 1, an exit code of -1 means the process never started or did not exit normally.
+*/
+func RetryAllowed(policy models.RetryPolicy, attempt int, exitCode int) bool {
+
+	if attempt >= policy.MaxAttempts {
+		return false
+	}
+
+	// No exit codes specified, retry on any failure
+	if len(policy.ExitCodes) == 0 {
+		return true
+	}
+
+	for _, c := range policy.ExitCodes {
+		if c == exitCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+RetryBackoffDelay returns how long to wait before running the next attempt.
+Fixed: the same delay between every attempt.
+Exponential: the delay doubles after each failed attempt.
+*/
+func RetryBackoffDelay(policy models.RetryPolicy, attempt int) time.Duration {
+
+	if policy.DelaySeconds <= 0 {
+		return 0
+	}
+
+	delay := time.Duration(policy.DelaySeconds) * time.Second
+
+	switch policy.Backoff {
+	case "exponential":
+		if attempt < 1 {
+			attempt = 1
+		}
+		multiplier := math.Pow(2, float64(attempt-1))
+		if float64(delay)*multiplier >= float64(RetryMaxDelay) {
+			return RetryMaxDelay
+		}
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+
+	if delay > RetryMaxDelay {
+		return RetryMaxDelay
+	}
+
+	return delay
+}
@@ -0,0 +1,44 @@
+package pipelines
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestRetryPolicy$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestRetryPolicy(t *testing.T) {
+
+	// No retries by default
+	assert.Equalf(t, false, RetryAllowed(models.RetryPolicy{}, 1, 1), "Retry default policy")
+	assert.Equalf(t, false, RetryAllowed(models.RetryPolicy{MaxAttempts: 1}, 1, 1), "Retry single attempt")
+
+	// Retry on any exit code
+	anyCode := models.RetryPolicy{MaxAttempts: 3}
+	assert.Equalf(t, true, RetryAllowed(anyCode, 1, 1), "Retry any code first attempt")
+	assert.Equalf(t, true, RetryAllowed(anyCode, 2, -1), "Retry any code second attempt")
+	assert.Equalf(t, false, RetryAllowed(anyCode, 3, 1), "Retry any code attempts exhausted")
+
+	// Retry on specific exit codes
+	someCodes := models.RetryPolicy{MaxAttempts: 3, ExitCodes: []int{75, 111}}
+	assert.Equalf(t, true, RetryAllowed(someCodes, 1, 75), "Retry listed exit code")
+	assert.Equalf(t, false, RetryAllowed(someCodes, 1, 1), "Retry unlisted exit code")
+
+	// Backoff
+	fixed := models.RetryPolicy{MaxAttempts: 5, Backoff: "fixed", DelaySeconds: 10}
+	assert.Equalf(t, 10*time.Second, RetryBackoffDelay(fixed, 1), "Fixed backoff attempt 1")
+	assert.Equalf(t, 10*time.Second, RetryBackoffDelay(fixed, 4), "Fixed backoff attempt 4")
+
+	exponential := models.RetryPolicy{MaxAttempts: 5, Backoff: "exponential", DelaySeconds: 10}
+	assert.Equalf(t, 10*time.Second, RetryBackoffDelay(exponential, 1), "Exponential backoff attempt 1")
+	assert.Equalf(t, 20*time.Second, RetryBackoffDelay(exponential, 2), "Exponential backoff attempt 2")
+	assert.Equalf(t, 80*time.Second, RetryBackoffDelay(exponential, 4), "Exponential backoff attempt 4")
+	assert.Equalf(t, RetryMaxDelay, RetryBackoffDelay(exponential, 40), "Exponential backoff capped")
+
+	assert.Equalf(t, time.Duration(0), RetryBackoffDelay(models.RetryPolicy{MaxAttempts: 2}, 1), "No delay")
+}
@@ -0,0 +1,128 @@
+package pipelines
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+/* Time buckets of run analytics, these are Postgres date_trunc fields */
+var AnalyticsBuckets = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+/* The most buckets a window can be split into */
+const AnalyticsMaxBuckets = 2000
+
+/* Failure reasons kept per node, most frequent first */
+const AnalyticsMaxReasons = 5
+
+/*
+AnalyticsWindow validates the time window and bucket of an analytics query.
+*/
+func AnalyticsWindow(from time.Time, to time.Time, bucket string) error {
+
+	size, ok := AnalyticsBuckets[bucket]
+	if !ok {
+		return errors.New("Bucket must be hour, day, week or month.")
+	}
+
+	if !to.After(from) {
+		return errors.New("Window must end after it starts.")
+	}
+
+	if to.Sub(from)/size > AnalyticsMaxBuckets {
+		return errors.New("Window has too many buckets, use a larger bucket.")
+	}
+
+	return nil
+}
+
+/*
+AnalyticsFailureRate is the share of failed out of total, rounded to 4 decimals.
+*/
+func AnalyticsFailureRate(failed int, total int) float64 {
+
+	if total <= 0 {
+		return 0
+	}
+
+	return math.Round(float64(failed)/float64(total)*10000) / 10000
+}
+
+/*
+AnalyticsReason groups failure reasons on their first line, so reasons that only differ after the first line count together.
+*/
+func AnalyticsReason(reason string) string {
+
+	reason = strings.TrimSpace(reason)
+	if i := strings.IndexByte(reason, '\n'); i >= 0 {
+		reason = strings.TrimSpace(reason[:i])
+	}
+
+	if reason == "" {
+		return "No reason given"
+	}
+
+	if r := []rune(reason); len(r) > 200 {
+		reason = string(r[:200]) + "…"
+	}
+
+	return reason
+}
+
+type AnalyticsReasonCount struct {
+	Reason       string
+	Failures     int
+	LastFailedAt time.Time
+}
+
+/*
+AnalyticsTopReasons merges reasons that group together and returns the most frequent, latest first on a tie.
+*/
+func AnalyticsTopReasons(reasons []AnalyticsReasonCount, limit int) []AnalyticsReasonCount {
+
+	merged := map[string]*AnalyticsReasonCount{}
+	out := []AnalyticsReasonCount{}
+
+	for _, r := range reasons {
+
+		key := AnalyticsReason(r.Reason)
+
+		m, ok := merged[key]
+		if !ok {
+			merged[key] = &AnalyticsReasonCount{Reason: key, Failures: r.Failures, LastFailedAt: r.LastFailedAt}
+			continue
+		}
+
+		m.Failures += r.Failures
+		if r.LastFailedAt.After(m.LastFailedAt) {
+			m.LastFailedAt = r.LastFailedAt
+		}
+	}
+
+	for _, m := range merged {
+		out = append(out, *m)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Failures != out[j].Failures {
+			return out[i].Failures > out[j].Failures
+		}
+		if !out[i].LastFailedAt.Equal(out[j].LastFailedAt) {
+			return out[i].LastFailedAt.After(out[j].LastFailedAt)
+		}
+		return out[i].Reason < out[j].Reason
+	})
+
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+
+	return out
+}
@@ -0,0 +1,49 @@
+package pipelines
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestRunAnalytics$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestRunAnalytics(t *testing.T) {
+
+	from := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// ----- Window
+	assert.NoError(t, AnalyticsWindow(from, from.AddDate(0, 0, 30), "day"), "Month by day")
+	assert.Error(t, AnalyticsWindow(from, from.AddDate(0, 0, 30), "minute"), "Unknown bucket")
+	assert.Error(t, AnalyticsWindow(from, from, "day"), "Empty window")
+	assert.Error(t, AnalyticsWindow(from, from.AddDate(-1, 0, 0), "day"), "Window backwards")
+	assert.Error(t, AnalyticsWindow(from, from.AddDate(1, 0, 0), "hour"), "Too many buckets")
+	assert.NoError(t, AnalyticsWindow(from, from.AddDate(1, 0, 0), "week"), "Year by week")
+
+	// ----- Failure rate
+	assert.Equalf(t, 0.0, AnalyticsFailureRate(0, 0), "No tasks")
+	assert.Equalf(t, 0.3333, AnalyticsFailureRate(1, 3), "Rounded")
+	assert.Equalf(t, 1.0, AnalyticsFailureRate(2, 2), "All failed")
+
+	// ----- Reasons
+	assert.Equalf(t, "No reason given", AnalyticsReason("  "), "Empty reason")
+	assert.Equalf(t, "exit status 1", AnalyticsReason("exit status 1\nTraceback..."), "First line")
+	assert.Equalf(t, 201, len([]rune(AnalyticsReason(strings.Repeat("é", 300)))), "Long reason cut")
+
+	reasons := AnalyticsTopReasons([]AnalyticsReasonCount{
+		{Reason: "exit status 1\nrun a", Failures: 2, LastFailedAt: from},
+		{Reason: "timeout", Failures: 3, LastFailedAt: from},
+		{Reason: "exit status 1\nrun b", Failures: 2, LastFailedAt: from.Add(time.Hour)},
+		{Reason: "", Failures: 1, LastFailedAt: from},
+	}, 2)
+
+	if assert.Equal(t, 2, len(reasons), "Top reasons") {
+		assert.Equalf(t, "exit status 1", reasons[0].Reason, "Merged reason first")
+		assert.Equalf(t, 4, reasons[0].Failures, "Merged failures")
+		assert.Truef(t, from.Add(time.Hour).Equal(reasons[0].LastFailedAt), "Latest failure kept")
+		assert.Equalf(t, "timeout", reasons[1].Reason, "Next reason")
+	}
+}
@@ -0,0 +1,316 @@
+package pipelines
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+
+	"github.com/go-co-op/gocron"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+/*
+ErrRunSkipped is returned when a run is not started because the pipeline is at its max concurrent runs and the policy is skip.
+*/
+var ErrRunSkipped = errors.New("Run skipped: pipeline is at its max concurrent runs")
+
+/*
+RunAdmit records a new run, or a queued run being started, against the max concurrent runs of the pipeline.
+The check holds a Postgres advisory lock for the pipeline so that all main app replicas see the same count.
+The returned run has the status Running if its tasks can start or Queued if it must wait.
+Runs of a pipeline with its runs paused are always queued, paused runs still take up a place.
+*/
+func RunAdmit(run models.PipelineRuns, maxRuns int, policy string, paused bool) (models.PipelineRuns, error) {
+
+	var cancel []string
+
+	err := database.DBConn.Transaction(func(tx *gorm.DB) error {
+
+		if maxRuns > 0 {
+			err := tx.Exec("select pg_advisory_xact_lock(hashtext(?))", "pipeline-runs-"+run.EnvironmentID+"-"+run.PipelineID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		// A queued run being started already has a record
+		var existing []models.PipelineRuns
+		err := tx.Select("run_id", "status", "created_at").Where("run_id = ?", run.RunID).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		queued := len(existing) > 0
+		if queued {
+			if existing[0].Status != "Queued" {
+				return errors.New("Run already started: " + run.RunID)
+			}
+			run.CreatedAt = existing[0].CreatedAt
+		}
+
+		status := "Running"
+		var cancelCount int
+
+		if paused {
+			status = "Queued"
+		} else if maxRuns > 0 {
+			var running []models.PipelineRuns
+			err = tx.Select("run_id").Where("pipeline_id = ? and environment_id = ? and status in (?)", run.PipelineID, run.EnvironmentID, []string{"Running", "Paused"}).Order("created_at").Find(&running).Error
+			if err != nil {
+				return err
+			}
+
+			var queuedAhead int64
+			query := tx.Model(&models.PipelineRuns{}).Where("pipeline_id = ? and environment_id = ? and status = ? and run_id <> ?", run.PipelineID, run.EnvironmentID, "Queued", run.RunID)
+			if queued {
+				query = query.Where("created_at < ?", run.CreatedAt)
+			}
+			err = query.Count(&queuedAhead).Error
+			if err != nil {
+				return err
+			}
+
+			status, cancelCount = RunConcurrencyDecision(maxRuns, policy, len(running), int(queuedAhead))
+
+			for i := 0; i < cancelCount && i < len(running); i++ {
+				cancel = append(cancel, running[i].RunID)
+			}
+		}
+
+		switch {
+		case status == "Skipped":
+			return ErrRunSkipped
+		case queued && status == "Queued":
+			run.Status = "Queued"
+			return nil
+		case queued:
+			run.Status = status
+			result := tx.Model(&models.PipelineRuns{}).Where("run_id = ? and status = ?", run.RunID, "Queued").Updates(map[string]interface{}{
+				"status":   status,
+				"run_json": run.RunJSON,
+			})
+			if result.Error != nil {
+				return result.Error
+			}
+			// Another replica started the run first
+			if result.RowsAffected == 0 {
+				return errors.New("Run already started: " + run.RunID)
+			}
+			return nil
+		}
+
+		run.Status = status
+		return tx.Create(&run).Error
+	})
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	for _, c := range cancel {
+		_, err := RunStop(c, run.EnvironmentID, "Cancelled by newer run")
+		if err != nil {
+			logging.PrintSecretsRedact("Cancel previous run:", c, err)
+		}
+	}
+
+	if dpconfig.Debug == "true" && run.Status == "Queued" {
+		log.Println("Run queued:", run.PipelineID, run.RunID)
+	}
+
+	return run, nil
+}
+
+/*
+runAdmitSettings retrieves the max concurrent runs, policy and paused setting a run is admitted with.
+Deployment runs use the settings of the active deployment.
+*/
+func runAdmitSettings(run models.PipelineRuns) (int, string, bool, error) {
+
+	switch run.RunType {
+	case "deployment":
+		deployment := models.DeployPipelines{}
+		err := database.DBConn.Select("max_concurrent_runs", "concurrency_policy", "pause_runs").Where("pipeline_id = ? and environment_id = ? and deploy_active = ?", run.PipelineID, run.EnvironmentID, true).First(&deployment).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return 0, "", false, errors.New("Retrieve deployment database error.")
+		}
+		return deployment.MaxConcurrentRuns, deployment.ConcurrencyPolicy, deployment.PauseRuns, nil
+
+	default:
+		pipeline := models.Pipelines{}
+		err := database.DBConn.Select("max_concurrent_runs", "concurrency_policy", "pause_runs").Where("pipeline_id = ? and environment_id = ?", run.PipelineID, run.EnvironmentID).First(&pipeline).Error
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			return 0, "", false, errors.New("Retrieve pipeline database error.")
+		}
+		return pipeline.MaxConcurrentRuns, pipeline.ConcurrencyPolicy, pipeline.PauseRuns, nil
+	}
+}
+
+/*
+RunQueueNext starts the oldest queued run of a pipeline if there is a place for it.
+Safe to call at any time from any replica, RunAdmit decides if the run can start.
+Returns true if a run was started.
+*/
+func RunQueueNext(pipelineID string, environmentID string, runType string) bool {
+
+	var queued []models.PipelineRuns
+	err := database.DBConn.Where("pipeline_id = ? and environment_id = ? and status = ?", pipelineID, environmentID, "Queued").Order("created_at").Limit(1).Find(&queued).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return false
+	}
+
+	if len(queued) == 0 {
+		return false
+	}
+
+	run := queued[0]
+
+	// A re-run keeps its tasks and starts from the nodes it was re-run from
+	if len(run.RerunNodes) > 0 && string(run.RerunNodes) != "null" {
+		run, err = rerunStart(run)
+		if err != nil {
+			logging.PrintSecretsRedact("Start queued re-run:", queued[0].RunID, err)
+			return false
+		}
+		return run.Status != "Queued"
+	}
+
+	options := RunOptions{
+		Parameters:       run.Parameters,
+		LogicalDate:      run.LogicalDate,
+		LogicalTimezone:  run.LogicalTimezone,
+		ParentRunID:      run.ParentRunID,
+		ParentTaskID:     run.ParentTaskID,
+		ParentPipelineID: run.ParentPipelineID,
+		Depth:            run.Depth,
+	}
+
+	switch runType {
+	case "deployment":
+		var payload []models.DeploymentApiTriggerRuns
+		database.DBConn.Where("run_id = ?", run.RunID).Limit(1).Find(&payload)
+
+		var runJson datatypes.JSON
+		if len(payload) > 0 {
+			runJson = payload[0].RunJSON
+		}
+		version := datatypes.JSON(fmt.Sprintf(`{"version":"%s"}`, strings.Trim(run.DeployVersion, "v")))
+
+		run, err = RunDeployment(pipelineID, environmentID, run.RunID, options, runJson, version)
+
+	default:
+		var payload []models.PipelineApiTriggerRuns
+		database.DBConn.Where("run_id = ?", run.RunID).Limit(1).Find(&payload)
+
+		if len(payload) > 0 {
+			run, err = RunPipeline(pipelineID, environmentID, run.RunID, options, payload[0].RunJSON)
+		} else {
+			run, err = RunPipeline(pipelineID, environmentID, run.RunID, options)
+		}
+	}
+
+	if err != nil {
+		logging.PrintSecretsRedact("Start queued run:", queued[0].RunID, err)
+		return false
+	}
+
+	return run.Status != "Queued"
+}
+
+/*
+RunQueueResume starts the queued runs of a pipeline that are waiting on its runs being unpaused, as many as there are places for.
+*/
+func RunQueueResume(pipelineID string, environmentID string, runType string) {
+
+	for RunQueueNext(pipelineID, environmentID, runType) {
+	}
+}
+
+/*
+RunQueueWatch starts queued runs that are waiting on runs which ended on another replica or without a run next.
+Only the leader checks.
+*/
+func RunQueueWatch(s *gocron.Scheduler) {
+
+	s.Every(5).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		var queued []models.PipelineRuns
+		err := database.DBConn.Model(&models.PipelineRuns{}).Distinct("pipeline_id", "environment_id", "run_type").Where("status = ?", "Queued").Find(&queued).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		for _, q := range queued {
+			RunQueueNext(q.PipelineID, q.EnvironmentID, q.RunType)
+		}
+	})
+}
+
+/*
+ValidateConcurrency checks the max concurrent runs setting of a pipeline before it is saved.
+*/
+func ValidateConcurrency(maxRuns int, policy string) error {
+
+	if maxRuns < 0 {
+		return errors.New("Max concurrent runs can't be negative")
+	}
+
+	switch policy {
+	case "queue", "skip", "cancel-previous":
+		return nil
+	}
+
+	return errors.New("Concurrency policy must be queue, skip or cancel-previous")
+}
+
+/*
+RunConcurrencyDecision decides what happens to a new run of a pipeline:
+Running - start the run, cancelling the given number of the oldest running runs first.
+Queued - wait until a running run finishes.
+Skipped - do not run.
+running is the number of runs in progress and queuedAhead the number of runs queued before this one.
+*/
+func RunConcurrencyDecision(maxRuns int, policy string, running int, queuedAhead int) (string, int) {
+
+	if maxRuns <= 0 {
+		return "Running", 0
+	}
+
+	full := running >= maxRuns
+
+	switch policy {
+	case "skip":
+		if full {
+			return "Skipped", 0
+		}
+	case "cancel-previous":
+		if full {
+			return "Running", running - maxRuns + 1
+		}
+	default:
+		// Runs start in the order they were triggered
+		if full || queuedAhead > 0 {
+			return "Queued", 0
+		}
+	}
+
+	return "Running", 0
+}
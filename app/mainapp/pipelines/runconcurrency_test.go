@@ -0,0 +1,46 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestRunConcurrency$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestRunConcurrency(t *testing.T) {
+
+	assert.NoError(t, ValidateConcurrency(0, "queue"), "No limit")
+	assert.NoError(t, ValidateConcurrency(2, "cancel-previous"), "Cancel previous")
+	assert.Error(t, ValidateConcurrency(-1, "queue"), "Negative limit")
+	assert.Error(t, ValidateConcurrency(1, "wait"), "Unknown policy")
+
+	type decision struct {
+		name        string
+		maxRuns     int
+		policy      string
+		running     int
+		queuedAhead int
+		status      string
+		cancel      int
+	}
+
+	decisions := []decision{
+		{"No limit", 0, "queue", 10, 0, "Running", 0},
+		{"Queue below limit", 2, "queue", 1, 0, "Running", 0},
+		{"Queue at limit", 2, "queue", 2, 0, "Queued", 0},
+		{"Queue behind queued runs", 2, "queue", 1, 1, "Queued", 0},
+		{"Skip at limit", 1, "skip", 1, 0, "Skipped", 0},
+		{"Skip below limit", 2, "skip", 1, 0, "Running", 0},
+		{"Cancel previous at limit", 1, "cancel-previous", 1, 0, "Running", 1},
+		{"Cancel previous over limit", 2, "cancel-previous", 3, 0, "Running", 2},
+		{"Cancel previous below limit", 2, "cancel-previous", 1, 0, "Running", 0},
+	}
+
+	for _, d := range decisions {
+		status, cancel := RunConcurrencyDecision(d.maxRuns, d.policy, d.running, d.queuedAhead)
+		assert.Equalf(t, d.status, status, d.name)
+		assert.Equalf(t, d.cancel, cancel, d.name)
+	}
+}
@@ -9,24 +9,27 @@ import (
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 	jsoniter "github.com/json-iterator/go"
 	"gorm.io/datatypes"
+	"gorm.io/gorm/clause"
 
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
 	"github.com/dataplane-app/dataplane/app/mainapp/worker"
 
 	"github.com/google/uuid"
 )
 
-func RunDeployment(pipelineID string, environmentID string, runID string, runJson ...datatypes.JSON) (models.PipelineRuns, error) {
+func RunDeployment(pipelineID string, environmentID string, runID string, options RunOptions, runJson ...datatypes.JSON) (models.PipelineRuns, error) {
 
 	// start := time.Now().UTC()
 
 	// Doesnt require concurrency safety, should be written / read in sequence.
 	var destinations = make(map[string][]string)
 	var dependencies = make(map[string][]string)
+	var conditions = make(map[string]map[string]models.EdgeCondition)
 	var triggerData = make(map[string]*models.WorkerTasks)
 
 	// Retrieve pipeline details
@@ -68,7 +71,8 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 			CreatedAt:     time.Now().UTC(),
 		}
 
-		err = database.DBConn.Create(&run).Error
+		// A queued run already has its payload
+		err = database.DBConn.Clauses(clause.OnConflict{DoNothing: true}).Create(&run).Error
 		if err != nil {
 
 			if dpconfig.Debug == "true" {
@@ -80,17 +84,25 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 
 	// Create a run
 	run := models.PipelineRuns{
-		RunID:         runID,
-		PipelineID:    pipelineID,
-		Status:        "Running",
-		EnvironmentID: environmentID,
-		CreatedAt:     time.Now().UTC(),
-		RunJSON:       pipelinedata.Json,
-		RunType:       "deployment",
-		DeployVersion: pipelinedata.Version,
+		RunID:            runID,
+		PipelineID:       pipelineID,
+		Status:           "Running",
+		EnvironmentID:    environmentID,
+		CreatedAt:        time.Now().UTC(),
+		RunJSON:          pipelinedata.Json,
+		Parameters:       options.Parameters,
+		RunType:          "deployment",
+		DeployVersion:    pipelinedata.Version,
+		LogicalDate:      options.LogicalDate,
+		LogicalTimezone:  options.LogicalTimezone,
+		ParentRunID:      options.ParentRunID,
+		ParentTaskID:     options.ParentTaskID,
+		ParentPipelineID: options.ParentPipelineID,
+		Depth:            options.Depth,
 	}
 
-	err = database.DBConn.Create(&run).Error
+	// The run waits if the pipeline is at its max concurrent runs or its runs are paused
+	run, err = RunAdmit(run, pipelinedata.MaxConcurrentRuns, pipelinedata.ConcurrencyPolicy, pipelinedata.PauseRuns)
 	if err != nil {
 
 		if dpconfig.Debug == "true" {
@@ -99,6 +111,12 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 		return models.PipelineRuns{}, err
 	}
 
+	if run.Status == "Queued" {
+		return run, nil
+	}
+
+	metrics.RunsStarted.WithLabelValues(environmentID, pipelineID, run.RunType).Inc()
+
 	// ------ Obtain folders
 	folders := make(chan []models.DeployCodeFolders)
 	parentfolder := make(chan string)
@@ -152,6 +170,11 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 		destinations[s.From] = append(destinations[s.From], s.To)
 		dependencies[s.To] = append(dependencies[s.To], s.From)
 
+		if conditions[s.To] == nil {
+			conditions[s.To] = make(map[string]models.EdgeCondition)
+		}
+		conditions[s.To][s.From] = models.EdgeCondition{Condition: s.Condition, Expression: s.Expression}
+
 	}
 
 	// Map folder structure:
@@ -231,23 +254,32 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 			logging.PrintSecretsRedact(err)
 		}
 
+		conditionsJSON, err := json.Marshal(conditions[s.NodeID])
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+		}
+
 		addTask := &models.WorkerTasks{
-			TaskID:        uuid.NewString(),
-			CreatedAt:     time.Now().UTC(),
-			EnvironmentID: environmentID,
-			RunID:         RunID,
-			WorkerGroup:   workergroup,
-			PipelineID:    s.PipelineID,
-			NodeID:        s.NodeID,
-			WorkerType:    s.NodeTypeDesc,
-			Status:        status,
-			Dependency:    dependJSON,
-			Commands:      s.Commands,
-			Destination:   destinationJSON,
-			Folder:        folderMap[s.NodeID],
-			FolderID:      folderNodeMap[s.NodeID],
-			RunType:       "deployment",
-			Version:       s.Version,
+			TaskID:         uuid.NewString(),
+			CreatedAt:      time.Now().UTC(),
+			EnvironmentID:  environmentID,
+			RunID:          RunID,
+			WorkerGroup:    workergroup,
+			PipelineID:     s.PipelineID,
+			NodeID:         s.NodeID,
+			WorkerType:     s.NodeTypeDesc,
+			Status:         status,
+			Dependency:     dependJSON,
+			Conditions:     conditionsJSON,
+			Commands:       s.Commands,
+			Destination:    destinationJSON,
+			Folder:         folderMap[s.NodeID],
+			FolderID:       folderNodeMap[s.NodeID],
+			RunType:        "deployment",
+			Version:        s.Version,
+			Attempt:        1,
+			RetryPolicy:    s.RetryPolicy,
+			TimeoutSeconds: worker.TaskTimeoutSeconds(s.TimeoutSeconds, pipelinedata.TimeoutSeconds),
 		}
 
 		if nodeType == "start" {
@@ -280,6 +312,16 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 		return models.PipelineRuns{}, err
 	}
 
+	// The API trigger payload is an output of the trigger node for downstream tasks
+	if runJson != nil && len(runJson[0]) != 0 {
+		err = RunTriggerPayload(startTask, runJson[0])
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+		}
+	}
+
 	// --- Run the first set of tasks
 	if dpconfig.Debug == "true" {
 		log.Println("trigger: ", trigger, triggerID)
@@ -315,7 +357,7 @@ func RunDeployment(pipelineID string, environmentID string, runID string, runJso
 		// }
 		// err = worker.WorkerRunTask("python_1", triggerData[s].TaskID, RunID, environmentID, pipelineID, s, []string{"sleep " + strconv.Itoa(x) + "; echo " + s})
 
-		err = worker.WorkerRunTask(triggerData[s].WorkerGroup, triggerData[s].TaskID, RunID, environmentID, pipelineID, s, commandsend, folderMap[triggerData[s].NodeID], folderNodeMap[triggerData[s].NodeID], triggerData[s].Version, "deployment", triggerData[s].WorkerType)
+		err = RunTask(*triggerData[s], commandsend)
 		if err != nil {
 			if dpconfig.Debug == "true" {
 				logging.PrintSecretsRedact(err)
@@ -9,8 +9,11 @@ import (
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
-	"github.com/dataplane-app/dataplane/app/mainapp/worker"
-	"gorm.io/gorm/clause"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
+	"github.com/dataplane-app/dataplane/app/mainapp/notifications"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
+
+	"gorm.io/datatypes"
 )
 
 func RunNext(msg models.WorkerTaskSend) {
@@ -21,10 +24,27 @@ func RunNext(msg models.WorkerTaskSend) {
 	// 	log.Println("Receive next:", msg.NodeID)
 	// }
 
-	// Get the current node
-	err := database.DBConn.Select("node_id", "destination", "dependency", "status").Where("node_id =? and pipeline_id =? and run_id=?", msg.NodeID, msg.PipelineID, msg.RunID).First(&currentNode).Error
+	// Get the current node - by task as a node can have several attempts in a run
+	err := database.DBConn.Where("task_id =? and pipeline_id =? and run_id=?", msg.TaskID, msg.PipelineID, msg.RunID).First(&currentNode).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+
+	// A failed attempt with retries left is queued again instead of moving on
+	if currentNode.Status == "Fail" && RetryTask(currentNode) {
+		return
+	}
+
+	// Only move on while the run is still running e.g. not stopped by a user
+	var run models.PipelineRuns
+	err = database.DBConn.Select("run_id", "status", "run_type", "created_at").Where("run_id = ?", msg.RunID).First(&run).Error
 	if err != nil {
 		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	if run.Status != "Running" {
+		return
 	}
 
 	// fmt.Printf("%+v\n", currentNode)
@@ -32,12 +52,7 @@ func RunNext(msg models.WorkerTaskSend) {
 	// Retrieve all destinations
 	var destinations []string
 
-	// Doesnt require concurrency safety, should be written / read in sequence.
-	var uniquedependencies = make(map[string]bool)
-	var uniquedependenciesarray []string
 	destinationNodes := []*models.WorkerTasks{}
-	dependencyCheck := []*models.WorkerTasks{}
-	completeCheck := []*models.WorkerTasks{}
 
 	json.Unmarshal(currentNode.Destination, &destinations)
 
@@ -51,7 +66,7 @@ func RunNext(msg models.WorkerTaskSend) {
 		logging.PrintSecretsRedact(err)
 	}
 
-	// if there are no destinations and the last run was a success, then close off the run
+	// once every task has finished the run is closed off, see RunNextComplete
 
 	/* The above query pulls out all the destinations data at queue status so say the the last 3 dependencies come to a single
 	point [1, 2, 3] > 4 - this will arrive 3 times but only the first will run as destination 4 will be success or at run status.
@@ -59,137 +74,274 @@ func RunNext(msg models.WorkerTaskSend) {
 	the first destination will run the */
 	// log.Println("Current node:", msg.NodeID, currentNode.Status, currentNode.Destination, len(destinationNodes))
 
-	if string(currentNode.Destination) == "null" && currentNode.Status == "Success" {
+	// If not at the end then continue with pipeline
 
-		// log.Println("Graph successfully run")
+	for _, s := range destinationNodes {
+		RunNextTask(s)
+	}
 
-		/* Check that all nodes are completed */
-		err = database.DBConn.Select("node_id", "status").Where("pipeline_id =? and run_id=? and status<>?", msg.PipelineID, msg.RunID, "Success").Find(&completeCheck).Error
-		if err != nil {
-			logging.PrintSecretsRedact(err)
-		}
+	RunNextComplete(run, msg)
 
-		if len(completeCheck) == 0 {
-			run := models.PipelineRuns{
-				RunID:   msg.RunID,
-				Status:  "Success",
-				EndedAt: time.Now().UTC(),
-			}
+}
 
-			err2 := database.DBConn.Clauses(clause.OnConflict{
-				Columns:   []clause.Column{{Name: "run_id"}},
-				DoUpdates: clause.AssignmentColumns([]string{"ended_at", "status"}),
-			}).Create(&run)
-			if err2.Error != nil {
-				logging.PrintSecretsRedact(err2.Error.Error())
-			}
+/*
+RunNextTask runs a queued task once all of its dependencies have finished, or finishes it
+as Skipped or Fail when the edge conditions say it should not run.
+*/
+func RunNextTask(s *models.WorkerTasks) {
 
-			// send message that trigger node has run - for front end websockets
-			errnat := messageq.MsgSend("taskupdate."+msg.EnvironmentID+"."+msg.RunID, map[string]interface{}{
-				"MSG":        "pipeline_complete",
-				"run_id":     msg.RunID,
-				"started_at": run.CreatedAt,
-				"status":     "Success",
-				"ended_at":   run.EndedAt})
-			if errnat != nil {
-				if dpconfig.Debug == "true" {
-					logging.PrintSecretsRedact(errnat)
-				}
+	// A retry attempt waiting on its backoff is started by RetryWatch
+	if s.NextAttemptAt != nil {
+		return
+	}
 
-			}
-		}
+	var err error
+
+	// Doesnt require concurrency safety, should be written / read in sequence.
+	var uniquedependencies = make(map[string]bool)
+	var uniquedependenciesarray []string
+	dependencyCheck := []*models.WorkerTasks{}
 
+	// log.Println("Destination:", s.RunID, " -> ", s.NodeID)
+	// if s.NodeID == "ae5ac151-9e03-4186-ab1f-fb6a415bcb82" {
+	// 	log.Println("Destination:", s.RunID, " -> ", s.NodeID, s.Dependency)
+	// }
+
+	// clear the dependency map - to avoid other destination nodes
+	for k := range uniquedependencies {
+		delete(uniquedependencies, k)
 	}
 
-	// If not at the end then continue with pipeline
+	// Set the dependencies for look up
+	var dependencies []string
+	json.Unmarshal(s.Dependency, &dependencies)
+	for _, v := range dependencies {
 
-	for _, s := range destinationNodes {
+		uniquedependencies[v] = true
+	}
+
+	uniquedependenciesarray = []string{}
+	for k, _ := range uniquedependencies {
+		uniquedependenciesarray = append(uniquedependenciesarray, k)
+	}
 
-		// log.Println("Destination:", s.RunID, " -> ", s.NodeID)
-		// if s.NodeID == "ae5ac151-9e03-4186-ab1f-fb6a415bcb82" {
-		// 	log.Println("Destination:", s.RunID, " -> ", s.NodeID, s.Dependency)
-		// }
+	// log.Println("Node: ", s.NodeID, " - Dependencies to check:", uniquedependencies, uniquedependenciesarray)
 
-		// clear the dependency map - to avoid other destination nodes
-		for k := range uniquedependencies {
-			delete(uniquedependencies, k)
-		}
+	/*
+		Check that destination isnt already running -
+		say you have 3 dependencies but 1 destination
+		this can trigger it 3 times if dependencies marked as success faster than reaching this point
+		each run needs to ensure that the destination isnt already running - must be queue status to run
 
-		// Set the dependencies for look up
-		var dependencies []string
-		json.Unmarshal(s.Dependency, &dependencies)
-		for _, v := range dependencies {
+		All dependencies need to have finished to continue, then the edge conditions decide if the destination
+		runs, is skipped or fails because of an upstream failure. Earlier attempts of a retried node are ignored.
+	*/
 
-			uniquedependencies[v] = true
-		}
+	err = database.DBConn.Select("node_id", "status", "reason", "exit_code", "attempt").Where("node_id in (?) and pipeline_id =? and run_id=? and status <> ?", uniquedependenciesarray, s.PipelineID, s.RunID, "Retry").Find(&dependencyCheck).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
 
-		uniquedependenciesarray = []string{}
-		for k, _ := range uniquedependencies {
-			uniquedependenciesarray = append(uniquedependenciesarray, k)
+	conditions := make(map[string]models.EdgeCondition)
+	json.Unmarshal(s.Conditions, &conditions)
+
+	// Outputs of the upstream tasks are only needed for expressions
+	upstreamOutputs := make(map[string]map[string]datatypes.JSON)
+	for _, c := range conditions {
+		if c.Condition == "expression" {
+			upstreamOutputs = RunOutputs(s.RunID, uniquedependenciesarray)
+			break
 		}
+	}
+
+	upstream := []models.WorkerTasks{}
+	conditionVars := make(map[string]map[string]interface{})
+	for _, d := range dependencyCheck {
+		upstream = append(upstream, *d)
+		conditionVars[d.NodeID] = EdgeConditionVars(*d, upstreamOutputs[d.NodeID])
+	}
 
-		// log.Println("Node: ", s.NodeID, " - Dependencies to check:", uniquedependencies, uniquedependenciesarray)
+	decision, reason := NextTaskDecision(upstream, conditions, conditionVars)
 
-		/*
-			Check that destination isnt already running -
-			say you have 3 dependencies but 1 destination
-			this can trigger it 3 times if dependencies marked as success faster than reaching this point
-			each run needs to ensure that the destination isnt already running - must be queue status to run
+	// if s.NodeID == "ae5ac151-9e03-4186-ab1f-fb6a415bcb82" {
+	// 	if len(dependencyCheck) > 0 {
+	// 		log.Println("Dependency check:", dependencyCheck[0].NodeID, dependencyCheck[0].Status)
+	// 		log.Println("unique dependency array:", uniquedependenciesarray)
+	// 	}
+	// }
 
-			All dependencies need to be marked as success to continue. The below look for any non-successful nodes.
-			If any return then there are still outstanding dependencies.
-		*/
+	// if err == gorm.ErrRecordNotFound {
+	// 	log.Println("Dependencies check:", err)
+	// }
 
-		err = database.DBConn.Select("node_id", "status").Where("node_id in (?) and pipeline_id =? and run_id=? and status<>?", uniquedependenciesarray, msg.PipelineID, msg.RunID, "Success").Find(&dependencyCheck).Error
+	switch decision {
+	case "Run":
+
+		commandsJson := []Command{}
+		commandsend := []string{}
+
+		// log.Println("All dependencies are successful")
+		json.Unmarshal(s.Commands, &commandsJson)
+
+		for _, c := range commandsJson {
+			commandsend = append(commandsend, c.Command)
+		}
+
+		// ------ run the destination -------
+		err = RunTask(*s, commandsend)
+		// err = worker.WorkerRunTask("python_1", triggerData[s].TaskID, RunID, environmentID, pipelineID, s, []string{"echo " + s})
 		if err != nil {
-			logging.PrintSecretsRedact(err)
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+
+		} else {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact("Next step:", s.RunID, " -> ", s.TaskID)
+			}
 		}
 
-		// if s.NodeID == "ae5ac151-9e03-4186-ab1f-fb6a415bcb82" {
-		// 	if len(dependencyCheck) > 0 {
-		// 		log.Println("Dependency check:", dependencyCheck[0].NodeID, dependencyCheck[0].Status)
-		// 		log.Println("unique dependency array:", uniquedependenciesarray)
-		// 	}
-		// }
+	case "Skipped", "Fail":
 
-		// if err == gorm.ErrRecordNotFound {
-		// 	log.Println("Dependencies check:", err)
-		// }
+		RunNextFinishTask(*s, decision, reason)
 
-		if len(dependencyCheck) == 0 {
+	default:
+		// These dependencies are not yet complete.
+		// fmt.Printf("Dependencies: %+v\n", &dependencyCheck)
+	}
 
-			commandsJson := []Command{}
-			commandsend := []string{}
+	// fmt.Printf("%+v\n", dependencyCheck)
 
-			// log.Println("All dependencies are successful")
-			json.Unmarshal(s.Commands, &commandsJson)
+}
 
-			for _, c := range commandsJson {
-				commandsend = append(commandsend, c.Command)
-			}
+/*
+RunNextFinishTask finishes a queued task that will not run - Skipped or Fail with an upstream failure -
+and moves on to its own destinations.
+*/
+func RunNextFinishTask(task models.WorkerTasks, status string, reason string) {
+
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", task.TaskID, "Queue").Updates(map[string]interface{}{
+		"status":   status,
+		"reason":   reason,
+		"start_dt": time.Now().UTC(),
+		"end_dt":   time.Now().UTC(),
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return
+	}
 
-			// ------ run the destination -------
-			err = worker.WorkerRunTask(s.WorkerGroup, s.TaskID, s.RunID, s.EnvironmentID, s.PipelineID, s.NodeID, commandsend, s.Folder, s.FolderID, s.Version, s.RunType, s.WorkerType)
-			// err = worker.WorkerRunTask("python_1", triggerData[s].TaskID, RunID, environmentID, pipelineID, s, []string{"echo " + s})
-			if err != nil {
-				if dpconfig.Debug == "true" {
-					logging.PrintSecretsRedact(err)
-				}
-
-			} else {
-				if dpconfig.Debug == "true" {
-					logging.PrintSecretsRedact("Next step:", s.RunID, " -> ", s.TaskID)
-				}
-			}
+	// Already finished by another run next
+	if result.RowsAffected == 0 {
+		return
+	}
 
-		} else {
+	task.Status = status
+	task.Reason = reason
 
-			// These dependencies are either failed or not yet complete.
-			// fmt.Printf("Dependencies: %+v\n", &dependencyCheck)
+	errnat := messageq.MsgSend("taskupdate."+task.EnvironmentID+"."+task.RunID, task)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
 		}
+	}
+
+	if dpconfig.Debug == "true" {
+		logging.PrintSecretsRedact("Next step:", task.RunID, " -> ", task.TaskID, status, reason)
+	}
+
+	RunNext(models.WorkerTaskSend{
+		TaskID:        task.TaskID,
+		CreatedAt:     task.CreatedAt,
+		EnvironmentID: task.EnvironmentID,
+		PipelineID:    task.PipelineID,
+		RunID:         task.RunID,
+		NodeID:        task.NodeID,
+	})
+
+}
+
+/*
+RunNextComplete closes off the run once every task has finished.
+The run fails if any task failed, including failures handled by a failure branch.
+*/
+func RunNextComplete(run models.PipelineRuns, msg models.WorkerTaskSend) {
 
-		// fmt.Printf("%+v\n", dependencyCheck)
+	completeCheck := []*models.WorkerTasks{}
 
+	/* Check that all nodes are completed */
+	err := database.DBConn.Select("node_id", "status").Where("pipeline_id =? and run_id=? and status not in (?)", msg.PipelineID, msg.RunID, []string{"Success", "Fail", "Skipped", "Retry"}).Find(&completeCheck).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	if len(completeCheck) > 0 {
+		return
+	}
+
+	var failed int64
+	err = database.DBConn.Model(&models.WorkerTasks{}).Where("pipeline_id =? and run_id=? and status = ?", msg.PipelineID, msg.RunID, "Fail").Count(&failed).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	status := "Success"
+	if failed > 0 {
+		status = "Fail"
 	}
 
+	endedAt := time.Now().UTC()
+
+	// Only the first run next to get here closes off the run
+	result := database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ? and status = ?", msg.RunID, "Running").Updates(map[string]interface{}{
+		"status":   status,
+		"ended_at": endedAt,
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	metrics.RunsCompleted.WithLabelValues(msg.EnvironmentID, msg.PipelineID, run.RunType, status).Inc()
+	tracing.RunSpan(msg.RunID, msg.PipelineID, msg.EnvironmentID, run.RunType, status, run.CreatedAt, endedAt)
+
+	// send message that the run is complete - for front end websockets
+	errnat := messageq.MsgSend("taskupdate."+msg.EnvironmentID+"."+msg.RunID, map[string]interface{}{
+		"MSG":        "pipeline_complete",
+		"run_id":     msg.RunID,
+		"started_at": run.CreatedAt,
+		"status":     status,
+		"ended_at":   endedAt})
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+
+	}
+
+	// Pipelines waiting on this one
+	go EventTriggersRunComplete(models.PipelineRuns{
+		RunID:         msg.RunID,
+		PipelineID:    msg.PipelineID,
+		EnvironmentID: msg.EnvironmentID,
+		RunType:       run.RunType,
+		Status:        status,
+		EndedAt:       endedAt,
+	})
+
+	// A sub-pipeline node waiting on this run
+	go SubPipelineComplete(msg.RunID)
+
+	// Failure and success notifications
+	go notifications.NotifyRunComplete(msg.RunID)
+
+	// A place is free for the next queued run
+	RunQueueNext(msg.PipelineID, msg.EnvironmentID, run.RunType)
+
 }
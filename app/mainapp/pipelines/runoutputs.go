@@ -0,0 +1,60 @@
+package pipelines
+
+import (
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm/clause"
+)
+
+/*
+RunOutputs returns the outputs of the given nodes in a run by node and key.
+*/
+func RunOutputs(runID string, nodeIDs []string) map[string]map[string]datatypes.JSON {
+
+	outputs := make(map[string]map[string]datatypes.JSON)
+
+	var rows []models.WorkerTaskOutputs
+	err := database.DBConn.Where("run_id = ? and node_id in (?)", runID, nodeIDs).Find(&rows).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return outputs
+	}
+
+	for _, r := range rows {
+		if outputs[r.NodeID] == nil {
+			outputs[r.NodeID] = make(map[string]datatypes.JSON)
+		}
+		outputs[r.NodeID][r.OutputKey] = r.Value
+	}
+
+	return outputs
+}
+
+/*
+RunTriggerPayload makes the API trigger payload available to downstream tasks as the payload output of the trigger node.
+*/
+func RunTriggerPayload(startTask *models.WorkerTasks, payload datatypes.JSON) error {
+
+	if startTask == nil || len(payload) == 0 {
+		return nil
+	}
+
+	output := models.WorkerTaskOutputs{
+		RunID:         startTask.RunID,
+		NodeID:        startTask.NodeID,
+		OutputKey:     "payload",
+		TaskID:        startTask.TaskID,
+		EnvironmentID: startTask.EnvironmentID,
+		PipelineID:    startTask.PipelineID,
+		Value:         payload,
+		CreatedAt:     startTask.CreatedAt,
+	}
+
+	return database.DBConn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "run_id"}, {Name: "node_id"}, {Name: "output_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"task_id", "value"}),
+	}).Create(&output).Error
+}
@@ -0,0 +1,271 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"gorm.io/datatypes"
+)
+
+/*
+RunParameters validates the parameter values submitted for a run against the parameters declared on the pipeline or deployment.
+For deployments an empty or latest version uses the active deployment.
+Returns the parameter values of the run with defaults applied.
+*/
+func RunParameters(pipelineID string, environmentID string, runType string, version string, submitted datatypes.JSON) (datatypes.JSON, error) {
+
+	defs, err := RunParameterDefs(pipelineID, environmentID, runType, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return ResolveRunParameters(defs, submitted)
+}
+
+/*
+RunParametersText is RunParameters for values submitted as text such as an API trigger query string.
+Values are converted to the declared type of each parameter, undeclared arguments are ignored.
+*/
+func RunParametersText(pipelineID string, environmentID string, runType string, version string, submitted map[string]string) (datatypes.JSON, error) {
+
+	defs, err := RunParameterDefs(pipelineID, environmentID, runType, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return ResolveRunParametersText(defs, submitted)
+}
+
+/*
+RunParameterDefs retrieves the run parameters declared on a pipeline or a deployment version.
+*/
+func RunParameterDefs(pipelineID string, environmentID string, runType string, version string) ([]models.RunParameter, error) {
+
+	switch runType {
+	case "pipeline":
+		pipelinedata := models.Pipelines{}
+		err := database.DBConn.Select("pipeline_id", "parameters").Where("pipeline_id = ? and environment_id =?", pipelineID, environmentID).First(&pipelinedata).Error
+		if err != nil {
+			return nil, errors.New("Run parameters: pipeline not found")
+		}
+		return pipelinedata.Parameters, nil
+
+	case "deployment":
+		pipelinedata := models.DeployPipelines{}
+		query := database.DBConn.Select("pipeline_id", "version", "parameters").Where("pipeline_id = ? and environment_id =?", pipelineID, environmentID)
+		if version == "" || version == "latest" {
+			query = query.Where("deploy_active = ?", true)
+		} else {
+			query = query.Where("version = ?", version)
+		}
+		err := query.First(&pipelinedata).Error
+		if err != nil {
+			return nil, errors.New("Run parameters: deployment not found")
+		}
+		return pipelinedata.Parameters, nil
+	}
+
+	return nil, errors.New("Run type not provided.")
+}
+
+var runParameterNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+/*
+ValidateRunParameterDefs checks the run parameters declared on a pipeline before they are saved.
+*/
+func ValidateRunParameterDefs(defs []models.RunParameter) error {
+
+	names := make(map[string]bool, len(defs))
+
+	for _, p := range defs {
+
+		if !runParameterNameRegex.MatchString(p.Name) {
+			return errors.New("Run parameter name must be letters, numbers or _ and start with a letter: " + p.Name)
+		}
+
+		// Names are case insensitive as environment variables are upper case
+		if names[strings.ToUpper(p.Name)] {
+			return errors.New("Run parameter declared twice: " + p.Name)
+		}
+		names[strings.ToUpper(p.Name)] = true
+
+		switch p.Type {
+		case "string", "number", "integer", "boolean", "json":
+		default:
+			return errors.New("Run parameter type must be string, number, integer, boolean or json: " + p.Name)
+		}
+
+		if runParameterSet(p.Default) {
+			if err := RunParameterCheck(p, p.Default); err != nil {
+				return errors.New("Run parameter default - " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+RunParameterCheck checks a value is of the declared type of the parameter.
+*/
+func RunParameterCheck(p models.RunParameter, value datatypes.JSON) error {
+
+	var ok bool
+
+	switch p.Type {
+	case "string":
+		var s string
+		ok = json.Unmarshal(value, &s) == nil
+	case "number":
+		var n float64
+		ok = json.Unmarshal(value, &n) == nil
+	case "integer":
+		var n float64
+		ok = json.Unmarshal(value, &n) == nil && n == math.Trunc(n)
+	case "boolean":
+		var b bool
+		ok = json.Unmarshal(value, &b) == nil
+	case "json":
+		ok = json.Valid(value)
+	}
+
+	if !ok {
+		return errors.New(p.Name + " must be " + p.Type)
+	}
+
+	return nil
+}
+
+/*
+RunParameterFromString converts a text value e.g. from a query string to the declared type of the parameter.
+*/
+func RunParameterFromString(p models.RunParameter, value string) (datatypes.JSON, error) {
+
+	var out []byte
+
+	switch p.Type {
+	case "string":
+		out, _ = json.Marshal(value)
+	case "number", "integer":
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, errors.New("Run parameter " + p.Name + " must be " + p.Type)
+		}
+		out, _ = json.Marshal(n)
+	case "boolean":
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return nil, errors.New("Run parameter " + p.Name + " must be " + p.Type)
+		}
+		out, _ = json.Marshal(b)
+	default:
+		out = []byte(value)
+	}
+
+	if err := RunParameterCheck(p, out); err != nil {
+		return nil, errors.New("Run parameter " + err.Error())
+	}
+
+	return datatypes.JSON(out), nil
+}
+
+/*
+ResolveRunParameters works out the parameter values of a run from the declared parameters and the values submitted with the run.
+Submitted values override defaults; parameters that are not declared, required parameters without a value and values of the wrong type are errors.
+Returns a JSON object of values by parameter name.
+*/
+func ResolveRunParameters(defs []models.RunParameter, submitted datatypes.JSON) (datatypes.JSON, error) {
+
+	values := make(map[string]json.RawMessage)
+
+	if runParameterSet(submitted) {
+		err := json.Unmarshal(submitted, &values)
+		if err != nil {
+			return nil, errors.New("Run parameters must be a JSON object")
+		}
+	}
+
+	declared := make(map[string]bool, len(defs))
+	for _, p := range defs {
+		declared[p.Name] = true
+	}
+
+	for k := range values {
+		if !declared[k] {
+			return nil, errors.New("Run parameter not declared: " + k)
+		}
+	}
+
+	resolved := make(map[string]json.RawMessage, len(defs))
+
+	for _, p := range defs {
+
+		value := datatypes.JSON(values[p.Name])
+		if !runParameterSet(value) {
+			value = p.Default
+		}
+
+		if !runParameterSet(value) {
+			if p.Required {
+				return nil, errors.New("Run parameter required: " + p.Name)
+			}
+			continue
+		}
+
+		if err := RunParameterCheck(p, value); err != nil {
+			return nil, errors.New("Run parameter " + err.Error())
+		}
+
+		resolved[p.Name] = json.RawMessage(value)
+	}
+
+	out, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	return datatypes.JSON(out), nil
+}
+
+/*
+ResolveRunParametersText is ResolveRunParameters for values submitted as text such as an API trigger query string.
+Only arguments declared as parameters are converted to their declared type, other arguments such as
+API keys or cache busters are ignored.
+*/
+func ResolveRunParametersText(defs []models.RunParameter, submitted map[string]string) (datatypes.JSON, error) {
+
+	values := make(map[string]json.RawMessage, len(submitted))
+
+	for _, p := range defs {
+		v, ok := submitted[p.Name]
+		if !ok {
+			continue
+		}
+
+		value, err := RunParameterFromString(p, v)
+		if err != nil {
+			return nil, err
+		}
+		values[p.Name] = json.RawMessage(value)
+	}
+
+	valuesJSON, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return ResolveRunParameters(defs, valuesJSON)
+}
+
+// A missing value and null both mean the parameter is not set
+func runParameterSet(value datatypes.JSON) bool {
+	v := strings.TrimSpace(string(value))
+	return v != "" && v != "null"
+}
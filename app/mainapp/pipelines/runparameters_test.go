@@ -0,0 +1,85 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+/*
+go test -timeout 30s -v -run ^TestRunParameters$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestRunParameters(t *testing.T) {
+
+	defs := []models.RunParameter{
+		{Name: "region", Type: "string", Default: datatypes.JSON(`"eu"`)},
+		{Name: "limit", Type: "integer", Default: datatypes.JSON(`100`)},
+		{Name: "full_load", Type: "boolean"},
+		{Name: "date", Type: "string", Required: true},
+	}
+
+	// Declarations
+	assert.NoError(t, ValidateRunParameterDefs(defs), "Valid declarations")
+	assert.Error(t, ValidateRunParameterDefs([]models.RunParameter{{Name: "1region", Type: "string"}}), "Invalid name")
+	assert.Error(t, ValidateRunParameterDefs([]models.RunParameter{{Name: "region", Type: "text"}}), "Invalid type")
+	assert.Error(t, ValidateRunParameterDefs([]models.RunParameter{{Name: "region", Type: "string"}, {Name: "REGION", Type: "number"}}), "Duplicate name")
+	assert.Error(t, ValidateRunParameterDefs([]models.RunParameter{{Name: "limit", Type: "integer", Default: datatypes.JSON(`1.5`)}}), "Default wrong type")
+
+	// Defaults and overrides
+	values, err := ResolveRunParameters(defs, datatypes.JSON(`{"date": "2022-01-31", "limit": 5}`))
+	assert.NoError(t, err, "Resolve")
+	assert.JSONEqf(t, `{"region": "eu", "limit": 5, "date": "2022-01-31"}`, string(values), "Resolved values")
+
+	_, err = ResolveRunParameters(defs, nil)
+	assert.EqualErrorf(t, err, "Run parameter required: date", "Required missing")
+
+	_, err = ResolveRunParameters(defs, datatypes.JSON(`{"date": "2022-01-31", "limit": "5"}`))
+	assert.EqualErrorf(t, err, "Run parameter limit must be integer", "Wrong type")
+
+	_, err = ResolveRunParameters(defs, datatypes.JSON(`{"date": "2022-01-31", "other": 1}`))
+	assert.EqualErrorf(t, err, "Run parameter not declared: other", "Not declared")
+
+	_, err = ResolveRunParameters(defs, datatypes.JSON(`[1]`))
+	assert.Errorf(t, err, "Not an object")
+
+	values, err = ResolveRunParameters(nil, datatypes.JSON(`null`))
+	assert.NoError(t, err, "No parameters")
+	assert.Equalf(t, `{}`, string(values), "No parameters")
+
+	// Text values
+	value, err := RunParameterFromString(defs[1], "25")
+	assert.NoError(t, err, "Integer from text")
+	assert.Equalf(t, `25`, string(value), "Integer from text")
+
+	value, err = RunParameterFromString(defs[2], "true")
+	assert.NoError(t, err, "Boolean from text")
+	assert.Equalf(t, `true`, string(value), "Boolean from text")
+
+	value, _ = RunParameterFromString(defs[0], "us")
+	assert.Equalf(t, `"us"`, string(value), "String from text")
+
+	_, err = RunParameterFromString(defs[1], "abc")
+	assert.Errorf(t, err, "Integer from invalid text")
+
+}
+
+/*
+go test -timeout 30s -v -run ^TestResolveRunParametersText$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestResolveRunParametersText(t *testing.T) {
+
+	defs := []models.RunParameter{
+		{Name: "region", Type: "string", Default: datatypes.JSON(`"eu"`)},
+		{Name: "limit", Type: "integer", Default: datatypes.JSON(`100`)},
+	}
+
+	// Query arguments that are not parameters are ignored
+	values, err := ResolveRunParametersText(defs, map[string]string{"limit": "10", "apikey": "secret", "_": "1670000000"})
+	assert.NoError(t, err, "Undeclared arguments ignored")
+	assert.JSONEqf(t, `{"region":"eu","limit":10}`, string(values), "Declared arguments converted")
+
+	_, err = ResolveRunParametersText(defs, map[string]string{"limit": "ten"})
+	assert.Error(t, err, "Declared argument wrong type")
+}
@@ -0,0 +1,205 @@
+package pipelines
+
+import (
+	"errors"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+)
+
+/*
+RunPause pauses a running run: tasks already running finish, queued tasks are held at status Paused
+and nothing new starts until the run is resumed, see RunResume.
+With nodes given only the queued tasks of those nodes are held and the rest of the run carries on.
+*/
+func RunPause(runID string, environmentID string, nodeIDs []string) (models.PipelineRuns, error) {
+
+	var run models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and environment_id = ?", runID, environmentID).First(&run).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.PipelineRuns{}, errors.New("Run not found.")
+	}
+
+	switch {
+	case len(nodeIDs) == 0 && run.Status != "Running":
+		return models.PipelineRuns{}, errors.New("Only a running run can be paused.")
+	case len(nodeIDs) > 0 && run.Status != "Running" && run.Status != "Paused":
+		return models.PipelineRuns{}, errors.New("Only the nodes of a running run can be paused.")
+	}
+
+	if len(nodeIDs) == 0 {
+
+		result := database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ? and status = ?", runID, "Running").Updates(map[string]interface{}{
+			"status":     "Paused",
+			"updated_at": time.Now().UTC(),
+		})
+		if result.Error != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(result.Error)
+			}
+			return models.PipelineRuns{}, errors.New("Pause run database error.")
+		}
+
+		if result.RowsAffected == 0 {
+			return models.PipelineRuns{}, errors.New("Only a running run can be paused.")
+		}
+
+		run.Status = "Paused"
+	}
+
+	held, err := runPauseTasks(runID, nodeIDs, "Queue", "Paused")
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	if len(nodeIDs) > 0 && len(held) == 0 {
+		return models.PipelineRuns{}, errors.New("The nodes have no queued tasks to pause.")
+	}
+
+	if len(nodeIDs) == 0 {
+		runPauseMessage(run, "pipeline_paused")
+	}
+
+	return run, nil
+}
+
+/*
+RunResume resumes a paused run, or the paused nodes of a running run, and starts the held tasks whose upstream has finished.
+Resuming a whole run also resumes its paused nodes.
+*/
+func RunResume(runID string, environmentID string, nodeIDs []string) (models.PipelineRuns, error) {
+
+	var run models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and environment_id = ?", runID, environmentID).First(&run).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.PipelineRuns{}, errors.New("Run not found.")
+	}
+
+	switch {
+	case len(nodeIDs) == 0 && run.Status != "Paused":
+		return models.PipelineRuns{}, errors.New("Only a paused run can be resumed.")
+	case len(nodeIDs) > 0 && run.Status == "Paused":
+		return models.PipelineRuns{}, errors.New("The run is paused, resume the run to resume its nodes.")
+	case len(nodeIDs) > 0 && run.Status != "Running":
+		return models.PipelineRuns{}, errors.New("Only the nodes of a running run can be resumed.")
+	}
+
+	if len(nodeIDs) == 0 {
+
+		result := database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ? and status = ?", runID, "Paused").Updates(map[string]interface{}{
+			"status":     "Running",
+			"updated_at": time.Now().UTC(),
+		})
+		if result.Error != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(result.Error)
+			}
+			return models.PipelineRuns{}, errors.New("Resume run database error.")
+		}
+
+		if result.RowsAffected == 0 {
+			return models.PipelineRuns{}, errors.New("Only a paused run can be resumed.")
+		}
+
+		run.Status = "Running"
+	}
+
+	resumed, err := runPauseTasks(runID, nodeIDs, "Paused", "Queue")
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	if len(nodeIDs) > 0 && len(resumed) == 0 {
+		return models.PipelineRuns{}, errors.New("The nodes have no paused tasks to resume.")
+	}
+
+	if len(nodeIDs) == 0 {
+		runPauseMessage(run, "pipeline_resumed")
+	}
+
+	// ----- Start the held tasks that are ready, the others start as their upstream finishes
+	for i := range resumed {
+		RunNextTask(&resumed[i])
+	}
+
+	// Everything may have finished while the run was paused
+	RunNextComplete(run, models.WorkerTaskSend{
+		RunID:         run.RunID,
+		PipelineID:    run.PipelineID,
+		EnvironmentID: run.EnvironmentID,
+	})
+
+	return run, nil
+}
+
+/*
+runPauseTasks moves the tasks of a run, or of some of its nodes, from one status to another and returns the tasks moved.
+*/
+func runPauseTasks(runID string, nodeIDs []string, from string, to string) ([]models.WorkerTasks, error) {
+
+	query := database.DBConn.Where("run_id = ? and status = ?", runID, from)
+	if len(nodeIDs) > 0 {
+		query = query.Where("node_id in (?)", nodeIDs)
+	}
+
+	tasks := []models.WorkerTasks{}
+	err := query.Find(&tasks).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, errors.New("Retrieve run tasks database error.")
+	}
+
+	moved := []models.WorkerTasks{}
+
+	for _, t := range tasks {
+
+		// A task can start or finish in the meantime
+		result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", t.TaskID, from).Update("status", to)
+		if result.Error != nil {
+			logging.PrintSecretsRedact(result.Error)
+			continue
+		}
+
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		t.Status = to
+		moved = append(moved, t)
+
+		errnat := messageq.MsgSend("taskupdate."+t.EnvironmentID+"."+t.RunID, t)
+		if errnat != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(errnat)
+			}
+		}
+	}
+
+	return moved, nil
+}
+
+func runPauseMessage(run models.PipelineRuns, msg string) {
+
+	errnat := messageq.MsgSend("taskupdate."+run.EnvironmentID+"."+run.RunID, map[string]interface{}{
+		"MSG":        msg,
+		"run_id":     run.RunID,
+		"started_at": run.CreatedAt,
+		"status":     run.Status})
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+}
@@ -12,28 +12,44 @@ import (
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
 	"github.com/dataplane-app/dataplane/app/mainapp/worker"
 
 	"github.com/google/uuid"
 	"gorm.io/datatypes"
+	"gorm.io/gorm/clause"
 )
 
 type Command struct {
 	Command string `json:command`
 }
 
-func RunPipeline(pipelineID string, environmentID string, runID string, runJson ...datatypes.JSON) (models.PipelineRuns, error) {
+/*
+RunOptions are recorded on a run when it is triggered.
+*/
+type RunOptions struct {
+	Parameters       datatypes.JSON // resolved run parameter values, see RunParameters
+	LogicalDate      *time.Time     // schedule fire time the run is for
+	LogicalTimezone  string
+	ParentRunID      string // sub-pipeline node run and task that started the run
+	ParentTaskID     string
+	ParentPipelineID string
+	Depth            int
+}
+
+func RunPipeline(pipelineID string, environmentID string, runID string, options RunOptions, runJson ...datatypes.JSON) (models.PipelineRuns, error) {
 
 	// start := time.Now().UTC()
 
 	// Doesnt require concurrency safety, should be written / read in sequence.
 	var destinations = make(map[string][]string)
 	var dependencies = make(map[string][]string)
+	var conditions = make(map[string]map[string]models.EdgeCondition)
 	var triggerData = make(map[string]*models.WorkerTasks)
 
 	// Retrieve pipeline details
 	pipelinedata := models.Pipelines{}
-	err := database.DBConn.Select("pipeline_id", "name", "worker_group", "json").Where("pipeline_id = ? and environment_id =?", pipelineID, environmentID).First(&pipelinedata).Error
+	err := database.DBConn.Select("pipeline_id", "name", "worker_group", "timeout_seconds", "max_concurrent_runs", "concurrency_policy", "pause_runs", "json").Where("pipeline_id = ? and environment_id =?", pipelineID, environmentID).First(&pipelinedata).Error
 	if err != nil {
 
 		if dpconfig.Debug == "true" {
@@ -55,7 +71,8 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 			CreatedAt:     time.Now().UTC(),
 		}
 
-		err = database.DBConn.Create(&run).Error
+		// A queued run already has its payload
+		err = database.DBConn.Clauses(clause.OnConflict{DoNothing: true}).Create(&run).Error
 		if err != nil {
 
 			if dpconfig.Debug == "true" {
@@ -67,16 +84,24 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 
 	// Create a run
 	run := models.PipelineRuns{
-		RunID:         runID,
-		PipelineID:    pipelineID,
-		Status:        "Running",
-		EnvironmentID: environmentID,
-		CreatedAt:     time.Now().UTC(),
-		RunJSON:       pipelinedata.Json,
-		RunType:       "pipeline",
+		RunID:            runID,
+		PipelineID:       pipelineID,
+		Status:           "Running",
+		EnvironmentID:    environmentID,
+		CreatedAt:        time.Now().UTC(),
+		RunJSON:          pipelinedata.Json,
+		Parameters:       options.Parameters,
+		RunType:          "pipeline",
+		LogicalDate:      options.LogicalDate,
+		LogicalTimezone:  options.LogicalTimezone,
+		ParentRunID:      options.ParentRunID,
+		ParentTaskID:     options.ParentTaskID,
+		ParentPipelineID: options.ParentPipelineID,
+		Depth:            options.Depth,
 	}
 
-	err = database.DBConn.Create(&run).Error
+	// The run waits if the pipeline is at its max concurrent runs or its runs are paused
+	run, err = RunAdmit(run, pipelinedata.MaxConcurrentRuns, pipelinedata.ConcurrencyPolicy, pipelinedata.PauseRuns)
 	if err != nil {
 
 		if dpconfig.Debug == "true" {
@@ -85,6 +110,12 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 		return models.PipelineRuns{}, err
 	}
 
+	if run.Status == "Queued" {
+		return run, nil
+	}
+
+	metrics.RunsStarted.WithLabelValues(environmentID, pipelineID, run.RunType).Inc()
+
 	// ------ Obtain folders
 	folders := make(chan []models.CodeFolders)
 	parentfolder := make(chan string)
@@ -138,6 +169,11 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 		destinations[s.From] = append(destinations[s.From], s.To)
 		dependencies[s.To] = append(dependencies[s.To], s.From)
 
+		if conditions[s.To] == nil {
+			conditions[s.To] = make(map[string]models.EdgeCondition)
+		}
+		conditions[s.To][s.From] = models.EdgeCondition{Condition: s.Condition, Expression: s.Expression}
+
 	}
 
 	// Map folder structure:
@@ -213,22 +249,31 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 			logging.PrintSecretsRedact(err)
 		}
 
+		conditionsJSON, err := json.Marshal(conditions[s.NodeID])
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+		}
+
 		addTask := &models.WorkerTasks{
-			TaskID:        uuid.NewString(),
-			CreatedAt:     time.Now().UTC(),
-			EnvironmentID: environmentID,
-			RunID:         RunID,
-			WorkerGroup:   workergroup,
-			WorkerType:    s.NodeTypeDesc,
-			PipelineID:    s.PipelineID,
-			NodeID:        s.NodeID,
-			Status:        status,
-			Dependency:    dependJSON,
-			Commands:      s.Commands,
-			Destination:   destinationJSON,
-			Folder:        folderMap[s.NodeID],
-			FolderID:      folderNodeMap[s.NodeID],
-			RunType:       "pipeline",
+			TaskID:         uuid.NewString(),
+			CreatedAt:      time.Now().UTC(),
+			EnvironmentID:  environmentID,
+			RunID:          RunID,
+			WorkerGroup:    workergroup,
+			WorkerType:     s.NodeTypeDesc,
+			PipelineID:     s.PipelineID,
+			NodeID:         s.NodeID,
+			Status:         status,
+			Dependency:     dependJSON,
+			Conditions:     conditionsJSON,
+			Commands:       s.Commands,
+			Destination:    destinationJSON,
+			Folder:         folderMap[s.NodeID],
+			FolderID:       folderNodeMap[s.NodeID],
+			RunType:        "pipeline",
+			Attempt:        1,
+			RetryPolicy:    s.RetryPolicy,
+			TimeoutSeconds: worker.TaskTimeoutSeconds(s.TimeoutSeconds, pipelinedata.TimeoutSeconds),
 		}
 
 		if nodeType == "start" {
@@ -262,6 +307,16 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 		return models.PipelineRuns{}, err
 	}
 
+	// The API trigger payload is an output of the trigger node for downstream tasks
+	if runJson != nil && len(runJson[0]) != 0 {
+		err = RunTriggerPayload(startTask, runJson[0])
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+		}
+	}
+
 	// --- Run the first set of tasks
 	if dpconfig.Debug == "true" {
 		log.Println("trigger: ", trigger, triggerID)
@@ -301,7 +356,7 @@ func RunPipeline(pipelineID string, environmentID string, runID string, runJson
 		// err = worker.WorkerRunTask("python_1", triggerData[s].TaskID, RunID, environmentID, pipelineID, s, []string{"sleep " + strconv.Itoa(x) + "; echo " + s})
 		// log.Println("Worker type:", triggerData[s].WorkerType)
 		/* Start the first task */
-		err = worker.WorkerRunTask(triggerData[s].WorkerGroup, triggerData[s].TaskID, RunID, environmentID, pipelineID, s, commandsend, folderMap[triggerData[s].NodeID], folderNodeMap[triggerData[s].NodeID], "", "pipeline", triggerData[s].WorkerType)
+		err = RunTask(*triggerData[s], commandsend)
 		if err != nil {
 			if dpconfig.Debug == "true" {
 				logging.PrintSecretsRedact(err)
@@ -0,0 +1,393 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+)
+
+/*
+RunRerun runs a finished run again from some of its nodes, keeping its run ID.
+With no nodes given the run is re-run from its failed nodes, otherwise from the nodes given.
+The nodes and everything downstream of them run again as the next attempt, their earlier attempts are kept with status Retry.
+Upstream nodes are not run again: their status and outputs are used as they are.
+The attempt of the run goes up by one and it is admitted like a new run: it goes back to Running,
+or waits as Queued if the pipeline is at its max concurrent runs or its runs are paused.
+*/
+func RunRerun(runID string, environmentID string, nodeIDs []string) (models.PipelineRuns, error) {
+
+	var run models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and environment_id = ?", runID, environmentID).First(&run).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.PipelineRuns{}, errors.New("Run not found.")
+	}
+
+	if run.Status != "Fail" && run.Status != "Success" {
+		return models.PipelineRuns{}, errors.New("Only a finished run can be re-run.")
+	}
+
+	taskByNode, destinations, dependencies, failed, err := rerunTasks(runID, environmentID)
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	// ----- Nodes to re-run from
+	start := []string{}
+
+	if len(nodeIDs) == 0 {
+		start = RerunFailedNodes(failed, dependencies)
+		if len(start) == 0 {
+			return models.PipelineRuns{}, errors.New("The run has no failed nodes to re-run from.")
+		}
+	}
+
+	for _, n := range nodeIDs {
+
+		if _, ok := taskByNode[n]; !ok {
+			return models.PipelineRuns{}, errors.New("Node not found in the run: " + n)
+		}
+
+		// The trigger has nothing to run, re-run from the nodes it starts
+		if len(dependencies[n]) == 0 {
+			start = append(start, destinations[n]...)
+			continue
+		}
+
+		start = append(start, n)
+	}
+
+	startJSON, err := json.Marshal(start)
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	// ----- The run waits for its next attempt, only once if re-run at the same time
+	finished := run
+
+	result := database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ? and status = ?", runID, run.Status).Updates(map[string]interface{}{
+		"status":      "Queued",
+		"reason":      "",
+		"attempt":     run.Attempt + 1,
+		"ended_at":    time.Time{},
+		"rerun_nodes": datatypes.JSON(startJSON),
+		"updated_at":  time.Now().UTC(),
+	})
+	if result.Error != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(result.Error)
+		}
+		return models.PipelineRuns{}, errors.New("Update run database error.")
+	}
+
+	if result.RowsAffected == 0 {
+		return models.PipelineRuns{}, errors.New("The run has already been re-run.")
+	}
+
+	run.Status = "Queued"
+	run.Reason = ""
+	run.Attempt++
+	run.EndedAt = time.Time{}
+	run.RerunNodes = datatypes.JSON(startJSON)
+
+	run, err = rerunStart(run)
+	if err != nil {
+
+		// Not admitted, the run is left as it was
+		database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ? and status = ?", runID, "Queued").Updates(map[string]interface{}{
+			"status":      finished.Status,
+			"reason":      finished.Reason,
+			"attempt":     finished.Attempt,
+			"ended_at":    finished.EndedAt,
+			"rerun_nodes": nil,
+		})
+		return models.PipelineRuns{}, err
+	}
+
+	return run, nil
+}
+
+/*
+rerunStart admits a run waiting on its re-run and, if it can start, queues the next attempts of its nodes.
+Called by RunRerun and by RunQueueNext for a re-run that had to wait.
+*/
+func rerunStart(run models.PipelineRuns) (models.PipelineRuns, error) {
+
+	maxRuns, policy, paused, err := runAdmitSettings(run)
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	run, err = RunAdmit(run, maxRuns, policy, paused)
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	if run.Status == "Queued" {
+		return run, nil
+	}
+
+	runID := run.RunID
+	environmentID := run.EnvironmentID
+
+	taskByNode, destinations, dependencies, _, err := rerunTasks(runID, environmentID)
+	if err != nil {
+		return models.PipelineRuns{}, err
+	}
+
+	start := []string{}
+	json.Unmarshal(run.RerunNodes, &start)
+
+	nodes := RerunNodes(start, destinations)
+	entry := RerunEntryNodes(nodes, dependencies)
+
+	err = database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ?", runID).Update("rerun_nodes", nil).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+	run.RerunNodes = nil
+
+	// ----- Earlier attempts are kept, the next attempts are queued
+	previous := []string{}
+	for _, n := range nodes {
+		previous = append(previous, taskByNode[n].TaskID)
+	}
+
+	err = database.DBConn.Model(&models.WorkerTasks{}).Where("task_id in (?) and status <> ?", previous, "Retry").Update("status", "Retry").Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+
+	next := []*models.WorkerTasks{}
+	nextByNode := map[string]*models.WorkerTasks{}
+
+	for _, n := range nodes {
+
+		t := taskByNode[n]
+
+		t.Status = "Retry"
+		errnat := messageq.MsgSend("taskupdate."+t.EnvironmentID+"."+t.RunID, t)
+		if errnat != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(errnat)
+			}
+		}
+
+		nextTask := &models.WorkerTasks{
+			TaskID:         uuid.NewString(),
+			CreatedAt:      time.Now().UTC(),
+			EnvironmentID:  t.EnvironmentID,
+			RunID:          t.RunID,
+			RunType:        t.RunType,
+			WorkerGroup:    t.WorkerGroup,
+			WorkerType:     t.WorkerType,
+			PipelineID:     t.PipelineID,
+			NodeID:         t.NodeID,
+			Folder:         t.Folder,
+			FolderID:       t.FolderID,
+			Dependency:     t.Dependency,
+			Conditions:     t.Conditions,
+			Destination:    t.Destination,
+			Status:         "Queue",
+			Commands:       t.Commands,
+			Version:        t.Version,
+			Attempt:        t.Attempt + 1,
+			RetryPolicy:    t.RetryPolicy,
+			TimeoutSeconds: t.TimeoutSeconds,
+		}
+
+		next = append(next, nextTask)
+		nextByNode[n] = nextTask
+	}
+
+	err = database.DBConn.Create(&next).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+
+		database.DBConn.Model(&models.PipelineRuns{}).Where("run_id = ?", runID).Updates(map[string]interface{}{
+			"status":   "Fail",
+			"reason":   "Re-run failed to queue tasks",
+			"ended_at": time.Now().UTC(),
+		})
+		return models.PipelineRuns{}, errors.New("Queue re-run tasks database error.")
+	}
+
+	// Release the node locks so that workers can pick up the next attempts
+	err = database.DBConn.Where("run_id = ? and node_id in (?)", runID, nodes).Delete(&models.WorkerTaskLock{}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+
+	for _, t := range next {
+		errnat := messageq.MsgSend("taskupdate."+t.EnvironmentID+"."+t.RunID, t)
+		if errnat != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(errnat)
+			}
+		}
+	}
+
+	metrics.RunsStarted.WithLabelValues(environmentID, run.PipelineID, run.RunType).Inc()
+
+	if dpconfig.Debug == "true" {
+		logging.PrintSecretsRedact("Re-run:", runID, "attempt", run.Attempt, "from", entry)
+	}
+
+	// ----- Start with the nodes whose upstream has finished
+	for _, n := range entry {
+		RunNextTask(nextByNode[n])
+	}
+
+	return run, nil
+}
+
+/*
+rerunTasks retrieves the latest attempt of each node of a run and the graph between them.
+*/
+func rerunTasks(runID string, environmentID string) (map[string]models.WorkerTasks, map[string][]string, map[string][]string, []string, error) {
+
+	tasks := []models.WorkerTasks{}
+	err := database.DBConn.Where("run_id = ? and environment_id = ? and status <> ?", runID, environmentID, "Retry").Find(&tasks).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return nil, nil, nil, nil, errors.New("Retrieve run tasks database error.")
+	}
+
+	taskByNode := map[string]models.WorkerTasks{}
+	destinations := map[string][]string{}
+	dependencies := map[string][]string{}
+	failed := []string{}
+
+	for _, t := range tasks {
+
+		taskByNode[t.NodeID] = t
+
+		var d []string
+		json.Unmarshal(t.Destination, &d)
+		destinations[t.NodeID] = d
+
+		d = nil
+		json.Unmarshal(t.Dependency, &d)
+		dependencies[t.NodeID] = d
+
+		if t.Status == "Fail" {
+			failed = append(failed, t.NodeID)
+		}
+	}
+
+	return taskByNode, destinations, dependencies, failed, nil
+}
+
+/*
+RerunFailedNodes returns the failed nodes a failed run is re-run from: failed nodes with no failed node upstream.
+Nodes failed by an upstream failure are re-run as part of the downstream of these.
+*/
+func RerunFailedNodes(failed []string, dependencies map[string][]string) []string {
+
+	isFailed := map[string]bool{}
+	for _, n := range failed {
+		isFailed[n] = true
+	}
+
+	out := []string{}
+
+	for _, n := range failed {
+
+		upstreamFailed := false
+		for _, d := range dependencies[n] {
+			if isFailed[d] {
+				upstreamFailed = true
+				break
+			}
+		}
+
+		if !upstreamFailed {
+			out = append(out, n)
+		}
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+/*
+RerunNodes returns the nodes to run again when a run is re-run from the start nodes: the start nodes and every node downstream of them.
+*/
+func RerunNodes(start []string, destinations map[string][]string) []string {
+
+	seen := map[string]bool{}
+	queue := append([]string{}, start...)
+
+	for len(queue) > 0 {
+
+		n := queue[0]
+		queue = queue[1:]
+
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+
+		queue = append(queue, destinations[n]...)
+	}
+
+	out := make([]string, 0, len(seen))
+	for n := range seen {
+		out = append(out, n)
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+/*
+RerunEntryNodes returns the nodes a re-run starts with: re-run nodes with no upstream node that is also re-run.
+Their upstream nodes have finished already, so they can run straight away.
+*/
+func RerunEntryNodes(nodes []string, dependencies map[string][]string) []string {
+
+	rerun := map[string]bool{}
+	for _, n := range nodes {
+		rerun[n] = true
+	}
+
+	out := []string{}
+
+	for _, n := range nodes {
+
+		entry := true
+		for _, d := range dependencies[n] {
+			if rerun[d] {
+				entry = false
+				break
+			}
+		}
+
+		if entry {
+			out = append(out, n)
+		}
+	}
+
+	sort.Strings(out)
+
+	return out
+}
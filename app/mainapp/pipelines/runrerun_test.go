@@ -0,0 +1,49 @@
+package pipelines
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestRunRerun$ github.com/dataplane-app/dataplane/app/mainapp/pipelines
+*/
+func TestRunRerun(t *testing.T) {
+
+	/*
+		trigger -> a -> b -> d
+		              \-> c -/
+		           e (failure branch of a)
+	*/
+	destinations := map[string][]string{
+		"trigger": {"a"},
+		"a":       {"b", "c", "e"},
+		"b":       {"d"},
+		"c":       {"d"},
+	}
+
+	dependencies := map[string][]string{
+		"a": {"trigger"},
+		"b": {"a"},
+		"c": {"a"},
+		"d": {"b", "c"},
+		"e": {"a"},
+	}
+
+	// ----- Failed nodes: c failed, d failed because of c
+	assert.Equalf(t, []string{"c"}, RerunFailedNodes([]string{"d", "c"}, dependencies), "Only the first failure")
+	assert.Equalf(t, []string{"b", "c"}, RerunFailedNodes([]string{"b", "c", "d"}, dependencies), "Parallel failures")
+	assert.Equalf(t, []string{}, RerunFailedNodes([]string{}, dependencies), "Nothing failed")
+
+	// ----- Downstream of the start nodes
+	assert.Equalf(t, []string{"c", "d"}, RerunNodes([]string{"c"}, destinations), "Failed node and downstream")
+	assert.Equalf(t, []string{"a", "b", "c", "d", "e"}, RerunNodes([]string{"a"}, destinations), "Whole branch")
+	assert.Equalf(t, []string{"b", "c", "d"}, RerunNodes([]string{"b", "c"}, destinations), "Shared downstream once")
+	assert.Equalf(t, []string{"d"}, RerunNodes([]string{"d"}, destinations), "Last node")
+
+	// ----- Entry nodes start the re-run
+	assert.Equalf(t, []string{"c"}, RerunEntryNodes([]string{"c", "d"}, dependencies), "Entry of a failed node")
+	assert.Equalf(t, []string{"b", "c"}, RerunEntryNodes([]string{"b", "c", "d"}, dependencies), "Parallel entries")
+	assert.Equalf(t, []string{"a"}, RerunEntryNodes(RerunNodes([]string{"a", "d"}, destinations), dependencies), "Selected node downstream of another")
+}
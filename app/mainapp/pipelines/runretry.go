@@ -0,0 +1,199 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+
+	"github.com/go-co-op/gocron"
+	"github.com/google/uuid"
+)
+
+/*
+RetryTask queues the next attempt of a failed task if its retry policy allows it.
+The failed attempt is kept as its own row with status Retry and the new attempt
+gets a new task ID so that its logs are recorded separately.
+Returns true if another attempt was queued.
+*/
+func RetryTask(failedTask models.WorkerTasks) bool {
+
+	// Cancelled tasks and tasks failed by an upstream failure are never retried
+	if failedTask.Reason == "cancel" || failedTask.Reason == "Upstream fail" {
+		return false
+	}
+
+	// A rejected approval is a decision, asking again would ignore it
+	if failedTask.WorkerType == "approval" {
+		return false
+	}
+
+	if !RetryAllowed(failedTask.RetryPolicy, failedTask.Attempt, failedTask.ExitCode) {
+		return false
+	}
+
+	// Only retry while the pipeline is still running e.g. not stopped by a user
+	var run models.PipelineRuns
+	err := database.DBConn.Select("run_id", "status").Where("run_id = ?", failedTask.RunID).First(&run).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return false
+	}
+
+	if run.Status != "Running" && run.Status != "Paused" {
+		return false
+	}
+
+	// A paused run holds the next attempt until it is resumed
+	status := "Queue"
+	if run.Status == "Paused" {
+		status = "Paused"
+	}
+
+	// Mark the failed attempt as retried - only once
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", failedTask.TaskID, "Fail").Updates(map[string]interface{}{"status": "Retry"})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return false
+	}
+
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	failedTask.Status = "Retry"
+	errnat := messageq.MsgSend("taskupdate."+failedTask.EnvironmentID+"."+failedTask.RunID, failedTask)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	// The backoff is kept on the next attempt so that it survives a restart, see RetryWatch
+	delay := RetryBackoffDelay(failedTask.RetryPolicy, failedTask.Attempt)
+
+	var nextAttemptAt *time.Time
+	if delay > 0 {
+		at := time.Now().UTC().Add(delay)
+		nextAttemptAt = &at
+	}
+
+	nextTask := models.WorkerTasks{
+		TaskID:         uuid.NewString(),
+		CreatedAt:      time.Now().UTC(),
+		EnvironmentID:  failedTask.EnvironmentID,
+		RunID:          failedTask.RunID,
+		RunType:        failedTask.RunType,
+		WorkerGroup:    failedTask.WorkerGroup,
+		WorkerType:     failedTask.WorkerType,
+		PipelineID:     failedTask.PipelineID,
+		NodeID:         failedTask.NodeID,
+		Folder:         failedTask.Folder,
+		FolderID:       failedTask.FolderID,
+		Dependency:     failedTask.Dependency,
+		Conditions:     failedTask.Conditions,
+		Destination:    failedTask.Destination,
+		Status:         status,
+		Commands:       failedTask.Commands,
+		Version:        failedTask.Version,
+		Attempt:        failedTask.Attempt + 1,
+		RetryPolicy:    failedTask.RetryPolicy,
+		TimeoutSeconds: failedTask.TimeoutSeconds,
+		NextAttemptAt:  nextAttemptAt,
+	}
+
+	err = database.DBConn.Create(&nextTask).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return false
+	}
+
+	// Release the node lock so that a worker can pick up the next attempt
+	err = database.DBConn.Where("run_id = ? and node_id = ?", failedTask.RunID, failedTask.NodeID).Delete(&models.WorkerTaskLock{}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+
+	errnat = messageq.MsgSend("taskupdate."+nextTask.EnvironmentID+"."+nextTask.RunID, nextTask)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	if dpconfig.Debug == "true" {
+		logging.PrintSecretsRedact("Retry task:", nextTask.RunID, " -> ", nextTask.NodeID, " attempt "+strconv.Itoa(nextTask.Attempt)+" in", delay)
+	}
+
+	// A paused run holds the next attempt and a backoff waits on RetryWatch
+	if status == "Paused" || nextAttemptAt != nil {
+		return true
+	}
+
+	retryStart(nextTask)
+
+	return true
+}
+
+/*
+RetryWatch starts the retry attempts whose backoff has passed.
+The wait is stored on the task so that attempts are not lost when the main app restarts. Only the leader checks.
+*/
+func RetryWatch(s *gocron.Scheduler) {
+
+	s.Every(5).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		var due []models.WorkerTasks
+		err := database.DBConn.Where("status = ? and next_attempt_at is not null and next_attempt_at <= ?", "Queue", time.Now().UTC()).Find(&due).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		for i := range due {
+
+			// Only one leader starts the attempt
+			result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ? and next_attempt_at is not null", due[i].TaskID, "Queue").Update("next_attempt_at", nil)
+			if result.Error != nil {
+				logging.PrintSecretsRedact(result.Error)
+				continue
+			}
+
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			due[i].NextAttemptAt = nil
+			retryStart(due[i])
+		}
+	})
+}
+
+// retryStart sends a retry attempt to its worker, or starts it for sub-pipeline and approval nodes
+func retryStart(task models.WorkerTasks) {
+
+	commandsJson := []Command{}
+	commandsend := []string{}
+
+	json.Unmarshal(task.Commands, &commandsJson)
+
+	for _, c := range commandsJson {
+		commandsend = append(commandsend, c.Command)
+	}
+
+	err := RunTask(task, commandsend)
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package pipelines
+
+import (
+	"log"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
+	"github.com/dataplane-app/dataplane/app/mainapp/notifications"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
+	"github.com/dataplane-app/dataplane/app/mainapp/worker"
+)
+
+/*
+RunStop cancels a queued or running run: queued tasks are failed, running tasks are cancelled on their workers
+and the run is marked as failed with the reason given. The next queued run of the pipeline can then start.
+*/
+func RunStop(runID string, environmentID string, reason string) (models.PipelineRuns, error) {
+
+	var currentRun models.PipelineRuns
+	err := database.DBConn.Where("run_id = ? and environment_id = ?", runID, environmentID).First(&currentRun).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err.Error())
+		return models.PipelineRuns{}, err
+	}
+
+	// Cancel all future tasks, including tasks held by a pause
+	err = database.DBConn.Model(&models.WorkerTasks{}).Where("run_id = ? and environment_id = ? and status in (?)", runID, environmentID, []string{"Queue", "Paused"}).Updates(map[string]interface{}{"status": "Fail", "reason": "Upstream fail"}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err.Error())
+	}
+
+	// Approval tasks waiting on a decision
+	ApprovalStop(runID, environmentID, reason)
+
+	// Get any current running tasks and cancel those tasks
+	currentTask := []*models.WorkerTasks{}
+	err = database.DBConn.Where("run_id = ? and environment_id = ? and status=?", runID, environmentID, "Run").Find(&currentTask).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err.Error())
+	}
+
+	var workerType string
+
+	for _, t := range currentTask {
+
+		/* Which type of worker */
+		switch t.WorkerType {
+
+		/* --- Server worker types ---- */
+		case "python", "bash", "checkpoint":
+
+			workerType = "server"
+		/* Send the task to the RPA worker */
+		case "rpa-python":
+			workerType = "rpa"
+
+		/* Sub-pipeline tasks stop their child runs */
+		case "subpipeline":
+			SubPipelineStop(*t, reason)
+			continue
+
+		default:
+			log.Println("Cancel run, worker type not found for node: ", t.NodeID)
+		}
+
+		errt := worker.WorkerCancelTask(t.TaskID, environmentID, workerType)
+		if errt != nil {
+			logging.PrintSecretsRedact(errt.Error())
+		}
+	}
+
+	// Update pipeline as cancelled
+	run := models.PipelineRuns{
+		RunID:         runID,
+		PipelineID:    currentRun.PipelineID,
+		Status:        "Fail",
+		Reason:        reason,
+		EnvironmentID: environmentID,
+		CreatedAt:     currentRun.CreatedAt,
+		EndedAt:       time.Now().UTC(),
+	}
+
+	err = database.DBConn.Updates(&run).Error
+	if err != nil {
+
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.PipelineRuns{}, err
+	}
+
+	if currentRun.Status == "Running" || currentRun.Status == "Paused" || currentRun.Status == "Queued" {
+		metrics.RunsCompleted.WithLabelValues(environmentID, currentRun.PipelineID, currentRun.RunType, "Fail").Inc()
+		tracing.RunSpan(runID, currentRun.PipelineID, environmentID, currentRun.RunType, "Fail", currentRun.CreatedAt, run.EndedAt)
+	}
+
+	// Pipelines waiting on this one
+	run.RunType = currentRun.RunType
+	go EventTriggersRunComplete(run)
+
+	// A sub-pipeline node waiting on this run
+	go SubPipelineComplete(runID)
+
+	// Failure notifications
+	go notifications.NotifyRunComplete(runID)
+
+	// A place is free for the next queued run
+	go RunQueueNext(currentRun.PipelineID, environmentID, currentRun.RunType)
+
+	return run, nil
+}
@@ -0,0 +1,391 @@
+package pipelines
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	"github.com/dataplane-app/dataplane/app/mainapp/worker"
+
+	"github.com/go-co-op/gocron"
+	"github.com/google/uuid"
+)
+
+/*
+SubPipelineDepthMax is how deep sub-pipeline runs can be nested.
+*/
+const SubPipelineDepthMax = 10
+
+/*
+RunTask starts a task on a worker in its worker group, sub-pipeline and approval tasks are started by the main app.
+*/
+func RunTask(task models.WorkerTasks, commands []string) error {
+
+	switch task.WorkerType {
+	case "subpipeline":
+		return SubPipelineStart(task)
+	case "approval":
+		return ApprovalStart(task)
+	}
+
+	return worker.WorkerRunTask(task.WorkerGroup, task.TaskID, task.RunID, task.EnvironmentID, task.PipelineID, task.NodeID, commands, task.Folder, task.FolderID, task.Version, task.RunType, task.WorkerType)
+}
+
+/*
+SubPipelineStart runs the pipeline or deployment of a sub-pipeline node as a child run.
+The task stays at Run until the child run finishes, see SubPipelineComplete.
+*/
+func SubPipelineStart(task models.WorkerTasks) error {
+
+	now := time.Now().UTC()
+
+	// Only the first run next to get here starts the child run
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", task.TaskID, "Queue").Updates(map[string]interface{}{
+		"status":   "Run",
+		"start_dt": now,
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	task.Status = "Run"
+	task.StartDT = now
+
+	errnat := messageq.MsgSend("taskupdate."+task.EnvironmentID+"."+task.RunID, task)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	childRunID, err := subPipelineRun(task)
+	if err != nil {
+		// The failure moves through the graph like any other failed task
+		worker.WSTaskLogError(task.EnvironmentID, task.RunID, "Sub-pipeline: "+err.Error(), task.NodeID, task.TaskID)
+		SubPipelineFinish(task.TaskID, "Fail", "Sub-pipeline: "+err.Error())
+		return nil
+	}
+
+	if dpconfig.Debug == "true" {
+		logging.PrintSecretsRedact("Sub-pipeline run:", task.RunID, " -> ", childRunID)
+	}
+
+	return nil
+}
+
+func subPipelineRun(task models.WorkerTasks) (string, error) {
+
+	var sub models.SubPipeline
+
+	switch task.RunType {
+	case "deployment":
+		node := models.DeployPipelineNodes{}
+		err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ? and version = ?", task.NodeID, task.PipelineID, task.EnvironmentID, task.Version).First(&node).Error
+		if err != nil {
+			return "", errors.New("node not found")
+		}
+		sub = node.SubPipeline
+	default:
+		node := models.PipelineNodes{}
+		err := database.DBConn.Where("node_id = ? and pipeline_id = ? and environment_id = ?", task.NodeID, task.PipelineID, task.EnvironmentID).First(&node).Error
+		if err != nil {
+			return "", errors.New("node not found")
+		}
+		sub = node.SubPipeline
+	}
+
+	if sub.PipelineID == "" {
+		return "", errors.New("no pipeline selected")
+	}
+
+	var parent models.PipelineRuns
+	err := database.DBConn.Select("run_id", "pipeline_id", "parent_run_id", "depth").Where("run_id = ?", task.RunID).First(&parent).Error
+	if err != nil {
+		return "", err
+	}
+
+	if parent.Depth+1 > SubPipelineDepthMax {
+		return "", errors.New("runs can only be nested " + strconv.Itoa(SubPipelineDepthMax) + " deep")
+	}
+
+	// Pipelines can change after they are saved, check the runs above this one as well
+	ancestor := parent
+	for {
+		if ancestor.PipelineID == sub.PipelineID {
+			return "", errors.New("cycle detected, " + sub.PipelineID + " is already running above this node")
+		}
+		if ancestor.ParentRunID == "" {
+			break
+		}
+		next := models.PipelineRuns{}
+		err := database.DBConn.Select("run_id", "pipeline_id", "parent_run_id").Where("run_id = ?", ancestor.ParentRunID).First(&next).Error
+		if err != nil {
+			return "", err
+		}
+		ancestor = next
+	}
+
+	runParameters, err := RunParameters(sub.PipelineID, task.EnvironmentID, sub.RunType, sub.Version, sub.Parameters)
+	if err != nil {
+		return "", err
+	}
+
+	options := RunOptions{
+		Parameters:       runParameters,
+		ParentRunID:      task.RunID,
+		ParentTaskID:     task.TaskID,
+		ParentPipelineID: task.PipelineID,
+		Depth:            parent.Depth + 1,
+	}
+
+	// The child run sees where it was started from as its trigger payload
+	payload, _ := json.Marshal(map[string]interface{}{
+		"parent_run_id":      task.RunID,
+		"parent_pipeline_id": task.PipelineID,
+		"parent_node_id":     task.NodeID,
+	})
+
+	childRunID := uuid.NewString()
+
+	switch sub.RunType {
+	case "deployment":
+		version := sub.Version
+		if version == "" {
+			version = "latest"
+		}
+		versionJSON, _ := json.Marshal(map[string]string{"version": version})
+		_, err = RunDeployment(sub.PipelineID, task.EnvironmentID, childRunID, options, payload, versionJSON)
+	default:
+		_, err = RunPipeline(sub.PipelineID, task.EnvironmentID, childRunID, options, payload)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return childRunID, nil
+}
+
+/*
+SubPipelineComplete passes the status of a finished child run to the sub-pipeline task that started it.
+*/
+func SubPipelineComplete(runID string) {
+
+	var run models.PipelineRuns
+	err := database.DBConn.Select("run_id", "status", "reason", "parent_task_id").Where("run_id = ?", runID).First(&run).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	if run.ParentTaskID == "" {
+		return
+	}
+
+	switch run.Status {
+	case "Success":
+		SubPipelineFinish(run.ParentTaskID, "Success", "")
+	case "Fail":
+		reason := "Sub-pipeline run failed: " + run.RunID
+		if run.Reason != "" {
+			reason += " - " + run.Reason
+		}
+		SubPipelineFinish(run.ParentTaskID, "Fail", reason)
+	}
+}
+
+/*
+SubPipelineFinish finishes a running sub-pipeline task and moves on to its destinations.
+A failed task can be retried by its retry policy.
+*/
+func SubPipelineFinish(taskID string, status string, reason string) {
+
+	exitCode := 0
+	if status == "Fail" {
+		exitCode = 1
+	}
+
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", taskID, "Run").Updates(map[string]interface{}{
+		"status":    status,
+		"reason":    reason,
+		"exit_code": exitCode,
+		"end_dt":    time.Now().UTC(),
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return
+	}
+
+	// Already finished e.g. timed out or stopped
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	var task models.WorkerTasks
+	err := database.DBConn.Where("task_id = ?", taskID).First(&task).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	errnat := messageq.MsgSend("taskupdate."+task.EnvironmentID+"."+task.RunID, task)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	RunNext(models.WorkerTaskSend{
+		TaskID:        task.TaskID,
+		CreatedAt:     task.CreatedAt,
+		EnvironmentID: task.EnvironmentID,
+		PipelineID:    task.PipelineID,
+		RunID:         task.RunID,
+		NodeID:        task.NodeID,
+	})
+}
+
+/*
+SubPipelineStop cancels a running sub-pipeline task and stops its child runs.
+*/
+func SubPipelineStop(task models.WorkerTasks, reason string) {
+
+	err := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", task.TaskID, "Run").Updates(map[string]interface{}{
+		"status": "Fail",
+		"reason": "cancel",
+		"end_dt": time.Now().UTC(),
+	}).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+	}
+
+	var children []models.PipelineRuns
+	err = database.DBConn.Select("run_id", "environment_id").Where("parent_task_id = ? and status in (?)", task.TaskID, []string{"Queued", "Running", "Paused"}).Find(&children).Error
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return
+	}
+
+	for _, c := range children {
+		_, err := RunStop(c.RunID, c.EnvironmentID, reason)
+		if err != nil {
+			logging.PrintSecretsRedact("Stop sub-pipeline run:", c.RunID, err)
+		}
+	}
+}
+
+/*
+SubPipelineWatch keeps sub-pipeline tasks and their child runs in step when a completion was missed on another replica:
+tasks whose child run has finished are finished and child runs whose task is no longer running, e.g. timed out, are stopped.
+Only the leader checks.
+*/
+func SubPipelineWatch(s *gocron.Scheduler) {
+
+	s.Every(10).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		running := database.DBConn.Model(&models.WorkerTasks{}).Select("task_id").Where("worker_type = ? and status = ?", "subpipeline", "Run")
+
+		var finished []models.PipelineRuns
+		err := database.DBConn.Select("run_id").Where("status in (?) and parent_task_id in (?)", []string{"Success", "Fail"}, running).Find(&finished).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		for _, run := range finished {
+			SubPipelineComplete(run.RunID)
+		}
+
+		var orphans []models.PipelineRuns
+		err = database.DBConn.Select("run_id", "environment_id").Where("status in (?) and parent_task_id <> ? and parent_task_id not in (?)", []string{"Queued", "Running", "Paused"}, "", running).Find(&orphans).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		for _, run := range orphans {
+			_, err := RunStop(run.RunID, run.EnvironmentID, "Parent task stopped")
+			if err != nil {
+				logging.PrintSecretsRedact("Stop sub-pipeline run:", run.RunID, err)
+			}
+		}
+	})
+}
+
+/*
+SubPipelineCycleCheck checks that the pipelines run by the sub-pipeline nodes of a pipeline or deployment don't lead back to it.
+The saved references of the other pipelines and active deployments in the environment are used.
+*/
+func SubPipelineCycleCheck(pipelineID string, environmentID string, references []string) error {
+
+	graph := make(map[string][]string)
+
+	var nodes []models.PipelineNodes
+	err := database.DBConn.Select("pipeline_id", "sub_pipeline_id").Where("environment_id = ? and node_type_desc = ? and pipeline_id <> ?", environmentID, "subpipeline", pipelineID).Find(&nodes).Error
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		graph[n.PipelineID] = append(graph[n.PipelineID], n.SubPipeline.PipelineID)
+	}
+
+	var deployNodes []models.DeployPipelineNodes
+	err = database.DBConn.Select("deploy_pipeline_nodes.pipeline_id", "deploy_pipeline_nodes.sub_pipeline_id").
+		Joins("join deploy_pipelines on deploy_pipelines.pipeline_id = deploy_pipeline_nodes.pipeline_id and deploy_pipelines.version = deploy_pipeline_nodes.version and deploy_pipelines.environment_id = deploy_pipeline_nodes.environment_id").
+		Where("deploy_pipeline_nodes.environment_id = ? and deploy_pipeline_nodes.node_type_desc = ? and deploy_pipeline_nodes.pipeline_id <> ? and deploy_pipelines.deploy_active = ?", environmentID, "subpipeline", pipelineID, true).
+		Find(&deployNodes).Error
+	if err != nil {
+		return err
+	}
+
+	for _, n := range deployNodes {
+		graph[n.PipelineID] = append(graph[n.PipelineID], n.SubPipeline.PipelineID)
+	}
+
+	graph[pipelineID] = references
+
+	return utilities.PipelineReferenceCycleCheck(graph, pipelineID)
+}
+
+/*
+SubPipelineValidate checks the pipeline or deployment a sub-pipeline node runs exists in the environment and takes the parameter values.
+*/
+func SubPipelineValidate(pipelineID string, environmentID string, sub models.SubPipeline) error {
+
+	if sub.PipelineID == "" {
+		return errors.New("Sub-pipeline requires a pipeline")
+	}
+
+	if sub.PipelineID == pipelineID {
+		return errors.New("Sub-pipeline can't run its own pipeline")
+	}
+
+	switch sub.RunType {
+	case "pipeline", "deployment":
+	default:
+		return errors.New("Sub-pipeline run type must be pipeline or deployment")
+	}
+
+	_, err := RunParameters(sub.PipelineID, environmentID, sub.RunType, sub.Version, sub.Parameters)
+	if err != nil {
+		return errors.New("Sub-pipeline " + err.Error())
+	}
+
+	return nil
+}
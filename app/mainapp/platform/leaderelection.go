@@ -6,15 +6,19 @@ import (
 	"time"
 
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
-	"github.com/dataplane-app/dataplane/app/mainapp/database"
-	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/leaderelection"
 	"github.com/dataplane-app/dataplane/app/mainapp/scheduler"
-	"github.com/go-redis/redis/v8"
 )
 
+/*
+PlatformLeaderElectionScheduler runs the leader election every second with the backend set by DP_LEADER_ELECTION.
+The leader loads the schedules and gives them up as soon as it loses the lease or can't reach the backend.
+*/
 func PlatformLeaderElectionScheduler(mainAppID string) {
 
-	ctx := context.Background()
+	backend := leaderelection.NewBackend()
+
+	log.Println("👑 Leader election:", dpconfig.LeaderElection)
 
 	ticker := time.NewTicker(1 * time.Second)
 	quit := make(chan struct{})
@@ -26,92 +30,53 @@ func PlatformLeaderElectionScheduler(mainAppID string) {
 
 			case <-ticker.C:
 
-				redisModel := models.LeaderElection{}
-				leaderID := ""
-
-				// log.Println("Leader ID:", dpconfig.Leader)
-				// https://github.com/bsm/redislock - considered but same
-				// Is there a leader?
-				if err := database.RedisConn.HGetAll(ctx, "leader").Scan(&redisModel); err != nil {
-					log.Println("Redis get leader error:", err)
-				}
+				ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 
 				/*
-					If leader is nil set the leader with a 5 second lease.
+					Take the lease with a 5 second lease if there is no leader or extend it if this node is the leader.
+					The fencing token goes up each time the leadership changes hands.
 				*/
-				if redisModel.NodeID == "" {
+				leaderID, token, err := backend.Elect(ctx, mainAppID, 5*time.Second)
+				cancel()
 
-					if dpconfig.SchedulerDebug == "true" {
-						log.Println("Scheduler: Elect leader.")
-					}
+				if err != nil {
+					log.Println("Leader election error:", err)
 
-					if _, err := database.RedisConn.Pipelined(ctx, func(rdb redis.Pipeliner) error {
-						rdb.HSet(ctx, "leader", "nodeid", mainAppID)
-						rdb.HSet(ctx, "leader", "timestamp", time.Now().UTC().Unix())
-						rdb.Expire(ctx, "leader", 5*time.Second)
-						leaderID = mainAppID
-						return nil
-					}); err != nil {
-						log.Println("Failed to elect leader for scheduler: ", err)
-						// panic("")
-					}
-
-					/*
-						After leader election load the schedules
-					*/
-					scheduler.LoadPipelineSchedules()
-					if dpconfig.Debug == "true" || dpconfig.SchedulerDebug == "true" {
-						log.Println("Schedules loaded.")
-					}
-
-				} else {
-					leaderID = redisModel.NodeID
-
-					/*
-						If this node is the leader extend the lease on key leader
-					*/
-					if leaderID == mainAppID {
-
-						if _, err := database.RedisConn.Pipelined(ctx, func(rdb redis.Pipeliner) error {
-							rdb.Expire(ctx, "leader", 5*time.Second)
-							rdb.HSet(ctx, "leader", "timestamp", time.Now().UTC().Unix())
-							return nil
-						}); err != nil {
-							log.Println("Failed to extend lease on leader election: ", err)
-							// panic("")
-						}
-					}
+					// Without the backend this node can't be sure it still leads
+					leaderID = ""
+					token = 0
 				}
 
-				/* Testing Leader election logs */
-
-				// tm := time.Unix(redisModel.Timestamp, 0)
-				// log.Println("Leader: ", redisModel.NodeID, tm, leaderID == mainAppID)
-				// for i, v := range dpconfig.PipelineScheduler.Keys() {
-
-				// if tmp, ok := dpconfig.PipelineScheduler.Get(v); ok {
-
-				// PipelineScheduler := tmp.(*gocron.Scheduler)
-				// log.Println("Scheduler:", i, v, PipelineScheduler.IsRunning(), PipelineScheduler.Len())
-				// }
-				// }
+				_, previousToken := leaderelection.Leader()
+				leaderelection.SetLeader(leaderID, token)
 
 				/*
 					Record the change in elected leader
 				*/
-				if dpconfig.Leader != leaderID {
+				if dpconfig.Leader != leaderID || (leaderID == mainAppID && previousToken != token) {
 					if dpconfig.SchedulerDebug == "true" {
-						log.Println("Changed elected leader: ", dpconfig.Leader, "->", leaderID)
+						log.Println("Changed elected leader: ", dpconfig.Leader, "->", leaderID, "token:", token)
 					}
 
-					/*
-						If I am no longer the leader, give up the schedules
-					*/
-					if mainAppID != leaderID {
+					wasLeader := dpconfig.Leader == mainAppID
+					dpconfig.Leader = leaderID
+
+					switch {
+					case leaderID == mainAppID && !wasLeader:
+						/*
+							After leader election load the schedules
+						*/
+						scheduler.LoadPipelineSchedules()
+						if dpconfig.Debug == "true" || dpconfig.SchedulerDebug == "true" {
+							log.Println("Schedules loaded.")
+						}
+
+					case leaderID != mainAppID && wasLeader:
+						/*
+							If I am no longer the leader, give up the schedules
+						*/
 						scheduler.RemovePipelineSchedules()
 					}
-
-					dpconfig.Leader = leaderID
 				}
 
 			case <-quit:
@@ -120,8 +85,4 @@ func PlatformLeaderElectionScheduler(mainAppID string) {
 			}
 		}
 	}()
-
-	// s.Every(1000).Milliseconds().Do(func() {
-
-	// })
 }
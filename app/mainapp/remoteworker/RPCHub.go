@@ -4,6 +4,7 @@ import (
 	"log"
 
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
 	"github.com/gofiber/websocket/v2"
 	cmap "github.com/orcaman/concurrent-map"
 )
@@ -41,6 +42,7 @@ func RPCHub() {
 			/* Does this connection already exist? */
 
 			WorkerIDClients.Set(connection.WorkerID, connection.Conn)
+			metrics.RemoteWorkerConnections.Set(float64(WorkerIDClients.Count()))
 
 			// log.Println(connection.Conn)
 
@@ -76,6 +78,7 @@ func RPCHub() {
 
 				WorkerIDClients.Remove(connection.WorkerID)
 			}
+			metrics.RemoteWorkerConnections.Set(float64(WorkerIDClients.Count()))
 			if dpconfig.MQDebug == "true" {
 				log.Println("connection " + connection.WorkerID + " unregistered.")
 			}
@@ -256,19 +256,24 @@ func RPCServer(conn *websocket.Conn, remoteWorkerID string) {
 
 				switch logmsg {
 
-				/* Successful run move to next */
-				case "Success":
+				/* Finished run move to next, run next retries or follows the failure through the graph */
+				case "Success", "Fail":
 					msg.Status = logmsg
 
-					/* Update the database with the task */
+					/* Update the database with the task, only while it is running - the task watchdog can have failed it already */
 					err2 := database.DBConn.Clauses(clause.OnConflict{
 						Columns:   []clause.Column{{Name: "task_id"}},
 						DoUpdates: clause.AssignmentColumns([]string{"end_dt", "status", "reason"}),
+						Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "worker_tasks", Name: "status"}, Value: "Run"}}},
 					}).Create(&msg)
 					if err2.Error != nil {
 						logging.PrintSecretsRedact(err2.Error.Error())
 					}
 
+					if err2.Error != nil || err2.RowsAffected == 0 {
+						break
+					}
+
 					// log.Println("action:", msg.Status)
 
 					RunNext := models.WorkerPipelineNext{
@@ -287,30 +292,6 @@ func RPCServer(conn *websocket.Conn, remoteWorkerID string) {
 						log.Println("Remote worker nats error send next step: ", errnat)
 					}
 
-					/* Failed run, mark all future as failed */
-				case "Fail":
-					msg.Status = logmsg
-
-					// Update pipeline as failed
-					run := models.PipelineRuns{
-						RunID:   runID,
-						Status:  "Fail",
-						EndedAt: time.Now().UTC(),
-					}
-
-					err2 := database.DBConn.Clauses(clause.OnConflict{
-						Columns:   []clause.Column{{Name: "run_id"}},
-						DoUpdates: clause.AssignmentColumns([]string{"ended_at", "status"}),
-					}).Create(&run)
-					if err2.Error != nil {
-						log.Println(err2.Error.Error())
-					}
-
-					// Update all the future tasks
-					err3 := database.DBConn.Model(&models.WorkerTasks{}).Where("run_id = ? and status=? and environment_id=?", msg.RunID, "Queue", envID).Updates(map[string]interface{}{"status": "Fail", "reason": "Upstream fail"}).Error
-					if err3 != nil {
-						log.Println(err3.Error())
-					}
 				}
 
 				if msg.Status == "Success" || msg.Status == "Fail" {
@@ -5,6 +5,7 @@ import (
 
 	distributefilesystem "github.com/dataplane-app/dataplane/app/mainapp/code_editor/distribute_filesystem"
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	"gorm.io/gorm"
 )
@@ -19,7 +20,9 @@ func DeployCompressFiles(db *gorm.DB, nodeID string, environmentID string, versi
 			cf.folder_id, 
 			cf.file_name, 
 			cs.checksum_md5,
-			cs.file_store
+			cs.file_store,
+			cs.external,
+			cs.object_key
 			from deploy_code_files cf, deploy_files_store cs
 			where 
 			cf.file_id = cs.file_id 
@@ -48,6 +51,15 @@ func DeployCompressFiles(db *gorm.DB, nodeID string, environmentID string, versi
 		/* prefix folder with run type */
 		FilesOutput[i].FolderPath = newdir
 
+		/* Remote workers are sent the content, even of files in object storage */
+		if file.External {
+			FilesOutput[i].FileStore, err = objectstorage.Get(file.ObjectKey)
+			if err != nil {
+				log.Println(err)
+				return nil, 0, err
+			}
+		}
+
 	}
 
 	output, filesize, err := distributefilesystem.CompressTarS2(FilesOutput)
@@ -0,0 +1,144 @@
+package routes
+
+import (
+	"bytes"
+	"html/template"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/auth"
+	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
+	"github.com/gofiber/fiber/v2"
+)
+
+var approvalPage = template.Must(template.New("approval").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>Dataplane approval</title></head>
+<body style="font-family: sans-serif; max-width: 40em; margin: 2em auto;">
+<h2>Approval</h2>
+<p>Pipeline: {{.Request.PipelineID}}<br>Run: {{.Request.RunID}}<br>Node: {{.Request.NodeID}}</p>
+{{if .Request.Message}}<p>{{.Request.Message}}</p>{{end}}
+{{if eq .Request.Status "Waiting"}}
+<form method="post">
+<p><textarea name="comment" rows="3" style="width: 100%;" placeholder="Comment"></textarea></p>
+<button type="submit" name="decision" value="approve">Approve</button>
+<button type="submit" name="decision" value="reject">Reject</button>
+</form>
+{{else}}
+<p>{{.Request.Status}}{{if .Request.DecidedVia}} ({{.Request.DecidedVia}}){{end}}{{if .Request.Comment}}: {{.Request.Comment}}{{end}}</p>
+{{end}}
+{{if .Error}}<p style="color: #c00;">{{.Error}}</p>{{end}}
+</body>
+</html>
+`))
+
+/*
+ApprovalLinkHandler shows a waiting approval to the holder of a signed approval link, see getApprovalLink.
+Deciding needs a POST so that link previews and scanners can't approve or reject.
+*/
+func ApprovalLinkHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		request, _, ferr := approvalLinkRequest(c)
+		if ferr != nil {
+			return c.Status(ferr.Code).SendString(ferr.Message)
+		}
+
+		return approvalLinkPage(c, request, "")
+	}
+}
+
+/*
+ApprovalLinkDecideHandler approves or rejects a waiting approval for the user the signed approval link was made for.
+The user must still be active and allowed to approve runs in the environment.
+*/
+func ApprovalLinkDecideHandler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+
+		request, userID, ferr := approvalLinkRequest(c)
+		if ferr != nil {
+			return c.Status(ferr.Code).SendString(ferr.Message)
+		}
+
+		var user models.Users
+		err := database.DBConn.Select("user_id", "active").Where("user_id = ?", userID).First(&user).Error
+		if err != nil || !user.Active {
+			return c.Status(fiber.StatusForbidden).SendString("Requires permissions.")
+		}
+
+		// ----- Permissions
+		perms := []models.Permissions{
+			{Subject: "user", SubjectID: userID, Resource: "admin_platform", ResourceID: dpconfig.PlatformID, Access: "write", EnvironmentID: "d_platform"},
+			{Subject: "user", SubjectID: userID, Resource: "admin_environment", ResourceID: request.EnvironmentID, Access: "write", EnvironmentID: request.EnvironmentID},
+			{Subject: "user", SubjectID: userID, Resource: "environment_approve_runs", ResourceID: request.EnvironmentID, Access: "write", EnvironmentID: request.EnvironmentID},
+		}
+
+		permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+		if permOutcome == "denied" {
+			return c.Status(fiber.StatusForbidden).SendString("Requires permissions.")
+		}
+
+		var approve bool
+		switch c.FormValue("decision") {
+		case "approve":
+			approve = true
+		case "reject":
+			approve = false
+		default:
+			return approvalLinkPage(c.Status(fiber.StatusBadRequest), request, "Decision must be approve or reject.")
+		}
+
+		decided, err := pipelines.ApprovalDecide(request.TaskID, approve, userID, "link", c.FormValue("comment"))
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact(err)
+			}
+			if decided.TaskID == "" {
+				decided = request
+			}
+			return approvalLinkPage(c.Status(fiber.StatusConflict), decided, err.Error())
+		}
+
+		return approvalLinkPage(c, decided, "")
+	}
+}
+
+/*
+approvalLinkRequest returns the approval and user of the signed approval link in the request.
+*/
+func approvalLinkRequest(c *fiber.Ctx) (models.ApprovalRequests, string, *fiber.Error) {
+
+	taskID, userID, err := pipelines.ApprovalTokenVerify(auth.JwtKey, c.Params("token"), time.Now().UTC())
+	if err != nil {
+		return models.ApprovalRequests{}, "", fiber.NewError(fiber.StatusForbidden, "Approval link: "+err.Error())
+	}
+
+	var request models.ApprovalRequests
+	err = database.DBConn.Where("task_id = ?", taskID).First(&request).Error
+	if err != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(err)
+		}
+		return models.ApprovalRequests{}, "", fiber.NewError(fiber.StatusNotFound, "Approval not found.")
+	}
+
+	return request, userID, nil
+}
+
+func approvalLinkPage(c *fiber.Ctx, request models.ApprovalRequests, errorMessage string) error {
+
+	var page bytes.Buffer
+	err := approvalPage.Execute(&page, map[string]interface{}{"Request": request, "Error": errorMessage})
+	if err != nil {
+		logging.PrintSecretsRedact(err)
+		return c.Status(fiber.StatusInternalServerError).SendString("Approval page error.")
+	}
+
+	c.Type("html")
+	return c.Send(page.Bytes())
+}
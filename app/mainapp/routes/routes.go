@@ -1,6 +1,8 @@
 package routes
 
 import (
+	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -14,18 +16,24 @@ import (
 	permissions "github.com/dataplane-app/dataplane/app/mainapp/auth_permissions"
 	distributefilesystem "github.com/dataplane-app/dataplane/app/mainapp/code_editor/distribute_filesystem"
 	"github.com/dataplane-app/dataplane/app/mainapp/code_editor/filesystem"
+	gitsync "github.com/dataplane-app/dataplane/app/mainapp/code_editor/git_sync"
+	objectstorage "github.com/dataplane-app/dataplane/app/mainapp/code_editor/object_storage"
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/migrations"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
+	"github.com/dataplane-app/dataplane/app/mainapp/notifications"
 	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
 	"github.com/dataplane-app/dataplane/app/mainapp/platform"
 	"github.com/dataplane-app/dataplane/app/mainapp/remoteworker"
 	"github.com/dataplane-app/dataplane/app/mainapp/remoteworker_processgroup"
 	"github.com/dataplane-app/dataplane/app/mainapp/scheduler"
 	"github.com/dataplane-app/dataplane/app/mainapp/scheduler/routinetasks"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
+	"github.com/dataplane-app/dataplane/app/mainapp/logme"
 	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
 	wsockets "github.com/dataplane-app/dataplane/app/mainapp/websockets"
 	"github.com/dataplane-app/dataplane/app/mainapp/worker"
@@ -57,6 +65,9 @@ func Setup(port string) *fiber.App {
 
 	dpconfig.LoadConfig()
 
+	// ------ Tracing, exported if OTEL_EXPORTER_OTLP_ENDPOINT is set ----
+	tracing.Init("dataplane-mainapp")
+
 	// ------ Logging ----
 	log.Println("🪵  Logging: Debug=", dpconfig.Debug, " | MQ Debug=", dpconfig.MQDebug)
 
@@ -206,6 +217,22 @@ func Setup(port string) *fiber.App {
 	}
 	log.Println("🎯 Platform ID: ", dpconfig.PlatformID)
 
+	/* Code files in object storage, files still in the database are moved across in the background */
+	if dpconfig.FSCodeFileStorage == "S3" {
+		err := objectstorage.Connect()
+		if err != nil {
+			panic("Code file object storage failed: " + err.Error())
+		}
+		log.Println("🪣 Code files in object storage:", dpconfig.FSCodeS3Endpoint+"/"+dpconfig.FSCodeS3Bucket)
+
+		go func() {
+			err := objectstorage.MoveFilesToObjectStorage(database.DBConn)
+			if err != nil {
+				log.Println("Move files to object storage:", err)
+			}
+		}()
+	}
+
 	/* Load code files if no distributed storage method is defined in platform table.
 	Even if a file storage method is used, files will be stored in a database.
 	*/
@@ -283,9 +310,9 @@ func Setup(port string) *fiber.App {
 	app.Static("/webapp/*", "./frontbuild/index.html")
 
 	// ------- GRAPHQL------
-	app.Post("/app/public/graphql", PublicGraphqlHandler())
-	app.Post("/app/private/graphql", auth.TokenAuthMiddle(), PrivateGraphqlHandler())
-	app.Post("/app/desktop/graphql", auth.DesktopAuthMiddle(), DesktopGraphqlHandler())
+	app.Post("/app/public/graphql", metrics.GraphQLTimer("public"), PublicGraphqlHandler())
+	app.Post("/app/private/graphql", auth.TokenAuthMiddle(), metrics.GraphQLTimer("private"), PrivateGraphqlHandler())
+	app.Post("/app/desktop/graphql", auth.DesktopAuthMiddle(), metrics.GraphQLTimer("desktop"), DesktopGraphqlHandler())
 
 	// WARNING: This is insecure and only for documentation, do not enable in production
 	if os.Getenv("DP_GRAPHQLDOCS") == "true" {
@@ -457,12 +484,18 @@ func Setup(port string) *fiber.App {
 
 		// dat, err := os.ReadFile(dpconfig.CodeDirectory + filepath)
 		dataFile := models.CodeFilesStore{}
-		err := database.DBConn.Select("file_store").Where("file_id = ? and environment_id = ?", fileID, environmentID).First(&dataFile).Error
+		err := database.DBConn.Select("file_store, external, object_key").Where("file_id = ? and environment_id = ?", fileID, environmentID).First(&dataFile).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return c.Status(http.StatusBadRequest).SendString("Failed to download file.")
+		}
+
+		content, err := objectstorage.Content(dataFile.FileStore, dataFile.External, dataFile.ObjectKey)
 		if err != nil {
 			logging.PrintSecretsRedact(err)
 			return c.Status(http.StatusBadRequest).SendString("Failed to download file.")
 		}
-		return c.SendString(string(dataFile.FileStore))
+		return c.SendString(string(content))
 	})
 
 	// Upload code files
@@ -521,6 +554,13 @@ func Setup(port string) *fiber.App {
 			return errors.New("Failed to save file.")
 		}
 
+		err = filesystem.FileRevisionCreate(File, doc, currentUser, "save")
+		if err != nil {
+			log.Println("Save file revision:", err)
+		}
+
+		gitsync.CommitChanges(pipelineID, environmentID, currentUser, "Save "+file.Filename)
+
 		// f := models.CodeFiles{}
 		// err = database.DBConn.Where("file_name = ? and folder_id = ? and environment_id = ?", file.Filename, folderID, environmentID).Find(&f).Error
 		// if err != nil {
@@ -533,6 +573,126 @@ func Setup(port string) *fiber.App {
 		return c.SendString(File.FileID)
 	})
 
+	// Download run logs, streamed as plain text or NDJSON (format=ndjson)
+	app.Get("/app/private/logs/download/:runID", auth.TokenAuthMiddle(), func(c *fiber.Ctx) error {
+
+		environmentID := string(c.Query("environment_id"))
+		pipelineID := string(c.Query("pipeline_id"))
+		nodeID := string(c.Query("node_id"))
+		format := string(c.Query("format", "text"))
+		search := string(c.Query("search"))
+
+		currentUser := c.Locals("currentUser").(string)
+		platformID := c.Locals("platformID").(string)
+
+		// ----- Permissions
+		perms := []models.Permissions{
+			{Subject: "user", SubjectID: currentUser, Resource: "admin_platform", ResourceID: platformID, Access: "write", EnvironmentID: "d_platform"},
+			{Subject: "user", SubjectID: currentUser, Resource: "admin_environment", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "environment_run_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "run", EnvironmentID: environmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "view", EnvironmentID: environmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "specific_pipeline", ResourceID: pipelineID, Access: "edit", EnvironmentID: environmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "environment_edit_all_pipelines", ResourceID: environmentID, Access: "write", EnvironmentID: environmentID},
+			{Subject: "user", SubjectID: currentUser, Resource: "environment_all_pipelines", ResourceID: environmentID, Access: "read", EnvironmentID: environmentID},
+		}
+
+		permOutcome, _, _, _ := permissions.MultiplePermissionChecks(perms)
+
+		if permOutcome == "denied" {
+			return c.Status(fiber.StatusForbidden).SendString("Requires permissions.")
+		}
+
+		if format != "text" && format != "ndjson" {
+			return c.Status(http.StatusBadRequest).SendString("Format must be text or ndjson.")
+		}
+
+		var levelsParam []string
+		if c.Query("levels") != "" {
+			levelsParam = strings.Split(c.Query("levels"), ",")
+		}
+		levels, err := logme.LogLevelsFilter(levelsParam)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString(err.Error())
+		}
+
+		// ----- The run has to belong to the pipeline the permissions were checked for
+		runID := string(c.Params("runID"))
+
+		run := models.PipelineRuns{}
+		err = database.DBConn.Select("run_id").Where("run_id = ? and pipeline_id = ? and environment_id = ?", runID, pipelineID, environmentID).First(&run).Error
+		if err != nil {
+			return c.Status(http.StatusBadRequest).SendString("Run not found.")
+		}
+
+		query := database.DBConn.Model(&models.LogsWorkers{}).Where("environment_id = ? and run_id = ?", environmentID, runID)
+		if nodeID != "" {
+			query = query.Where("node_id = ?", nodeID)
+		}
+		if len(levels) > 0 {
+			query = query.Where("log_type in (?)", levels)
+		}
+		if search != "" {
+			query = query.Where("to_tsvector('simple', log) @@ plainto_tsquery('simple', ?)", search)
+		}
+
+		rows, err := query.Order("created_at asc, uid asc").Rows()
+		if err != nil {
+			logging.PrintSecretsRedact("Logs download:", err)
+			return c.Status(http.StatusBadRequest).SendString("Failed to download logs.")
+		}
+
+		filename := "run-" + runID
+		if format == "ndjson" {
+			c.Set("Content-Type", "application/x-ndjson")
+			filename += ".ndjson"
+		} else {
+			c.Set("Content-Type", "text/plain; charset=utf-8")
+			filename += ".log"
+		}
+		c.Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+		// Rows are written as they are read so large logs are never held in memory
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer rows.Close()
+
+			enc := json.NewEncoder(w)
+			count := 0
+
+			for rows.Next() {
+				var line models.LogsWorkers
+				if err := database.DBConn.ScanRows(rows, &line); err != nil {
+					logging.PrintSecretsRedact("Logs download scan:", err)
+					return
+				}
+
+				if format == "ndjson" {
+					err = enc.Encode(line)
+				} else {
+					_, err = w.WriteString(logme.LogLine(line.CreatedAt, line.LogType, line.Log))
+				}
+				if err != nil {
+					return
+				}
+
+				count++
+				if count%1000 == 0 {
+					if err := w.Flush(); err != nil {
+						return
+					}
+				}
+			}
+
+			w.Flush()
+		})
+
+		return nil
+	})
+
+	// Approve or reject a waiting approval with a signed link, see getApprovalLink
+	app.Get("/app/approval/:token", ApprovalLinkHandler())
+	app.Post("/app/approval/:token", ApprovalLinkDecideHandler())
+
 	// Pipeline API Trigger public
 	app.Post("/publicapi/api-trigger/:id", auth.ApiAuthMiddle("public"), func(c *fiber.Ctx) error {
 		c.Accepts("application/json")
@@ -541,9 +701,18 @@ func Setup(port string) *fiber.App {
 
 		var jsonPayload datatypes.JSON = c.Body()
 
+		// Run parameters are submitted in the query string
+		parameters, err := pipelines.RunParametersText(pipelineID, environmentID, "pipeline", "", apiTriggerParameters(c))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
+
 		// Run pipeline
 		runID := uuid.NewString()
-		pipelines.RunPipeline(pipelineID, environmentID, runID, jsonPayload)
+		_, err = pipelines.RunPipeline(pipelineID, environmentID, runID, pipelines.RunOptions{Parameters: parameters}, jsonPayload)
+		if err == pipelines.ErrRunSkipped {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{"runID": runID, "Data Platform": "Dataplane"})
 	})
@@ -556,9 +725,18 @@ func Setup(port string) *fiber.App {
 
 		var jsonPayload datatypes.JSON = c.Body()
 
+		// Run parameters are submitted in the query string
+		parameters, err := pipelines.RunParametersText(pipelineID, environmentID, "pipeline", "", apiTriggerParameters(c))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
+
 		// Run pipeline
 		runID := uuid.NewString()
-		pipelines.RunPipeline(pipelineID, environmentID, runID, jsonPayload)
+		_, err = pipelines.RunPipeline(pipelineID, environmentID, runID, pipelines.RunOptions{Parameters: parameters}, jsonPayload)
+		if err == pipelines.ErrRunSkipped {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{"runID": runID, "Data Platform": "Dataplane"})
 	})
@@ -573,9 +751,18 @@ func Setup(port string) *fiber.App {
 		var jsonPayload datatypes.JSON = c.Body()
 		var jsonVersion datatypes.JSON = []byte(fmt.Sprintf(`{"version":"%s"}`, strings.Trim(version, "v")))
 
+		// Run parameters are submitted in the query string
+		parameters, err := pipelines.RunParametersText(pipelineID, environmentID, "deployment", strings.Trim(version, "v"), apiTriggerParameters(c))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
+
 		// Run deployment
 		runID := uuid.NewString()
-		pipelines.RunDeployment(pipelineID, environmentID, runID, jsonPayload, jsonVersion)
+		_, err = pipelines.RunDeployment(pipelineID, environmentID, runID, pipelines.RunOptions{Parameters: parameters}, jsonPayload, jsonVersion)
+		if err == pipelines.ErrRunSkipped {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{"runID": runID, "Data Platform": "Dataplane"})
 	})
@@ -590,9 +777,18 @@ func Setup(port string) *fiber.App {
 		var jsonPayload datatypes.JSON = c.Body()
 		var jsonVersion datatypes.JSON = []byte(fmt.Sprintf(`{"version":"%s"}`, strings.Trim(version, "v")))
 
+		// Run parameters are submitted in the query string
+		parameters, err := pipelines.RunParametersText(pipelineID, environmentID, "deployment", strings.Trim(version, "v"), apiTriggerParameters(c))
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
+
 		// Run deployment
 		runID := uuid.NewString()
-		pipelines.RunDeployment(pipelineID, environmentID, runID, jsonPayload, jsonVersion)
+		_, err = pipelines.RunDeployment(pipelineID, environmentID, runID, pipelines.RunOptions{Parameters: parameters}, jsonPayload, jsonVersion)
+		if err == pipelines.ErrRunSkipped {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"r": "error", "msg": err.Error()})
+		}
 
 		return c.Status(http.StatusOK).JSON(fiber.Map{"runID": runID, "Data Platform": "Dataplane"})
 	})
@@ -608,8 +804,19 @@ func Setup(port string) *fiber.App {
 	// worker.LoadWorkers(MainAppID)
 	worker.WorkerListen()
 	worker.WorkerRemovalListen(dpconfig.Scheduler, database.DBConn)
+	worker.WorkerTaskWatchdog(dpconfig.Scheduler, database.DBConn)
+	pipelines.RunQueueWatch(dpconfig.Scheduler)
+	pipelines.RetryWatch(dpconfig.Scheduler)
 	pipelines.RunNextPipeline()
+	if dpconfig.FSCodeFileStorage == "S3" {
+		objectstorage.PresignListen()
+	}
 	scheduler.PipelineSchedulerListen()
+	pipelines.EventTriggersListen()
+	pipelines.EventTriggersCompleteWatch(dpconfig.Scheduler)
+	pipelines.SubPipelineWatch(dpconfig.Scheduler)
+	pipelines.ApprovalWatch(dpconfig.Scheduler)
+	notifications.NotificationWatch(dpconfig.Scheduler)
 
 	// Electing a leader by listening for running nodes
 	log.Println("👷 Queue and worker subscriptions")
@@ -617,6 +824,9 @@ func Setup(port string) *fiber.App {
 	/* Scheduled tasks */
 	routinetasks.CleanTaskLocks(dpconfig.Scheduler, database.DBConn)
 	routinetasks.CleanTasks(dpconfig.Scheduler, database.DBConn)
+	routinetasks.CleanScheduleHistory(dpconfig.Scheduler, database.DBConn)
+	routinetasks.CleanEventTriggerEvents(dpconfig.Scheduler, database.DBConn)
+	routinetasks.CleanCodeFileRevisions(dpconfig.Scheduler, database.DBConn)
 	routinetasks.CleanWorkerLogs(dpconfig.Scheduler, database.DBConn)
 	platform.PlatformLeaderElectionScheduler(MainAppID)
 
@@ -625,6 +835,9 @@ func Setup(port string) *fiber.App {
 		return c.SendString("Hello 👋! Healthy 🍏")
 	})
 
+	// Prometheus metrics
+	app.Get("/metrics", metrics.Handler())
+
 	stop := time.Now()
 	// Do something with response
 	log.Println("🐆 Start time:", fmt.Sprintf("%f", float32(stop.Sub(start))/float32(time.Millisecond))+"ms")
@@ -667,3 +880,14 @@ func Timer() fiber.Handler {
 		return err
 	}
 }
+
+/*
+API trigger run parameters from the query string e.g. ?region=eu&limit=10, arguments not declared as parameters are ignored
+*/
+func apiTriggerParameters(c *fiber.Ctx) map[string]string {
+	parameters := make(map[string]string)
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		parameters[string(key)] = string(value)
+	})
+	return parameters
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/leaderelection"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/scheduler/firetimes"
+
+	"github.com/google/uuid"
+)
+
+// Most missed fire times looked through, a schedule down for longer than this is not caught up
+const catchUpScanMax = 100000
+
+/*
+CatchUpSchedule runs the fire times a schedule missed since it last fired, for schedules set to catch up.
+The latest DP_SCHEDULE_CATCHUP_MAX missed fire times are run, oldest first.
+Called before the schedule is loaded so that a new fire is not taken as the last one.
+*/
+func CatchUpSchedule(s models.Scheduler) {
+
+	if !s.CatchUp || !s.Online {
+		return
+	}
+
+	var last []models.SchedulerLastFire
+	err := database.DBConn.Where("node_id = ? and environment_id = ?", s.NodeID, s.EnvironmentID).Limit(1).Find(&last).Error
+	if err != nil {
+		logging.PrintSecretsRedact("Schedule catch up:", s.NodeID, err)
+		return
+	}
+
+	// The schedule record is recreated each time the pipeline is saved or turned on, nothing before that was missed
+	from := s.CreatedAt.UTC()
+	if len(last) > 0 && !last[0].FiredAt.Before(from) {
+		from = last[0].FiredAt.Add(time.Second)
+	}
+
+	now := time.Now().UTC()
+
+	missed, err := firetimes.ScheduleFireTimes(s.ScheduleType, s.Schedule, s.Timezone, from, now, catchUpScanMax)
+	if err != nil {
+		log.Println("Schedule catch up skipped:", s.NodeID, err)
+		return
+	}
+
+	if len(missed) == 0 {
+		return
+	}
+
+	if len(missed) > dpconfig.ScheduleCatchUpMax {
+		missed = missed[len(missed)-dpconfig.ScheduleCatchUpMax:]
+	}
+
+	if dpconfig.SchedulerDebug == "true" {
+		log.Println("Schedule catch up:", s.NodeID, len(missed), "missed runs from", missed[0])
+	}
+
+	ScheduleFired(s, missed[len(missed)-1])
+
+	go func() {
+		for _, fireAt := range missed {
+
+			// Stop if the leadership moves on part way through
+			err := leaderelection.FenceCheck()
+			if err != nil {
+				log.Println("Schedule catch up stopped, leadership fenced:", s.NodeID, err)
+				return
+			}
+
+			err = ScheduleRun(s, uuid.NewString(), fireAt, "catchup")
+			if err != nil {
+				logging.PrintSecretsRedact("Schedule catch up run:", s.NodeID, fireAt, err)
+			}
+		}
+	}()
+}
+
+/*
+BackfillSchedule runs a schedule for each of the given past fire times, oldest first.
+*/
+func BackfillSchedule(s models.Scheduler, fireTimes []time.Time, runIDs []string) {
+
+	for i, fireAt := range fireTimes {
+		err := ScheduleRun(s, runIDs[i], fireAt, "backfill")
+		if err != nil {
+			logging.PrintSecretsRedact("Schedule backfill run:", s.NodeID, fireAt, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package firetimes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+/*
+ScheduleBackfillMax is the most runs a single backfill can start.
+*/
+const ScheduleBackfillMax = 1000
+
+/*
+ScheduleParse parses a schedule the same way gocron does:
+cron - 5 field cron in the timezone of the schedule.
+cronseconds - 6 field cron with seconds, always UTC.
+*/
+func ScheduleParse(scheduleType string, schedule string, timezone string) (cron.Schedule, error) {
+
+	switch scheduleType {
+	case "cron":
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, errors.New("Schedule timezone invalid: " + timezone)
+		}
+		if !strings.HasPrefix(schedule, "CRON_TZ=") && !strings.HasPrefix(schedule, "TZ=") {
+			schedule = "CRON_TZ=" + timezone + " " + schedule
+		}
+		return cron.ParseStandard(schedule)
+
+	case "cronseconds":
+		p := cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+		return p.Parse("CRON_TZ=UTC " + schedule)
+	}
+
+	return nil, errors.New("Schedule type must be cron or cronseconds")
+}
+
+/*
+ScheduleFireTimes returns the times a schedule fires from and to, both inclusive.
+Returns an error if there are more than limit fire times.
+*/
+func ScheduleFireTimes(scheduleType string, schedule string, timezone string, from time.Time, to time.Time, limit int) ([]time.Time, error) {
+
+	sched, err := ScheduleParse(scheduleType, schedule, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	if to.Before(from) {
+		return nil, errors.New("Schedule date range ends before it starts")
+	}
+
+	var times []time.Time
+
+	// Next is always after the given time, step back so that a fire time on from is included
+	for t := sched.Next(from.Add(-time.Nanosecond)); !t.IsZero() && !t.After(to); t = sched.Next(t) {
+
+		if len(times) >= limit {
+			return nil, fmt.Errorf("Schedule fires more than %d times in the date range", limit)
+		}
+		times = append(times, t)
+	}
+
+	return times, nil
+}
+
+/*
+ScheduleNextFireTimes returns the next n times a schedule fires after the given time.
+*/
+func ScheduleNextFireTimes(scheduleType string, schedule string, timezone string, after time.Time, n int) ([]time.Time, error) {
+
+	sched, err := ScheduleParse(scheduleType, schedule, timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	times := []time.Time{}
+	for t := sched.Next(after); !t.IsZero() && len(times) < n; t = sched.Next(t) {
+		times = append(times, t)
+	}
+
+	return times, nil
+}
@@ -0,0 +1,83 @@
+package firetimes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestScheduleFireTimes$ github.com/dataplane-app/dataplane/app/mainapp/scheduler/firetimes
+*/
+func TestScheduleFireTimes(t *testing.T) {
+
+	from := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2022, 11, 3, 0, 0, 0, 0, time.UTC)
+
+	// Daily at midnight UTC, both ends of the range included
+	times, err := ScheduleFireTimes("cron", "0 0 * * *", "UTC", from, to, 10)
+	assert.NoError(t, err, "Daily UTC")
+	assert.Equal(t, 3, len(times), "Daily UTC count")
+	assert.True(t, times[0].Equal(from), "Daily UTC first")
+	assert.True(t, times[2].Equal(to), "Daily UTC last")
+
+	// Midnight in Sydney is 13:00 UTC the day before during daylight saving
+	times, err = ScheduleFireTimes("cron", "0 0 * * *", "Australia/Sydney", from, to, 10)
+	assert.NoError(t, err, "Daily Sydney")
+	assert.Equal(t, 2, len(times), "Daily Sydney count")
+	assert.True(t, times[0].Equal(time.Date(2022, 11, 1, 13, 0, 0, 0, time.UTC)), "Daily Sydney first")
+
+	// Cron with seconds is always UTC
+	times, err = ScheduleFireTimes("cronseconds", "*/20 * * * * *", "Australia/Sydney", from, from.Add(time.Minute), 10)
+	assert.NoError(t, err, "Seconds")
+	assert.Equal(t, 4, len(times), "Seconds count")
+
+	_, err = ScheduleFireTimes("cron", "* * * * *", "UTC", from, to, 10)
+	assert.Error(t, err, "Over the limit")
+
+	_, err = ScheduleFireTimes("cron", "0 0 * * *", "UTC", to, from, 10)
+	assert.Error(t, err, "Range ends before it starts")
+
+	_, err = ScheduleFireTimes("cron", "not a cron", "UTC", from, to, 10)
+	assert.Error(t, err, "Invalid cron")
+
+	_, err = ScheduleFireTimes("cron", "0 0 * * *", "Mars/Olympus", from, to, 10)
+	assert.Error(t, err, "Invalid timezone")
+
+	_, err = ScheduleFireTimes("interval", "0 0 * * *", "UTC", from, to, 10)
+	assert.Error(t, err, "Invalid schedule type")
+}
+
+/*
+go test -timeout 30s -v -run ^TestScheduleNextFireTimes$ github.com/dataplane-app/dataplane/app/mainapp/scheduler/firetimes
+*/
+func TestScheduleNextFireTimes(t *testing.T) {
+
+	after := time.Date(2022, 11, 1, 10, 30, 0, 0, time.UTC)
+
+	// A fire time on after is not included
+	times, err := ScheduleNextFireTimes("cron", "30 10 * * *", "UTC", after, 3)
+	assert.NoError(t, err, "Daily UTC")
+	assert.Equal(t, 3, len(times), "Daily UTC count")
+	assert.True(t, times[0].Equal(after.AddDate(0, 0, 1)), "Daily UTC first")
+
+	// 9am in New York is 13:00 UTC during daylight saving and 14:00 UTC after it ends on 6 November
+	times, err = ScheduleNextFireTimes("cron", "0 9 * * *", "America/New_York", time.Date(2022, 11, 5, 0, 0, 0, 0, time.UTC), 2)
+	assert.NoError(t, err, "Daily New York")
+	assert.True(t, times[0].Equal(time.Date(2022, 11, 5, 13, 0, 0, 0, time.UTC)), "Daily New York before daylight saving ends")
+	assert.True(t, times[1].Equal(time.Date(2022, 11, 6, 14, 0, 0, 0, time.UTC)), "Daily New York after daylight saving ends")
+
+	times, err = ScheduleNextFireTimes("cronseconds", "*/15 * * * * *", "UTC", after, 5)
+	assert.NoError(t, err, "Seconds")
+	assert.Equal(t, 5, len(times), "Seconds count")
+	assert.True(t, times[4].Equal(after.Add(75*time.Second)), "Seconds last")
+
+	// February 30th never comes
+	times, err = ScheduleNextFireTimes("cron", "0 0 30 2 *", "UTC", after, 5)
+	assert.NoError(t, err, "Never fires")
+	assert.Equal(t, 0, len(times), "Never fires count")
+
+	_, err = ScheduleNextFireTimes("cron", "not a cron", "UTC", after, 5)
+	assert.Error(t, err, "Invalid cron")
+}
@@ -29,6 +29,7 @@ func LoadPipelineSchedules() {
 
 	for _, s := range pipelineSchedules {
 
+		CatchUpSchedule(s)
 		LoadSingleSchedule(s)
 
 	}
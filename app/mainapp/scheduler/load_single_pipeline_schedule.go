@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
@@ -10,14 +11,18 @@ import (
 
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/leaderelection"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
 	"github.com/dataplane-app/dataplane/app/mainapp/pipelines"
 
 	"github.com/go-co-op/gocron"
 	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm/clause"
 )
 
-func mytask(nodeID string, pipelineID string, environmentID string, timezone string, runType string) {
+func mytask(nodeID string, pipelineID string, environmentID string, timezone string, runType string, parameters datatypes.JSON) {
 
 	ctx := context.Background()
 
@@ -25,21 +30,43 @@ func mytask(nodeID string, pipelineID string, environmentID string, timezone str
 		log.Println("Schedule run:", nodeID, timezone)
 	}
 
+	sch := models.Scheduler{
+		NodeID:        nodeID,
+		PipelineID:    pipelineID,
+		EnvironmentID: environmentID,
+		Timezone:      timezone,
+		RunType:       runType,
+		Parameters:    parameters,
+	}
+
+	fireAt := time.Now().UTC().Truncate(time.Second)
+
+	// A replica that lost the leadership may still have the schedule loaded
+	errfence := leaderelection.FenceCheck()
+	if errfence != nil {
+		log.Println("Schedule not run, leadership fenced:", nodeID, errfence)
+		ScheduleHistory(sch, fireAt, "schedule", "Fenced", "", errfence)
+		return
+	}
+
 	// Is there a lock on this run?
 	val, errlock := database.RedisConn.Get(ctx, environmentID+"-"+nodeID+"-sch-lock").Result()
 	if errlock != nil && errlock != redis.Nil {
 		log.Println("Scheduled lock error:", nodeID, errlock)
+		ScheduleHistory(sch, fireAt, "schedule", "Failed", "", errlock)
 		return
 	}
 
 	// If there is a lock then stop the run else create a lock for 1 second
 	if val == "l" {
 		log.Println("Lock already exists, scheduled lock could not be obtained", nodeID)
+		ScheduleHistory(sch, fireAt, "schedule", "Locked", "", nil)
 		return
 	} else {
 		_, err := database.RedisConn.SetNX(ctx, environmentID+"-"+nodeID+"-sch-lock", "l", 1*time.Second).Result()
 		if err != nil {
 			log.Println("Scheduled create lock error:", nodeID, err)
+			ScheduleHistory(sch, fireAt, "schedule", "Failed", "", err)
 			return
 		}
 	}
@@ -54,15 +81,12 @@ func mytask(nodeID string, pipelineID string, environmentID string, timezone str
 	// 	log.Println("Lock could not be obtained", nodeID, err2.Error.Error())
 	// 	return
 	// }
-	runID := uuid.NewString()
-	var err error
-	switch runType {
-	case "pipeline":
-		_, err = pipelines.RunPipeline(pipelineID, environmentID, runID)
-	case "deployment":
-		_, err = pipelines.RunDeployment(pipelineID, environmentID, runID)
-	default:
-		log.Println("Run type not provided in scheduler.")
+	ScheduleFired(sch, fireAt)
+
+	err := ScheduleRun(sch, uuid.NewString(), fireAt, "schedule")
+
+	if err == pipelines.ErrRunSkipped {
+		log.Println("Schedule run skipped, max concurrent runs reached:", nodeID)
 		return
 	}
 
@@ -74,6 +98,102 @@ func mytask(nodeID string, pipelineID string, environmentID string, timezone str
 
 }
 
+/*
+ScheduleRun starts a run of a schedule for the time it fired, passed to tasks as the logical date.
+The outcome is recorded in the schedule history with the trigger: schedule, catchup or backfill.
+*/
+func ScheduleRun(s models.Scheduler, runID string, fireAt time.Time, trigger string) (err error) {
+
+	defer func() {
+		switch {
+		case err == nil:
+			ScheduleHistory(s, fireAt, trigger, "Fired", runID, nil)
+		case err == pipelines.ErrRunSkipped:
+			ScheduleHistory(s, fireAt, trigger, "Skipped", "", err)
+		default:
+			ScheduleHistory(s, fireAt, trigger, "Failed", "", err)
+		}
+	}()
+
+	// Parameters are checked at each run as the pipeline parameters may have changed since the schedule was saved
+	runParameters, err := pipelines.RunParameters(s.PipelineID, s.EnvironmentID, s.RunType, "", s.Parameters)
+	if err != nil {
+		log.Println("Schedule run parameters error:", s.NodeID, err)
+		return err
+	}
+
+	options := pipelines.RunOptions{
+		Parameters:      runParameters,
+		LogicalDate:     &fireAt,
+		LogicalTimezone: s.Timezone,
+	}
+
+	switch s.RunType {
+	case "pipeline":
+		_, err = pipelines.RunPipeline(s.PipelineID, s.EnvironmentID, runID, options)
+	case "deployment":
+		_, err = pipelines.RunDeployment(s.PipelineID, s.EnvironmentID, runID, options)
+	default:
+		log.Println("Run type not provided in scheduler.")
+		return errors.New("Run type not provided in scheduler.")
+	}
+
+	return err
+}
+
+/*
+ScheduleHistory records a fire of a schedule:
+Fired - a run was started or queued.
+Locked - another fire of the schedule held the lock.
+Skipped - the pipeline was at its max concurrent runs.
+Fenced - the replica the schedule fired on is no longer the leader.
+Failed - the run could not be started.
+*/
+func ScheduleHistory(s models.Scheduler, fireAt time.Time, trigger string, status string, runID string, runErr error) {
+
+	history := models.SchedulerHistory{
+		ID:            uuid.NewString(),
+		NodeID:        s.NodeID,
+		EnvironmentID: s.EnvironmentID,
+		PipelineID:    s.PipelineID,
+		RunType:       s.RunType,
+		Trigger:       trigger,
+		FiredAt:       fireAt,
+		Status:        status,
+		RunID:         runID,
+		CreatedAt:     time.Now().UTC(),
+	}
+
+	if runErr != nil {
+		history.Error = runErr.Error()
+	}
+
+	metrics.SchedulerFires.WithLabelValues(s.EnvironmentID, s.RunType, trigger, status).Inc()
+
+	err := database.DBConn.Create(&history).Error
+	if err != nil {
+		log.Println("Schedule history error:", s.NodeID, err)
+	}
+}
+
+/*
+ScheduleFired records the last time a schedule fired for catch up.
+*/
+func ScheduleFired(s models.Scheduler, fireAt time.Time) {
+
+	err := database.DBConn.Clauses(clause.OnConflict{
+		UpdateAll: true,
+	}).Create(&models.SchedulerLastFire{
+		NodeID:        s.NodeID,
+		EnvironmentID: s.EnvironmentID,
+		PipelineID:    s.PipelineID,
+		FiredAt:       fireAt,
+	}).Error
+	if err != nil {
+		log.Println("Schedule last fire error:", s.NodeID, err)
+	}
+}
+
 func LoadSingleSchedule(s models.Scheduler) {
 
 	var PipelineScheduler *gocron.Scheduler
@@ -90,7 +210,7 @@ func LoadSingleSchedule(s models.Scheduler) {
 				PipelineScheduler = tmp.(*gocron.Scheduler)
 			}
 
-			PSJob, _ := PipelineScheduler.Cron(s.Schedule).Do(mytask, s.NodeID, s.PipelineID, s.EnvironmentID, s.Timezone, s.RunType)
+			PSJob, _ := PipelineScheduler.Cron(s.Schedule).Do(mytask, s.NodeID, s.PipelineID, s.EnvironmentID, s.Timezone, s.RunType, s.Parameters)
 			dpconfig.PipelineSchedulerJob.Set(s.NodeID, PSJob)
 		}
 	case "cronseconds":
@@ -103,7 +223,7 @@ func LoadSingleSchedule(s models.Scheduler) {
 				PipelineScheduler = tmp.(*gocron.Scheduler)
 			}
 
-			PSJob, _ := PipelineScheduler.CronWithSeconds(s.Schedule).Do(mytask, s.NodeID, s.PipelineID, s.EnvironmentID, "UTC", s.RunType)
+			PSJob, _ := PipelineScheduler.CronWithSeconds(s.Schedule).Do(mytask, s.NodeID, s.PipelineID, s.EnvironmentID, "UTC", s.RunType, s.Parameters)
 			dpconfig.PipelineSchedulerJob.Set(s.NodeID, PSJob)
 
 		}
@@ -0,0 +1,51 @@
+package routinetasks
+
+import (
+	"log"
+	"strconv"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/go-co-op/gocron"
+	"gorm.io/gorm"
+)
+
+/*
+CleanCodeFileRevisions keeps at most DP_CLEANREVISIONS_MAX revisions per file and removes revisions older than DP_CLEANREVISIONS_DAYS.
+The latest revision of a file is always kept, a deleted file is undeleted from it.
+With S3 storage the content objects stay, they are keyed by checksum and can be shared with files and other revisions.
+*/
+func CleanCodeFileRevisions(s *gocron.Scheduler, db *gorm.DB) {
+
+	s.Every(1).Day().At("02:00").Do(func() {
+
+		result := db.Exec(`
+		DELETE FROM code_file_revisions
+		WHERE revision_id in (
+			SELECT revision_id FROM (
+				SELECT revision_id, created_at,
+				row_number() OVER (PARTITION BY file_id, environment_id ORDER BY created_at desc) AS revision
+				FROM code_file_revisions
+			) r
+			WHERE r.revision > ? or (r.revision > 1 and r.created_at < NOW() - INTERVAL '? days')
+		);
+		`, dpconfig.CleanRevisionsMax, dpconfig.CleanRevisions)
+		if result.Error != nil {
+			log.Println("Clean code file revisions:", result.Error)
+		}
+
+		if dpconfig.Debug == "true" {
+			log.Println("Removed old code file revisions")
+		}
+
+		db.Create(&models.LogsPlatform{
+			EnvironmentID: "d_platform",
+			Category:      "platform",
+			LogType:       "info", //can be error, info or debug
+			Log:           "Routine schedule: Clean code file revisions - count: " + strconv.Itoa(int(result.RowsAffected)),
+		})
+
+	})
+
+}
@@ -0,0 +1,36 @@
+package routinetasks
+
+import (
+	"log"
+	"strconv"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/go-co-op/gocron"
+	"gorm.io/gorm"
+)
+
+/*
+CleanEventTriggerEvents removes the events received by event triggers older than DP_CLEANEVENTS_DAYS.
+The event key only has to be remembered long enough to stop an event being delivered twice.
+*/
+func CleanEventTriggerEvents(s *gocron.Scheduler, db *gorm.DB) {
+
+	s.Every(1).Day().At("01:30").Do(func() {
+
+		result := db.Where("created_at < NOW() - INTERVAL '? days'", dpconfig.CleanEvents).Delete(&models.EventTriggerEvents{})
+		if dpconfig.Debug == "true" {
+			log.Println("Removed old event trigger events")
+		}
+
+		db.Create(&models.LogsPlatform{
+			EnvironmentID: "d_platform",
+			Category:      "platform",
+			LogType:       "info", //can be error, info or debug
+			Log:           "Routine schedule: Clean event trigger events - count: " + strconv.Itoa(int(result.RowsAffected)),
+		})
+
+	})
+
+}
@@ -0,0 +1,32 @@
+package routinetasks
+
+import (
+	"log"
+	"strconv"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"github.com/go-co-op/gocron"
+	"gorm.io/gorm"
+)
+
+func CleanScheduleHistory(s *gocron.Scheduler, db *gorm.DB) {
+
+	s.Every(1).Day().At("01:00").Do(func() {
+
+		result := db.Where("created_at < NOW() - INTERVAL '? days'", dpconfig.CleanTasks).Delete(&models.SchedulerHistory{})
+		if dpconfig.Debug == "true" {
+			log.Println("Removed old schedule history")
+		}
+
+		db.Create(&models.LogsPlatform{
+			EnvironmentID: "d_platform",
+			Category:      "platform",
+			LogType:       "info", //can be error, info or debug
+			Log:           "Routine schedule: Clean schedule history - count: " + strconv.Itoa(int(result.RowsAffected)),
+		})
+
+	})
+
+}
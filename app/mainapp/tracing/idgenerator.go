@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type runSpanKey struct{}
+
+/*
+runIDGenerator gives the run span the IDs worked out from the run ID and random IDs to every other span.
+*/
+type runIDGenerator struct{}
+
+func (runIDGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+
+	if sc, ok := ctx.Value(runSpanKey{}).(trace.SpanContext); ok {
+		return sc.TraceID(), sc.SpanID()
+	}
+
+	var traceID trace.TraceID
+	for !traceID.IsValid() {
+		rand.Read(traceID[:])
+	}
+
+	return traceID, newSpanID()
+}
+
+func (runIDGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	return newSpanID()
+}
+
+func newSpanID() trace.SpanID {
+
+	var spanID trace.SpanID
+	for !spanID.IsValid() {
+		rand.Read(spanID[:])
+	}
+
+	return spanID
+}
@@ -0,0 +1,153 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"log"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+Tracing is exported with OTLP over HTTP when OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set,
+the rest of the OTLP exporter is set with the standard OTEL_* environment variables. Without an endpoint spans are dropped.
+
+A run is traced across the main app replicas and workers without keeping a span open:
+the trace and span IDs of a run are worked out from the run ID, so every task can join the trace of its run
+and the run span itself is recorded once the run finishes with the time it started.
+*/
+
+const tracerName = "github.com/dataplane-app/dataplane"
+
+var propagator = propagation.TraceContext{}
+
+/*
+Init sets up the tracer provider of a main app or worker, the function returned flushes and stops the exporter.
+*/
+func Init(serviceName string) func(context.Context) error {
+
+	otel.SetTextMapPropagator(propagator)
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }
+	}
+
+	exporter, err := otlptracehttp.New(context.Background())
+	if err != nil {
+		log.Println("Tracing exporter error:", err)
+		return func(context.Context) error { return nil }
+	}
+
+	res, _ := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceNameKey.String(serviceName)))
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithIDGenerator(runIDGenerator{}),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Println("🔭 Tracing: OTLP exporter for", serviceName)
+
+	return provider.Shutdown
+}
+
+/*
+Tracer is the tracer of Dataplane spans.
+*/
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+/*
+RunSpanContext is the span context of a run, the same on every replica and worker.
+*/
+func RunSpanContext(runID string) trace.SpanContext {
+
+	sum := sha256.Sum256([]byte("dataplane-run:" + runID))
+
+	var traceID trace.TraceID
+	var spanID trace.SpanID
+	copy(traceID[:], sum[:16])
+	copy(spanID[:], sum[16:24])
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+		Remote:     true,
+	})
+}
+
+/*
+RunContext is a context with the run span as the parent, for the spans of the tasks of a run.
+*/
+func RunContext(ctx context.Context, runID string) context.Context {
+	return trace.ContextWithRemoteSpanContext(ctx, RunSpanContext(runID))
+}
+
+/*
+RunSpan records the span of a finished run from when it started.
+*/
+func RunSpan(runID string, pipelineID string, environmentID string, runType string, status string, startedAt time.Time, endedAt time.Time) {
+
+	ctx := context.WithValue(context.Background(), runSpanKey{}, RunSpanContext(runID))
+
+	_, span := Tracer().Start(ctx, "pipeline.run",
+		trace.WithNewRoot(),
+		trace.WithTimestamp(startedAt),
+		trace.WithAttributes(
+			attribute.String("dataplane.run_id", runID),
+			attribute.String("dataplane.pipeline_id", pipelineID),
+			attribute.String("dataplane.environment_id", environmentID),
+			attribute.String("dataplane.run_type", runType),
+			attribute.String("dataplane.status", status),
+		))
+
+	if status == "Fail" {
+		span.SetStatus(codes.Error, "Run failed")
+	}
+
+	span.End(trace.WithTimestamp(endedAt))
+}
+
+/*
+Inject returns the W3C traceparent of the span in the context, empty if there is no span.
+*/
+func Inject(ctx context.Context) string {
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	return carrier.Get("traceparent")
+}
+
+/*
+Extract returns a context with the remote span of a W3C traceparent.
+*/
+func Extract(ctx context.Context, traceparent string) context.Context {
+
+	if traceparent == "" {
+		return ctx
+	}
+
+	return propagator.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
+/*
+SpanError marks a span as failed.
+*/
+func SpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,104 @@
+package tracing
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+go test -timeout 30s -v -run ^TestTracing$ github.com/dataplane-app/dataplane/app/mainapp/tracing
+*/
+func TestTracing(t *testing.T) {
+
+	// ----- A stand-in OTLP collector
+	var mu sync.Mutex
+	var spans []*tracepb.Span
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		assert.Equal(t, "/v1/traces", r.URL.Path, "OTLP traces path")
+
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			assert.NoError(t, err, "Gzip body")
+			body = gz
+		}
+
+		data, _ := io.ReadAll(body)
+
+		var req coltracepb.ExportTraceServiceRequest
+		assert.NoError(t, proto.Unmarshal(data, &req), "OTLP request")
+
+		mu.Lock()
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				spans = append(spans, ss.Spans...)
+			}
+		}
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", collector.URL)
+
+	shutdown := Init("dataplane-test")
+
+	// ----- A task span in the trace of its run, passed on as a traceparent
+	runID := "2b7b4b4e-0a3c-4a8e-9d55-5d8f2c0e3c11"
+	started := time.Now().Add(-time.Minute)
+
+	ctx, task := Tracer().Start(RunContext(context.Background(), runID), "task.dispatch")
+	traceparent := Inject(ctx)
+	task.End()
+
+	assert.Equal(t, "00-"+RunSpanContext(runID).TraceID().String()+"-"+task.SpanContext().SpanID().String()+"-01", traceparent, "Traceparent")
+
+	_, command := Tracer().Start(Extract(context.Background(), traceparent), "task.command")
+	command.End()
+
+	RunSpan(runID, "pipeline1", "env1", "pipeline", "Success", started, time.Now())
+
+	assert.NoError(t, shutdown(context.Background()), "Flush spans")
+
+	// ----- The collector got the run span with the IDs worked out from the run ID
+	mu.Lock()
+	defer mu.Unlock()
+
+	byName := map[string]*tracepb.Span{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	run := byName["pipeline.run"]
+	if assert.NotNil(t, run, "Run span exported") {
+		traceID := RunSpanContext(runID).TraceID()
+		spanID := RunSpanContext(runID).SpanID()
+		assert.True(t, bytes.Equal(traceID[:], run.TraceId), "Run trace ID")
+		assert.True(t, bytes.Equal(spanID[:], run.SpanId), "Run span ID")
+		assert.Equal(t, uint64(started.UnixNano()), run.StartTimeUnixNano, "Run start time")
+	}
+
+	runSpanID := RunSpanContext(runID).SpanID()
+	if assert.NotNil(t, byName["task.dispatch"], "Task span exported") {
+		assert.True(t, bytes.Equal(runSpanID[:], byName["task.dispatch"].ParentSpanId), "Task parent is the run")
+	}
+
+	if assert.NotNil(t, byName["task.command"], "Command span exported") {
+		assert.True(t, bytes.Equal(byName["task.dispatch"].SpanId, byName["task.command"].ParentSpanId), "Command parent from the traceparent")
+	}
+}
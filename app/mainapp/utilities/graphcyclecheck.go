@@ -3,6 +3,7 @@ package utilities
 import (
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 
 	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
@@ -34,6 +35,48 @@ func GraphCycleCheck(edges []*models.PipelineEdges, node string) bool {
 	return false
 }
 
+/*
+PipelineReferenceCycleCheck checks that the sub-pipeline nodes of a pipeline don't lead back to it,
+directly or through other pipelines. References map a pipeline to the pipelines its sub-pipeline nodes run.
+*/
+func PipelineReferenceCycleCheck(references map[string][]string, pipelineID string) error {
+
+	graph := NewGraph()
+	graph.AddNode(pipelineID)
+
+	for from, to := range references {
+		for _, t := range to {
+			graph.AddEdge(from, t)
+		}
+	}
+
+	_, err := graph.TopSort(pipelineID)
+	return err
+}
+
+/*
+GraphCycle returns the first cycle found in a graph as "a -> b -> a", empty if there is none.
+Edges map a node to the nodes it leads to. Nodes are visited in name order so that the same cycle is always reported.
+*/
+func GraphCycle(edges map[string][]string) string {
+
+	graph := NewGraph()
+
+	for from, to := range edges {
+		graph.AddNode(from)
+		for _, t := range to {
+			graph.AddEdge(from, t)
+		}
+	}
+
+	cycle := graph.Cycle()
+	if cycle == nil {
+		return ""
+	}
+
+	return strings.Join(cycle, " -> ")
+}
+
 type Graph struct {
 	nodes map[string]node
 }
@@ -81,6 +124,37 @@ func (g *Graph) TopSort(name string) ([]string, error) {
 	return results.items, nil
 }
 
+/*
+Cycle returns the nodes of the first cycle found in the graph with the first node repeated at the end, nil if there is none.
+*/
+func (g *Graph) Cycle() []string {
+
+	names := make([]string, 0, len(g.nodes))
+	for name := range g.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := newOrderedSet()
+	for _, name := range names {
+		err := g.visit(name, results, nil)
+		if cycle, ok := err.(*CycleError); ok {
+			return cycle.Path
+		}
+	}
+
+	return nil
+}
+
+// CycleError is returned by TopSort when the graph has a cycle, Path is the cycle with the first node repeated at the end.
+type CycleError struct {
+	Path []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("Cycle error: %s", strings.Join(e.Path, " -> "))
+}
+
 func (g *Graph) visit(name string, results *orderedset, visited *orderedset) error {
 	if visited == nil {
 		visited = newOrderedSet()
@@ -89,8 +163,13 @@ func (g *Graph) visit(name string, results *orderedset, visited *orderedset) err
 	added := visited.add(name)
 	if !added {
 		index := visited.index(name)
-		cycle := append(visited.items[index:], name)
-		return fmt.Errorf("Cycle error: %s", strings.Join(cycle, " -> "))
+		cycle := append(append([]string{}, visited.items[index:]...), name)
+		return &CycleError{Path: cycle}
+	}
+
+	// Already sorted nodes and everything after them have no cycle
+	if results.index(name) != -1 {
+		return nil
 	}
 
 	n := g.nodes[name]
@@ -116,6 +195,7 @@ func (n node) edges() []string {
 	for k := range n {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
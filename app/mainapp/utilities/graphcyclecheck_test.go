@@ -62,3 +62,62 @@ func TestGraphCycleCheckFunction(t *testing.T) {
 	}
 
 }
+
+/*
+go test -timeout 30s -v -run ^TestPipelineReferenceCycleCheck$ github.com/dataplane-app/dataplane/app/mainapp/utilities
+*/
+func TestPipelineReferenceCycleCheck(t *testing.T) {
+
+	// a > [b, c] > d
+	references := map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+	}
+	assert.NoError(t, PipelineReferenceCycleCheck(references, "a"), "No cycle")
+	assert.NoError(t, PipelineReferenceCycleCheck(references, "e"), "No references")
+
+	// d > a closes the loop across pipelines
+	references["d"] = []string{"a"}
+	assert.Error(t, PipelineReferenceCycleCheck(references, "a"), "Cycle through other pipelines")
+	assert.Error(t, PipelineReferenceCycleCheck(references, "b"), "Cycle from inside the loop")
+
+	assert.Error(t, PipelineReferenceCycleCheck(map[string][]string{"a": {"a"}}, "a"), "Runs itself")
+}
+
+/*
+go test -timeout 30s -v -run ^TestGraphCycle$ github.com/dataplane-app/dataplane/app/mainapp/utilities
+*/
+func TestGraphCycle(t *testing.T) {
+
+	// a > [b, c] > d
+	assert.Equal(t, "", GraphCycle(map[string][]string{
+		"a": {"b", "c"},
+		"b": {"d"},
+		"c": {"d"},
+	}), "No cycle")
+
+	// The cycle is found whichever node it is reachable from and always reported the same way
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "b -> c -> d -> b", GraphCycle(map[string][]string{
+			"a": {"b"},
+			"b": {"d", "c"},
+			"c": {"d"},
+			"d": {"b"},
+			"e": {"a"},
+		}), "Cycle path")
+	}
+
+	assert.Equal(t, "a -> a", GraphCycle(map[string][]string{"a": {"a"}}), "Self loop")
+
+	_, err := func() ([]string, error) {
+		graph := NewGraph()
+		graph.AddEdge("x", "y")
+		graph.AddEdge("y", "x")
+		return graph.TopSort("x")
+	}()
+	cycle, ok := err.(*CycleError)
+	assert.True(t, ok, "TopSort cycle error")
+	assert.Equal(t, []string{"x", "y", "x"}, cycle.Path)
+	assert.Equal(t, "Cycle error: x -> y -> x", err.Error())
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
 
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
 	"github.com/gofiber/websocket/v2"
 	"github.com/nats-io/nats.go"
 )
@@ -70,6 +71,8 @@ func RoomUpdates(conn *websocket.Conn, room string) {
 
 	// Register the client
 	Register <- Subscription{Conn: conn, Room: room}
+	metrics.WebsocketClients.WithLabelValues(metrics.Subject(room)).Inc()
+	defer metrics.WebsocketClients.WithLabelValues(metrics.Subject(room)).Dec()
 
 	// go SecureTimeout()
 
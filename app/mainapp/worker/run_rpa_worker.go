@@ -16,7 +16,6 @@ import (
 	"github.com/dataplane-app/dataplane/app/mainapp/remoteworker"
 	wsockets "github.com/dataplane-app/dataplane/app/mainapp/websockets"
 	"github.com/google/uuid"
-	"gorm.io/gorm/clause"
 )
 
 func RPAWorker(envID string, workerGroup string, runid string, taskid string, pipelineID string, nodeID string, commands []string, Folder string, FolderID string, Version string, RunType string) error {
@@ -197,73 +196,11 @@ func RPAWorker(envID string, workerGroup string, runid string, taskid string, pi
 	}
 
 	// ----- Failed with RPA worker -------
-	// If task not successfully sent, mark as failed
+	// If task not successfully sent, mark as failed, run next retries it or fails the run
 	if markFail == true {
 		log.Println("RPA marked failed", markFail)
-		TaskFinal := models.WorkerTasks{
-			TaskID:        taskid,
-			CreatedAt:     time.Now().UTC(),
-			EnvironmentID: envID,
-			RunID:         runid,
-			WorkerGroup:   workerGroup,
-			WorkerID:      remoteWorkerID,
-			StartDT:       time.Now().UTC(),
-			Status:        "Fail",
-			Reason:        "No workers",
-		}
-
-		UpdateWorkerTasks(TaskFinal)
 
-		errnat := messageq.MsgSend("taskupdate."+envID+"."+runid, TaskFinal)
-		if errnat != nil {
-			errmsg = "Failed to send to nats: " + "taskupdate." + envID + "." + runid
-			if dpconfig.Debug == "true" {
-				logging.PrintSecretsRedact(errnat)
-			}
-
-		}
-
-		// If worker recovers but part of the pipeline starts running - cancel any running jobs
-		// Get any current running tasks and cancel those tasks
-		currentTask := []*models.WorkerTasks{}
-		err := database.DBConn.Where("run_id = ? and environment_id = ? and status=?", runid, envID, "Run").Find(&currentTask).Error
-		if err != nil {
-			errmsg = "DB error couldn't find existing running tasks: " + err.Error()
-			logging.PrintSecretsRedact(err.Error())
-		}
-
-		if len(currentTask) > 0 {
-			for _, t := range currentTask {
-				errt := WorkerCancelTask(t.TaskID, envID, "rpa")
-				if errt != nil {
-					errmsg = "Could not cancel remaining tasks on fail: " + errt.Error()
-					logging.PrintSecretsRedact(errt.Error())
-				}
-			}
-		}
-
-		// Update all the future tasks
-		// Update pipeline as failed
-		run := models.PipelineRuns{
-			RunID:   runid,
-			Status:  "Fail",
-			EndedAt: time.Now().UTC(),
-		}
-
-		err2 := database.DBConn.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "run_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"ended_at", "status"}),
-		}).Create(&run)
-		if err2.Error != nil {
-			errmsg = "Pipeline database update on run fail error : " + err2.Error.Error()
-			logging.PrintSecretsRedact(err2.Error.Error())
-		}
-
-		err3 := database.DBConn.Model(&models.WorkerTasks{}).Where("run_id = ? and status=?", runid, "Queue").Updates(map[string]interface{}{"status": "Fail", "reason": "Upstream no workers"}).Error
-		if err3 != nil {
-			errmsg = "database update on run fail error 2 : " + err3.Error()
-			logging.PrintSecretsRedact(err3.Error())
-		}
+		WorkerFailUnsentTask(envID, runid, taskid, pipelineID, nodeID, workerGroup, remoteWorkerID)
 
 		return errors.New("Server worker failed: " + errmsg)
 	}
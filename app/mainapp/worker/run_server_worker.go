@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"context"
 	"errors"
 	"log"
 	"strconv"
@@ -11,9 +12,12 @@ import (
 	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	"github.com/dataplane-app/dataplane/app/mainapp/logging"
 	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+	"github.com/dataplane-app/dataplane/app/mainapp/metrics"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
 	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
 func ServerWorker(envID string, workerGroup string, runid string, taskid string, pipelineID string, nodeID string, commands []string, Folder string, FolderID string, Version string, RunType string) error {
@@ -22,6 +26,15 @@ func ServerWorker(envID string, workerGroup string, runid string, taskid string,
 	if none, keep trying for 10 x 2 seconds
 	before failing */
 	// var err1 error
+	start := time.Now()
+
+	ctx, span := tracing.Tracer().Start(tracing.RunContext(context.Background(), runid), "task.dispatch", trace.WithAttributes(
+		attribute.String("dataplane.task_id", taskid),
+		attribute.String("dataplane.node_id", nodeID),
+		attribute.String("dataplane.worker_group", workerGroup),
+	))
+	defer span.End()
+
 	markFail := true
 	maxRetiresAllowed := 5
 	var errmsg string
@@ -105,6 +118,7 @@ func ServerWorker(envID string, workerGroup string, runid string, taskid string,
 				FolderID:      FolderID,
 				Version:       Version,
 				RunType:       RunType,
+				TraceParent:   tracing.Inject(ctx),
 			}
 
 			var response models.TaskResponse
@@ -121,6 +135,8 @@ func ServerWorker(envID string, workerGroup string, runid string, taskid string,
 
 			// successful send to worker
 			if response.R == "ok" {
+				span.SetAttributes(attribute.String("dataplane.worker_id", loadbalanceNext))
+				metrics.TaskQueueSeconds.WithLabelValues(envID, workerGroup).Observe(time.Since(start).Seconds())
 				markFail = false
 				break
 			}
@@ -140,76 +156,85 @@ func ServerWorker(envID string, workerGroup string, runid string, taskid string,
 		time.Sleep(2 * time.Second)
 	}
 
-	// If task not successfully sent, mark as failed
+	// If task not successfully sent, mark as failed, run next retries it or fails the run
 	if markFail {
-		TaskFinal := models.WorkerTasks{
-			TaskID:        taskid,
-			CreatedAt:     time.Now().UTC(),
-			EnvironmentID: envID,
-			RunID:         runid,
-			WorkerGroup:   workerGroup,
-			StartDT:       time.Now().UTC(),
-			Status:        "Fail",
-			Reason:        "No workers",
-		}
 
-		UpdateWorkerTasks(TaskFinal)
+		WorkerFailUnsentTask(envID, runid, taskid, pipelineID, nodeID, workerGroup, "")
 
-		errnat := messageq.MsgSend("taskupdate."+envID+"."+runid, TaskFinal)
-		if errnat != nil {
-			errmsg = "Failed to send to nats: " + "taskupdate." + envID + "." + runid
-			if dpconfig.Debug == "true" {
-				logging.PrintSecretsRedact(errnat)
-			}
+		tracing.SpanError(span, errors.New("Server worker failed: "+errmsg))
 
-		}
+		return errors.New("Server worker failed: " + errmsg)
 
-		// If worker recovers but part of the pipeline starts running - cancel any running jobs
-		// Get any current running tasks and cancel those tasks
-		currentTask := []*models.WorkerTasks{}
-		err := database.DBConn.Where("run_id = ? and environment_id = ? and status=?", runid, envID, "Run").Find(&currentTask).Error
-		if err != nil {
-			errmsg = "DB error couldn't find existing running tasks: " + err.Error()
-			logging.PrintSecretsRedact(err.Error())
-		}
+	}
 
-		if len(currentTask) > 0 {
-			for _, t := range currentTask {
-				errt := WorkerCancelTask(t.TaskID, envID, "server")
-				if errt != nil {
-					errmsg = "Could not cancel remaining tasks on fail: " + errt.Error()
-					logging.PrintSecretsRedact(errt.Error())
-				}
-			}
-		}
+	return nil
 
-		// Update all the future tasks
-		// Update pipeline as failed
-		run := models.PipelineRuns{
-			RunID:   runid,
-			Status:  "Fail",
-			EndedAt: time.Now().UTC(),
-		}
+}
 
-		err2 := database.DBConn.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "run_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"ended_at", "status"}),
-		}).Create(&run)
-		if err2.Error != nil {
-			errmsg = "Pipeline database update on run fail error : " + err2.Error.Error()
-			logging.PrintSecretsRedact(err2.Error.Error())
-		}
+/*
+WorkerFailUnsentTask fails a queued task that could not be sent to a worker and passes it to run next,
+which retries it or follows the failure through the graph and closes off the run.
+No process ran so the exit code is -1, a retry policy limited to exit codes retries it when -1 is one of them.
+*/
+func WorkerFailUnsentTask(envID string, runid string, taskid string, pipelineID string, nodeID string, workerGroup string, workerID string) {
+
+	now := time.Now().UTC()
+
+	// A stopped run has failed its queued tasks already
+	result := database.DBConn.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", taskid, "Queue").Updates(map[string]interface{}{
+		"status":       "Fail",
+		"reason":       "No workers",
+		"exit_code":    -1,
+		"worker_group": workerGroup,
+		"worker_id":    workerID,
+		"start_dt":     now,
+		"end_dt":       now,
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return
+	}
 
-		err3 := database.DBConn.Model(&models.WorkerTasks{}).Where("run_id = ? and status=?", runid, "Queue").Updates(map[string]interface{}{"status": "Fail", "reason": "Upstream no workers"}).Error
-		if err3 != nil {
-			errmsg = "database update on run fail error 2 : " + err3.Error()
-			logging.PrintSecretsRedact(err3.Error())
-		}
+	if result.RowsAffected == 0 {
+		return
+	}
 
-		return errors.New("Server worker failed: " + errmsg)
+	TaskFinal := models.WorkerTasks{
+		TaskID:        taskid,
+		EnvironmentID: envID,
+		RunID:         runid,
+		PipelineID:    pipelineID,
+		NodeID:        nodeID,
+		WorkerGroup:   workerGroup,
+		WorkerID:      workerID,
+		StartDT:       now,
+		EndDT:         now,
+		Status:        "Fail",
+		Reason:        "No workers",
+		ExitCode:      -1,
+	}
 
+	errnat := messageq.MsgSend("taskupdate."+envID+"."+runid, TaskFinal)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
 	}
 
-	return nil
+	RunNext := models.WorkerPipelineNext{
+		TaskID:        taskid,
+		CreatedAt:     now,
+		EnvironmentID: envID,
+		PipelineID:    pipelineID,
+		RunID:         runid,
+		NodeID:        nodeID,
+		Status:        "Fail",
+	}
 
+	errnat = messageq.MsgSend("pipeline-run-next", RunNext)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
 }
@@ -28,7 +28,8 @@ func WorkerCancelTask(taskid string, envID string, workerType string) error {
 		logging.PrintSecretsRedact(err2.Error.Error())
 	}
 
-	if task.Status == "Success" || task.Status == "Fail" {
+	// A task failed by the task watchdog can still have its process running on the worker
+	if task.Status == "Success" || (task.Status == "Fail" && task.Reason != "Timeout" && task.Reason != "Worker lost") {
 		return errors.New("Task completed with fail or success")
 	}
 
@@ -92,7 +93,11 @@ func WorkerCancelTask(taskid string, envID string, workerType string) error {
 		}
 		complete = true
 
-		/* Update the front end status to Failed */
+		/* Update the front end status to Failed, a task failed by the task watchdog already is */
+		if task.Status != "Run" {
+			break
+		}
+
 		TaskFinal := models.WorkerTasks{
 			TaskID:        task.TaskID,
 			EnvironmentID: task.EnvironmentID,
@@ -118,7 +123,7 @@ func WorkerCancelTask(taskid string, envID string, workerType string) error {
 	}
 
 	if complete == false {
-		// mark task as failed
+		// mark task as failed, unless it finished or was failed and retried in the meantime
 		taskUpdate := models.WorkerTasks{
 			TaskID: task.TaskID,
 			EndDT:  time.Now().UTC(),
@@ -129,6 +134,7 @@ func WorkerCancelTask(taskid string, envID string, workerType string) error {
 		err2 := database.DBConn.Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "task_id"}},
 			DoUpdates: clause.AssignmentColumns([]string{"end_dt", "status", "reason"}),
+			Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "worker_tasks", Name: "status"}, Value: "Run"}}},
 		}).Create(&taskUpdate)
 		if err2.Error != nil {
 			logging.PrintSecretsRedact(err2.Error.Error())
@@ -0,0 +1,35 @@
+package worker
+
+import "time"
+
+/*
+TaskTimeoutSeconds returns the timeout that applies to a task.
+A node timeout overrides the pipeline timeout, 0 = no timeout.
+*/
+func TaskTimeoutSeconds(nodeTimeout int, pipelineTimeout int) int {
+
+	if nodeTimeout > 0 {
+		return nodeTimeout
+	}
+
+	if pipelineTimeout > 0 {
+		return pipelineTimeout
+	}
+
+	return 0
+}
+
+/*
+TaskTimedOut checks if a task started at startDT has run past its timeout plus a grace period.
+Tasks without a timeout never time out.
+*/
+func TaskTimedOut(startDT time.Time, timeoutSeconds int, grace time.Duration, now time.Time) bool {
+
+	if timeoutSeconds <= 0 || startDT.IsZero() {
+		return false
+	}
+
+	deadline := startDT.Add(time.Duration(timeoutSeconds)*time.Second + grace)
+
+	return now.After(deadline)
+}
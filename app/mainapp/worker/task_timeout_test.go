@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestTaskTimeout$ github.com/dataplane-app/dataplane/app/mainapp/worker
+*/
+func TestTaskTimeout(t *testing.T) {
+
+	// Node overrides pipeline
+	assert.Equalf(t, 0, TaskTimeoutSeconds(0, 0), "No timeout")
+	assert.Equalf(t, 60, TaskTimeoutSeconds(0, 60), "Pipeline timeout")
+	assert.Equalf(t, 30, TaskTimeoutSeconds(30, 60), "Node timeout")
+	assert.Equalf(t, 60, TaskTimeoutSeconds(-1, 60), "Negative node timeout")
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equalf(t, false, TaskTimedOut(start, 0, 0, start.Add(24*time.Hour)), "No timeout never times out")
+	assert.Equalf(t, false, TaskTimedOut(time.Time{}, 10, 0, start), "Not started")
+	assert.Equalf(t, false, TaskTimedOut(start, 10, 0, start.Add(10*time.Second)), "At deadline")
+	assert.Equalf(t, true, TaskTimedOut(start, 10, 0, start.Add(11*time.Second)), "Past deadline")
+	assert.Equalf(t, false, TaskTimedOut(start, 10, 30*time.Second, start.Add(20*time.Second)), "Within grace")
+	assert.Equalf(t, true, TaskTimedOut(start, 10, 30*time.Second, start.Add(41*time.Second)), "Past grace")
+}
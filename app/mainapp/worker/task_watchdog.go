@@ -0,0 +1,177 @@
+package worker
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	dpconfig "github.com/dataplane-app/dataplane/app/mainapp/config"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/logging"
+	"github.com/dataplane-app/dataplane/app/mainapp/messageq"
+
+	"github.com/go-co-op/gocron"
+	cmap "github.com/orcaman/concurrent-map"
+	"gorm.io/gorm"
+)
+
+/*
+Running tasks whose worker stopped heartbeating and when that was first noticed.
+*/
+var workerLostSince = cmap.New()
+
+/*
+WorkerTaskWatchdog fails running tasks that no worker will finish:
+Worker lost - the worker running the task stopped heartbeating.
+Timeout - the task ran past its timeout and the worker did not stop it.
+Only the leader checks so that each task is failed once.
+*/
+func WorkerTaskWatchdog(s *gocron.Scheduler, db *gorm.DB) {
+
+	s.Every(5).Seconds().Do(func() {
+
+		if dpconfig.MainAppID != dpconfig.Leader {
+			return
+		}
+
+		var tasks []models.WorkerTasks
+		err := db.Where("status = ?", "Run").Find(&tasks).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		if len(tasks) == 0 {
+			workerLostSince.Clear()
+			return
+		}
+
+		// Workers still sending heartbeats, stale workers are removed by WorkerRemovalListen
+		var onlineWorkers []string
+		err = db.Model(&models.Workers{}).Distinct("worker_id").Pluck("worker_id", &onlineWorkers).Error
+		if err != nil {
+			logging.PrintSecretsRedact(err)
+			return
+		}
+
+		online := make(map[string]bool, len(onlineWorkers))
+		for _, w := range onlineWorkers {
+			online[w] = true
+		}
+
+		grace := time.Duration(dpconfig.WorkerLostSeconds) * time.Second
+		now := time.Now().UTC()
+		running := make(map[string]bool, len(tasks))
+
+		for _, t := range tasks {
+
+			running[t.TaskID] = true
+
+			if TaskTimedOut(t.StartDT, t.TimeoutSeconds, grace, now) {
+				WorkerFailLostTask(db, t, "Timeout")
+				workerLostSince.Remove(t.TaskID)
+				continue
+			}
+
+			// RPA workers report back over RPC and do not send heartbeats
+			if strings.HasPrefix(t.WorkerType, "rpa") || t.WorkerID == "" || online[t.WorkerID] {
+				workerLostSince.Remove(t.TaskID)
+				continue
+			}
+
+			if tmp, ok := workerLostSince.Get(t.TaskID); ok {
+				if now.Sub(tmp.(time.Time)) > grace {
+					WorkerFailLostTask(db, t, "Worker lost")
+					workerLostSince.Remove(t.TaskID)
+				}
+			} else {
+				workerLostSince.Set(t.TaskID, now)
+			}
+		}
+
+		// Forget tasks that are no longer running
+		for _, taskID := range workerLostSince.Keys() {
+			if !running[taskID] {
+				workerLostSince.Remove(taskID)
+			}
+		}
+
+	})
+
+}
+
+/*
+WorkerFailLostTask marks a running task as failed on behalf of its worker and cancels it on the worker, in case the process is still running.
+Run next decides if the task is retried or how the failure moves through the graph.
+*/
+func WorkerFailLostTask(db *gorm.DB, task models.WorkerTasks, reason string) {
+
+	// Only fail the task if the worker has not finished it in the meantime
+	result := db.Model(&models.WorkerTasks{}).Where("task_id = ? and status = ?", task.TaskID, "Run").Updates(map[string]interface{}{
+		"status":    "Fail",
+		"reason":    reason,
+		"exit_code": -1,
+		"end_dt":    time.Now().UTC(),
+	})
+	if result.Error != nil {
+		logging.PrintSecretsRedact(result.Error)
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		return
+	}
+
+	log.Println("Task watchdog:", reason, "- run:", task.RunID, "node:", task.NodeID, "worker:", task.WorkerID)
+
+	task.Status = "Fail"
+	task.Reason = reason
+	task.ExitCode = -1
+	task.EndDT = time.Now().UTC()
+
+	errnat := messageq.MsgSend("taskupdate."+task.EnvironmentID+"."+task.RunID, task)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+	db.Create(&models.LogsPlatform{
+		EnvironmentID: task.EnvironmentID,
+		Category:      "platform",
+		LogType:       "error",
+		Log:           "Task watchdog: " + reason + " - run: " + task.RunID + " node: " + task.NodeID + " worker: " + task.WorkerID,
+	})
+
+	// A worker that comes back or a task past its timeout would otherwise still finish the task, its final status is not recorded
+	workerType := "server"
+	if strings.HasPrefix(task.WorkerType, "rpa") {
+		workerType = "rpa"
+	}
+	go func() {
+		err := WorkerCancelTask(task.TaskID, task.EnvironmentID, workerType)
+		if err != nil {
+			if dpconfig.Debug == "true" {
+				logging.PrintSecretsRedact("Task watchdog cancel:", err)
+			}
+		}
+	}()
+
+	RunNext := models.WorkerPipelineNext{
+		TaskID:        task.TaskID,
+		CreatedAt:     task.CreatedAt,
+		EnvironmentID: task.EnvironmentID,
+		PipelineID:    task.PipelineID,
+		RunID:         task.RunID,
+		NodeID:        task.NodeID,
+		Status:        task.Status,
+	}
+
+	errnat = messageq.MsgSend("pipeline-run-next", RunNext)
+	if errnat != nil {
+		if dpconfig.Debug == "true" {
+			logging.PrintSecretsRedact(errnat)
+		}
+	}
+
+}
@@ -14,14 +14,19 @@ import (
 
 /* Return errors to the logging console */
 func WSTaskLogError(envID string, runID string, logline string, nodeID string, taskID string) {
+	WSTaskLog(envID, runID, logline, "error", nodeID, taskID)
+}
+
+/* Send a log line of a task run by the main app to the logging console, logType is info, error or action */
+func WSTaskLog(envID string, runID string, logline string, logType string, nodeID string, taskID string) {
 
-	/* Send the error log */
+	/* Send the log */
 	uidstring := uuid.NewString()
 	sendmsg := models.LogsSend{
 		CreatedAt:     time.Now().UTC(),
 		UID:           uidstring,
 		Log:           logline,
-		LogType:       "error",
+		LogType:       logType,
 		EnvironmentID: envID,
 		RunID:         runID,
 	}
@@ -45,7 +50,7 @@ func WSTaskLogError(envID string, runID string, logline string, nodeID string, t
 		NodeID:        nodeID,
 		TaskID:        taskID,
 		Log:           logline,
-		LogType:       "error",
+		LogType:       logType,
 	}
 
 	err2 := database.DBConn.Create(&recordlog)
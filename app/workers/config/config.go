@@ -30,6 +30,25 @@ var FSCodeFileStorage string
 var FSCodeFileBatches int
 var FSCodeDirectory string
 
+// External log sinks for task output
+var LogSinks []string
+var LogSinkFileDir string
+var LogSinkFileMaxMB int
+var LogSinkFileMaxAgeDays int
+var LogSinkS3Endpoint string
+var LogSinkS3SSL bool
+var LogSinkS3Region string
+var LogSinkS3Bucket string
+var LogSinkS3Prefix string
+var LogSinkS3AccessKey string
+var LogSinkS3SecretKey string
+var LogSinkS3FlushSeconds int
+var LogSinkSyslogNetwork string
+var LogSinkSyslogAddress string
+var LogSinkOTLPEndpoint string
+var LogSinkBlockSeconds int
+var LogSinkDropWhenFull bool
+
 func LoadConfig() {
 
 	Debug = os.Getenv("DP_DEBUG")
@@ -77,4 +96,67 @@ func LoadConfig() {
 		FSCodeDirectory = "/appdev/dfs-code-files/"
 	}
 
+	/* --- LOG SINKS: any of file, s3, syslog, otlp ---- */
+	LogSinks = []string{}
+	for _, sink := range strings.Split(os.Getenv("DP_LOG_SINKS"), ",") {
+		sink = strings.ToLower(strings.TrimSpace(sink))
+		if sink != "" {
+			LogSinks = append(LogSinks, sink)
+		}
+	}
+
+	/* A task waits this long for room in the sink queue, after that the line is spilled to the file sink */
+	LogSinkBlockSeconds, _ = strconv.Atoi(os.Getenv("DP_LOG_SINK_BLOCK_SECONDS"))
+	if LogSinkBlockSeconds == 0 {
+		LogSinkBlockSeconds = 5
+	}
+
+	/* Opt in to dropping lines when the sinks are behind instead of slowing tasks down */
+	LogSinkDropWhenFull = os.Getenv("DP_LOG_SINK_DROP_WHEN_FULL") == "true"
+
+	LogSinkFileDir = os.Getenv("DP_LOG_SINK_FILE_DIR")
+	if LogSinkFileDir == "" {
+		LogSinkFileDir = "/appdev/logs/"
+	}
+
+	LogSinkFileMaxMB, _ = strconv.Atoi(os.Getenv("DP_LOG_SINK_FILE_MAX_MB"))
+	if LogSinkFileMaxMB == 0 {
+		LogSinkFileMaxMB = 100
+	}
+
+	LogSinkFileMaxAgeDays, _ = strconv.Atoi(os.Getenv("DP_LOG_SINK_FILE_MAX_AGE_DAYS"))
+	if LogSinkFileMaxAgeDays == 0 {
+		LogSinkFileMaxAgeDays = 365
+	}
+
+	LogSinkS3Endpoint = os.Getenv("DP_LOG_SINK_S3_ENDPOINT")
+	LogSinkS3SSL = os.Getenv("DP_LOG_SINK_S3_SSL") != "false"
+	LogSinkS3Region = os.Getenv("DP_LOG_SINK_S3_REGION")
+	if LogSinkS3Region == "" {
+		LogSinkS3Region = "us-east-1"
+	}
+	LogSinkS3Bucket = os.Getenv("DP_LOG_SINK_S3_BUCKET")
+	LogSinkS3Prefix = os.Getenv("DP_LOG_SINK_S3_PREFIX")
+	if LogSinkS3Prefix == "" {
+		LogSinkS3Prefix = "dataplane-logs"
+	}
+	LogSinkS3AccessKey = os.Getenv("DP_LOG_SINK_S3_ACCESS_KEY")
+	LogSinkS3SecretKey = os.Getenv("secret_log_sink_s3_secret_key")
+
+	LogSinkS3FlushSeconds, _ = strconv.Atoi(os.Getenv("DP_LOG_SINK_S3_FLUSH_SECONDS"))
+	if LogSinkS3FlushSeconds == 0 {
+		LogSinkS3FlushSeconds = 60
+	}
+
+	LogSinkSyslogNetwork = os.Getenv("DP_LOG_SINK_SYSLOG_NETWORK")
+	LogSinkSyslogAddress = os.Getenv("DP_LOG_SINK_SYSLOG_ADDRESS")
+
+	LogSinkOTLPEndpoint = os.Getenv("DP_LOG_SINK_OTLP_ENDPOINT")
+	if LogSinkOTLPEndpoint == "" {
+		LogSinkOTLPEndpoint = os.Getenv("OTEL_EXPORTER_OTLP_LOGS_ENDPOINT")
+	}
+	if LogSinkOTLPEndpoint == "" && os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		LogSinkOTLPEndpoint = strings.TrimSuffix(os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/") + "/v1/logs"
+	}
+
 }
@@ -65,7 +65,9 @@ func DistributedStorageDeploymentDownload(environmentID string, folder string, f
 		cf.folder_id, 
 		cf.file_name, 
 		cs.checksum_md5,
-		cs.file_store
+		cs.file_store,
+		cs.external,
+		cs.object_key
 		from deploy_code_files cf, deploy_files_store cs
 		where 
 		cf.file_id = cs.file_id 
@@ -91,6 +93,13 @@ func DistributedStorageDeploymentDownload(environmentID string, folder string, f
 			return err
 		}
 
+		/* Files in object storage are downloaded with presigned URLs */
+		err = objectStorageDownload(FilesOutput)
+		if err != nil {
+			log.Println("Download object storage files: ", err)
+			return err
+		}
+
 		// distfilesystem.BatchFileWrite(FilesOutput, folderID, environmentID, folder)
 		// log.Println("==== FS:", RunType, version)
 
@@ -65,7 +65,9 @@ func DistributedStoragePipelineDownload(environmentID string, folder string, fol
 		cf.folder_id, 
 		cf.file_name, 
 		cs.checksum_md5,
-		cs.file_store
+		cs.file_store,
+		cs.external,
+		cs.object_key
 		from code_files cf, code_files_store cs
 		where 
 		cf.file_id = cs.file_id and cf.environment_id = cs.environment_id and cf.node_id = ? and cs.run_include = true
@@ -87,6 +89,13 @@ func DistributedStoragePipelineDownload(environmentID string, folder string, fol
 			return err
 		}
 
+		/* Files in object storage are downloaded with presigned URLs */
+		err = objectStorageDownload(FilesOutput)
+		if err != nil {
+			log.Println("Download object storage files: ", err)
+			return err
+		}
+
 		// distfilesystem.BatchFileWrite(FilesOutput, folderID, environmentID, folder)
 		// log.Println("==== FS:", RunType, version)
 
@@ -0,0 +1,96 @@
+package distfilesystem
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
+	"github.com/dataplane-app/dataplane/app/workers/messageq"
+)
+
+/* Keys presigned per request, keeps the reply well under the NATS message size */
+const presignBatch = 500
+
+var presignClient = &http.Client{Timeout: 5 * time.Minute}
+
+/*
+objectStorageDownload fills in the content of files kept in object storage (External).
+The main app presigns their keys and the content is downloaded straight from the object store.
+*/
+func objectStorageDownload(files []*models.CodeFilesCacheOutput) error {
+
+	keys := []string{}
+	seen := map[string]bool{}
+	for _, f := range files {
+		if f.External && !seen[f.ObjectKey] {
+			seen[f.ObjectKey] = true
+			keys = append(keys, f.ObjectKey)
+		}
+	}
+
+	contents := map[string][]byte{}
+	for start := 0; start < len(keys); start += presignBatch {
+
+		end := start + presignBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		response := models.CodeFilesPresignResponse{}
+		err := messageq.NATSencoded.Request("code-file-presign", models.CodeFilesPresign{ObjectKeys: keys[start:end]}, &response, 10*time.Second)
+		if err != nil {
+			return errors.New("Presign code files: " + err.Error())
+		}
+		if response.R != "ok" {
+			return errors.New("Presign code files: " + response.M)
+		}
+
+		err = presignedDownload(response.URLs, contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		if f.External {
+			f.FileStore = contents[f.ObjectKey]
+		}
+	}
+
+	return nil
+}
+
+/* presignedDownload downloads each key from its URL into contents. */
+func presignedDownload(urls map[string]string, contents map[string][]byte) error {
+
+	for key, u := range urls {
+
+		resp, err := presignClient.Get(u)
+		if err != nil {
+			return fmt.Errorf("Download %s: %w", key, err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("Download %s: %w", key, err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("Download %s: status %d", key, resp.StatusCode)
+		}
+
+		contents[key] = body
+
+		if wrkerconfig.Debug == "true" {
+			log.Println("Object storage download:", key, len(body))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package distfilesystem
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestPresignedDownload$ github.com/dataplane-app/dataplane/app/workers/distfilesystem
+*/
+func TestPresignedDownload(t *testing.T) {
+
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("X-Amz-Signature") != "ok" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("content of " + r.URL.Path))
+	}))
+	defer s3.Close()
+
+	contents := map[string][]byte{}
+	err := presignedDownload(map[string]string{
+		"a": s3.URL + "/code/a?X-Amz-Signature=ok",
+		"b": s3.URL + "/code/b?X-Amz-Signature=ok",
+	}, contents)
+	assert.NoError(t, err)
+	assert.Equal(t, "content of /code/a", string(contents["a"]))
+	assert.Equal(t, "content of /code/b", string(contents["b"]))
+
+	// An expired or wrong signature fails the download
+	err = presignedDownload(map[string]string{"c": s3.URL + "/code/c?X-Amz-Signature=expired"}, contents)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "status 403")
+}
@@ -0,0 +1,149 @@
+package filetrigger
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
+	"github.com/dataplane-app/dataplane/app/workers/logging"
+	"github.com/dataplane-app/dataplane/app/workers/messageq"
+)
+
+// Stops a large directory from holding up the other triggers
+const fileScanMax = 10000
+
+var errScanMax = errors.New("file scan limit reached")
+
+type fileSeen struct {
+	size    int64
+	modTime time.Time
+	sent    bool
+}
+
+/*
+FileTriggerWatch scans the directories of the file triggers for this worker group and tells the main app when a file arrives.
+A file is only sent once its size and modified time are the same across two scans so that files still being written are not picked up.
+Every worker in the group scans, the main app starts one run per file.
+*/
+func FileTriggerWatch() {
+
+	i, _ := strconv.Atoi(os.Getenv("DP_FILE_TRIGGER_SECONDS"))
+	if i < 1 || i > 3600 {
+		i = 10
+	}
+
+	log.Println("📂 File trigger interval: " + strconv.Itoa(i) + " second(s)")
+
+	// node|path -> last scan
+	seen := make(map[string]fileSeen)
+
+	ticker := time.NewTicker(time.Duration(i) * time.Second)
+
+	go func() {
+		for range ticker.C {
+
+			var triggers []models.EventTriggers
+			err := database.DBConn.Where("trigger_type = ? and worker_group = ? and environment_id = ? and online = true", "file", wrkerconfig.WorkerGroup, wrkerconfig.EnvID).Find(&triggers).Error
+			if err != nil {
+				logging.PrintSecretsRedact("File trigger:", err)
+				continue
+			}
+
+			current := make(map[string]fileSeen)
+
+			for _, t := range triggers {
+
+				// Files already there when the trigger was saved or turned on don't start it
+				since := t.CreatedAt
+				if t.UpdatedAt != nil && t.UpdatedAt.After(since) {
+					since = *t.UpdatedAt
+				}
+
+				scanned := 0
+				err := filepath.WalkDir(t.Path, func(path string, d fs.DirEntry, err error) error {
+					if err != nil {
+						// Directory may not exist yet
+						return nil
+					}
+					if d.IsDir() {
+						return nil
+					}
+
+					scanned++
+					if scanned > fileScanMax {
+						return errScanMax
+					}
+
+					if !EventFileMatch(t.Pattern, d.Name()) {
+						return nil
+					}
+
+					info, err := d.Info()
+					if err != nil || !info.Mode().IsRegular() {
+						return nil
+					}
+
+					if info.ModTime().Before(since) {
+						return nil
+					}
+
+					key := t.NodeID + "|" + path
+					file := fileSeen{size: info.Size(), modTime: info.ModTime()}
+
+					previous, ok := seen[key]
+					if ok && previous.size == file.size && previous.modTime.Equal(file.modTime) {
+						file.sent = previous.sent
+						if !file.sent {
+							err := messageq.MsgSend("event-trigger-file", models.EventTriggerFile{
+								NodeID:        t.NodeID,
+								PipelineID:    t.PipelineID,
+								EnvironmentID: t.EnvironmentID,
+								FilePath:      path,
+								Size:          file.size,
+								ModTime:       file.modTime.UTC(),
+								WorkerID:      wrkerconfig.WorkerID,
+							})
+							if err != nil {
+								logging.PrintSecretsRedact("NATS error:", err)
+							} else {
+								file.sent = true
+							}
+						}
+					}
+
+					current[key] = file
+					return nil
+				})
+				if err != nil && (err != errScanMax || wrkerconfig.Debug == "true") {
+					logging.PrintSecretsRedact("File trigger:", t.Path, err)
+				}
+			}
+
+			// Files removed or triggers turned off are forgotten
+			seen = current
+		}
+	}()
+}
+
+/*
+EventFileMatch checks a file name against the pattern of a file trigger, an empty pattern matches all files.
+*/
+func EventFileMatch(pattern string, name string) bool {
+
+	if pattern == "" {
+		return true
+	}
+
+	match, err := filepath.Match(pattern, name)
+	if err != nil {
+		return false
+	}
+	return match
+}
@@ -0,0 +1,18 @@
+package filetrigger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestEventFileMatch$ github.com/dataplane-app/dataplane/app/workers/filetrigger
+*/
+func TestEventFileMatch(t *testing.T) {
+
+	assert.True(t, EventFileMatch("", "anything.txt"), "Empty pattern")
+	assert.True(t, EventFileMatch("*.csv", "sales.csv"), "Pattern match")
+	assert.False(t, EventFileMatch("*.csv", "sales.csv.tmp"), "Pattern no match")
+	assert.False(t, EventFileMatch("[a-", "a"), "Bad pattern")
+}
@@ -18,3 +18,13 @@ func PrintSecretsRedact(logmessages ...interface{}) {
 
 	log.Println(wrkerconfig.Secrets.Replace(printString))
 }
+
+/* Redact replaces the secrets in a log line, before secrets are loaded the line is returned as is */
+func Redact(line string) string {
+
+	if wrkerconfig.Secrets == nil {
+		return line
+	}
+
+	return wrkerconfig.Secrets.Replace(line)
+}
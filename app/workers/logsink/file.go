@@ -0,0 +1,163 @@
+package logsink
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+)
+
+/*
+FileSink writes NDJSON lines to local files, a new file is started each day and when a file reaches its maximum size.
+Files are named <prefix>-<date>-<sequence>.ndjson and removed once older than the maximum age.
+*/
+type FileSink struct {
+	mu       sync.Mutex
+	dir      string
+	prefix   string
+	maxBytes int64
+	maxAge   time.Duration
+	file     *os.File
+	size     int64
+	day      string
+	seq      int
+	now      func() time.Time
+}
+
+func NewFileSink(dir string, prefix string, maxBytes int64, maxAge time.Duration) (*FileSink, error) {
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		dir:      dir,
+		prefix:   prefix,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		now:      time.Now,
+	}, nil
+}
+
+func (f *FileSink) Name() string {
+	return "file " + f.dir
+}
+
+func (f *FileSink) Write(line modelmain.LogsWorkers) error {
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	day := f.now().UTC().Format("2006-01-02")
+
+	if f.file == nil || day != f.day || (f.size > 0 && f.size+int64(len(b)) > f.maxBytes) {
+		if err := f.rotate(day); err != nil {
+			return err
+		}
+	}
+
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+
+	return err
+}
+
+func (f *FileSink) Close() error {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return err
+}
+
+/* Opens the next file with room, files left from before a restart are appended to */
+func (f *FileSink) rotate(day string) error {
+
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+		f.seq++
+	}
+
+	if day != f.day {
+		f.day = day
+		f.seq = 0
+	}
+
+	for {
+		name := filepath.Join(f.dir, fmt.Sprintf("%s-%s-%03d.ndjson", f.prefix, f.day, f.seq))
+
+		info, err := os.Stat(name)
+		if err == nil && info.Size() >= f.maxBytes {
+			f.seq++
+			continue
+		}
+
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+
+		f.file = file
+		f.size = 0
+		if info != nil {
+			f.size = info.Size()
+		}
+		break
+	}
+
+	f.prune()
+
+	return nil
+}
+
+/* Removes files of this sink past the maximum age */
+func (f *FileSink) prune() {
+
+	if f.maxAge <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-f.maxAge)
+
+	for _, e := range entries {
+
+		if e.IsDir() || !strings.HasPrefix(e.Name(), f.prefix+"-") || !strings.HasSuffix(e.Name(), ".ndjson") {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+
+		if f.file != nil && filepath.Base(f.file.Name()) == e.Name() {
+			continue
+		}
+
+		os.Remove(filepath.Join(f.dir, e.Name()))
+	}
+}
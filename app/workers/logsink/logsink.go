@@ -0,0 +1,214 @@
+package logsink
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
+	"github.com/dataplane-app/dataplane/app/workers/logging"
+)
+
+/*
+A Sink keeps task output outside of the database, alongside logs_workers which is cleaned up after DP_REMOVELOGS_DAYS.
+Sinks are set with DP_LOG_SINKS, any of: file, s3, syslog, otlp.
+Lines reach a sink with secrets already redacted.
+*/
+type Sink interface {
+	Name() string
+	Write(line modelmain.LogsWorkers) error
+	Close() error
+}
+
+/*
+Lines waiting for the sinks. Once the queue is full a task waits up to DP_LOG_SINK_BLOCK_SECONDS for room,
+then the line is written straight to the file sink if there is one. Lines are only dropped without waiting
+when DP_LOG_SINK_DROP_WHEN_FULL is true.
+*/
+const queueSize = 10000
+
+var mu sync.RWMutex
+var sinks []Sink
+var spill Sink
+var queue chan modelmain.LogsWorkers
+var stopped sync.WaitGroup
+var dropped int64
+var spilled int64
+
+var blockTimeout = 5 * time.Second
+var dropWhenFull = false
+
+/*
+Setup creates the sinks set in the worker config and starts sending lines to them.
+*/
+func Setup() error {
+
+	configured := []Sink{}
+
+	for _, name := range wrkerconfig.LogSinks {
+
+		var sink Sink
+		var err error
+
+		switch name {
+		case "file":
+			hostname, _ := os.Hostname()
+			sink, err = NewFileSink(
+				wrkerconfig.LogSinkFileDir,
+				"tasks-"+wrkerconfig.WorkerGroup+"-"+hostname,
+				int64(wrkerconfig.LogSinkFileMaxMB)*1024*1024,
+				time.Duration(wrkerconfig.LogSinkFileMaxAgeDays)*24*time.Hour,
+			)
+		case "s3":
+			sink, err = NewS3Sink(S3Config{
+				Endpoint:  wrkerconfig.LogSinkS3Endpoint,
+				SSL:       wrkerconfig.LogSinkS3SSL,
+				Region:    wrkerconfig.LogSinkS3Region,
+				Bucket:    wrkerconfig.LogSinkS3Bucket,
+				Prefix:    path.Join(wrkerconfig.LogSinkS3Prefix, wrkerconfig.EnvID, wrkerconfig.WorkerGroup),
+				Name:      wrkerconfig.WorkerID,
+				AccessKey: wrkerconfig.LogSinkS3AccessKey,
+				SecretKey: wrkerconfig.LogSinkS3SecretKey,
+				Flush:     time.Duration(wrkerconfig.LogSinkS3FlushSeconds) * time.Second,
+			})
+		case "syslog":
+			sink, err = NewSyslogSink(wrkerconfig.LogSinkSyslogNetwork, wrkerconfig.LogSinkSyslogAddress)
+		case "otlp":
+			sink, err = NewOTLPSink(wrkerconfig.LogSinkOTLPEndpoint, "dataplane-worker", 5*time.Second)
+		default:
+			err = errors.New("Log sink not recognised: " + name)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		configured = append(configured, sink)
+		log.Println("🪵 Log sink:", sink.Name())
+	}
+
+	blockTimeout = time.Duration(wrkerconfig.LogSinkBlockSeconds) * time.Second
+	dropWhenFull = wrkerconfig.LogSinkDropWhenFull
+
+	Start(configured)
+
+	return nil
+}
+
+/*
+Start sends the lines written to the sinks given.
+*/
+func Start(s []Sink) {
+
+	if len(s) == 0 {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	sinks = s
+	queue = make(chan modelmain.LogsWorkers, queueSize)
+
+	spill = nil
+	for _, sink := range s {
+		if _, ok := sink.(*FileSink); ok {
+			spill = sink
+			break
+		}
+	}
+
+	stopped.Add(1)
+	go func(queue chan modelmain.LogsWorkers) {
+		defer stopped.Done()
+
+		lastError := map[string]time.Time{}
+
+		for line := range queue {
+			for _, sink := range s {
+				if err := sink.Write(line); err != nil {
+					// Log sink errors at most once a minute so a sink that is down doesn't flood the worker log
+					if time.Since(lastError[sink.Name()]) > time.Minute {
+						logging.PrintSecretsRedact("Log sink", sink.Name(), "error:", err)
+						lastError[sink.Name()] = time.Now()
+					}
+				}
+			}
+		}
+	}(queue)
+}
+
+/*
+Write redacts the secrets of a line of task output and queues it for the sinks.
+*/
+func Write(line modelmain.LogsWorkers) {
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if queue == nil {
+		return
+	}
+
+	line.Log = logging.Redact(line.Log)
+
+	select {
+	case queue <- line:
+		return
+	default:
+	}
+
+	if !dropWhenFull {
+		timer := time.NewTimer(blockTimeout)
+		defer timer.Stop()
+
+		select {
+		case queue <- line:
+			return
+		case <-timer.C:
+		}
+
+		// Keep the line locally rather than lose it, the other sinks miss it
+		if spill != nil && spill.Write(line) == nil {
+			if atomic.AddInt64(&spilled, 1)%1000 == 1 {
+				log.Println("Log sinks are behind, lines written to the file sink only:", atomic.LoadInt64(&spilled))
+			}
+			return
+		}
+	}
+
+	if atomic.AddInt64(&dropped, 1)%1000 == 1 {
+		log.Println("Log sinks are behind, lines dropped:", atomic.LoadInt64(&dropped))
+	}
+}
+
+/*
+Close sends the lines still queued and flushes the sinks, for when the worker stops.
+*/
+func Close() {
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if queue == nil {
+		return
+	}
+
+	close(queue)
+	stopped.Wait()
+	queue = nil
+
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			logging.PrintSecretsRedact("Log sink", sink.Name(), "close error:", err)
+		}
+	}
+
+	sinks = nil
+	spill = nil
+}
@@ -0,0 +1,344 @@
+package logsink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
+	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
+	"github.com/stretchr/testify/assert"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func testLine(log string, logType string) modelmain.LogsWorkers {
+	return modelmain.LogsWorkers{
+		CreatedAt:     time.Now().UTC(),
+		EnvironmentID: "env1",
+		UID:           "uid-" + log,
+		RunID:         "run1",
+		NodeID:        "node1",
+		TaskID:        "task1",
+		Category:      "task",
+		Log:           log,
+		LogType:       logType,
+	}
+}
+
+/*
+go test -timeout 30s -v -run ^TestFileSink$ github.com/dataplane-app/dataplane/app/workers/logsink
+*/
+func TestFileSink(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// An old file of the sink and a file of something else
+	old := filepath.Join(dir, "tasks-test-2020-01-01-000.ndjson")
+	other := filepath.Join(dir, "other.ndjson")
+	os.WriteFile(old, []byte("{}\n"), 0644)
+	os.WriteFile(other, []byte("{}\n"), 0644)
+	past := time.Now().Add(-400 * 24 * time.Hour)
+	os.Chtimes(old, past, past)
+	os.Chtimes(other, past, past)
+
+	sink, err := NewFileSink(dir, "tasks-test", 300, 365*24*time.Hour)
+	assert.NoError(t, err, "New file sink")
+
+	day := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	sink.now = func() time.Time { return day }
+
+	for i := 0; i < 4; i++ {
+		assert.NoError(t, sink.Write(testLine("line", "info")), "Write line")
+	}
+
+	// ----- Next day starts a new file
+	day = day.Add(24 * time.Hour)
+	assert.NoError(t, sink.Write(testLine("next day", "info")), "Write next day")
+	assert.NoError(t, sink.Close(), "Close")
+
+	_, err = os.Stat(old)
+	assert.True(t, os.IsNotExist(err), "Old file removed")
+	_, err = os.Stat(other)
+	assert.NoError(t, err, "Other files kept")
+
+	first, _ := filepath.Glob(filepath.Join(dir, "tasks-test-2022-06-01-*.ndjson"))
+	assert.Greater(t, len(first), 1, "Rotated on size")
+
+	for _, f := range first {
+		info, _ := os.Stat(f)
+		assert.LessOrEqual(t, info.Size(), int64(300), "File within max size")
+	}
+
+	second, _ := os.ReadFile(filepath.Join(dir, "tasks-test-2022-06-02-000.ndjson"))
+	var line modelmain.LogsWorkers
+	assert.NoError(t, json.Unmarshal(bytes.TrimSpace(second), &line), "NDJSON line")
+	assert.Equal(t, "next day", line.Log, "Line of the next day")
+
+	// ----- A restart appends to the file with room
+	sink, _ = NewFileSink(dir, "tasks-test", 300, 0)
+	sink.now = func() time.Time { return day }
+	assert.NoError(t, sink.Write(testLine("restart", "info")), "Write after restart")
+	sink.Close()
+
+	second, _ = os.ReadFile(filepath.Join(dir, "tasks-test-2022-06-02-000.ndjson"))
+	assert.Equal(t, 2, strings.Count(string(second), "\n"), "Appended after restart")
+}
+
+/*
+go test -timeout 30s -v -run ^TestS3Sink$ github.com/dataplane-app/dataplane/app/workers/logsink
+*/
+func TestS3Sink(t *testing.T) {
+
+	// ----- A MinIO stand-in that keeps the objects put
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	minio := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=access/", "Signed request")
+
+		body, _ := io.ReadAll(r.Body)
+
+		// Over plain HTTP the payload is signed in chunks: <size hex>;chunk-signature=<sig>\r\n<data>\r\n
+		if strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+			chunks := bufio.NewReader(bytes.NewReader(body))
+			decoded := []byte{}
+			for {
+				header, err := chunks.ReadString('\n')
+				if err != nil {
+					break
+				}
+				size, _ := strconv.ParseInt(strings.SplitN(header, ";", 2)[0], 16, 64)
+				if size == 0 {
+					break
+				}
+				data := make([]byte, size+2)
+				io.ReadFull(chunks, data)
+				decoded = append(decoded, data[:size]...)
+			}
+			body = decoded
+		}
+
+		mu.Lock()
+		objects[r.URL.Path] = body
+		mu.Unlock()
+
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer minio.Close()
+
+	wrkerconfig.Secrets = strings.NewReplacer("squirrel", "** Secret **")
+	defer func() { wrkerconfig.Secrets = nil }()
+
+	sink, err := NewS3Sink(S3Config{
+		Endpoint:  strings.TrimPrefix(minio.URL, "http://"),
+		Region:    "us-east-1",
+		Bucket:    "logs",
+		Prefix:    "dataplane-logs/env1/python_1",
+		Name:      "worker1",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Flush:     time.Hour,
+	})
+	assert.NoError(t, err, "New S3 sink")
+
+	// ----- Lines go through the queue so they are redacted before upload
+	Start([]Sink{sink})
+	Write(testLine("the grey squirrel", "info"))
+	Write(testLine("done", "error"))
+	Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.Equal(t, 1, len(objects), "One object uploaded on close") {
+		for key, body := range objects {
+			assert.True(t, strings.HasPrefix(key, "/logs/dataplane-logs/env1/python_1/"+time.Now().UTC().Format("2006/01/02")+"/worker1-"), "Object key "+key)
+			assert.True(t, strings.HasSuffix(key, ".ndjson.gz"), "Gzipped NDJSON")
+
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			assert.NoError(t, err, "Gzip object")
+
+			lines := []modelmain.LogsWorkers{}
+			scanner := bufio.NewScanner(gz)
+			for scanner.Scan() {
+				var line modelmain.LogsWorkers
+				assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line), "NDJSON line")
+				lines = append(lines, line)
+			}
+
+			if assert.Equal(t, 2, len(lines), "Lines uploaded") {
+				assert.Equal(t, "the grey ** Secret **", lines[0].Log, "Secret redacted")
+				assert.Equal(t, "run1", lines[0].RunID, "Run ID kept")
+				assert.Equal(t, "error", lines[1].LogType, "Log type kept")
+			}
+		}
+	}
+}
+
+/*
+go test -timeout 30s -v -run ^TestOTLPSink$ github.com/dataplane-app/dataplane/app/workers/logsink
+*/
+func TestOTLPSink(t *testing.T) {
+
+	// ----- A stand-in OTLP collector
+	var mu sync.Mutex
+	var received []*collogspb.ExportLogsServiceRequest
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		assert.Equal(t, "/v1/logs", r.URL.Path, "OTLP logs path")
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"), "Header from OTEL_EXPORTER_OTLP_HEADERS")
+
+		data, _ := io.ReadAll(r.Body)
+
+		var req collogspb.ExportLogsServiceRequest
+		assert.NoError(t, proto.Unmarshal(data, &req), "OTLP request")
+
+		mu.Lock()
+		received = append(received, &req)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_HEADERS", "Authorization=Bearer%20token")
+
+	sink, err := NewOTLPSink(collector.URL+"/v1/logs", "dataplane-worker", time.Hour)
+	assert.NoError(t, err, "New OTLP sink")
+
+	assert.NoError(t, sink.Write(testLine("failed", "error")), "Write line")
+	assert.NoError(t, sink.Close(), "Close")
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.Equal(t, 1, len(received), "One export") {
+		rl := received[0].ResourceLogs[0]
+		assert.Equal(t, "dataplane-worker", rl.Resource.Attributes[0].Value.GetStringValue(), "Service name")
+
+		record := rl.ScopeLogs[0].LogRecords[0]
+		assert.Equal(t, "failed", record.Body.GetStringValue(), "Log body")
+		assert.Equal(t, "ERROR", record.SeverityText, "Severity")
+
+		traceID := tracing.RunSpanContext("run1").TraceID()
+		assert.True(t, bytes.Equal(traceID[:], record.TraceId), "Trace ID of the run")
+	}
+}
+
+/*
+go test -timeout 30s -v -run ^TestSyslogSink$ github.com/dataplane-app/dataplane/app/workers/logsink
+*/
+func TestSyslogSink(t *testing.T) {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err, "Syslog stand-in")
+	defer conn.Close()
+
+	sink, err := NewSyslogSink("udp", conn.LocalAddr().String())
+	assert.NoError(t, err, "New syslog sink")
+
+	assert.NoError(t, sink.Write(testLine("failed", "error")), "Write line")
+	sink.Close()
+
+	buf := make([]byte, 2048)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(t, err, "Syslog message received")
+
+	msg := string(buf[:n])
+	assert.True(t, strings.HasPrefix(msg, "<11>"), "Error severity of the user facility "+msg)
+	assert.Contains(t, msg, "run_id=run1 node_id=node1 task_id=task1 failed", "Message")
+}
+
+type blockedSink struct {
+	release chan struct{}
+}
+
+func (b *blockedSink) Name() string { return "blocked" }
+func (b *blockedSink) Write(line modelmain.LogsWorkers) error {
+	<-b.release
+	return nil
+}
+func (b *blockedSink) Close() error { return nil }
+
+/*
+go test -timeout 30s -v -run ^TestWriteFull$ github.com/dataplane-app/dataplane/app/workers/logsink
+*/
+func TestWriteFull(t *testing.T) {
+
+	dir := t.TempDir()
+	file, err := NewFileSink(dir, "tasks-test", 1024*1024, 0)
+	assert.NoError(t, err, "New file sink")
+
+	blocked := &blockedSink{release: make(chan struct{})}
+
+	blockTimeout = 50 * time.Millisecond
+	defer func() {
+		blockTimeout = 5 * time.Second
+		dropWhenFull = false
+	}()
+
+	// The sink ahead of the file sink holds up the queue
+	Start([]Sink{blocked, file})
+
+	fill := func() {
+		for i := 0; i <= queueSize; i++ {
+			Write(testLine("queued", "info"))
+		}
+	}
+	fill()
+
+	// ----- A full queue waits, then spills to the file sink
+	start := time.Now()
+	Write(testLine("spilled", "info"))
+	assert.GreaterOrEqual(t, time.Since(start), blockTimeout, "Waited for room")
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "tasks-test-*.ndjson"))
+	assert.Equal(t, 1, len(matches), "Spill file")
+	content, _ := os.ReadFile(matches[0])
+	assert.Equal(t, 1, strings.Count(string(content), "\n"), "Spilled line only")
+	assert.Contains(t, string(content), `"spilled"`, "Spilled line")
+
+	// ----- Dropping when full is opt in and doesn't wait
+	dropWhenFull = true
+	before := atomic.LoadInt64(&dropped)
+	start = time.Now()
+	Write(testLine("dropped", "info"))
+	assert.Less(t, time.Since(start), blockTimeout, "Dropped without waiting")
+	assert.Equal(t, before+1, atomic.LoadInt64(&dropped), "Dropped count")
+
+	close(blocked.release)
+	Close()
+
+	content = nil
+	matches, _ = filepath.Glob(filepath.Join(dir, "tasks-test-*.ndjson"))
+	for _, m := range matches {
+		b, _ := os.ReadFile(m)
+		content = append(content, b...)
+	}
+	assert.Equal(t, queueSize+2, strings.Count(string(content), "\n"), "Queued lines sent after the sink caught up")
+	assert.NotContains(t, string(content), `"dropped"`, "Dropped line")
+}
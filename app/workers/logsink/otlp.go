@@ -0,0 +1,214 @@
+package logsink
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+/* Records sent in one export and the most kept while the collector can't be reached */
+const otlpBatch = 512
+const otlpMaxPending = 10000
+
+/*
+OTLPSink exports lines as OTLP logs over HTTP, each record carries the trace ID of its run so logs line up with the run's trace.
+Headers are taken from OTEL_EXPORTER_OTLP_LOGS_HEADERS or OTEL_EXPORTER_OTLP_HEADERS.
+*/
+type OTLPSink struct {
+	mu       sync.Mutex
+	endpoint string
+	headers  map[string]string
+	resource *resourcepb.Resource
+	client   *http.Client
+	records  []*logspb.LogRecord
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func NewOTLPSink(endpoint string, serviceName string, flush time.Duration) (*OTLPSink, error) {
+
+	if endpoint == "" {
+		return nil, errors.New("OTLP log sink requires DP_LOG_SINK_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+
+	headers := os.Getenv("OTEL_EXPORTER_OTLP_LOGS_HEADERS")
+	if headers == "" {
+		headers = os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	}
+
+	s := &OTLPSink{
+		endpoint: endpoint,
+		headers:  otlpHeaders(headers),
+		resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{otlpString("service.name", serviceName)}},
+		client:   &http.Client{Timeout: 10 * time.Second},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(flush)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				s.flush()
+				s.mu.Unlock()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *OTLPSink) Name() string {
+	return "otlp " + s.endpoint
+}
+
+func (s *OTLPSink) Write(line modelmain.LogsWorkers) error {
+
+	severity := logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	switch line.LogType {
+	case "error":
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case "debug":
+		severity = logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	}
+
+	traceID := tracing.RunSpanContext(line.RunID).TraceID()
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(line.CreatedAt.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity,
+		SeverityText:         strings.ToUpper(line.LogType),
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: line.Log}},
+		Attributes: []*commonpb.KeyValue{
+			otlpString("dataplane.environment_id", line.EnvironmentID),
+			otlpString("dataplane.run_id", line.RunID),
+			otlpString("dataplane.node_id", line.NodeID),
+			otlpString("dataplane.task_id", line.TaskID),
+			otlpString("dataplane.log_uid", line.UID),
+		},
+		TraceId: traceID[:],
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, record)
+
+	if len(s.records) >= otlpBatch {
+		return s.flush()
+	}
+
+	return nil
+}
+
+func (s *OTLPSink) Close() error {
+
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush()
+}
+
+/* Exports the batched records, they are kept for the next flush if the export fails */
+func (s *OTLPSink) flush() error {
+
+	if len(s.records) == 0 {
+		return nil
+	}
+
+	req := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: s.resource,
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: "github.com/dataplane-app/dataplane"},
+				LogRecords: s.records,
+			}},
+		}},
+	}
+
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range s.headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(httpReq)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("collector responded %d", resp.StatusCode)
+		}
+	}
+
+	if err != nil {
+		if len(s.records) > otlpMaxPending {
+			s.records = s.records[len(s.records)-otlpMaxPending:]
+		}
+		return err
+	}
+
+	s.records = nil
+
+	return nil
+}
+
+func otlpString(key string, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+/* Headers in the OTEL format: key1=value1,key2=value2 with URL encoded values */
+func otlpHeaders(raw string) map[string]string {
+
+	headers := map[string]string{}
+
+	for _, pair := range strings.Split(raw, ",") {
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			continue
+		}
+
+		value, err := url.QueryUnescape(strings.TrimSpace(kv[1]))
+		if err != nil {
+			value = strings.TrimSpace(kv[1])
+		}
+
+		headers[strings.TrimSpace(kv[0])] = value
+	}
+
+	return headers
+}
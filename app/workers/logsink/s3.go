@@ -0,0 +1,164 @@
+package logsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+/* An object is uploaded once this much is buffered, without waiting for the flush interval */
+const s3ObjectBytes = 8 * 1024 * 1024
+
+/* If uploads keep failing, buffered lines past this are dropped */
+const s3MaxPendingBytes = 64 * 1024 * 1024
+
+type S3Config struct {
+	Endpoint  string
+	SSL       bool
+	Region    string
+	Bucket    string
+	Prefix    string
+	Name      string
+	AccessKey string
+	SecretKey string
+	Flush     time.Duration
+}
+
+/*
+S3Sink uploads gzipped NDJSON objects to S3 compatible storage, such as AWS S3 or MinIO.
+Objects are keyed <prefix>/<yyyy>/<mm>/<dd>/<name>-<unix nano>.ndjson.gz, bucket lifecycle rules can then set retention.
+*/
+type S3Sink struct {
+	mu     sync.Mutex
+	client *minio.Client
+	config S3Config
+	buf    bytes.Buffer
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewS3Sink(config S3Config) (*S3Sink, error) {
+
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("S3 log sink requires DP_LOG_SINK_S3_ENDPOINT and DP_LOG_SINK_S3_BUCKET")
+	}
+
+	client, err := minio.New(config.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure: config.SSL,
+		Region: config.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Flush <= 0 {
+		config.Flush = time.Minute
+	}
+
+	s := &S3Sink{
+		client: client,
+		config: config,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(config.Flush)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.mu.Lock()
+				s.flush()
+				s.mu.Unlock()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return s, nil
+}
+
+func (s *S3Sink) Name() string {
+	return "s3 " + s.config.Endpoint + "/" + s.config.Bucket
+}
+
+func (s *S3Sink) Write(line modelmain.LogsWorkers) error {
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Write(b)
+	s.buf.WriteByte('\n')
+
+	if s.buf.Len() >= s3ObjectBytes {
+		return s.flush()
+	}
+
+	return nil
+}
+
+func (s *S3Sink) Close() error {
+
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.flush()
+}
+
+/* Uploads the buffered lines, they are kept for the next flush if the upload fails */
+func (s *S3Sink) flush() error {
+
+	if s.buf.Len() == 0 {
+		return nil
+	}
+
+	var payload bytes.Buffer
+	gz := gzip.NewWriter(&payload)
+	gz.Write(s.buf.Bytes())
+	gz.Close()
+
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s/%s/%s-%d.ndjson.gz", s.config.Prefix, now.Format("2006/01/02"), s.config.Name, now.UnixNano())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := s.client.PutObject(ctx, s.config.Bucket, key, bytes.NewReader(payload.Bytes()), int64(payload.Len()), minio.PutObjectOptions{
+		ContentType:     "application/x-ndjson",
+		ContentEncoding: "gzip",
+	})
+	if err != nil {
+		if s.buf.Len() > s3MaxPendingBytes {
+			s.buf.Reset()
+			return fmt.Errorf("upload failed, buffered lines dropped: %w", err)
+		}
+		return err
+	}
+
+	s.buf.Reset()
+
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build !windows && !plan9
+
+package logsink
+
+import (
+	"fmt"
+	"log/syslog"
+
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+)
+
+/*
+SyslogSink sends lines to syslog, the local daemon when no address is set.
+*/
+type SyslogSink struct {
+	name   string
+	writer *syslog.Writer
+}
+
+func NewSyslogSink(network string, address string) (*SyslogSink, error) {
+
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_USER, "dataplane-worker")
+	if err != nil {
+		return nil, err
+	}
+
+	name := "syslog"
+	if address != "" {
+		name += " " + network + "://" + address
+	}
+
+	return &SyslogSink{name: name, writer: writer}, nil
+}
+
+func (s *SyslogSink) Name() string {
+	return s.name
+}
+
+func (s *SyslogSink) Write(line modelmain.LogsWorkers) error {
+
+	msg := fmt.Sprintf("environment_id=%s run_id=%s node_id=%s task_id=%s %s", line.EnvironmentID, line.RunID, line.NodeID, line.TaskID, line.Log)
+
+	switch line.LogType {
+	case "error":
+		return s.writer.Err(msg)
+	case "debug":
+		return s.writer.Debug(msg)
+	default:
+		return s.writer.Info(msg)
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
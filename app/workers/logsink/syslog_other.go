@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package logsink
+
+import "errors"
+
+/* Syslog isn't available on Windows or Plan 9 */
+func NewSyslogSink(network string, address string) (Sink, error) {
+	return nil, errors.New("Syslog log sink is not supported on this platform.")
+}
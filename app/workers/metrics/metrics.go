@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"github.com/gofiber/adaptor/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+/*
+Metrics of a worker served on /metrics in the Prometheus text format.
+Every metric is labelled with the environment and worker group, the worker itself is the scrape target.
+*/
+
+var TaskSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "dataplane_worker_task_duration_seconds",
+	Help:    "Time tasks took to run on the worker by status: Success or Fail.",
+	Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 300, 900, 1800, 3600},
+}, []string{"environment_id", "worker_group", "status"})
+
+var TasksRunning = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dataplane_worker_tasks_running",
+	Help: "Tasks running on the worker.",
+}, []string{"environment_id", "worker_group"})
+
+var CPUPercent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dataplane_worker_cpu_percent",
+	Help: "CPU used by the worker, of the container when the worker type is container.",
+}, []string{"environment_id", "worker_group"})
+
+var MemoryPercent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dataplane_worker_memory_percent",
+	Help: "Memory used by the worker as a percent of the memory available.",
+}, []string{"environment_id", "worker_group"})
+
+var MemoryUsedBytes = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dataplane_worker_memory_used_bytes",
+	Help: "Memory used by the worker.",
+}, []string{"environment_id", "worker_group"})
+
+var Load = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "dataplane_worker_load1",
+	Help: "One minute load average of the worker host.",
+}, []string{"environment_id", "worker_group"})
+
+/*
+Handler serves the metrics.
+*/
+func Handler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
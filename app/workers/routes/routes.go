@@ -9,9 +9,13 @@ import (
 
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
 	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
 	"github.com/dataplane-app/dataplane/app/mainapp/utilities"
 	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
+	"github.com/dataplane-app/dataplane/app/workers/filetrigger"
+	"github.com/dataplane-app/dataplane/app/workers/logsink"
 	"github.com/dataplane-app/dataplane/app/workers/messageq"
+	"github.com/dataplane-app/dataplane/app/workers/metrics"
 	runcodeworker "github.com/dataplane-app/dataplane/app/workers/runcode"
 	"github.com/dataplane-app/dataplane/app/workers/runtask"
 	"github.com/dataplane-app/dataplane/app/workers/secrets"
@@ -31,6 +35,9 @@ func Setup(port string) *fiber.App {
 
 	wrkerconfig.LoadConfig()
 
+	// ------ Tracing, exported if OTEL_EXPORTER_OTLP_ENDPOINT is set ----
+	tracing.Init("dataplane-worker")
+
 	// ------- DATABASE CONNECT ------
 
 	database.DBConnect()
@@ -121,6 +128,11 @@ func Setup(port string) *fiber.App {
 	wrkerconfig.WorkerID = uuid.NewString()
 	log.Println("👷 Worker Group and ID: ", wrkerconfig.WorkerGroup, " - ", wrkerconfig.WorkerID)
 
+	// ------- Log sinks, after secrets so lines are redacted before they leave the worker ------
+	if err := logsink.Setup(); err != nil {
+		panic("Log sinks: " + err.Error())
+	}
+
 	//recover from panic
 	app.Use(recover.New())
 
@@ -158,12 +170,16 @@ func Setup(port string) *fiber.App {
 		return c.SendString("Hello 👋! Healthy 🍏")
 	})
 
+	// Prometheus metrics
+	app.Get("/metrics", metrics.Handler())
+
 	/* ---- Listen for tasks ------- */
 	secrets.ListenSecretUpdates()
 	runtask.ListenTasks()
 	runcodeworker.ListenRunCode()
 	runcodeworker.CodeLoadPackagesListen()
 	runcodeworker.ListenDisributedStorageDownload()
+	filetrigger.FileTriggerWatch()
 
 	/* Every 5 seconds tell mainapp about my status
 	Needs to be called after listen for tasks to avoid timing issues when accepting tasks
@@ -127,8 +127,8 @@ func coderunworker(ctx context.Context, msg modelmain.CodeRun) {
 		directoryRun := codeDirectory + msg.Folder
 
 		switch wrkerconfig.FSCodeFileStorage {
-		case "Database":
-			// Database download
+		case "Database", "S3":
+			// Database download, files in object storage are downloaded from there
 			codeDirectory = wrkerconfig.FSCodeDirectory
 			directoryRun = codeDirectory + msg.Folder
 			err := distfilesystem.DistributedStoragePipelineDownload(msg.EnvironmentID, msg.Folder, msg.FolderID, msg.NodeID)
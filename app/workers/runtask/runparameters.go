@@ -0,0 +1,103 @@
+package runtask
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+
+	"gorm.io/datatypes"
+)
+
+/*
+TaskParametersEnv passes the run parameters and the API trigger payload to a task:
+DP_PARAM_<NAME> - value of each run parameter.
+DP_PARAMETERS_FILE - JSON file with all the run parameters.
+DP_PAYLOAD_FILE - the body the run was triggered with, only set for API triggered runs.
+DP_LOGICAL_DATE - RFC 3339 fire time in the schedule timezone, only set for scheduled, catch-up and backfill runs.
+*/
+func TaskParametersEnv(msg modelmain.WorkerTaskSend, run modelmain.PipelineRuns, dir string) ([]string, error) {
+
+	var env []string
+
+	parameters := make(map[string]json.RawMessage)
+	if len(run.Parameters) > 0 {
+		err := json.Unmarshal(run.Parameters, &parameters)
+		if err != nil {
+			return env, err
+		}
+	}
+
+	parametersJSON, err := json.Marshal(parameters)
+	if err != nil {
+		return env, err
+	}
+
+	parametersFile := filepath.Join(dir, "parameters.json")
+	err = os.WriteFile(parametersFile, parametersJSON, 0600)
+	if err != nil {
+		return env, err
+	}
+	env = append(env, "DP_PARAMETERS_FILE="+parametersFile)
+
+	names := make([]string, 0, len(parameters))
+	for k := range parameters {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		env = append(env, RunParameterEnvName(k)+"="+OutputEnvValue(datatypes.JSON(parameters[k])))
+	}
+
+	// ----- API trigger payload
+	var payload datatypes.JSON
+	switch msg.RunType {
+	case "deployment":
+		var apiRuns []modelmain.DeploymentApiTriggerRuns
+		err = database.DBConn.Where("run_id = ?", msg.RunID).Limit(1).Find(&apiRuns).Error
+		if err == nil && len(apiRuns) > 0 {
+			payload = apiRuns[0].RunJSON
+		}
+	default:
+		var apiRuns []modelmain.PipelineApiTriggerRuns
+		err = database.DBConn.Where("run_id = ?", msg.RunID).Limit(1).Find(&apiRuns).Error
+		if err == nil && len(apiRuns) > 0 {
+			payload = apiRuns[0].RunJSON
+		}
+	}
+	if err != nil {
+		return env, err
+	}
+
+	if run.LogicalDate != nil {
+		logicalDate := *run.LogicalDate
+		if loc, err := time.LoadLocation(run.LogicalTimezone); err == nil {
+			logicalDate = logicalDate.In(loc)
+		}
+		env = append(env, "DP_LOGICAL_DATE="+logicalDate.Format(time.RFC3339))
+	}
+
+	if len(payload) > 0 {
+		payloadFile := filepath.Join(dir, "payload.json")
+		err = os.WriteFile(payloadFile, payload, 0600)
+		if err != nil {
+			return env, err
+		}
+		env = append(env, "DP_PAYLOAD_FILE="+payloadFile)
+	}
+
+	return env, nil
+}
+
+/*
+RunParameterEnvName is the environment variable a parameter is passed to tasks as e.g. region -> DP_PARAM_REGION
+*/
+func RunParameterEnvName(name string) string {
+	return "DP_PARAM_" + strings.ToUpper(name)
+}
@@ -0,0 +1,16 @@
+package runtask
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+go test -timeout 30s -v -run ^TestRunParameterEnvName$ github.com/dataplane-app/dataplane/app/workers/runtask
+*/
+func TestRunParameterEnvName(t *testing.T) {
+
+	assert.Equalf(t, "DP_PARAM_FULL_LOAD", RunParameterEnvName("full_load"), "Environment variable name")
+	assert.Equalf(t, "DP_PARAM_REGION", RunParameterEnvName("region"), "Lower case name")
+}
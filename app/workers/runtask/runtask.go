@@ -15,13 +15,19 @@ import (
 	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
 
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	"github.com/dataplane-app/dataplane/app/mainapp/tracing"
 	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
 	"github.com/dataplane-app/dataplane/app/workers/distfilesystem"
+	"github.com/dataplane-app/dataplane/app/workers/logsink"
 	"github.com/dataplane-app/dataplane/app/workers/messageq"
+	"github.com/dataplane-app/dataplane/app/workers/metrics"
 
 	"github.com/google/uuid"
 	cmap "github.com/orcaman/concurrent-map"
 	clog "github.com/pieterclaerhout/go-log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Runner struct {
@@ -51,6 +57,9 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 	var TasksStatusWG string
 	var TasksRun Task
 
+	// -1 = the command did not start or did not exit normally
+	var exitCode int
+
 	if wrkerconfig.Debug == "true" {
 		log.Printf("starting task with id %s - node: %s run: %s type: %s version: %s \n", msg.TaskID, msg.NodeID, msg.RunID, msg.RunType, msg.Version)
 	}
@@ -77,7 +86,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 	// --- Check if this task is already running
 	var lockCheck modelmain.WorkerTasks
-	err2 := database.DBConn.Select("task_id", "status").Where("task_id = ?", msg.TaskID).First(&lockCheck).Error
+	err2 := database.DBConn.Where("task_id = ?", msg.TaskID).First(&lockCheck).Error
 	if err2 != nil {
 		log.Println(err2.Error())
 		WSLogError("Task already running:"+err2.Error(), msg)
@@ -92,6 +101,19 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 	statusUpdate = "Run"
 
+	// --- Trace the task in the trace of its run, from the dispatch span of the main app
+	spanCtx := tracing.RunContext(context.Background(), msg.RunID)
+	if msg.TraceParent != "" {
+		spanCtx = tracing.Extract(context.Background(), msg.TraceParent)
+	}
+	spanCtx, span := tracing.Tracer().Start(spanCtx, "task.run", trace.WithAttributes(
+		attribute.String("dataplane.task_id", msg.TaskID),
+		attribute.String("dataplane.node_id", msg.NodeID),
+		attribute.String("dataplane.worker_group", wrkerconfig.WorkerGroup),
+		attribute.String("dataplane.worker_id", wrkerconfig.WorkerID),
+	))
+	defer span.End()
+
 	TaskUpdate := modelmain.WorkerTasks{
 		TaskID:        msg.TaskID,
 		CreatedAt:     time.Now().UTC(),
@@ -107,9 +129,39 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 	UpdateWorkerTasks(TaskUpdate)
 
+	metrics.TasksRunning.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup).Inc()
+	defer metrics.TasksRunning.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup).Dec()
+
+	// --- Record which attempt this is for retried tasks
+	if lockCheck.Attempt > 1 {
+		attemptLog := fmt.Sprintf("Attempt %d of %d", lockCheck.Attempt, lockCheck.RetryPolicy.MaxAttempts)
+		logmsg := modelmain.LogsWorkers{
+			CreatedAt:     time.Now().UTC(),
+			UID:           uuid.NewString(),
+			EnvironmentID: msg.EnvironmentID,
+			RunID:         msg.RunID,
+			NodeID:        msg.NodeID,
+			TaskID:        msg.TaskID,
+			Category:      "task",
+			Log:           attemptLog,
+			LogType:       "info",
+		}
+
+		sendmsg := modelmain.LogsSend{
+			CreatedAt: logmsg.CreatedAt,
+			UID:       logmsg.UID,
+			Log:       attemptLog,
+			LogType:   "info",
+		}
+
+		messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
+		database.DBConn.Create(&logmsg)
+		logsink.Write(logmsg)
+	}
+
 	// --- Check if pipeline has failed
 	var pipelineCheck modelmain.PipelineRuns
-	err2 = database.DBConn.Select("run_id", "status").Where("run_id = ?", msg.RunID).First(&pipelineCheck).Error
+	err2 = database.DBConn.Select("run_id", "status", "parameters", "logical_date", "logical_timezone").Where("run_id = ?", msg.RunID).First(&pipelineCheck).Error
 	if err2 != nil {
 		log.Println(err2.Error())
 		WSLogError("Skipping not in queue - runid:"+msg.RunID+" - node:"+msg.NodeID, msg)
@@ -134,11 +186,33 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 			Reason:        "Pipeline not running",
 			EndDT:         time.Now().UTC(),
 		}
-		UpdateWorkerTasks(TaskFinal)
+		UpdateWorkerTasksFinal(TaskFinal)
 
 		return
 	}
 
+	// --- Outputs of upstream tasks in and outputs of this task out
+	outputs, errout := NewTaskOutputs(msg, lockCheck)
+	if errout != nil {
+		log.Println("Task outputs:", errout)
+	}
+	defer outputs.Remove()
+
+	// --- Run parameters and the API trigger payload
+	var parametersEnv []string
+	if outputs != nil {
+		parametersEnv, errout = TaskParametersEnv(msg, pipelineCheck, outputs.Dir())
+		if errout != nil {
+			log.Println("Task parameters:", errout)
+		}
+	}
+
+	// --- The timeout covers all the commands of the task, 0 = no timeout
+	var deadline time.Time
+	if lockCheck.TimeoutSeconds > 0 {
+		deadline = TaskUpdate.StartDT.Add(time.Duration(lockCheck.TimeoutSeconds) * time.Second)
+	}
+
 	for _, v := range msg.Commands {
 		// Print the log timestamps
 		clog.PrintTimestamp = true
@@ -158,23 +232,35 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 			break
 		}
 
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			statusUpdate = "Fail"
+			exitCode = -1
+			TasksStatus.Set(msg.TaskID, "Timeout")
+			break
+		}
+
 		codeDirectory := wrkerconfig.CodeDirectory
 		directoryRun := codeDirectory + msg.Folder + "/"
 
 		var errfs error
 		switch wrkerconfig.FSCodeFileStorage {
-		case "Database":
-			// Database download
+		case "Database", "S3":
+			// Database download, files in object storage are downloaded from there
 			codeDirectory = wrkerconfig.FSCodeDirectory
 			directoryRun = codeDirectory + msg.Folder + "/"
 
 			// msg.RunType, msg.Version
+			_, downloadSpan := tracing.Tracer().Start(spanCtx, "task.download")
 			switch msg.RunType {
 			case "deployment":
 				errfs = distfilesystem.DistributedStorageDeploymentDownload(msg.EnvironmentID, msg.Folder+"/", msg.FolderID, msg.NodeID, msg.RunType, msg.Version)
 			default:
 				errfs = distfilesystem.DistributedStoragePipelineDownload(msg.EnvironmentID, msg.Folder+"/", msg.FolderID, msg.NodeID)
 			}
+			if errfs != nil {
+				tracing.SpanError(downloadSpan, errfs)
+			}
+			downloadSpan.End()
 
 		case "LocalFile":
 
@@ -191,6 +277,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 		if errfs != nil {
 			statusUpdate = "Fail"
+			exitCode = -1
 			if TasksStatusWG != "cancel" {
 				TasksStatus.Set(msg.RunID, "error")
 				// TasksStatus[msg.TaskID] = "error"
@@ -218,6 +305,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 			messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
 			database.DBConn.Create(&logmsg)
+			logsink.Write(logmsg)
 
 			break
 		}
@@ -257,6 +345,8 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 		}
 
 		// log.Println("command:", v)
+		commandCtx, commandSpan := tracing.Tracer().Start(spanCtx, "task.command")
+
 		var cmd *exec.Cmd
 		switch wrkerconfig.DPworkerCMD {
 		case "/bin/bash":
@@ -273,6 +363,9 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 		cmd.Env = append(cmd.Env, "DP_RUNID="+msg.RunID)
 		cmd.Env = append(cmd.Env, "DP_TASKID="+msg.TaskID)
 		cmd.Env = append(cmd.Env, "DP_ENVID="+msg.EnvironmentID)
+		cmd.Env = append(cmd.Env, "TRACEPARENT="+tracing.Inject(commandCtx))
+		cmd.Env = append(cmd.Env, outputs.Env()...)
+		cmd.Env = append(cmd.Env, parametersEnv...)
 
 		// Request the OS to assign process group to the new process, to which all its children will belong
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -299,6 +392,8 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 				uid := uuid.NewString()
 				line := wrkerconfig.Secrets.Replace(scanner.Text())
 
+				outputs.Marker(line)
+
 				logmsg := modelmain.LogsWorkers{
 					CreatedAt:     time.Now().UTC(),
 					UID:           uid,
@@ -324,6 +419,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 				messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
 				database.DBConn.Create(&logmsg)
+				logsink.Write(logmsg)
 				if wrkerconfig.Debug == "true" {
 					clog.Info(line)
 				}
@@ -377,6 +473,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 				messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
 				database.DBConn.Create(&logmsg)
+				logsink.Write(logmsg)
 				if wrkerconfig.Debug == "true" {
 					clog.Error(line)
 				}
@@ -407,6 +504,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 		err := cmd.Start()
 		if err != nil {
 			statusUpdate = "Fail"
+			exitCode = -1
 			if TasksStatusWG != "cancel" {
 				TasksStatus.Set(msg.TaskID, "error")
 				// TasksStatus[msg.TaskID] = "error"
@@ -440,15 +538,29 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 			messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
 			database.DBConn.Create(&logmsg)
+			logsink.Write(logmsg)
 			if wrkerconfig.Debug == "true" {
 				clog.Error(line)
 			}
 
+			tracing.SpanError(commandSpan, err)
+			commandSpan.End()
+
 			// Break the loop
 			break
 		}
 		task.PID = cmd.Process.Pid
 		Tasks.Set(msg.TaskID, task)
+
+		// Kill the whole process group if the task runs past its timeout
+		var timeoutTimer *time.Timer
+		if !deadline.IsZero() {
+			pgid := cmd.Process.Pid
+			timeoutTimer = time.AfterFunc(time.Until(deadline), func() {
+				TasksStatus.Set(msg.TaskID, "Timeout")
+				_ = syscall.Kill(-pgid, syscall.SIGKILL)
+			})
+		}
 		// Tasks[msg.TaskID] = task
 
 		if wrkerconfig.Debug == "true" {
@@ -464,13 +576,26 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 		// Wait for the command to finish
 		err = cmd.Wait()
 
+		if timeoutTimer != nil {
+			timeoutTimer.Stop()
+		}
+
+		if err != nil {
+			tracing.SpanError(commandSpan, err)
+		}
+		commandSpan.End()
+
 		if tmp, ok := TasksStatus.Get(msg.TaskID); ok {
 			TasksStatusWG = tmp.(string)
 		}
 
-		if err != nil {
+		if err != nil || TasksStatusWG == "Timeout" {
 			statusUpdate = "Fail"
-			if TasksStatusWG != "cancel" {
+			exitCode = -1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				exitCode = exitErr.ExitCode()
+			}
+			if TasksStatusWG != "cancel" && TasksStatusWG != "Timeout" {
 				TasksStatus.Set(msg.TaskID, "error")
 				// TasksStatus[msg.TaskID] = "error"
 			}
@@ -497,21 +622,89 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 		TasksStatusWG = tmp.(string)
 	}
 
+	if TasksStatusWG == "Timeout" {
+		timeoutLog := fmt.Sprintf("Timeout: task killed after %d seconds", lockCheck.TimeoutSeconds)
+		logmsg := modelmain.LogsWorkers{
+			CreatedAt:     time.Now().UTC(),
+			UID:           uuid.NewString(),
+			EnvironmentID: msg.EnvironmentID,
+			RunID:         msg.RunID,
+			NodeID:        msg.NodeID,
+			TaskID:        msg.TaskID,
+			Category:      "task",
+			Log:           timeoutLog,
+			LogType:       "error",
+		}
+
+		sendmsg := modelmain.LogsSend{
+			CreatedAt: logmsg.CreatedAt,
+			UID:       logmsg.UID,
+			Log:       timeoutLog,
+			LogType:   "error",
+		}
+
+		messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
+		database.DBConn.Create(&logmsg)
+		logsink.Write(logmsg)
+	}
+
+	// Outputs are kept for failed tasks too so that failure branches can use them
+	for _, outputLog := range outputs.Save(msg) {
+		logmsg := modelmain.LogsWorkers{
+			CreatedAt:     time.Now().UTC(),
+			UID:           uuid.NewString(),
+			EnvironmentID: msg.EnvironmentID,
+			RunID:         msg.RunID,
+			NodeID:        msg.NodeID,
+			TaskID:        msg.TaskID,
+			Category:      "task",
+			Log:           outputLog,
+			LogType:       "error",
+		}
+
+		sendmsg := modelmain.LogsSend{
+			CreatedAt: logmsg.CreatedAt,
+			UID:       logmsg.UID,
+			Log:       outputLog,
+			LogType:   "error",
+		}
+
+		messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
+		database.DBConn.Create(&logmsg)
+		logsink.Write(logmsg)
+	}
+
 	TaskFinal := modelmain.WorkerTasks{
-		TaskID:        msg.TaskID,
-		CreatedAt:     TaskUpdate.CreatedAt,
-		EnvironmentID: wrkerconfig.EnvID,
-		RunID:         msg.RunID,
-		WorkerGroup:   TaskUpdate.WorkerGroup,
-		WorkerID:      wrkerconfig.WorkerID,
-		NodeID:        msg.NodeID,
-		PipelineID:    msg.PipelineID,
-		StartDT:       TaskUpdate.StartDT,
-		Status:        statusUpdate,
-		Reason:        TasksStatusWG,
-		EndDT:         time.Now().UTC(),
+		TaskID:         msg.TaskID,
+		CreatedAt:      TaskUpdate.CreatedAt,
+		EnvironmentID:  wrkerconfig.EnvID,
+		RunID:          msg.RunID,
+		WorkerGroup:    TaskUpdate.WorkerGroup,
+		WorkerID:       wrkerconfig.WorkerID,
+		NodeID:         msg.NodeID,
+		PipelineID:     msg.PipelineID,
+		StartDT:        TaskUpdate.StartDT,
+		Status:         statusUpdate,
+		Reason:         TasksStatusWG,
+		EndDT:          time.Now().UTC(),
+		Attempt:        lockCheck.Attempt,
+		ExitCode:       exitCode,
+		RetryPolicy:    lockCheck.RetryPolicy,
+		TimeoutSeconds: lockCheck.TimeoutSeconds,
+	}
+
+	// The task watchdog can have failed the task already, run next was sent for it then
+	finalUpdated := UpdateWorkerTasksFinal(TaskFinal)
+	if !finalUpdated {
+		log.Println("Task no longer running, final status not recorded - runid:", msg.RunID, "node:", msg.NodeID)
+	}
+
+	span.SetAttributes(attribute.String("dataplane.status", statusUpdate))
+	if statusUpdate == "Fail" {
+		span.SetStatus(codes.Error, "Task failed")
 	}
-	UpdateWorkerTasks(TaskFinal)
+
+	metrics.TaskSeconds.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup, statusUpdate).Observe(TaskFinal.EndDT.Sub(TaskUpdate.StartDT).Seconds())
 
 	if wrkerconfig.Debug == "true" {
 		// log.Println("tasks delete:", msg.TaskID)
@@ -541,6 +734,7 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 
 	messageq.MsgSend("workerlogs."+msg.EnvironmentID+"."+msg.RunID+"."+msg.NodeID, sendmsg)
 	database.DBConn.Create(&logmsg)
+	logsink.Write(logmsg)
 
 	// Queue the next set of tasks
 	RunNext := modelmain.WorkerPipelineNext{
@@ -553,13 +747,15 @@ func worker(ctx context.Context, msg modelmain.WorkerTaskSend) {
 		Status:        statusUpdate,
 	}
 
-	errnat := messageq.MsgSend("pipeline-run-next", RunNext)
-	if errnat != nil {
-		WSLogError("Failed nats to send to next run runid: "+msg.RunID+" - node:"+msg.NodeID, msg)
-		if wrkerconfig.Debug == "true" {
-			log.Println(errnat)
-		}
+	if finalUpdated {
+		errnat := messageq.MsgSend("pipeline-run-next", RunNext)
+		if errnat != nil {
+			WSLogError("Failed nats to send to next run runid: "+msg.RunID+" - node:"+msg.NodeID, msg)
+			if wrkerconfig.Debug == "true" {
+				log.Println(errnat)
+			}
 
+		}
 	}
 
 	// delete(TasksStatus, msg.TaskID)
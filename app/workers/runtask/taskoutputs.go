@@ -0,0 +1,226 @@
+package runtask
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dataplane-app/dataplane/app/mainapp/database"
+	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
+	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
+
+	"gorm.io/datatypes"
+)
+
+/*
+TaskOutputs collects the outputs of a running task and passes the outputs of upstream tasks in:
+DP_INPUTS_FILE - JSON file with the outputs of all previous tasks in the run by node id.
+DP_INPUT_<KEY> - outputs of the direct upstream tasks.
+DP_OUTPUTS_FILE - JSON object the task can write its outputs to.
+*/
+type TaskOutputs struct {
+	dir     string
+	env     []string
+	mu      sync.Mutex
+	markers map[string]datatypes.JSON
+}
+
+/*
+NewTaskOutputs prepares the inputs and outputs files of a task.
+*/
+func NewTaskOutputs(msg modelmain.WorkerTaskSend, task modelmain.WorkerTasks) (*TaskOutputs, error) {
+
+	dir, err := os.MkdirTemp("", "dp-task-"+msg.TaskID+"-")
+	if err != nil {
+		return nil, err
+	}
+
+	o := &TaskOutputs{
+		dir:     dir,
+		markers: make(map[string]datatypes.JSON),
+	}
+
+	var rows []modelmain.WorkerTaskOutputs
+	err = database.DBConn.Where("run_id = ?", msg.RunID).Order("node_id, output_key").Find(&rows).Error
+	if err != nil {
+		return o, err
+	}
+
+	inputs := make(map[string]map[string]json.RawMessage)
+	for _, r := range rows {
+		if inputs[r.NodeID] == nil {
+			inputs[r.NodeID] = make(map[string]json.RawMessage)
+		}
+		inputs[r.NodeID][r.OutputKey] = json.RawMessage(r.Value)
+	}
+
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return o, err
+	}
+
+	inputsFile := filepath.Join(dir, "inputs.json")
+	err = os.WriteFile(inputsFile, inputsJSON, 0600)
+	if err != nil {
+		return o, err
+	}
+
+	o.env = append(o.env, "DP_INPUTS_FILE="+inputsFile)
+	o.env = append(o.env, "DP_OUTPUTS_FILE="+o.outputsFile())
+
+	// Direct upstream outputs as environment variables, sorted so that clashing keys are predictable
+	var dependencies []string
+	json.Unmarshal(task.Dependency, &dependencies)
+	sort.Strings(dependencies)
+
+	for _, d := range dependencies {
+		keys := make([]string, 0, len(inputs[d]))
+		for k := range inputs[d] {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			o.env = append(o.env, OutputEnvName(k)+"="+OutputEnvValue(datatypes.JSON(inputs[d][k])))
+		}
+	}
+
+	return o, nil
+}
+
+/*
+Dir is the temporary directory of the task for files passed to the task.
+*/
+func (o *TaskOutputs) Dir() string {
+	return o.dir
+}
+
+func (o *TaskOutputs) outputsFile() string {
+	return filepath.Join(o.dir, "outputs.json")
+}
+
+/*
+Env is the environment to add to each command of the task.
+*/
+func (o *TaskOutputs) Env() []string {
+	if o == nil {
+		return nil
+	}
+	return o.env
+}
+
+/*
+Marker records an output printed by the task as ::output::key=value
+*/
+func (o *TaskOutputs) Marker(line string) {
+	if o == nil {
+		return
+	}
+
+	key, value, ok := ParseOutputLine(line)
+	if !ok {
+		return
+	}
+
+	o.mu.Lock()
+	o.markers[key] = value
+	o.mu.Unlock()
+}
+
+/*
+Save stores the outputs of the task, values in the outputs file win over printed markers.
+Returns messages for the task log about outputs that were not stored.
+*/
+func (o *TaskOutputs) Save(msg modelmain.WorkerTaskSend) []string {
+
+	if o == nil {
+		return nil
+	}
+
+	var messages []string
+
+	o.mu.Lock()
+	outputs := make(map[string]datatypes.JSON, len(o.markers))
+	for k, v := range o.markers {
+		outputs[k] = v
+	}
+	o.mu.Unlock()
+
+	data, err := os.ReadFile(o.outputsFile())
+	if err == nil {
+		fileOutputs, err := ParseOutputsFile(data)
+		if err != nil {
+			messages = append(messages, "Outputs file: "+err.Error())
+		}
+		for k, v := range fileOutputs {
+			outputs[k] = v
+		}
+	}
+
+	keys := make([]string, 0, len(outputs))
+	for k := range outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := []modelmain.WorkerTaskOutputs{}
+	for _, k := range keys {
+
+		if len(rows) >= OutputMaxKeys {
+			messages = append(messages, fmt.Sprintf("Output skipped: %s - more than %d outputs", k, OutputMaxKeys))
+			continue
+		}
+
+		value := datatypes.JSON(wrkerconfig.Secrets.Replace(string(outputs[k])))
+		if !json.Valid(value) {
+			value = OutputValue(string(value))
+		}
+
+		if len(value) > OutputMaxValueBytes {
+			messages = append(messages, fmt.Sprintf("Output skipped: %s - larger than %d bytes", k, OutputMaxValueBytes))
+			continue
+		}
+
+		rows = append(rows, modelmain.WorkerTaskOutputs{
+			RunID:         msg.RunID,
+			NodeID:        msg.NodeID,
+			OutputKey:     k,
+			TaskID:        msg.TaskID,
+			EnvironmentID: msg.EnvironmentID,
+			PipelineID:    msg.PipelineID,
+			Value:         value,
+			CreatedAt:     time.Now().UTC(),
+		})
+	}
+
+	// Outputs of a previous attempt are replaced
+	err = database.DBConn.Where("run_id = ? and node_id = ?", msg.RunID, msg.NodeID).Delete(&modelmain.WorkerTaskOutputs{}).Error
+	if err != nil {
+		log.Println("Outputs delete:", err)
+	}
+
+	if len(rows) > 0 {
+		err = database.DBConn.Create(&rows).Error
+		if err != nil {
+			log.Println("Outputs save:", err)
+			messages = append(messages, "Outputs not saved: "+wrkerconfig.Secrets.Replace(err.Error()))
+		}
+	}
+
+	return messages
+}
+
+/*
+Remove deletes the inputs and outputs files.
+*/
+func (o *TaskOutputs) Remove() {
+	if o == nil {
+		return
+	}
+	os.RemoveAll(o.dir)
+}
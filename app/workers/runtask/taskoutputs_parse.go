@@ -0,0 +1,127 @@
+package runtask
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+
+	"gorm.io/datatypes"
+)
+
+/*
+Tasks pass outputs to downstream tasks in the same run either by printing a marker line:
+
+	::output::rows=120
+
+or by writing a JSON object to the file in DP_OUTPUTS_FILE:
+
+	{"rows": 120, "table": "sales"}
+*/
+const OutputMarker = "::output::"
+
+// Limits on what a single task can store
+const OutputMaxKeys = 100
+const OutputMaxValueBytes = 64 * 1024
+
+var outputKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]{0,127}$`)
+
+/*
+OutputKeyValid checks an output key: letters, numbers, _ . - and no longer than 128 characters.
+*/
+func OutputKeyValid(key string) bool {
+	return outputKeyRegex.MatchString(key)
+}
+
+/*
+OutputValue keeps a value as JSON if it is valid JSON, otherwise stores it as a JSON string.
+*/
+func OutputValue(value string) datatypes.JSON {
+
+	value = strings.TrimSpace(value)
+
+	if value != "" && json.Valid([]byte(value)) {
+		return datatypes.JSON(value)
+	}
+
+	quoted, _ := json.Marshal(value)
+	return datatypes.JSON(quoted)
+}
+
+/*
+ParseOutputLine reads an output from a marker line printed by a task.
+*/
+func ParseOutputLine(line string) (string, datatypes.JSON, bool) {
+
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, OutputMarker) {
+		return "", nil, false
+	}
+
+	keyvalue := strings.SplitN(strings.TrimPrefix(line, OutputMarker), "=", 2)
+	if len(keyvalue) != 2 {
+		return "", nil, false
+	}
+
+	key := strings.TrimSpace(keyvalue[0])
+	if !OutputKeyValid(key) {
+		return "", nil, false
+	}
+
+	return key, OutputValue(keyvalue[1]), true
+}
+
+/*
+ParseOutputsFile reads the JSON object a task writes to DP_OUTPUTS_FILE.
+*/
+func ParseOutputsFile(data []byte) (map[string]datatypes.JSON, error) {
+
+	outputs := make(map[string]datatypes.JSON)
+
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return outputs, nil
+	}
+
+	raw := make(map[string]json.RawMessage)
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return outputs, errors.New("Outputs file must be a JSON object: " + err.Error())
+	}
+
+	for k, v := range raw {
+		if !OutputKeyValid(k) {
+			return outputs, errors.New("Output key not allowed: " + k)
+		}
+		outputs[k] = datatypes.JSON(v)
+	}
+
+	return outputs, nil
+}
+
+/*
+OutputEnvName is the environment variable an output is passed to downstream tasks as e.g. rows -> DP_INPUT_ROWS
+*/
+func OutputEnvName(key string) string {
+
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, key)
+
+	return "DP_INPUT_" + strings.ToUpper(name)
+}
+
+/*
+OutputEnvValue is the text of an output for environment variables, JSON strings are unquoted.
+*/
+func OutputEnvValue(value datatypes.JSON) string {
+
+	var s string
+	if json.Unmarshal(value, &s) == nil {
+		return s
+	}
+
+	return string(value)
+}
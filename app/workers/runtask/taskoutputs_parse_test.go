@@ -0,0 +1,52 @@
+package runtask
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/datatypes"
+)
+
+/*
+go test -timeout 30s -v -run ^TestTaskOutputs$ github.com/dataplane-app/dataplane/app/workers/runtask
+*/
+func TestTaskOutputs(t *testing.T) {
+
+	// Marker lines
+	key, value, ok := ParseOutputLine("::output::rows=120")
+	assert.Equalf(t, true, ok, "Marker line")
+	assert.Equalf(t, "rows", key, "Marker key")
+	assert.Equalf(t, `120`, string(value), "Marker number value")
+
+	_, value, _ = ParseOutputLine("  ::output::table = sales table ")
+	assert.Equalf(t, `"sales table"`, string(value), "Marker string value")
+
+	_, value, _ = ParseOutputLine(`::output::meta={"a":1}`)
+	assert.Equalf(t, `{"a":1}`, string(value), "Marker JSON value")
+
+	_, _, ok = ParseOutputLine("rows=120")
+	assert.Equalf(t, false, ok, "No marker")
+
+	_, _, ok = ParseOutputLine("::output::1rows=120")
+	assert.Equalf(t, false, ok, "Invalid key")
+
+	// Outputs file
+	outputs, err := ParseOutputsFile([]byte(`{"rows": 120, "table": "sales"}`))
+	assert.NoError(t, err, "Outputs file")
+	assert.Equalf(t, `120`, string(outputs["rows"]), "Outputs file number")
+	assert.Equalf(t, `"sales"`, string(outputs["table"]), "Outputs file string")
+
+	outputs, err = ParseOutputsFile([]byte(""))
+	assert.NoError(t, err, "Empty outputs file")
+	assert.Equalf(t, 0, len(outputs), "Empty outputs file")
+
+	_, err = ParseOutputsFile([]byte(`[1, 2]`))
+	assert.Error(t, err, "Outputs file not an object")
+
+	// Environment variables
+	assert.Equalf(t, "DP_INPUT_ROWS", OutputEnvName("rows"), "Env name")
+	assert.Equalf(t, "DP_INPUT_SALES_TABLE_V2", OutputEnvName("sales.table-v2"), "Env name characters")
+	assert.Equalf(t, "sales", OutputEnvValue(datatypes.JSON(`"sales"`)), "Env string value")
+	assert.Equalf(t, `{"a":1}`, OutputEnvValue(datatypes.JSON(`{"a":1}`)), "Env JSON value")
+
+}
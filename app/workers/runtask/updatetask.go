@@ -2,9 +2,7 @@ package runtask
 
 import (
 	"log"
-	"time"
 
-	"github.com/dataplane-app/dataplane/app/mainapp/database/models"
 	modelmain "github.com/dataplane-app/dataplane/app/mainapp/database/models"
 
 	"github.com/dataplane-app/dataplane/app/mainapp/database"
@@ -23,35 +21,45 @@ func UpdateWorkerTasks(msg modelmain.WorkerTasks) {
 
 	err2 := database.DBConn.Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "task_id"}},
-		DoUpdates: clause.AssignmentColumns([]string{"start_dt", "end_dt", "status", "reason", "worker_id", "worker_group"}),
+		DoUpdates: clause.AssignmentColumns([]string{"start_dt", "end_dt", "status", "reason", "worker_id", "worker_group", "exit_code"}),
 	}).Create(&msg)
 	if err2.Error != nil {
 		log.Println(err2.Error.Error())
 	}
 
-	if msg.Status == "Fail" {
+	/* Failed tasks are passed to run next on the main app, which retries the task
+	or follows the failure along the edge conditions of the graph. */
 
-		// Update pipeline as failed
-		run := models.PipelineRuns{
-			RunID:   msg.RunID,
-			Status:  "Fail",
-			EndedAt: time.Now().UTC(),
+	errnat := messageq.MsgSend("taskupdate."+msg.EnvironmentID+"."+msg.RunID, msg)
+	if errnat != nil {
+		if wrkerconfig.Debug == "true" {
+			log.Println(errnat)
 		}
 
-		err2 := database.DBConn.Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "run_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"ended_at", "status"}),
-		}).Create(&run)
-		if err2.Error != nil {
-			log.Println(err2.Error.Error())
-		}
+	}
 
-		// Update all the future tasks
-		err3 := database.DBConn.Model(&modelmain.WorkerTasks{}).Where("run_id = ? and status=?", msg.RunID, "Queue").Updates(map[string]interface{}{"status": "Fail", "reason": "Upstream fail"}).Error
-		if err3 != nil {
-			log.Println(err3.Error())
-		}
+	// }()
+
+}
+
+/*
+UpdateWorkerTasksFinal records the end of a task only while it is still running. A task failed on the main app
+by the task watchdog and since retried is not overwritten. Returns false when the task was no longer running.
+*/
+func UpdateWorkerTasksFinal(msg modelmain.WorkerTasks) bool {
 
+	result := database.DBConn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"start_dt", "end_dt", "status", "reason", "worker_id", "worker_group", "exit_code"}),
+		Where:     clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: "worker_tasks", Name: "status"}, Value: "Run"}}},
+	}).Create(&msg)
+	if result.Error != nil {
+		log.Println(result.Error.Error())
+		return false
+	}
+
+	if result.RowsAffected == 0 {
+		return false
 	}
 
 	errnat := messageq.MsgSend("taskupdate."+msg.EnvironmentID+"."+msg.RunID, msg)
@@ -59,9 +67,7 @@ func UpdateWorkerTasks(msg modelmain.WorkerTasks) {
 		if wrkerconfig.Debug == "true" {
 			log.Println(errnat)
 		}
-
 	}
 
-	// }()
-
+	return true
 }
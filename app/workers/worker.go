@@ -3,7 +3,10 @@ package main
 import (
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/dataplane-app/dataplane/app/workers/logsink"
 	"github.com/dataplane-app/dataplane/app/workers/routes"
 )
 
@@ -16,5 +19,14 @@ func main() {
 
 	app := routes.Setup(port)
 
+	// Flush the log sinks before the worker stops
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+		logsink.Close()
+		os.Exit(0)
+	}()
+
 	log.Fatal(app.Listen("0.0.0.0:" + port))
 }
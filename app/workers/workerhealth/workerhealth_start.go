@@ -13,6 +13,7 @@ import (
 	wrkerconfig "github.com/dataplane-app/dataplane/app/workers/config"
 	"github.com/dataplane-app/dataplane/app/workers/logging"
 	"github.com/dataplane-app/dataplane/app/workers/messageq"
+	"github.com/dataplane-app/dataplane/app/workers/metrics"
 
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/load"
@@ -118,6 +119,11 @@ func WorkerHealthStart() {
 
 				// log.Printf("cpu perc:%v | mem percent:%v | mem used :%v | load:%v \n", percentCPUsend, percentMemorysend, memoryused, loadsend)
 
+				metrics.CPUPercent.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup).Set(percentCPUsend)
+				metrics.MemoryPercent.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup).Set(percentMemorysend)
+				metrics.MemoryUsedBytes.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup).Set(memoryused)
+				metrics.Load.WithLabelValues(wrkerconfig.EnvID, wrkerconfig.WorkerGroup).Set(loadsend)
+
 				workerdata := &models.WorkerStats{
 					WorkerGroup: wrkerconfig.WorkerGroup,
 					WorkerID:    wrkerconfig.WorkerID,